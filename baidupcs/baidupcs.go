@@ -149,6 +149,13 @@ type (
 		fixPCSAddr  bool
 		ph          *panhome.PanHome
 		cacheOpMap  cachemap.CacheOpMap
+
+		batchSize       int                     // 批量操作单次请求的最大路径数量
+		batchParallel   int                     // 批量操作分片的最大并发量
+		listPageSize    int                     // 分页获取目录列表时每页的条目数量
+		recurseParallel int                     // 递归获取目录列表时同时进行的请求数量
+		metaCache       *MetaCache              // 持久化的目录列表和元信息缓存
+		apiLimiters     [numAPIClass]apiLimiter // 各接口类别的限速器
 	}
 
 	userInfoJSON struct {
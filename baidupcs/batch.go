@@ -0,0 +1,238 @@
+package baidupcs
+
+import (
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/qjfoidnh/BaiduPCS-Go/baidupcs/pcserror"
+	"github.com/qjfoidnh/BaiduPCS-Go/pcstable"
+	"github.com/qjfoidnh/BaiduPCS-Go/pcsutil/waitgroup"
+)
+
+const (
+	// DefaultBatchSize 批量操作单次请求的默认路径数量
+	DefaultBatchSize = 100
+	// DefaultBatchParallel 批量操作分片的默认并发量
+	DefaultBatchParallel = 4
+)
+
+type (
+	// BatchOpResult 批量操作中单个路径的执行结果
+	BatchOpResult struct {
+		Path     string         // 源路径
+		To       string         // 目标路径, 只对拷贝/移动有效
+		PCSError pcserror.Error // 错误信息, 为空则代表执行成功
+	}
+
+	// BatchOpResultList 批量操作的结果列表, 顺序与输入一致
+	BatchOpResultList []*BatchOpResult
+
+	// batchItemInfo 批量操作出错时, 服务器返回的单个路径的执行结果
+	batchItemInfo struct {
+		Errno int    `json:"errno"`
+		Path  string `json:"path"`
+	}
+
+	// batchErrJSON 批量操作的错误响应, 部分接口会在 info 中给出每个路径的执行结果
+	batchErrJSON struct {
+		*pcserror.PCSErrInfo
+		Info []*batchItemInfo `json:"info"`
+	}
+)
+
+// SetBatchSize 设置批量操作单次请求的最大路径数量
+func (pcs *BaiduPCS) SetBatchSize(size int) {
+	pcs.batchSize = size
+}
+
+// SetBatchParallel 设置批量操作分片的最大并发量
+func (pcs *BaiduPCS) SetBatchParallel(parallel int) {
+	pcs.batchParallel = parallel
+}
+
+func (pcs *BaiduPCS) getBatchSize() int {
+	if pcs.batchSize < 1 {
+		return DefaultBatchSize
+	}
+	return pcs.batchSize
+}
+
+func (pcs *BaiduPCS) getBatchParallel() int {
+	if pcs.batchParallel < 1 {
+		return DefaultBatchParallel
+	}
+	return pcs.batchParallel
+}
+
+// runBatch 将 n 个元素按批量大小分片, 并发执行 handleChunk
+func (pcs *BaiduPCS) runBatch(n int, handleChunk func(start, end int)) {
+	size := pcs.getBatchSize()
+	if n <= size {
+		if n > 0 {
+			handleChunk(0, n)
+		}
+		return
+	}
+
+	wg := waitgroup.NewWaitGroup(pcs.getBatchParallel())
+	for start := 0; start < n; start += size {
+		end := start + size
+		if end > n {
+			end = n
+		}
+
+		wg.AddDelta()
+		go func(start, end int) {
+			defer wg.Done()
+			handleChunk(start, end)
+		}(start, end)
+	}
+	wg.Wait()
+}
+
+// decodeBatchError 解析批量操作的响应, 出错时一并返回每个路径的执行结果 (如果服务器给出)
+func decodeBatchError(op string, data io.Reader) (info []*batchItemInfo, pcsError pcserror.Error) {
+	jsonData := batchErrJSON{
+		PCSErrInfo: pcserror.NewPCSErrorInfo(op),
+	}
+	if pcserror.HandleJSONParse(op, data, &jsonData) == nil {
+		return nil, nil
+	}
+	return jsonData.Info, jsonData.PCSErrInfo
+}
+
+// applyInfo 根据服务器返回的每个路径的执行结果设置错误, 结果不完整时返回 false
+func (brl BatchOpResultList) applyInfo(op string, info []*batchItemInfo) bool {
+	if len(info) == 0 {
+		return false
+	}
+
+	errnos := make(map[string]int, len(info))
+	for _, item := range info {
+		if item != nil {
+			errnos[item.Path] = item.Errno
+		}
+	}
+	for _, r := range brl {
+		if _, ok := errnos[r.Path]; !ok {
+			return false
+		}
+	}
+
+	for _, r := range brl {
+		errno := errnos[r.Path]
+		if errno == 0 {
+			r.PCSError = nil
+			continue
+		}
+		r.PCSError = &pcserror.PCSErrInfo{
+			Operation: op,
+			ErrType:   pcserror.ErrTypeRemoteError,
+			ErrCode:   errno,
+		}
+	}
+	return true
+}
+
+// isSplittableBatchError 分片整体失败时, 是否需要拆分为单个路径重新执行, 以确定具体出错的路径
+func isSplittableBatchError(pcsError pcserror.Error) bool {
+	return pcsError.GetErrType() == pcserror.ErrTypeRemoteError
+}
+
+// Err 返回第一个错误, 全部成功则返回空
+func (brl BatchOpResultList) Err() pcserror.Error {
+	for _, r := range brl {
+		if r != nil && r.PCSError != nil {
+			return r.PCSError
+		}
+	}
+	return nil
+}
+
+// Succeeded 返回执行成功的结果
+func (brl BatchOpResultList) Succeeded() (succeeded BatchOpResultList) {
+	for _, r := range brl {
+		if r != nil && r.PCSError == nil {
+			succeeded = append(succeeded, r)
+		}
+	}
+	return
+}
+
+// Failed 返回执行失败的结果
+func (brl BatchOpResultList) Failed() (failed BatchOpResultList) {
+	for _, r := range brl {
+		if r != nil && r.PCSError != nil {
+			failed = append(failed, r)
+		}
+	}
+	return
+}
+
+func (brl BatchOpResultList) paths() []string {
+	paths := make([]string, len(brl))
+	for k := range brl {
+		paths[k] = brl[k].Path
+	}
+	return paths
+}
+
+func (brl BatchOpResultList) setError(pcsError pcserror.Error) {
+	for _, r := range brl {
+		r.PCSError = pcsError
+	}
+}
+
+// allRelatedPaths 获取执行成功的原路径和目标路径
+func (brl BatchOpResultList) allRelatedPaths() (paths []string) {
+	paths = make([]string, 0, len(brl)*2)
+	for _, r := range brl.Succeeded() {
+		paths = append(paths, r.Path)
+		if r.To != "" {
+			paths = append(paths, r.To)
+		}
+	}
+	return paths
+}
+
+func (brl BatchOpResultList) String() string {
+	var (
+		builder = &strings.Builder{}
+		tb      = pcstable.NewTable(builder)
+		hasTo   bool
+	)
+
+	for _, r := range brl {
+		if r != nil && r.To != "" {
+			hasTo = true
+			break
+		}
+	}
+
+	if hasTo {
+		tb.SetHeader([]string{"#", "原路径", "目标路径", "结果"})
+	} else {
+		tb.SetHeader([]string{"#", "文件/目录", "结果"})
+	}
+
+	for k, r := range brl {
+		if r == nil {
+			continue
+		}
+
+		status := "成功"
+		if r.PCSError != nil {
+			status = r.PCSError.Error()
+		}
+
+		if hasTo {
+			tb.Append([]string{strconv.Itoa(k), r.Path, r.To, status})
+		} else {
+			tb.Append([]string{strconv.Itoa(k), r.Path, status})
+		}
+	}
+
+	tb.Render()
+	return builder.String()
+}
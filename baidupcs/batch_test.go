@@ -0,0 +1,128 @@
+package baidupcs_test
+
+import (
+	"testing"
+
+	"github.com/qjfoidnh/BaiduPCS-Go/baidupcs"
+	"github.com/qjfoidnh/BaiduPCS-Go/baidupcs/pcserror"
+)
+
+func TestBatchRemoveChunks(t *testing.T) {
+	fp, pcs := newFakePCS(t, "/a", "/b", "/c", "/d", "/e")
+	pcs.SetBatchSize(2)
+
+	paths := []string{"/a", "/b", "/c", "/d", "/e"}
+	results := pcs.BatchRemove(paths...)
+	if err := results.Err(); err != nil {
+		t.Fatal(err)
+	}
+	for k, r := range results {
+		if r.Path != paths[k] {
+			t.Errorf("result %d: %s, want %s", k, r.Path, paths[k])
+		}
+		if fp.exists(r.Path) {
+			t.Errorf("%s not removed", r.Path)
+		}
+	}
+
+	// 5 个路径分为 3 片, 每片不超过 2 个路径
+	if n := fp.countRequests("delete"); n != 3 {
+		t.Errorf("delete requests %d, want 3", n)
+	}
+	for _, req := range fp.requests {
+		if len(req.paths) > 2 {
+			t.Errorf("chunk %v exceeds batch size", req.paths)
+		}
+	}
+}
+
+func TestBatchRemovePartialFailure(t *testing.T) {
+	fp, pcs := newFakePCS(t, "/a", "/b", "/c")
+	pcs.SetBatchSize(3)
+
+	// 分片整体失败且没有 info, 逐个重新删除, 不存在的路径报告真实的错误
+	results := pcs.BatchRemove("/a", "/missing", "/c")
+	if results[0].PCSError != nil || results[2].PCSError != nil {
+		t.Errorf("results: %s", results)
+	}
+	if !pcserror.IsNotExist(results[1].PCSError) {
+		t.Errorf("missing path: %v", results[1].PCSError)
+	}
+	if fp.exists("/a") || fp.exists("/c") || !fp.exists("/b") {
+		t.Errorf("files after remove: %v", fp.files)
+	}
+	if n := fp.countRequests("delete"); n != 4 {
+		t.Errorf("delete requests %d, want 4", n)
+	}
+	if len(results.Succeeded()) != 2 || len(results.Failed()) != 1 {
+		t.Errorf("succeeded %d, failed %d", len(results.Succeeded()), len(results.Failed()))
+	}
+}
+
+func TestBatchRemoveInfo(t *testing.T) {
+	fp, pcs := newFakePCS(t, "/a", "/c")
+	fp.batchInfo = true
+
+	// 服务器给出每个路径的执行结果时, 以 info 为准, 不再逐个执行
+	results := pcs.BatchRemove("/a", "/missing", "/c")
+	if results[0].PCSError != nil || results[2].PCSError != nil {
+		t.Errorf("results: %s", results)
+	}
+	if err := results[1].PCSError; err == nil || err.GetRemoteErrCode() != -9 {
+		t.Errorf("missing path: %v", err)
+	}
+	if n := fp.countRequests("delete"); n != 1 {
+		t.Errorf("delete requests %d, want 1", n)
+	}
+}
+
+func TestBatchRemoveThrottled(t *testing.T) {
+	fp, pcs := newFakePCS(t, "/a", "/b")
+	fp.throttle = 1
+
+	// 被限流的分片在限速器退避后重试
+	if err := pcs.BatchRemove("/a", "/b").Err(); err != nil {
+		t.Fatal(err)
+	}
+	if n := fp.countRequests("delete"); n != 2 || fp.exists("/a") || fp.exists("/b") {
+		t.Errorf("delete requests %d", n)
+	}
+}
+
+func TestBatchCopyPartialFailure(t *testing.T) {
+	fp, pcs := newFakePCS(t, "/a", "/b", "/backup/b")
+
+	// 目标已存在的路径报告错误, 即使目标与源相同
+	results := pcs.BatchCopy(&baidupcs.CpMvJSON{From: "/a", To: "/backup/a"}, &baidupcs.CpMvJSON{From: "/b", To: "/backup/b"})
+	if results[0].PCSError != nil || results[0].To != "/backup/a" || !fp.exists("/backup/a") {
+		t.Errorf("copy /a: %v", results[0].PCSError)
+	}
+	if err := results[1].PCSError; err == nil || err.GetRemoteErrCode() != 31061 {
+		t.Errorf("copy /b: %v", err)
+	}
+}
+
+func TestFilesDirectoriesBatchMeta(t *testing.T) {
+	_, pcs := newFakePCS(t, "/a", "/b", "/c", "/d/")
+	pcs.SetBatchSize(2)
+
+	// 出错的分片对应的位置为 nil, 其余结果的位置不变
+	fds, err := pcs.FilesDirectoriesBatchMeta("/a", "/b", "/missing", "/c", "/d")
+	if !pcserror.IsNotExist(err) {
+		t.Errorf("err: %v", err)
+	}
+	if len(fds) != 5 {
+		t.Fatalf("got %d results", len(fds))
+	}
+	for k, want := range []string{"/a", "/b", "", "", "/d"} {
+		switch {
+		case want == "" && fds[k] != nil:
+			t.Errorf("result %d: %s, want nil", k, fds[k].Path)
+		case want != "" && (fds[k] == nil || fds[k].Path != want):
+			t.Errorf("result %d: %v, want %s", k, fds[k], want)
+		}
+	}
+	if !fds[4].Isdir || fds[0].Isdir {
+		t.Errorf("isdir: %v %v", fds[0].Isdir, fds[4].Isdir)
+	}
+}
@@ -1,26 +1,48 @@
 package baidupcs
 
 import (
+	"path"
+
 	"github.com/qjfoidnh/BaiduPCS-Go/baidupcs/expires"
 	"github.com/qjfoidnh/BaiduPCS-Go/baidupcs/pcserror"
 	"time"
 )
 
-// deleteCache 删除含有 dirs 的缓存
+var (
+	cacheOrderOptions = []*OrderOptions{
+		{By: OrderByName, Order: OrderAsc}, {By: OrderByName, Order: OrderDesc},
+		{By: OrderByTime, Order: OrderAsc}, {By: OrderByTime, Order: OrderDesc},
+		{By: OrderBySize, Order: OrderAsc}, {By: OrderBySize, Order: OrderDesc},
+	}
+)
+
+// deleteCache 删除含有 dirs 的内存缓存
 func (pcs *BaiduPCS) deleteCache(dirs []string) {
 	cache := pcs.cacheOpMap.LazyInitCachePoolOp(OperationFilesDirectoriesList)
 	for _, v := range dirs {
-		key := v + "_" + defaultOrderOptionsStr
-		_, ok := cache.Load(key)
-		if ok {
-			cache.Delete(key)
+		for _, options := range cacheOrderOptions {
+			cache.Delete(v + "_" + listCacheKey(options))
 		}
 	}
 }
 
-// CacheFilesDirectoriesList 缓存获取
+// deleteCacheTree 删除 paths 本身, 其子孙, 及其父目录的缓存,
+// 用于创建, 删除, 移动, 覆盖等会改变路径自身的操作
+func (pcs *BaiduPCS) deleteCacheTree(paths []string) {
+	dirs := make([]string, 0, len(paths))
+	for _, p := range paths {
+		dirs = append(dirs, path.Dir(p))
+	}
+	pcs.deleteCache(dirs)
+
+	if pcs.metaCache != nil {
+		pcs.metaCache.invalidateTree(paths)
+	}
+}
+
+// CacheFilesDirectoriesList 缓存获取, 只使用内存缓存
 func (pcs *BaiduPCS) CacheFilesDirectoriesList(path string, options *OrderOptions) (fdl FileDirectoryList, pcsError pcserror.Error) {
-	data := pcs.cacheOpMap.CacheOperation(OperationFilesDirectoriesList, path+"_"+string(options.By)+string(options.Order), func() expires.DataExpires {
+	data := pcs.cacheOpMap.CacheOperation(OperationFilesDirectoriesList, path+"_"+listCacheKey(options), func() expires.DataExpires {
 		fdl, pcsError = pcs.FilesDirectoriesList(path, options)
 		if pcsError != nil {
 			return nil
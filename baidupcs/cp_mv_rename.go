@@ -2,41 +2,92 @@ package baidupcs
 
 import (
 	"github.com/qjfoidnh/BaiduPCS-Go/baidupcs/pcserror"
-	"unsafe"
 )
 
 // Rename 重命名文件/目录
 func (pcs *BaiduPCS) Rename(from, to string) (pcsError pcserror.Error) {
-	return pcs.cpmvOp(OperationRename, &CpMvJSON{
-		From: from,
-		To:   to,
-	})
+	cpmvJSON := []*CpMvJSON{
+		&CpMvJSON{
+			From: from,
+			To:   to,
+		},
+	}
+	_, pcsError = pcs.cpmvOp(OperationRename, cpmvJSON...)
+	if pcsError != nil {
+		return
+	}
+
+	// 更新缓存
+	pcs.deleteCacheTree([]string{from, to})
+	return nil
 }
 
-// Copy 批量拷贝文件/目录
+// Copy 批量拷贝文件/目录, 返回第一个遇到的错误
 func (pcs *BaiduPCS) Copy(cpmvJSON ...*CpMvJSON) (pcsError pcserror.Error) {
-	return pcs.cpmvOp(OperationCopy, cpmvJSON...)
+	return pcs.BatchCopy(cpmvJSON...).Err()
 }
 
-// Move 批量移动文件/目录
+// Move 批量移动文件/目录, 返回第一个遇到的错误
 func (pcs *BaiduPCS) Move(cpmvJSON ...*CpMvJSON) (pcsError pcserror.Error) {
-	return pcs.cpmvOp(OperationMove, cpmvJSON...)
+	return pcs.BatchMove(cpmvJSON...).Err()
 }
 
-func (pcs *BaiduPCS) cpmvOp(op string, cpmvJSON ...*CpMvJSON) (pcsError pcserror.Error) {
-	dataReadCloser, err := pcs.prepareCpMvOp(op, cpmvJSON...)
-	if err != nil {
-		return
-	}
+// BatchCopy 批量拷贝文件/目录, 自动分片执行, 返回每个路径的执行结果
+func (pcs *BaiduPCS) BatchCopy(cpmvJSON ...*CpMvJSON) (results BatchOpResultList) {
+	return pcs.batchCpMvOp(OperationCopy, cpmvJSON...)
+}
 
-	defer dataReadCloser.Close()
+// BatchMove 批量移动文件/目录, 自动分片执行, 返回每个路径的执行结果
+func (pcs *BaiduPCS) BatchMove(cpmvJSON ...*CpMvJSON) (results BatchOpResultList) {
+	return pcs.batchCpMvOp(OperationMove, cpmvJSON...)
+}
 
-	errInfo := pcserror.DecodePCSJSONError(op, dataReadCloser)
-	if errInfo != nil {
-		return errInfo
+func (pcs *BaiduPCS) batchCpMvOp(op string, cpmvJSON ...*CpMvJSON) (results BatchOpResultList) {
+	results = make(BatchOpResultList, len(cpmvJSON))
+	for k := range cpmvJSON {
+		results[k] = &BatchOpResult{
+			Path: cpmvJSON[k].From,
+			To:   cpmvJSON[k].To,
+		}
 	}
 
+	pcs.runBatch(len(cpmvJSON), func(start, end int) {
+		chunk := results[start:end]
+		var info []*batchItemInfo
+		pcsError := retryThrottled(op, func() (pcsError pcserror.Error) {
+			info, pcsError = pcs.cpmvOp(op, cpmvJSON[start:end]...)
+			return
+		})
+		if pcsError == nil {
+			return
+		}
+
+		if len(chunk) == 1 || !isSplittableBatchError(pcsError) {
+			chunk.setError(pcsError)
+			return
+		}
+
+		if chunk.applyInfo(op, info) {
+			return
+		}
+
+		// 服务器未给出每个路径的执行结果, 逐个重新执行, 找出出错的路径
+		for k, r := range chunk {
+			_, r.PCSError = pcs.cpmvOp(op, cpmvJSON[start+k])
+		}
+	})
+
 	// 更新缓存
-	pcs.deleteCache((*CpMvJSONList)(unsafe.Pointer(&cpmvJSON)).AllRelatedDir())
-	return nil
+	pcs.deleteCacheTree(results.allRelatedPaths())
+	return results
+}
+
+func (pcs *BaiduPCS) cpmvOp(op string, cpmvJSON ...*CpMvJSON) (info []*batchItemInfo, pcsError pcserror.Error) {
+	dataReadCloser, pcsError := pcs.prepareCpMvOp(op, cpmvJSON...)
+	if pcsError != nil {
+		return
+	}
+
+	defer dataReadCloser.Close()
+	return decodeBatchError(op, dataReadCloser)
 }
@@ -0,0 +1,336 @@
+package baidupcs_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/qjfoidnh/BaiduPCS-Go/baidupcs"
+	"github.com/qjfoidnh/BaiduPCS-Go/requester"
+)
+
+type (
+	// fakeFile 模拟网盘中的文件或目录
+	fakeFile struct {
+		isdir bool
+		size  int64
+		md5   string
+	}
+
+	// fakeRequest 记录收到的请求
+	fakeRequest struct {
+		method string
+		paths  []string // 批量操作的路径, 目录列表为目录路径和分页参数
+	}
+
+	// fakePCS 模拟网盘的 PCS 文件接口, 文件保存在内存中
+	fakePCS struct {
+		t     *testing.T
+		mu    sync.Mutex
+		files map[string]*fakeFile
+
+		// batchInfo 批量操作失败时, 执行其余路径, 并在 info 中给出每个路径的执行结果,
+		// 否则整个请求不执行, 且不给出 info
+		batchInfo bool
+		throttle  int // 接下来的批量操作请求返回限流错误的次数
+		requests  []*fakeRequest
+
+		cursor  int      // filediff cursor
+		changes []string // 下次 filediff 返回的变更路径
+	}
+
+	fakeBatchItem struct {
+		Path string `json:"path"`
+		From string `json:"from"`
+		To   string `json:"to"`
+	}
+)
+
+// fakeErrCodes 单个路径的错误代码对应的 PCS 错误代码
+var fakeErrCodes = map[int]int{
+	-9: 31066, // 文件不存在
+	-8: 31061, // 文件已存在
+}
+
+// newFakePCS 启动模拟服务器, 返回通过代理将所有请求发送到模拟服务器的 BaiduPCS
+func newFakePCS(t *testing.T, paths ...string) (*fakePCS, *baidupcs.BaiduPCS) {
+	fp := &fakePCS{
+		t:     t,
+		files: map[string]*fakeFile{},
+	}
+	fp.add(paths...)
+
+	srv := httptest.NewServer(fp)
+	requester.SetGlobalProxy(srv.Listener.Addr().String())
+	t.Cleanup(func() {
+		requester.SetGlobalProxy("")
+		srv.Close()
+	})
+
+	pcs := baidupcs.NewPCS(0, "")
+	pcs.SetPanUserAgent(baidupcs.NetdiskUA)
+	pcs.GetClient()
+	pcs.SetHTTPS(false)
+	pcs.SetStaticPCSAddr(true)
+	pcs.SetBatchParallel(1)
+	return fp, pcs
+}
+
+// add 添加文件, 以 / 结尾的为目录, 自动创建上级目录
+func (fp *fakePCS) add(paths ...string) {
+	fp.mu.Lock()
+	defer fp.mu.Unlock()
+	for _, p := range paths {
+		isdir := strings.HasSuffix(p, "/")
+		p = path.Clean(p)
+		if isdir {
+			fp.files[p] = &fakeFile{isdir: true}
+		} else {
+			fp.files[p] = &fakeFile{size: int64(len(p)), md5: fmt.Sprintf("%032x", len(p))}
+		}
+		for dir := path.Dir(p); dir != "/"; dir = path.Dir(dir) {
+			fp.files[dir] = &fakeFile{isdir: true}
+		}
+	}
+}
+
+func (fp *fakePCS) exists(p string) bool {
+	fp.mu.Lock()
+	defer fp.mu.Unlock()
+	return fp.files[p] != nil
+}
+
+// touch 在服务器端添加或修改文件, 下次 filediff 时返回变更
+func (fp *fakePCS) touch(paths ...string) {
+	fp.add(paths...)
+	fp.mu.Lock()
+	defer fp.mu.Unlock()
+	for _, p := range paths {
+		fp.changes = append(fp.changes, path.Clean(p))
+	}
+}
+
+// countRequests 返回 method 的请求数量
+func (fp *fakePCS) countRequests(method string) (n int) {
+	fp.mu.Lock()
+	defer fp.mu.Unlock()
+	for _, req := range fp.requests {
+		if req.method == method {
+			n++
+		}
+	}
+	return
+}
+
+func (fp *fakePCS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	fp.mu.Lock()
+	defer fp.mu.Unlock()
+
+	if r.URL.Path == "/api/batch/filediff" {
+		fp.requests = append(fp.requests, &fakeRequest{method: "filediff", paths: []string{r.URL.Query().Get("cursor")}})
+		fp.filediff(w)
+		return
+	}
+	if r.URL.Path != "/rest/2.0/pcs/file" {
+		fp.t.Errorf("unexpected request: %s %s", r.Method, r.URL)
+		http.NotFound(w, r)
+		return
+	}
+
+	var (
+		method = r.URL.Query().Get("method")
+		items  []*fakeBatchItem
+	)
+	if param := r.FormValue("param"); param != "" {
+		var list struct {
+			List []*fakeBatchItem `json:"list"`
+		}
+		if err := json.Unmarshal([]byte(param), &list); err != nil {
+			fp.t.Errorf("param %s: %s", param, err)
+		}
+		items = list.List
+	}
+
+	req := &fakeRequest{method: method}
+	for _, item := range items {
+		req.paths = append(req.paths, item.Path+item.From)
+	}
+	fp.requests = append(fp.requests, req)
+
+	switch method {
+	case "meta":
+		fp.meta(w, items)
+	case "list":
+		req.paths = []string{r.URL.Query().Get("path"), r.URL.Query().Get("limit")}
+		fp.list(w, r.URL.Query().Get("path"), r.URL.Query().Get("limit"))
+	case "delete", "copy", "move":
+		fp.batch(w, method, items)
+	default:
+		fp.t.Errorf("unexpected method: %s", method)
+		http.NotFound(w, r)
+	}
+}
+
+func (fp *fakePCS) fileJSON(p string) map[string]interface{} {
+	f := fp.files[p]
+	isdir := 0
+	if f.isdir {
+		isdir = 1
+	}
+	return map[string]interface{}{
+		"fs_id":           len(p),
+		"path":            p,
+		"server_filename": path.Base(p),
+		"size":            f.size,
+		"md5":             f.md5,
+		"isdir":           isdir,
+	}
+}
+
+func (fp *fakePCS) writeJSON(w http.ResponseWriter, v interface{}) {
+	data, _ := json.Marshal(v)
+	w.Write(data)
+}
+
+func (fp *fakePCS) meta(w http.ResponseWriter, items []*fakeBatchItem) {
+	list := make([]interface{}, 0, len(items))
+	for _, item := range items {
+		if fp.files[item.Path] == nil {
+			io.WriteString(w, `{"error_code":31066,"error_msg":"file does not exist"}`)
+			return
+		}
+		list = append(list, fp.fileJSON(item.Path))
+	}
+	fp.writeJSON(w, map[string]interface{}{"list": list})
+}
+
+func (fp *fakePCS) list(w http.ResponseWriter, dir, limit string) {
+	if f := fp.files[dir]; dir != "/" && (f == nil || !f.isdir) {
+		io.WriteString(w, `{"error_code":31066,"error_msg":"file does not exist"}`)
+		return
+	}
+
+	var names []string
+	for p := range fp.files {
+		if p != "/" && path.Dir(p) == dir {
+			names = append(names, p)
+		}
+	}
+	sort.Strings(names)
+
+	var start, end int
+	fmt.Sscanf(limit, "%d-%d", &start, &end)
+	if end > len(names) {
+		end = len(names)
+	}
+	list := make([]interface{}, 0)
+	for _, p := range names[min(start, end):end] {
+		list = append(list, fp.fileJSON(p))
+	}
+	fp.writeJSON(w, map[string]interface{}{"list": list})
+}
+
+func (fp *fakePCS) filediff(w http.ResponseWriter) {
+	entries := make([]interface{}, 0, len(fp.changes))
+	for _, p := range fp.changes {
+		entry := map[string]interface{}{"path": p, "isdelete": 1}
+		if fp.files[p] != nil {
+			entry = fp.fileJSON(p)
+		}
+		entries = append(entries, entry)
+	}
+	fp.changes = nil
+	fp.cursor++
+	fp.writeJSON(w, map[string]interface{}{
+		"errno":    0,
+		"entries":  entries,
+		"cursor":   "c" + strconv.Itoa(fp.cursor),
+		"has_more": false,
+	})
+}
+
+// batchErrno 返回执行单个路径的错误代码
+func (fp *fakePCS) batchErrno(method string, item *fakeBatchItem) int {
+	if method == "delete" {
+		if fp.files[item.Path] == nil {
+			return -9
+		}
+		return 0
+	}
+	if fp.files[item.From] == nil {
+		return -9
+	}
+	if fp.files[item.To] != nil {
+		return -8 // 目标已存在
+	}
+	return 0
+}
+
+func (fp *fakePCS) applyBatch(method string, item *fakeBatchItem) {
+	switch method {
+	case "delete":
+		for p := range fp.files {
+			if p == item.Path || strings.HasPrefix(p, item.Path+"/") {
+				delete(fp.files, p)
+			}
+		}
+	case "copy", "move":
+		for p, f := range fp.files {
+			if p == item.From || strings.HasPrefix(p, item.From+"/") {
+				fp.files[item.To+strings.TrimPrefix(p, item.From)] = f
+				if method == "move" {
+					delete(fp.files, p)
+				}
+			}
+		}
+	}
+}
+
+func (fp *fakePCS) batch(w http.ResponseWriter, method string, items []*fakeBatchItem) {
+	if fp.throttle > 0 {
+		fp.throttle--
+		io.WriteString(w, `{"error_code":31034,"error_msg":"hit frequence limit"}`)
+		return
+	}
+
+	var (
+		errnos = make([]int, len(items))
+		failed bool
+	)
+	for k, item := range items {
+		errnos[k] = fp.batchErrno(method, item)
+		failed = failed || errnos[k] != 0
+	}
+
+	if !failed || fp.batchInfo {
+		info := make([]interface{}, 0, len(items))
+		for k, item := range items {
+			if errnos[k] == 0 {
+				fp.applyBatch(method, item)
+			}
+			info = append(info, map[string]interface{}{"errno": errnos[k], "path": item.Path + item.From})
+		}
+		if !failed {
+			io.WriteString(w, `{"extra":{},"request_id":1}`)
+			return
+		}
+		fp.writeJSON(w, map[string]interface{}{"error_code": 12, "error_msg": "batch failed", "info": info})
+		return
+	}
+
+	// 整个请求不执行, 返回第一个出错的路径的错误
+	for k := range items {
+		if errnos[k] != 0 {
+			io.WriteString(w, `{"error_code":`+strconv.Itoa(fakeErrCodes[errnos[k]])+`,"error_msg":"batch failed"}`)
+			return
+		}
+	}
+}
@@ -2,13 +2,11 @@ package baidupcs
 
 import (
 	"errors"
-	"fmt"
 	"github.com/olekukonko/tablewriter"
 	"github.com/qjfoidnh/BaiduPCS-Go/baidupcs/pcserror"
 	"github.com/qjfoidnh/BaiduPCS-Go/pcstable"
 	"github.com/qjfoidnh/BaiduPCS-Go/pcsutil/converter"
 	"github.com/qjfoidnh/BaiduPCS-Go/pcsutil/pcstime"
-	"path/filepath"
 	"strconv"
 	"strings"
 	"unsafe"
@@ -74,7 +72,8 @@ type (
 		IsdirInt       int8  `json:"isdir"`
 		IfhassubdirInt int8  `json:"ifhassubdir"`
 
-		// 对齐
+		// 对齐, 与 FileDirectory 的内存布局保持一致
+		_ string // PreBase
 		_ *fdJSON
 		_ []*fdJSON
 	}
@@ -102,16 +101,37 @@ var (
 		By:    OrderByName,
 		Order: OrderAsc,
 	}
-
-	defaultOrderOptionsStr = fmt.Sprint(DefaultOrderOptions)
 )
 
 // FilesDirectoriesMeta 获取单个文件/目录的元信息
 func (pcs *BaiduPCS) FilesDirectoriesMeta(path string) (data *FileDirectory, pcsError pcserror.Error) {
+	return pcs.filesDirectoriesMeta(path, false)
+}
+
+// CacheFilesDirectoriesMeta 获取单个文件/目录的元信息, 使用持久化缓存 (如果已设置),
+// 数据最多可能滞后 MetaCacheSyncInterval, 只应用于浏览
+func (pcs *BaiduPCS) CacheFilesDirectoriesMeta(path string) (data *FileDirectory, pcsError pcserror.Error) {
+	return pcs.filesDirectoriesMeta(path, true)
+}
+
+func (pcs *BaiduPCS) filesDirectoriesMeta(path string, useCache bool) (data *FileDirectory, pcsError pcserror.Error) {
 	if path == "" {
 		path = PathSeparator
 	}
 
+	var (
+		mc      *MetaCache
+		cacheOK bool
+	)
+	if useCache {
+		mc, cacheOK = pcs.syncMetaCache()
+	}
+	if cacheOK {
+		if fd, ok := mc.loadMeta(path); ok {
+			return fd, nil
+		}
+	}
+
 	fds, err := pcs.FilesDirectoriesBatchMeta(path)
 	if err != nil {
 		return nil, err
@@ -125,11 +145,44 @@ func (pcs *BaiduPCS) FilesDirectoriesMeta(path string) (data *FileDirectory, pcs
 			Err:       errors.New("未知返回数据"),
 		}
 	}
+
+	if cacheOK {
+		mc.storeMeta(fds[0])
+	}
 	return fds[0], nil
 }
 
-// FilesDirectoriesBatchMeta 获取多个文件/目录的元信息
+// FilesDirectoriesBatchMeta 获取多个文件/目录的元信息, 自动分片执行, 返回结果的位置与输入一一对应.
+// 部分分片出错时, 出错分片对应的位置为 nil, 同时返回第一个遇到的错误
 func (pcs *BaiduPCS) FilesDirectoriesBatchMeta(paths ...string) (data FileDirectoryList, pcsError pcserror.Error) {
+	if len(paths) == 0 {
+		return pcs.filesDirectoriesBatchMeta()
+	}
+
+	var (
+		batchSize = pcs.getBatchSize()
+		errs      = make([]pcserror.Error, (len(paths)+batchSize-1)/batchSize)
+	)
+
+	data = make(FileDirectoryList, len(paths))
+	pcs.runBatch(len(paths), func(start, end int) {
+		var chunk FileDirectoryList
+		errs[start/batchSize] = retryThrottled(OperationFilesDirectoriesMeta, func() (pcsError pcserror.Error) {
+			chunk, pcsError = pcs.filesDirectoriesBatchMeta(paths[start:end]...)
+			return
+		})
+		copy(data[start:end], chunk)
+	})
+
+	for _, err := range errs {
+		if err != nil {
+			return data, err
+		}
+	}
+	return data, nil
+}
+
+func (pcs *BaiduPCS) filesDirectoriesBatchMeta(paths ...string) (data FileDirectoryList, pcsError pcserror.Error) {
 	dataReadCloser, pcsError := pcs.PrepareFilesDirectoriesBatchMeta(paths...)
 	if pcsError != nil {
 		return nil, pcsError
@@ -155,29 +208,11 @@ func (pcs *BaiduPCS) FilesDirectoriesBatchMeta(paths ...string) (data FileDirect
 	return
 }
 
-// FilesDirectoriesList 获取目录下的文件和目录列表
+// FilesDirectoriesList 获取目录下的文件和目录列表, 分页请求后合并返回
 func (pcs *BaiduPCS) FilesDirectoriesList(path string, options *OrderOptions) (data FileDirectoryList, pcsError pcserror.Error) {
-	dataReadCloser, pcsError := pcs.PrepareFilesDirectoriesList(path, options)
-	if pcsError != nil {
-		return nil, pcsError
-	}
-
-	defer dataReadCloser.Close()
-
-	jsonData := fdData{
-		PCSErrInfo: pcserror.NewPCSErrorInfo(OperationFilesDirectoriesList),
-	}
-
-	pcsError = pcserror.HandleJSONParse(OperationFilesDirectoriesList, dataReadCloser, (*fdDataJSONExport)(unsafe.Pointer(&jsonData)))
-	if pcsError != nil {
-		return nil, pcsError
-	}
-
-	// 修复MD5
-	jsonData.List.fixMD5()
-
-	data = jsonData.List
-	return
+	return pcs.ListIter(path, &ListOptions{
+		OrderOptions: options,
+	}).All()
 }
 
 // Search 按文件名搜索文件, 不支持查找目录
@@ -210,50 +245,14 @@ func (pcs *BaiduPCS) Search(targetPath, keyword string, recursive bool) (fdl Fil
 	return
 }
 
-func (pcs *BaiduPCS) recurseList(path string, depth int, options *OrderOptions, prebase string, handleFileDirectoryFunc HandleFileDirectoryFunc) (fdl FileDirectoryList, ok bool) {
-	fdl, pcsError := pcs.FilesDirectoriesList(path, options)
-	if pcsError != nil {
-		ok := handleFileDirectoryFunc(depth, path, nil, pcsError) // 传递错误
-		return nil, ok
-	}
-
-	for k := range fdl {
-		fdl[k].PreBase = prebase
-		ok = handleFileDirectoryFunc(depth+1, fdl[k].Path, fdl[k], nil)
-		if !ok {
-			return
-		}
-
-		if !fdl[k].Isdir {
-			continue
-		}
-
-		fdl[k].Children, ok = pcs.recurseList(fdl[k].Path, depth+1, options, filepath.Join(prebase, filepath.Base(fdl[k].Path)), handleFileDirectoryFunc)
-		if !ok {
-			return
-		}
-	}
-
-	return fdl, true
-}
-
-// FilesDirectoriesRecurseList 递归获取目录下的文件和目录列表
+// FilesDirectoriesRecurseList 递归获取目录下的文件和目录列表, 返回完整的目录树
+// 子目录的列表会并发预读, 回调顺序与深度优先遍历一致
 func (pcs *BaiduPCS) FilesDirectoriesRecurseList(path string, options *OrderOptions, handleFileDirectoryFunc HandleFileDirectoryFunc) (data FileDirectoryList) {
-	fd, pcsError := pcs.FilesDirectoriesMeta(path)
-	if pcsError != nil {
-		handleFileDirectoryFunc(0, path, nil, pcsError) // 传递错误
-		return nil
-	}
-
-	if !fd.Isdir { // 不是一个目录
-		handleFileDirectoryFunc(0, path, fd, nil)
-		return FileDirectoryList{fd}
-	} else {
-		handleFileDirectoryFunc(0, path, fd, nil)
-	}
-
-	data, _ = pcs.recurseList(path, 0, options, filepath.Base(path), handleFileDirectoryFunc)
-	return data
+	return pcs.FilesDirectoriesWalk(path, &WalkOptions{
+		OrderOptions: options,
+		Ordered:      true,
+		KeepChildren: true,
+	}, handleFileDirectoryFunc)
 }
 
 // fixMD5 尝试修复MD5字段
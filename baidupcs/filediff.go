@@ -0,0 +1,113 @@
+package baidupcs
+
+import (
+	"bytes"
+	"unsafe"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/qjfoidnh/BaiduPCS-Go/baidupcs/pcserror"
+)
+
+type (
+	// FileDiffEntry 发生变更的文件或目录
+	FileDiffEntry struct {
+		*FileDirectory
+		IsDelete bool // 是否已被删除
+	}
+
+	// FileDiff cursor 之后的文件变更信息
+	FileDiff struct {
+		Entries []*FileDiffEntry
+		Cursor  string // 下次请求使用的 cursor
+		HasMore bool   // 是否还有更多变更
+		Reset   bool   // 为 true 时, 之前的数据应全部作废
+	}
+
+	fdDiffEntryJSON struct {
+		fdJSON
+		IsDelete int8 `json:"isdelete"`
+	}
+
+	fdDiffJSON struct {
+		*pcserror.PanErrorInfo
+		Entries jsoniter.RawMessage `json:"entries"`
+		Cursor  string              `json:"cursor"`
+		HasMore interface{}         `json:"has_more"`
+		Reset   interface{}         `json:"reset"`
+	}
+)
+
+// FilesDirectoriesDiff 获取 cursor 之后的文件变更信息, cursor 为空则从头获取
+func (pcs *BaiduPCS) FilesDirectoriesDiff(cursor string) (diff *FileDiff, pcsError pcserror.Error) {
+	dataReadCloser, pcsError := pcs.PrepareFilesDirectoriesDiff(cursor)
+	if pcsError != nil {
+		return
+	}
+
+	defer dataReadCloser.Close()
+
+	errInfo := pcserror.NewPanErrorInfo(OperationGetCursorDiff)
+	jsonData := fdDiffJSON{
+		PanErrorInfo: errInfo,
+	}
+
+	pcsError = pcserror.HandleJSONParse(OperationGetCursorDiff, dataReadCloser, &jsonData)
+	if pcsError != nil {
+		return
+	}
+
+	// 没有变更时, entries 可能是空数组
+	var entries []*fdDiffEntryJSON
+	raw := bytes.TrimSpace(jsonData.Entries)
+	if len(raw) > 0 && raw[0] == '{' {
+		entryMap := map[string]*fdDiffEntryJSON{}
+		err := jsoniter.Unmarshal(raw, &entryMap)
+		if err != nil {
+			errInfo.ErrType = pcserror.ErrTypeJSONParseError
+			errInfo.Err = err
+			return nil, errInfo
+		}
+		for _, entry := range entryMap {
+			entries = append(entries, entry)
+		}
+	} else if len(raw) > 0 && raw[0] == '[' {
+		err := jsoniter.Unmarshal(raw, &entries)
+		if err != nil {
+			errInfo.ErrType = pcserror.ErrTypeJSONParseError
+			errInfo.Err = err
+			return nil, errInfo
+		}
+	}
+
+	diff = &FileDiff{
+		Entries: make([]*FileDiffEntry, 0, len(entries)),
+		Cursor:  jsonData.Cursor,
+		HasMore: isTrueValue(jsonData.HasMore),
+		Reset:   isTrueValue(jsonData.Reset),
+	}
+	for _, entry := range entries {
+		if entry == nil {
+			continue
+		}
+		fd := (*FileDirectory)(unsafe.Pointer(&entry.fdJSON))
+		fd.fixMD5()
+		diff.Entries = append(diff.Entries, &FileDiffEntry{
+			FileDirectory: fd,
+			IsDelete:      entry.IsDelete != 0,
+		})
+	}
+	return diff, nil
+}
+
+// isTrueValue 服务器返回的布尔值可能是 bool 或数字
+func isTrueValue(v interface{}) bool {
+	switch value := v.(type) {
+	case bool:
+		return value
+	case float64:
+		return value != 0
+	case string:
+		return value == "1" || value == "true"
+	}
+	return false
+}
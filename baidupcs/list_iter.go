@@ -0,0 +1,206 @@
+package baidupcs
+
+import (
+	"unsafe"
+
+	"github.com/qjfoidnh/BaiduPCS-Go/baidupcs/pcserror"
+)
+
+const (
+	// DefaultListPageSize 分页获取目录列表时, 默认每页的条目数量
+	DefaultListPageSize = 1000
+)
+
+type (
+	// ListOptions 分页获取目录列表可选项
+	ListOptions struct {
+		OrderOptions *OrderOptions // 排序, 为空则使用默认排序
+		PageSize     int           // 每页的条目数量, 小于1则使用默认值
+
+		// UseCache 使用持久化缓存 (如果已设置), 数据最多可能滞后 MetaCacheSyncInterval,
+		// 只应用于浏览, 不应用于下载, 同步等依据结果进行操作的场景
+		UseCache bool
+	}
+
+	// ListIterator 分页获取目录列表的迭代器, 非并发安全
+	ListIterator struct {
+		pcs      *BaiduPCS
+		path     string
+		options  *OrderOptions
+		pageSize int
+
+		offset int               // 下一页的起始位置
+		page   FileDirectoryList // 当前页
+		index  int               // 当前条目在当前页中的位置
+		cur    *FileDirectory
+		done   bool
+		err    pcserror.Error
+
+		cached    FileDirectoryList // 持久化缓存中的完整列表
+		fromCache bool
+		mc        *MetaCache // 未命中缓存时, 获取完毕后写入
+		collected FileDirectoryList
+	}
+)
+
+// SetListPageSize 设置分页获取目录列表时, 默认每页的条目数量
+func (pcs *BaiduPCS) SetListPageSize(size int) {
+	pcs.listPageSize = size
+}
+
+func (pcs *BaiduPCS) getListPageSize() int {
+	if pcs.listPageSize < 1 {
+		return DefaultListPageSize
+	}
+	return pcs.listPageSize
+}
+
+// ListIter 返回分页获取目录列表的迭代器, 按需逐页请求服务器
+func (pcs *BaiduPCS) ListIter(path string, opts *ListOptions) *ListIterator {
+	it := &ListIterator{
+		pcs:      pcs,
+		path:     path,
+		options:  DefaultOrderOptions,
+		pageSize: pcs.getListPageSize(),
+	}
+	if opts == nil {
+		return it
+	}
+	if opts.OrderOptions != nil {
+		it.options = opts.OrderOptions
+	}
+	if opts.PageSize > 0 {
+		it.pageSize = opts.PageSize
+	}
+	if !opts.UseCache {
+		return it
+	}
+
+	if mc, ok := pcs.syncMetaCache(); ok {
+		it.cached, it.fromCache = mc.loadList(path, listCacheKey(it.options))
+		if !it.fromCache {
+			it.mc = mc
+		}
+	}
+	return it
+}
+
+// NextPage 获取下一页, 没有更多数据时返回空
+func (it *ListIterator) NextPage() (page FileDirectoryList, pcsError pcserror.Error) {
+	if it.done || it.err != nil {
+		return nil, it.err
+	}
+
+	if it.fromCache {
+		end := it.offset + it.pageSize
+		if end >= len(it.cached) {
+			end = len(it.cached)
+			it.done = true
+		}
+		page = it.cached[it.offset:end]
+		it.offset = end
+		return page, nil
+	}
+
+	pcsError = retryThrottled(OperationFilesDirectoriesList, func() (pcsError pcserror.Error) {
+		page, pcsError = it.pcs.filesDirectoriesListPage(it.path, it.options, it.offset, it.pageSize)
+		return
+	})
+	if pcsError != nil {
+		it.err = pcsError
+		return nil, pcsError
+	}
+
+	it.offset += len(page)
+	if len(page) < it.pageSize {
+		it.done = true
+	}
+
+	if it.mc != nil {
+		it.collected = append(it.collected, page...)
+		if it.done {
+			it.mc.storeList(it.path, listCacheKey(it.options), it.collected)
+			it.collected = nil
+		}
+	}
+	return page, nil
+}
+
+// HasMore 是否可能还有下一页
+func (it *ListIterator) HasMore() bool {
+	return !it.done && it.err == nil
+}
+
+// Next 移动到下一个条目, 遇到错误或没有更多数据时返回 false
+func (it *ListIterator) Next() bool {
+	for it.index >= len(it.page) {
+		if it.done || it.err != nil {
+			it.cur = nil
+			return false
+		}
+
+		page, pcsError := it.NextPage()
+		if pcsError != nil {
+			it.cur = nil
+			return false
+		}
+		it.page, it.index = page, 0
+	}
+
+	it.cur = it.page[it.index]
+	it.index++
+	return true
+}
+
+// Value 返回当前条目
+func (it *ListIterator) Value() *FileDirectory {
+	return it.cur
+}
+
+// Err 返回迭代过程中遇到的错误
+func (it *ListIterator) Err() pcserror.Error {
+	return it.err
+}
+
+// All 获取剩余的全部条目
+func (it *ListIterator) All() (fdl FileDirectoryList, pcsError pcserror.Error) {
+	// 当前页未读取的部分
+	if it.index < len(it.page) {
+		fdl = append(fdl, it.page[it.index:]...)
+		it.index = len(it.page)
+	}
+
+	for {
+		page, pcsError := it.NextPage()
+		if pcsError != nil {
+			return nil, pcsError
+		}
+		if len(page) == 0 {
+			return fdl, nil
+		}
+		fdl = append(fdl, page...)
+	}
+}
+
+func (pcs *BaiduPCS) filesDirectoriesListPage(path string, options *OrderOptions, start, limit int) (data FileDirectoryList, pcsError pcserror.Error) {
+	dataReadCloser, pcsError := pcs.PrepareFilesDirectoriesListPage(path, options, start, limit)
+	if pcsError != nil {
+		return nil, pcsError
+	}
+
+	defer dataReadCloser.Close()
+
+	jsonData := fdData{
+		PCSErrInfo: pcserror.NewPCSErrorInfo(OperationFilesDirectoriesList),
+	}
+
+	pcsError = pcserror.HandleJSONParse(OperationFilesDirectoriesList, dataReadCloser, (*fdDataJSONExport)(unsafe.Pointer(&jsonData)))
+	if pcsError != nil {
+		return nil, pcsError
+	}
+
+	// 修复MD5
+	jsonData.List.fixMD5()
+
+	return jsonData.List, nil
+}
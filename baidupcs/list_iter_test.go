@@ -0,0 +1,75 @@
+package baidupcs_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/qjfoidnh/BaiduPCS-Go/baidupcs"
+	"github.com/qjfoidnh/BaiduPCS-Go/baidupcs/pcserror"
+)
+
+func TestListIterPages(t *testing.T) {
+	fp, pcs := newFakePCS(t, "/d/1", "/d/2", "/d/3", "/d/4", "/d/5")
+
+	it := pcs.ListIter("/d", &baidupcs.ListOptions{PageSize: 2})
+	var paths []string
+	for it.Next() {
+		paths = append(paths, it.Value().Path)
+	}
+	if it.Err() != nil {
+		t.Fatal(it.Err())
+	}
+	if want := []string{"/d/1", "/d/2", "/d/3", "/d/4", "/d/5"}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("paths %v, want %v", paths, want)
+	}
+
+	// 最后一页不满时不再请求
+	var limits []string
+	for _, req := range fp.requests {
+		limits = append(limits, req.paths[1])
+	}
+	if want := []string{"0-2", "2-4", "4-6"}; !reflect.DeepEqual(limits, want) {
+		t.Errorf("limits %v, want %v", limits, want)
+	}
+	if it.HasMore() || it.Next() {
+		t.Error("iterator not done")
+	}
+}
+
+func TestListIterFullLastPage(t *testing.T) {
+	fp, pcs := newFakePCS(t, "/d/1", "/d/2", "/d/3", "/d/4")
+
+	// 最后一页刚好满时, 需要再请求一次空页才能确定结束
+	it := pcs.ListIter("/d", &baidupcs.ListOptions{PageSize: 2})
+	if !it.Next() || it.Value().Path != "/d/1" || !it.HasMore() {
+		t.Fatalf("first entry, err %v", it.Err())
+	}
+
+	// All 返回当前页未读取的部分和之后的全部条目
+	rest, err := it.All()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rest) != 3 || rest[0].Path != "/d/2" || rest[2].Path != "/d/4" {
+		t.Errorf("rest %v", rest)
+	}
+	if n := fp.countRequests("list"); n != 3 || fp.requests[2].paths[1] != "4-6" {
+		t.Errorf("list requests %d, want 3", n)
+	}
+}
+
+func TestListIterError(t *testing.T) {
+	_, pcs := newFakePCS(t)
+	pcs.SetListPageSize(10)
+
+	it := pcs.ListIter("/missing", nil)
+	if it.Next() {
+		t.Fatal("Next on missing directory")
+	}
+	if !pcserror.IsNotExist(it.Err()) || it.HasMore() {
+		t.Errorf("err %v", it.Err())
+	}
+	if _, err := pcs.FilesDirectoriesList("/missing", nil); !pcserror.IsNotExist(err) {
+		t.Errorf("FilesDirectoriesList err %v", err)
+	}
+}
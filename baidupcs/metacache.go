@@ -0,0 +1,563 @@
+package baidupcs
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+const (
+	// MetaCacheSyncInterval 持久化缓存通过 filediff 同步服务器端变更的最小间隔,
+	// 使用缓存的数据最多可能滞后这么长时间
+	MetaCacheSyncInterval = 30 * time.Second
+
+	// metaCacheSaveDelay 缓存变更后延迟写入文件的时间, 合并多次写入
+	metaCacheSaveDelay = 2 * time.Second
+
+	// metaCacheSyncMaxPages 增量同步时最多请求的 filediff 页数, 超出说明变更过多, 直接作废缓存
+	metaCacheSyncMaxPages = 10
+
+	// metaCacheCompactMinRecords 日志记录数超过有效记录数的两倍, 且超过该值时压缩日志
+	metaCacheCompactMinRecords = 1024
+)
+
+const (
+	metaCacheOpState = "state" // 同步状态
+	metaCacheOpList  = "list"  // 目录列表
+	metaCacheOpMeta  = "meta"  // 元信息
+	metaCacheOpDrop  = "drop"  // 使路径本身, 其子孙, 及其父目录的缓存失效
+	metaCacheOpReset = "reset" // 清空缓存
+)
+
+type (
+	// MetaCache 持久化的目录列表和元信息缓存, 跨会话保留,
+	// 由本程序的修改操作和 filediff cursor 使缓存失效.
+	// 缓存以追加日志的形式写入文件, 每次只写入变更的部分, 日志过长时压缩.
+	MetaCache struct {
+		cursor   string                                  // filediff cursor
+		syncTime int64                                   // 上次同步的时间
+		catchUp  bool                                    // 是否正在获取初始的 cursor, 完成前不使用缓存
+		lists    map[string]map[string]FileDirectoryList // 目录 -> 排序方式 -> 目录列表
+		metas    map[string]*FileDirectory               // 路径 -> 元信息
+
+		mu         sync.Mutex
+		syncMu     sync.Mutex
+		filePath   string
+		saveTimer  *time.Timer
+		pending    []*metaCacheRecord // 尚未写入文件的记录
+		logRecords int                // 文件中的记录数
+		compact    bool               // 下次写入时压缩日志
+		catchingUp bool               // 后台获取 cursor 的协程是否正在运行
+	}
+
+	// metaCacheRecord 日志中的一条记录
+	metaCacheRecord struct {
+		Op       string            `json:"op"`
+		Path     string            `json:"path,omitempty"`
+		Key      string            `json:"key,omitempty"`
+		List     FileDirectoryList `json:"list,omitempty"`
+		Meta     *FileDirectory    `json:"meta,omitempty"`
+		Paths    []string          `json:"paths,omitempty"`
+		Cursor   string            `json:"cursor,omitempty"`
+		SyncTime int64             `json:"sync_time,omitempty"`
+		CatchUp  bool              `json:"catch_up,omitempty"`
+	}
+)
+
+var (
+	metaCaches sync.Map // 已打开的缓存, 同一个文件只打开一次
+)
+
+// OpenMetaCache 打开持久化缓存文件, 文件不存在时使用空缓存, 末尾损坏的记录会被丢弃
+func OpenMetaCache(filePath string) (mc *MetaCache, err error) {
+	filePath, err = filepath.Abs(filePath)
+	if err != nil {
+		return nil, err
+	}
+	if mcItf, ok := metaCaches.Load(filePath); ok {
+		return mcItf.(*MetaCache), nil
+	}
+
+	mc = &MetaCache{
+		filePath: filePath,
+	}
+	mc.clear()
+
+	file, err := os.Open(filePath)
+	if err == nil {
+		err = mc.replay(file)
+		file.Close()
+		if err != nil {
+			// 记录损坏, 保留已读取的部分, 下次写入时重写文件
+			mc.compact = true
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	mcItf, _ := metaCaches.LoadOrStore(filePath, mc)
+	return mcItf.(*MetaCache), nil
+}
+
+// replay 读取日志并重放
+func (mc *MetaCache) replay(r io.Reader) error {
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadBytes('\n')
+		if err == io.EOF {
+			if len(bytes.TrimSpace(line)) > 0 {
+				return io.ErrUnexpectedEOF // 写入中途退出
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		record := &metaCacheRecord{}
+		err = jsoniter.Unmarshal(line, record)
+		if err != nil {
+			return err
+		}
+		mc.apply(record)
+		mc.logRecords++
+	}
+}
+
+// apply 执行一条记录, 调用时需持有锁或尚未共享
+func (mc *MetaCache) apply(record *metaCacheRecord) {
+	switch record.Op {
+	case metaCacheOpState:
+		mc.cursor, mc.syncTime, mc.catchUp = record.Cursor, record.SyncTime, record.CatchUp
+	case metaCacheOpList:
+		if mc.lists[record.Path] == nil {
+			mc.lists[record.Path] = map[string]FileDirectoryList{}
+		}
+		mc.lists[record.Path][record.Key] = record.List
+	case metaCacheOpMeta:
+		if record.Meta != nil {
+			mc.metas[record.Meta.Path] = record.Meta
+		}
+	case metaCacheOpDrop:
+		mc.drop(record.Paths)
+	case metaCacheOpReset:
+		mc.clear()
+	}
+}
+
+// record 执行一条记录, 并延迟写入文件, 调用时需持有锁
+func (mc *MetaCache) record(record *metaCacheRecord) {
+	mc.apply(record)
+	mc.pending = append(mc.pending, record)
+	mc.scheduleSave()
+}
+
+// stateRecord 当前的同步状态, 调用时需持有锁
+func (mc *MetaCache) stateRecord() *metaCacheRecord {
+	return &metaCacheRecord{
+		Op:       metaCacheOpState,
+		Cursor:   mc.cursor,
+		SyncTime: mc.syncTime,
+		CatchUp:  mc.catchUp,
+	}
+}
+
+// liveRecords 有效记录数, 调用时需持有锁
+func (mc *MetaCache) liveRecords() int {
+	n := 1 + len(mc.metas)
+	for _, lists := range mc.lists {
+		n += len(lists)
+	}
+	return n
+}
+
+// Save 立即将未写入的记录追加到文件, 日志过长时压缩
+func (mc *MetaCache) Save() error {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	if mc.saveTimer != nil {
+		mc.saveTimer.Stop()
+		mc.saveTimer = nil
+	}
+
+	live := mc.liveRecords()
+	if mc.compact || mc.logRecords+len(mc.pending) > 2*live && mc.logRecords+len(mc.pending) > metaCacheCompactMinRecords {
+		return mc.rewrite()
+	}
+	if len(mc.pending) == 0 {
+		return nil
+	}
+
+	file, err := os.OpenFile(mc.filePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	err = writeMetaCacheRecords(file, mc.pending)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		// 可能只写入了一部分, 下次重写文件
+		mc.compact = true
+		return err
+	}
+	mc.logRecords += len(mc.pending)
+	mc.pending = nil
+	return nil
+}
+
+// rewrite 将当前的有效记录写入新文件, 替换原日志, 调用时需持有锁
+func (mc *MetaCache) rewrite() error {
+	records := make([]*metaCacheRecord, 0, mc.liveRecords())
+	records = append(records, mc.stateRecord())
+	for dir, lists := range mc.lists {
+		for key, fdl := range lists {
+			records = append(records, &metaCacheRecord{
+				Op:   metaCacheOpList,
+				Path: dir,
+				Key:  key,
+				List: fdl,
+			})
+		}
+	}
+	for _, fd := range mc.metas {
+		records = append(records, &metaCacheRecord{
+			Op:   metaCacheOpMeta,
+			Meta: fd,
+		})
+	}
+
+	// 先写入临时文件, 避免写入中途退出损坏缓存
+	tmpPath := mc.filePath + ".tmp"
+	file, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	err = writeMetaCacheRecords(file, records)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	err = os.Rename(tmpPath, mc.filePath)
+	if err != nil {
+		return err
+	}
+
+	mc.logRecords = len(records)
+	mc.pending = nil
+	mc.compact = false
+	return nil
+}
+
+func writeMetaCacheRecords(w io.Writer, records []*metaCacheRecord) error {
+	bw := bufio.NewWriter(w)
+	for _, record := range records {
+		data, err := jsoniter.Marshal(record)
+		if err != nil {
+			return err
+		}
+		bw.Write(data)
+		bw.WriteByte('\n')
+	}
+	return bw.Flush()
+}
+
+// scheduleSave 延迟写入文件, 调用时需持有锁
+func (mc *MetaCache) scheduleSave() {
+	if mc.saveTimer != nil {
+		return
+	}
+	mc.saveTimer = time.AfterFunc(metaCacheSaveDelay, func() {
+		err := mc.Save()
+		if err != nil {
+			baiduPCSVerbose.Warnf("save meta cache error: %s\n", err)
+		}
+	})
+}
+
+// clear 清空缓存数据, 调用时需持有锁或尚未共享
+func (mc *MetaCache) clear() {
+	mc.cursor, mc.syncTime, mc.catchUp = "", 0, false
+	mc.lists = map[string]map[string]FileDirectoryList{}
+	mc.metas = map[string]*FileDirectory{}
+}
+
+// reset 清空缓存, 并开始获取初始的 cursor, 调用时需持有锁
+func (mc *MetaCache) reset() {
+	mc.record(&metaCacheRecord{
+		Op: metaCacheOpReset,
+	})
+	mc.catchUp = true
+	mc.record(mc.stateRecord())
+	mc.compact = true // 之前的记录已全部作废
+}
+
+// isUsable 缓存是否可以使用, 调用时需持有锁
+func (mc *MetaCache) isUsable() bool {
+	return mc.cursor != "" && !mc.catchUp
+}
+
+func (mc *MetaCache) loadList(dir, orderKey string) (fdl FileDirectoryList, ok bool) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	fdl, ok = mc.lists[dir][orderKey]
+	if !ok {
+		return nil, false
+	}
+	return fdl.cacheCopy(), true
+}
+
+func (mc *MetaCache) storeList(dir, orderKey string, fdl FileDirectoryList) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	if !mc.isUsable() {
+		return
+	}
+	mc.record(&metaCacheRecord{
+		Op:   metaCacheOpList,
+		Path: dir,
+		Key:  orderKey,
+		List: fdl.cacheCopy(),
+	})
+}
+
+func (mc *MetaCache) loadMeta(pcspath string) (fd *FileDirectory, ok bool) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	fd, ok = mc.metas[pcspath]
+	if ok {
+		return fd.cacheCopy(), true
+	}
+
+	// 从父目录的列表中查找
+	for _, fdl := range mc.lists[path.Dir(pcspath)] {
+		for _, fd = range fdl {
+			if fd.Path == pcspath {
+				return fd.cacheCopy(), true
+			}
+		}
+		break
+	}
+	return nil, false
+}
+
+func (mc *MetaCache) storeMeta(fd *FileDirectory) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	if !mc.isUsable() {
+		return
+	}
+	mc.record(&metaCacheRecord{
+		Op:   metaCacheOpMeta,
+		Meta: fd.cacheCopy(),
+	})
+}
+
+// invalidateTree 使路径本身, 其子孙, 及其父目录的列表和元信息缓存失效
+func (mc *MetaCache) invalidateTree(paths []string) {
+	if len(paths) == 0 {
+		return
+	}
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	mc.record(&metaCacheRecord{
+		Op:    metaCacheOpDrop,
+		Paths: paths,
+	})
+}
+
+// drop 删除路径本身, 其子孙, 及其父目录的列表和元信息, 调用时需持有锁
+func (mc *MetaCache) drop(paths []string) {
+	dropped := make(map[string]struct{}, len(paths))
+	for _, p := range paths {
+		if p == PathSeparator {
+			mc.lists = map[string]map[string]FileDirectoryList{}
+			mc.metas = map[string]*FileDirectory{}
+			return
+		}
+		dropped[p] = struct{}{}
+
+		// 父目录的列表和修改时间已改变
+		parent := path.Dir(p)
+		delete(mc.lists, parent)
+		delete(mc.metas, parent)
+	}
+
+	// 路径本身或其祖先被删除
+	isDropped := func(p string) bool {
+		for {
+			if _, ok := dropped[p]; ok {
+				return true
+			}
+			parent := path.Dir(p)
+			if parent == p {
+				return false
+			}
+			p = parent
+		}
+	}
+	for dir := range mc.lists {
+		if isDropped(dir) {
+			delete(mc.lists, dir)
+		}
+	}
+	for metaPath := range mc.metas {
+		if isDropped(metaPath) {
+			delete(mc.metas, metaPath)
+		}
+	}
+}
+
+// cacheCopy 复制一份不含父子关系的元信息, 避免缓存被外部修改
+func (f *FileDirectory) cacheCopy() *FileDirectory {
+	fd := *f
+	fd.Parent, fd.Children = nil, nil
+	return &fd
+}
+
+func (fl FileDirectoryList) cacheCopy() FileDirectoryList {
+	fdl := make(FileDirectoryList, len(fl))
+	for k := range fl {
+		fdl[k] = fl[k].cacheCopy()
+	}
+	return fdl
+}
+
+// SetMetaCache 设置持久化缓存, 为空则不使用
+func (pcs *BaiduPCS) SetMetaCache(mc *MetaCache) {
+	pcs.metaCache = mc
+}
+
+// MetaCache 返回持久化缓存, 可能为空
+func (pcs *BaiduPCS) MetaCache() *MetaCache {
+	return pcs.metaCache
+}
+
+// syncMetaCache 距上次同步超过 MetaCacheSyncInterval 时, 通过 filediff 同步服务器端的变更,
+// 返回缓存当前是否可用. 尚未获取到 cursor 时在后台获取, 期间不使用缓存.
+func (pcs *BaiduPCS) syncMetaCache() (mc *MetaCache, ok bool) {
+	mc = pcs.metaCache
+	if mc == nil {
+		return nil, false
+	}
+
+	mc.syncMu.Lock()
+	defer mc.syncMu.Unlock()
+
+	mc.mu.Lock()
+	cursor, syncTime, usable := mc.cursor, mc.syncTime, mc.isUsable()
+	mc.mu.Unlock()
+	if !usable {
+		pcs.catchUpMetaCache(mc)
+		return mc, false
+	}
+	if time.Since(time.Unix(syncTime, 0)) < MetaCacheSyncInterval {
+		return mc, true
+	}
+
+	var paths []string
+	for pages := 0; ; pages++ {
+		diff, pcsError := pcs.FilesDirectoriesDiff(cursor)
+		if pcsError != nil {
+			// 无法确认服务器端的变更, 本次不使用缓存
+			baiduPCSVerbose.Warnf("%s\n", pcsError)
+			return mc, false
+		}
+
+		if diff.Reset || diff.Cursor == "" || pages >= metaCacheSyncMaxPages {
+			// 之前的缓存全部作废, 重新获取 cursor
+			mc.mu.Lock()
+			mc.reset()
+			mc.mu.Unlock()
+			pcs.catchUpMetaCache(mc)
+			return mc, false
+		}
+
+		for _, entry := range diff.Entries {
+			paths = append(paths, entry.Path)
+		}
+		cursor = diff.Cursor
+		if !diff.HasMore {
+			break
+		}
+	}
+
+	mc.mu.Lock()
+	if len(paths) > 0 {
+		mc.record(&metaCacheRecord{
+			Op:    metaCacheOpDrop,
+			Paths: paths,
+		})
+	}
+	mc.cursor, mc.syncTime = cursor, time.Now().Unix()
+	mc.record(mc.stateRecord())
+	mc.mu.Unlock()
+	return mc, true
+}
+
+// catchUpMetaCache 在后台获取当前的 filediff cursor, 期间返回的条目全部丢弃, 不阻塞调用者.
+// 进度随缓存保存, 中断后下次继续.
+func (pcs *BaiduPCS) catchUpMetaCache(mc *MetaCache) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	if mc.catchingUp {
+		return
+	}
+	if !mc.catchUp {
+		mc.reset()
+	}
+	mc.catchingUp = true
+	cursor := mc.cursor
+
+	go func() {
+		defer func() {
+			mc.mu.Lock()
+			mc.catchingUp = false
+			mc.mu.Unlock()
+		}()
+
+		for {
+			diff, pcsError := pcs.FilesDirectoriesDiff(cursor)
+			if pcsError != nil {
+				// 下次同步时重试
+				baiduPCSVerbose.Warnf("%s\n", pcsError)
+				return
+			}
+
+			mc.mu.Lock()
+			if diff.Reset {
+				// 之前获取的 cursor 作废
+				mc.reset()
+			}
+			mc.cursor = diff.Cursor
+			done := !diff.HasMore && mc.cursor != ""
+			if done {
+				mc.catchUp, mc.syncTime = false, time.Now().Unix()
+			}
+			mc.record(mc.stateRecord())
+			cursor = mc.cursor
+			mc.mu.Unlock()
+
+			if done || cursor == "" {
+				return
+			}
+		}
+	}()
+}
+
+// listCacheKey 目录列表在缓存中的 key
+func listCacheKey(options *OrderOptions) string {
+	if options == nil {
+		options = DefaultOrderOptions
+	}
+	return string(options.By) + string(options.Order)
+}
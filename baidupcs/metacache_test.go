@@ -0,0 +1,156 @@
+package baidupcs_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/qjfoidnh/BaiduPCS-Go/baidupcs"
+)
+
+// listCached 使用缓存获取 /d 的列表, 返回条目路径, 以及是否请求了服务器
+func listCached(t *testing.T, fp *fakePCS, pcs *baidupcs.BaiduPCS) (paths []string, fromServer bool) {
+	before := fp.countRequests("list")
+	fdl, err := pcs.ListIter("/d", &baidupcs.ListOptions{UseCache: true}).All()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, fd := range fdl {
+		paths = append(paths, fd.Path)
+	}
+	return paths, fp.countRequests("list") > before
+}
+
+// openMetaCache 打开缓存, 测试结束前写入文件, 停止延迟写入
+func openMetaCache(t *testing.T, pcs *baidupcs.BaiduPCS, filePath string) *baidupcs.MetaCache {
+	mc, err := baidupcs.OpenMetaCache(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pcs.SetMetaCache(mc)
+	t.Cleanup(func() {
+		mc.Save()
+	})
+	return mc
+}
+
+// waitCached 等待后台获取 cursor 完成, 直到列表从缓存中获取
+func waitCached(t *testing.T, fp *fakePCS, pcs *baidupcs.BaiduPCS) []string {
+	for i := 0; i < 200; i++ {
+		paths, fromServer := listCached(t, fp, pcs)
+		if !fromServer {
+			return paths
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("list is never served from the meta cache")
+	return nil
+}
+
+func TestMetaCacheInvalidate(t *testing.T) {
+	fp, pcs := newFakePCS(t, "/d/1", "/d/2", "/d/3")
+	openMetaCache(t, pcs, filepath.Join(t.TempDir(), "meta.log"))
+
+	// 获取到 cursor 之前不使用缓存
+	if _, fromServer := listCached(t, fp, pcs); !fromServer {
+		t.Fatal("list served from cache before catching up")
+	}
+	if paths := waitCached(t, fp, pcs); !reflect.DeepEqual(paths, []string{"/d/1", "/d/2", "/d/3"}) {
+		t.Errorf("cached paths %v", paths)
+	}
+	if fp.countRequests("filediff") == 0 {
+		t.Error("cursor not fetched")
+	}
+
+	// 元信息从父目录的列表中获取
+	metaRequests := fp.countRequests("meta")
+	if fd, err := pcs.CacheFilesDirectoriesMeta("/d/2"); err != nil || fd.Path != "/d/2" || fp.countRequests("meta") != metaRequests {
+		t.Errorf("cached meta %v, err %v", fd, err)
+	}
+
+	// 本程序的修改操作使缓存失效
+	if err := pcs.Remove("/d/1"); err != nil {
+		t.Fatal(err)
+	}
+	paths, fromServer := listCached(t, fp, pcs)
+	if !fromServer || !reflect.DeepEqual(paths, []string{"/d/2", "/d/3"}) {
+		t.Errorf("after remove: %v, from server %v", paths, fromServer)
+	}
+	if paths, fromServer = listCached(t, fp, pcs); fromServer || len(paths) != 2 {
+		t.Errorf("list not cached again: %v", paths)
+	}
+
+	// 不使用缓存的请求总是请求服务器
+	if _, err := pcs.FilesDirectoriesMeta("/d/2"); err != nil || fp.countRequests("meta") != metaRequests+1 {
+		t.Errorf("uncached meta err %v", err)
+	}
+}
+
+func TestMetaCacheReplay(t *testing.T) {
+	fp, pcs := newFakePCS(t, "/d/1", "/d/2", "/d/3")
+	dir := t.TempDir()
+	mc := openMetaCache(t, pcs, filepath.Join(dir, "meta.log"))
+	waitCached(t, fp, pcs)
+	if err := mc.Save(); err != nil {
+		t.Fatal(err)
+	}
+	saved, err := os.ReadFile(filepath.Join(dir, "meta.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 追加同步状态和写入中途退出的记录, 重放时丢弃损坏的记录
+	replayed := filepath.Join(dir, "replayed.log")
+	state := fmt.Sprintf(`{"op":"state","cursor":"cX","sync_time":%d}`, time.Now().Unix())
+	err = os.WriteFile(replayed, append(saved, state+"\n"+`{"op":"list","pa`...), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mc = openMetaCache(t, pcs, replayed)
+	diffRequests := fp.countRequests("filediff")
+	paths, fromServer := listCached(t, fp, pcs)
+	if fromServer || !reflect.DeepEqual(paths, []string{"/d/1", "/d/2", "/d/3"}) {
+		t.Errorf("replayed list %v, from server %v", paths, fromServer)
+	}
+	if fp.countRequests("filediff") != diffRequests {
+		t.Error("synced within MetaCacheSyncInterval")
+	}
+
+	// 保存时重写损坏的日志
+	if err = mc.Save(); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(replayed)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		if !json.Valid(scanner.Bytes()) {
+			t.Errorf("invalid record after save: %s", scanner.Text())
+		}
+	}
+
+	// 距上次同步超过 MetaCacheSyncInterval 时, 通过 filediff 同步服务器端的变更
+	stale := filepath.Join(dir, "stale.log")
+	err = os.WriteFile(stale, append(saved, `{"op":"state","cursor":"cX","sync_time":1}`+"\n"...), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fp.touch("/d/4")
+	openMetaCache(t, pcs, stale)
+	paths, fromServer = listCached(t, fp, pcs)
+	if !fromServer || !reflect.DeepEqual(paths, []string{"/d/1", "/d/2", "/d/3", "/d/4"}) {
+		t.Errorf("list after server change %v, from server %v", paths, fromServer)
+	}
+	last := fp.requests[len(fp.requests)-2]
+	if last.method != "filediff" || last.paths[0] != "cX" {
+		t.Errorf("filediff request %+v", last)
+	}
+	if _, fromServer = listCached(t, fp, pcs); fromServer {
+		t.Error("list not cached after sync")
+	}
+}
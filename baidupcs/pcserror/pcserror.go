@@ -22,6 +22,11 @@ type (
 		GetRemoteErrMsg() string
 		GetError() error
 	}
+
+	// RemoteErrorObserver 数据来源可选实现, 解析出远端服务器错误时被通知
+	RemoteErrorObserver interface {
+		ObserveRemoteError(errCode int)
+	}
 )
 
 const (
@@ -87,9 +92,24 @@ func HandleJSONParse(op string, data io.Reader, info interface{}) (pcsError Erro
 	}
 
 	// 设置出错类型为远程错误
-	if errInfo.GetRemoteErrCode() != 0 {
+	if errCode := errInfo.GetRemoteErrCode(); errCode != 0 {
+		if observer, ok := data.(RemoteErrorObserver); ok {
+			observer.ObserveRemoteError(errCode)
+		}
 		errInfo.SetRemoteError()
 		return errInfo
 	}
 	return nil
 }
+
+// IsNotExist 是否为远端文件或目录不存在的错误
+func IsNotExist(pcsError Error) bool {
+	if pcsError == nil || pcsError.GetErrType() != ErrTypeRemoteError {
+		return false
+	}
+	switch pcsError.GetRemoteErrCode() {
+	case 31066, -3, -9:
+		return true
+	}
+	return false
+}
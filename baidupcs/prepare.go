@@ -67,7 +67,28 @@ func (pcs *BaiduPCS) sendReqReturnResp(rt reqType, op, method, urlStr string, po
 		}
 	}
 
-	resp, err := pcs.client.Req(method, urlStr, post, header)
+	// 限速, 被限流时降低速率后重试
+	var (
+		limiter    = pcs.getAPILimiter(op, urlStr)
+		makePost   = func() interface{} { return post }
+		repeatable = true
+		err        error
+	)
+	if limiter != nil {
+		makePost, repeatable = makeReplayablePost(post)
+	}
+	for retry := 0; ; retry++ {
+		limiter.wait()
+		resp, err = pcs.client.Req(method, urlStr, makePost(), header)
+		if err != nil || !limiter.isThrottled(resp) {
+			break
+		}
+		if retry >= throttleMaxRetry || !repeatable {
+			break
+		}
+		handleRespClose(resp)
+		baiduPCSVerbose.Warnf("%s: 请求过于频繁, 降低请求速率后重试 %d/%d\n", op, retry+1, throttleMaxRetry)
+	}
 	if err != nil {
 		handleRespClose(resp)
 		switch rt {
@@ -160,6 +181,15 @@ func (pcs *BaiduPCS) PrepareFilesDirectoriesBatchMeta(paths ...string) (dataRead
 
 // PrepareFilesDirectoriesList 获取目录下的文件和目录列表, 只返回服务器响应数据和错误信息
 func (pcs *BaiduPCS) PrepareFilesDirectoriesList(path string, options *OrderOptions) (dataReadCloser io.ReadCloser, pcsError pcserror.Error) {
+	return pcs.prepareFilesDirectoriesList(path, options, "0-2147483647")
+}
+
+// PrepareFilesDirectoriesListPage 分页获取目录下的文件和目录列表, 返回 [start, start+limit) 之间的条目, 只返回服务器响应数据和错误信息
+func (pcs *BaiduPCS) PrepareFilesDirectoriesListPage(path string, options *OrderOptions, start, limit int) (dataReadCloser io.ReadCloser, pcsError pcserror.Error) {
+	return pcs.prepareFilesDirectoriesList(path, options, strconv.Itoa(start)+"-"+strconv.Itoa(start+limit))
+}
+
+func (pcs *BaiduPCS) prepareFilesDirectoriesList(path string, options *OrderOptions, limit string) (dataReadCloser io.ReadCloser, pcsError pcserror.Error) {
 	pcs.lazyInit()
 	if options == nil {
 		options = DefaultOrderOptions
@@ -172,7 +202,7 @@ func (pcs *BaiduPCS) PrepareFilesDirectoriesList(path string, options *OrderOpti
 		"path":  path,
 		"by":    *(*string)(unsafe.Pointer(&options.By)),
 		"order": *(*string)(unsafe.Pointer(&options.Order)),
-		"limit": "0-2147483647",
+		"limit": limit,
 	})
 	baiduPCSVerbose.Infof("%s URL: %s\n", OperationFilesDirectoriesList, pcsURL)
 
@@ -0,0 +1,301 @@
+package baidupcs
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/qjfoidnh/BaiduPCS-Go/baidupcs/pcserror"
+	"github.com/qjfoidnh/BaiduPCS-Go/requester"
+	"github.com/qjfoidnh/BaiduPCS-Go/requester/rio"
+)
+
+type (
+	// APIClass 接口类别, 每个类别单独限速
+	APIClass int
+
+	// apiLimiter 令牌桶限速器, 服务器返回限流错误时自动降低速率
+	apiLimiter struct {
+		mu     sync.Mutex
+		rate   int // 每秒请求数, 0代表不限制
+		tokens float64
+		last   time.Time
+
+		throttleLevel int       // 退避等级, 每级速率减半
+		throttleTime  time.Time // 上次被限流或恢复一级的时间
+		pauseUntil    time.Time // 被限流后暂停请求直到此时
+	}
+)
+
+const (
+	// APIClassPCS PCS 接口, pcs.baidu.com 等
+	APIClassPCS APIClass = iota
+	// APIClassPan 网盘首页接口, pan.baidu.com
+	APIClassPan
+	// APIClassXPan 开放平台接口, pan.baidu.com/rest/2.0/xpan
+	APIClassXPan
+
+	numAPIClass = 3
+)
+
+const (
+	// DefaultAPIRatePCS PCS 接口默认每秒最大请求数
+	DefaultAPIRatePCS = 20
+	// DefaultAPIRatePan 网盘首页接口默认每秒最大请求数
+	DefaultAPIRatePan = 10
+	// DefaultAPIRateXPan 开放平台接口默认每秒最大请求数
+	DefaultAPIRateXPan = 10
+
+	throttleMaxLevel        = 4                // 最大退避等级, 即速率最低降为 1/16
+	throttleBaseRate        = 8                // 不限速的接口被限流后, 以此速率为基准退避
+	throttleRecoverInterval = 30 * time.Second // 未再被限流时, 每隔此时间恢复一级
+	throttleMaxRetry        = 3                // 被限流的请求最多重试的次数
+	throttleErrCode         = 31034            // 限流错误代码, 命中接口频控
+	maxReplayPostSize       = 1 << 20          // 为了被限流后重发, 读入内存的 post 数据的最大长度
+)
+
+var (
+	// unlimitedOperations 传输数据的请求, 不参与限速
+	unlimitedOperations = map[string]bool{
+		OperationUpload:             true,
+		OperationUploadTmpFile:      true,
+		OperationUploadSuperfile2:   true,
+		OperationDownloadFile:       true,
+		OperationDownloadStreamFile: true,
+	}
+)
+
+func (ac APIClass) String() string {
+	switch ac {
+	case APIClassPCS:
+		return "PCS"
+	case APIClassPan:
+		return "Pan"
+	case APIClassXPan:
+		return "XPan"
+	}
+	return "Unknown"
+}
+
+// SetAPIRate 设置接口类别每秒最大请求数, 0代表不限制
+func (pcs *BaiduPCS) SetAPIRate(class APIClass, rate int) {
+	if class < 0 || class >= numAPIClass {
+		return
+	}
+	l := &pcs.apiLimiters[class]
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if rate < 0 {
+		rate = 0
+	}
+	l.rate = rate
+}
+
+// getAPILimiter 根据请求地址获取限速器, 不参与限速的请求返回空
+func (pcs *BaiduPCS) getAPILimiter(op, urlStr string) *apiLimiter {
+	if unlimitedOperations[op] {
+		return nil
+	}
+	return &pcs.apiLimiters[classifyAPI(urlStr)]
+}
+
+// classifyAPI 根据请求地址判断接口类别
+func classifyAPI(urlStr string) APIClass {
+	u, err := url.Parse(urlStr)
+	if err != nil {
+		return APIClassPCS
+	}
+	if strings.HasPrefix(u.Path, "/rest/2.0/xpan/") {
+		return APIClassXPan
+	}
+	host := u.Hostname()
+	if host == PanBaiduCom || host == YunBaiduCom {
+		return APIClassPan
+	}
+	return APIClassPCS
+}
+
+type (
+	// replayPost 读入内存的 post 数据, 保留原数据的 Content-Type 和长度
+	replayPost struct {
+		io.Reader
+		contentType string
+		length      int64
+	}
+
+	// throttleBody 响应数据, 解析出限流错误时通知限速器退避
+	throttleBody struct {
+		io.ReadCloser
+		limiter *apiLimiter
+	}
+)
+
+// ContentType 实现 requester.ContentTyper
+func (rp *replayPost) ContentType() string {
+	return rp.contentType
+}
+
+// ContentLength 实现 requester.ContentLengther
+func (rp *replayPost) ContentLength() int64 {
+	return rp.length
+}
+
+// ObserveRemoteError 实现 pcserror.RemoteErrorObserver
+func (tb *throttleBody) ObserveRemoteError(errCode int) {
+	if errCode == throttleErrCode {
+		tb.limiter.backoff()
+	}
+}
+
+// makeReplayablePost 返回每次发送请求时使用的 post 数据.
+// io.Reader 类型的数据只能读取一次, 读入内存后才可以重发, 超过 maxReplayPostSize 时不重发.
+func makeReplayablePost(post interface{}) (makePost func() interface{}, repeatable bool) {
+	r, ok := post.(io.Reader)
+	if !ok {
+		return func() interface{} { return post }, true
+	}
+
+	var (
+		contentType string
+		length      int64
+	)
+	if ct, ok := post.(requester.ContentTyper); ok {
+		contentType = ct.ContentType()
+	}
+	switch value := post.(type) {
+	case requester.ContentLengther:
+		length = value.ContentLength()
+	case rio.Lener:
+		length = int64(value.Len())
+	case rio.Lener64:
+		length = value.Len()
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r, maxReplayPostSize+1))
+	if err != nil || len(data) > maxReplayPostSize {
+		// 无法完整读入内存, 只发送一次
+		once := &replayPost{
+			Reader:      io.MultiReader(bytes.NewReader(data), r),
+			contentType: contentType,
+			length:      length,
+		}
+		return func() interface{} { return once }, false
+	}
+
+	return func() interface{} {
+		return &replayPost{
+			Reader:      bytes.NewReader(data),
+			contentType: contentType,
+			length:      int64(len(data)),
+		}
+	}, true
+}
+
+// isThrottleError 是否为限流错误
+func isThrottleError(pcsError pcserror.Error) bool {
+	return pcsError != nil && pcsError.GetErrType() == pcserror.ErrTypeRemoteError && pcsError.GetRemoteErrCode() == throttleErrCode
+}
+
+// retryThrottled 执行 op, 返回限流错误时重试, 重试前由限速器等待.
+// 用于批量操作等容易被限流, 且解析响应后才能发现限流错误的请求.
+func retryThrottled(op string, fn func() pcserror.Error) (pcsError pcserror.Error) {
+	for retry := 0; ; retry++ {
+		pcsError = fn()
+		if !isThrottleError(pcsError) || retry >= throttleMaxRetry {
+			return pcsError
+		}
+		baiduPCSVerbose.Warnf("%s: 请求过于频繁, 降低请求速率后重试 %d/%d\n", op, retry+1, throttleMaxRetry)
+	}
+}
+
+// wait 等待获取令牌
+func (l *apiLimiter) wait() {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	d := l.reserve(time.Now())
+	l.mu.Unlock()
+	if d > 0 {
+		time.Sleep(d)
+	}
+}
+
+// reserve 预定一个令牌, 返回需要等待的时间, 调用时需持有锁
+func (l *apiLimiter) reserve(now time.Time) (d time.Duration) {
+	if l.throttleLevel > 0 && now.Sub(l.throttleTime) >= throttleRecoverInterval {
+		l.throttleLevel--
+		l.throttleTime = now
+	}
+
+	if now.Before(l.pauseUntil) {
+		d = l.pauseUntil.Sub(now)
+	}
+
+	rate := l.effectiveRate()
+	if rate <= 0 {
+		return d
+	}
+
+	burst := math.Max(1, rate)
+	if l.last.IsZero() {
+		l.tokens = burst
+	} else {
+		l.tokens = math.Min(burst, l.tokens+now.Sub(l.last).Seconds()*rate)
+	}
+	l.last = now
+	l.tokens--
+	if l.tokens < 0 {
+		if wait := time.Duration(-l.tokens / rate * float64(time.Second)); wait > d {
+			d = wait
+		}
+	}
+	return d
+}
+
+// effectiveRate 考虑退避后的实际速率, 0代表不限制
+func (l *apiLimiter) effectiveRate() float64 {
+	if l.throttleLevel == 0 {
+		return float64(l.rate)
+	}
+	base := float64(l.rate)
+	if base <= 0 {
+		base = throttleBaseRate
+	}
+	return base / float64(int(1)<<uint(l.throttleLevel))
+}
+
+// backoff 被限流, 降低速率并暂停一段时间
+func (l *apiLimiter) backoff() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	if l.throttleLevel < throttleMaxLevel {
+		l.throttleLevel++
+	}
+	l.throttleTime = now
+	l.pauseUntil = now.Add(time.Duration(l.throttleLevel) * time.Second)
+}
+
+// isThrottled 根据状态码检测响应是否为限流错误, 检测到时自动退避.
+// 状态码正常时, 包装 resp.Body, 由解析响应时发现的错误代码触发退避, 不预读响应.
+func (l *apiLimiter) isThrottled(resp *http.Response) bool {
+	if l == nil || resp == nil {
+		return false
+	}
+	if resp.StatusCode == http.StatusTooManyRequests {
+		l.backoff()
+		return true
+	}
+
+	resp.Body = &throttleBody{
+		ReadCloser: resp.Body,
+		limiter:    l,
+	}
+	return false
+}
@@ -0,0 +1,112 @@
+package baidupcs
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestAPILimiterRate(t *testing.T) {
+	var (
+		l  = &apiLimiter{rate: 10}
+		t0 = time.Now()
+	)
+
+	// 初始可以连续发出 rate 个请求, 之后按速率等待
+	for i := 0; i < 10; i++ {
+		if d := l.reserve(t0); d != 0 {
+			t.Fatalf("request %d waits %s", i, d)
+		}
+	}
+	if d := l.reserve(t0); d != 100*time.Millisecond {
+		t.Errorf("wait %s, want 100ms", d)
+	}
+
+	// 令牌随时间恢复
+	if d := l.reserve(t0.Add(time.Second)); d != 0 {
+		t.Errorf("wait %s after refill", d)
+	}
+
+	// 不限速时不等待
+	unlimited := &apiLimiter{}
+	for i := 0; i < 100; i++ {
+		if d := unlimited.reserve(t0); d != 0 {
+			t.Fatalf("unlimited request %d waits %s", i, d)
+		}
+	}
+}
+
+func TestAPILimiterBackoff(t *testing.T) {
+	l := &apiLimiter{rate: 16}
+
+	// 被限流后暂停请求, 每级速率减半, 最多退避 throttleMaxLevel 级
+	l.backoff()
+	now := time.Now()
+	if l.throttleLevel != 1 || l.effectiveRate() != 8 {
+		t.Errorf("level %d, rate %v", l.throttleLevel, l.effectiveRate())
+	}
+	if d := l.reserve(now); d < 900*time.Millisecond || d > time.Second {
+		t.Errorf("pause %s, want about 1s", d)
+	}
+	for i := 0; i < 10; i++ {
+		l.backoff()
+	}
+	if l.throttleLevel != throttleMaxLevel || l.effectiveRate() != 1 {
+		t.Errorf("level %d, rate %v", l.throttleLevel, l.effectiveRate())
+	}
+
+	// 未再被限流时, 每隔 throttleRecoverInterval 恢复一级
+	now = l.throttleTime
+	for level := throttleMaxLevel - 1; level >= 0; level-- {
+		now = now.Add(throttleRecoverInterval)
+		l.reserve(now)
+		if l.throttleLevel != level {
+			t.Errorf("level %d, want %d", l.throttleLevel, level)
+		}
+	}
+	if l.effectiveRate() != 16 {
+		t.Errorf("recovered rate %v", l.effectiveRate())
+	}
+
+	// 不限速的接口被限流后, 以 throttleBaseRate 为基准退避
+	unlimited := &apiLimiter{}
+	unlimited.backoff()
+	if unlimited.effectiveRate() != throttleBaseRate/2 {
+		t.Errorf("unlimited backoff rate %v", unlimited.effectiveRate())
+	}
+}
+
+func TestAPILimiterThrottled(t *testing.T) {
+	l := &apiLimiter{rate: 10}
+	if !l.isThrottled(&http.Response{StatusCode: http.StatusTooManyRequests}) || l.throttleLevel != 1 {
+		t.Errorf("429 not throttled, level %d", l.throttleLevel)
+	}
+
+	// 状态码正常时, 由解析出的限流错误代码触发退避
+	resp := &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}
+	if l.isThrottled(resp) {
+		t.Error("200 throttled")
+	}
+	observer := resp.Body.(*throttleBody)
+	observer.ObserveRemoteError(31066)
+	if l.throttleLevel != 1 {
+		t.Errorf("level %d after other error", l.throttleLevel)
+	}
+	observer.ObserveRemoteError(throttleErrCode)
+	if l.throttleLevel != 2 {
+		t.Errorf("level %d after throttle error", l.throttleLevel)
+	}
+}
+
+func TestClassifyAPI(t *testing.T) {
+	for urlStr, want := range map[string]APIClass{
+		"https://pcs.baidu.com/rest/2.0/pcs/file?method=list": APIClassPCS,
+		"https://pan.baidu.com/api/list":                      APIClassPan,
+		"https://pan.baidu.com/rest/2.0/xpan/file":            APIClassXPan,
+		"https://d.pcs.baidu.com/file/abc":                    APIClassPCS,
+	} {
+		if got := classifyAPI(urlStr); got != want {
+			t.Errorf("classifyAPI(%s) = %s, want %s", urlStr, got, want)
+		}
+	}
+}
@@ -2,26 +2,61 @@ package baidupcs
 
 import (
 	"github.com/qjfoidnh/BaiduPCS-Go/baidupcs/pcserror"
-	"path"
 )
 
-// Remove 批量删除文件/目录
+// Remove 批量删除文件/目录, 返回第一个遇到的错误
 func (pcs *BaiduPCS) Remove(paths ...string) (pcsError pcserror.Error) {
+	return pcs.BatchRemove(paths...).Err()
+}
+
+// BatchRemove 批量删除文件/目录, 自动分片执行, 返回每个路径的执行结果
+func (pcs *BaiduPCS) BatchRemove(paths ...string) (results BatchOpResultList) {
+	results = make(BatchOpResultList, len(paths))
+	for k := range paths {
+		results[k] = &BatchOpResult{
+			Path: paths[k],
+		}
+	}
+
+	pcs.runBatch(len(results), func(start, end int) {
+		chunk := results[start:end]
+		var info []*batchItemInfo
+		pcsError := retryThrottled(OperationRemove, func() (pcsError pcserror.Error) {
+			info, pcsError = pcs.remove(chunk.paths()...)
+			return
+		})
+		if pcsError == nil {
+			return
+		}
+
+		if len(chunk) == 1 || !isSplittableBatchError(pcsError) {
+			chunk.setError(pcsError)
+			return
+		}
+
+		if chunk.applyInfo(OperationRemove, info) {
+			return
+		}
+
+		// 服务器未给出每个路径的执行结果, 逐个重新删除, 找出出错的路径
+		for _, r := range chunk {
+			_, r.PCSError = pcs.remove(r.Path)
+		}
+	})
+
+	// 更新缓存
+	pcs.deleteCacheTree(results.allRelatedPaths())
+	return results
+}
+
+func (pcs *BaiduPCS) remove(paths ...string) (info []*batchItemInfo, pcsError pcserror.Error) {
 	dataReadCloser, pcsError := pcs.PrepareRemove(paths...)
 	if pcsError != nil {
 		return
 	}
 
 	defer dataReadCloser.Close()
-
-	errInfo := pcserror.DecodePCSJSONError(OperationRemove, dataReadCloser)
-	if errInfo != nil {
-		return errInfo
-	}
-
-	// 更新缓存
-	pcs.deleteCache(allRelatedDir(paths))
-	return nil
+	return decodeBatchError(OperationRemove, dataReadCloser)
 }
 
 // Mkdir 创建目录
@@ -39,6 +74,6 @@ func (pcs *BaiduPCS) Mkdir(pcspath string) (pcsError pcserror.Error) {
 	}
 
 	// 更新缓存
-	pcs.deleteCache([]string{path.Dir(pcspath)})
+	pcs.deleteCacheTree([]string{pcspath})
 	return
 }
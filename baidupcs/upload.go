@@ -6,7 +6,6 @@ import (
 	"github.com/qjfoidnh/BaiduPCS-Go/pcsutil/converter"
 	"net/http"
 	"net/url"
-	"path"
 	"strings"
 	"time"
 )
@@ -18,6 +17,8 @@ const (
 	MiddleUploadBlockSize = 16 * converter.MB
 	// MinUploadBlockSize 上传的文件分片最小大小
 	MinUploadBlockSize = 4 * converter.MB
+	// MaxUploadBlockCount 上传的文件分片数量上限, 与 MiddleUploadThreshold 和 MinUploadBlockSize 对应
+	MaxUploadBlockCount = 2048
 	// RecommendedUploadSize 推荐的最高文件上传大小
 	RecommendedUploadSize = 32 * converter.GB
 	// MaxUploadSize 目前支持的最大文件大小
@@ -106,7 +107,7 @@ func (pcs *BaiduPCS) RapidUpload(targetPath, policy, uploadid, contentMD5, slice
 	defer func() {
 		if pcsError == nil {
 			// 更新缓存
-			pcs.deleteCache([]string{path.Dir(targetPath)})
+			pcs.deleteCacheTree([]string{targetPath})
 		}
 	}()
 	pcsError, jsonData = pcs.rapidUploadV2(targetPath, policy, uploadid, strings.ToLower(contentMD5), strings.ToLower(sliceMD5), dataContent, crc32, offset, length, totalSize, dataTime, blockListMD5)
@@ -118,7 +119,7 @@ func (pcs *BaiduPCS) FakeRapidUpload(targetPath, policy string, length int64) (p
 	defer func() {
 		if pcsError == nil {
 			// 更新缓存
-			pcs.deleteCache([]string{path.Dir(targetPath)})
+			pcs.deleteCacheTree([]string{targetPath})
 		}
 	}()
 	if length <= MinUploadBlockSize {
@@ -218,7 +219,7 @@ func (pcs *BaiduPCS) UploadCreateSuperFile(uploadid, policy string, fileSize int
 	}
 
 	// 更新缓存, targetPath取了dir所以不受重命名策略影响
-	pcs.deleteCache([]string{path.Dir(targetPath)})
+	pcs.deleteCacheTree([]string{targetPath})
 	return nil
 }
 
@@ -0,0 +1,357 @@
+package baidupcs
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+
+	"github.com/qjfoidnh/BaiduPCS-Go/baidupcs/pcserror"
+)
+
+const (
+	// DefaultRecurseParallel 递归获取目录列表时, 默认同时进行的请求数量
+	DefaultRecurseParallel = 4
+	// DefaultWalkPrefetch 有序遍历时, 默认最多预读的目录数量
+	DefaultWalkPrefetch = 64
+)
+
+type (
+	// WalkOptions 并发递归获取目录列表可选项
+	WalkOptions struct {
+		OrderOptions *OrderOptions   // 排序, 为空则使用默认排序
+		Parallel     int             // 同时进行的列目录请求数量, 小于1则使用默认值
+		Ordered      bool            // 是否保证回调顺序与串行深度优先遍历一致
+		Prefetch     int             // 有序遍历时, 已获取或正在获取但尚未回调的目录的最大数量, 小于1则使用默认值
+		UseCache     bool            // 使用持久化缓存, 见 ListOptions
+		MaxDepth     int             // 回调的最大深度, 根目录的子项深度为1, 0代表不限制
+		KeepChildren bool            // 有序遍历时, 回调完成后保留子目录的子项, 以返回完整的目录树
+		Context      context.Context // 用于取消遍历, 可为空
+
+		// SkipDir 返回 true 则不获取该目录的子项, 目录本身仍会回调, 可为空.
+		// 可能被并发调用.
+		SkipDir func(fd *FileDirectory) bool
+	}
+
+	walker struct {
+		pcs      *BaiduPCS
+		opts     *WalkOptions
+		handle   HandleFileDirectoryFunc
+		ctx      context.Context
+		cancel   context.CancelFunc
+		sem      chan struct{}
+		wg       sync.WaitGroup
+		handleMu sync.Mutex
+
+		// 以下只在有序遍历时使用, 只由回调所在的协程访问
+		prefetch int           // 预读窗口大小
+		ahead    int           // 已开始获取但尚未回调的目录数量
+		stack    []*walkFrame  // 正在回调的目录, 栈顶为最深的目录
+		progress chan struct{} // 有目录获取完成时通知, 以便继续预读
+	}
+
+	// walkFrame 正在回调的目录, 以及下一个待进入的子目录
+	walkFrame struct {
+		node *walkNode
+		next int
+	}
+
+	// walkNode 待获取列表的目录
+	walkNode struct {
+		fd      *FileDirectory
+		path    string
+		depth   int
+		prebase string
+
+		done     chan struct{}
+		err      pcserror.Error
+		started  bool                         // 是否已开始获取, 只在有序遍历时使用
+		subNodes map[*FileDirectory]*walkNode // 子目录对应的节点, 只在有序遍历时使用
+		subList  []*walkNode                  // 子目录节点, 顺序与回调顺序一致, 只在有序遍历时使用
+	}
+)
+
+// SetRecurseParallel 设置递归获取目录列表时, 同时进行的请求数量
+func (pcs *BaiduPCS) SetRecurseParallel(parallel int) {
+	pcs.recurseParallel = parallel
+}
+
+func (pcs *BaiduPCS) getRecurseParallel() int {
+	if pcs.recurseParallel < 1 {
+		return DefaultRecurseParallel
+	}
+	return pcs.recurseParallel
+}
+
+// FilesDirectoriesWalk 并发递归获取目录下的文件和目录列表
+// 回调函数不会被并发调用, 返回 false 则取消遍历.
+// 无序遍历时, 子目录的回调可能早于同级的其他条目.
+// 有序遍历时, 按深度优先顺序预读有限数量的目录, 子目录回调完成后即释放其子项,
+// 返回的根目录子项中不包含更深的子项, 除非设置了 KeepChildren.
+func (pcs *BaiduPCS) FilesDirectoriesWalk(path string, opts *WalkOptions, handleFileDirectoryFunc HandleFileDirectoryFunc) (data FileDirectoryList) {
+	if opts == nil {
+		opts = &WalkOptions{}
+	}
+
+	fd, pcsError := pcs.filesDirectoriesMeta(path, opts.UseCache)
+	if pcsError != nil {
+		handleFileDirectoryFunc(0, path, nil, pcsError) // 传递错误
+		return nil
+	}
+
+	if !handleFileDirectoryFunc(0, path, fd, nil) {
+		return nil
+	}
+	if !fd.Isdir { // 不是一个目录
+		return FileDirectoryList{fd}
+	}
+
+	w := &walker{
+		pcs:    pcs,
+		opts:   opts,
+		handle: handleFileDirectoryFunc,
+	}
+	parallel := opts.Parallel
+	if parallel < 1 {
+		parallel = pcs.getRecurseParallel()
+	}
+	w.sem = make(chan struct{}, parallel)
+
+	ctx := opts.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	w.ctx, w.cancel = context.WithCancel(ctx)
+	defer w.cancel()
+
+	root := w.newNode(fd, path, 0, filepath.Base(path))
+	if opts.Ordered {
+		w.prefetch = opts.Prefetch
+		if w.prefetch < 1 {
+			w.prefetch = DefaultWalkPrefetch
+		}
+		w.progress = make(chan struct{}, 1)
+		w.emit(root)
+		w.cancel() // 停止预读
+	} else {
+		w.fetch(root)
+	}
+	w.wg.Wait()
+
+	return fd.Children
+}
+
+func (w *walker) newNode(fd *FileDirectory, path string, depth int, prebase string) *walkNode {
+	return &walkNode{
+		fd:      fd,
+		path:    path,
+		depth:   depth,
+		prebase: prebase,
+		done:    make(chan struct{}),
+	}
+}
+
+// isExceedDepth 子项是否超出最大深度
+func (w *walker) isExceedDepth(depth int) bool {
+	return w.opts.MaxDepth > 0 && depth > w.opts.MaxDepth
+}
+
+// shouldDescend 是否获取深度为 depth 的条目的子项
+func (w *walker) shouldDescend(fd *FileDirectory, depth int) bool {
+	if !fd.Isdir || w.isExceedDepth(depth+1) {
+		return false
+	}
+	return w.opts.SkipDir == nil || !w.opts.SkipDir(fd)
+}
+
+// fetch 异步获取目录列表
+func (w *walker) fetch(node *walkNode) {
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		defer w.notifyProgress()
+		defer close(node.done)
+
+		select {
+		case w.sem <- struct{}{}:
+		case <-w.ctx.Done():
+			return
+		}
+		fdl, pcsError := w.list(node.path)
+		<-w.sem
+
+		if pcsError != nil {
+			node.err = pcsError
+			if !w.opts.Ordered {
+				w.callHandle(node.depth, node.path, nil, pcsError)
+			}
+			return
+		}
+
+		for _, fd := range fdl {
+			fd.PreBase = node.prebase
+			fd.Parent = node.fd
+		}
+		node.fd.Children = fdl
+
+		if w.opts.Ordered {
+			// 记录子目录, 由 emit 按顺序预读和回调
+			node.subNodes = make(map[*FileDirectory]*walkNode)
+			for _, fd := range fdl {
+				if !w.shouldDescend(fd, node.depth+1) {
+					continue
+				}
+				subNode := w.newNode(fd, fd.Path, node.depth+1, filepath.Join(node.prebase, filepath.Base(fd.Path)))
+				node.subNodes[fd] = subNode
+				node.subList = append(node.subList, subNode)
+			}
+			return
+		}
+
+		for _, fd := range fdl {
+			if !w.callHandle(node.depth+1, fd.Path, fd, nil) {
+				return
+			}
+			if !w.shouldDescend(fd, node.depth+1) {
+				continue
+			}
+			w.fetch(w.newNode(fd, fd.Path, node.depth+1, filepath.Join(node.prebase, filepath.Base(fd.Path))))
+		}
+	}()
+}
+
+// notifyProgress 通知有目录获取完成, 只在有序遍历时使用
+func (w *walker) notifyProgress() {
+	if w.progress == nil {
+		return
+	}
+	select {
+	case w.progress <- struct{}{}:
+	default:
+	}
+}
+
+// start 开始获取目录列表, 只在有序遍历时使用
+func (w *walker) start(node *walkNode) {
+	if node.started {
+		return
+	}
+	node.started = true
+	w.ahead++
+	w.fetch(node)
+}
+
+// prefetchAhead 按深度优先顺序预读即将回调的目录, 直到预读窗口已满
+func (w *walker) prefetchAhead() {
+	budget := w.prefetch - w.ahead
+	for i := len(w.stack) - 1; i >= 0 && budget > 0; i-- {
+		frame := w.stack[i]
+		budget = w.prefetchNodes(frame.node.subList[frame.next:], budget)
+	}
+}
+
+// prefetchNodes 预读 nodes 及已获取完成的节点的子目录, 返回剩余的窗口大小
+func (w *walker) prefetchNodes(nodes []*walkNode, budget int) int {
+	for _, node := range nodes {
+		if budget <= 0 {
+			break
+		}
+		if !node.started {
+			w.start(node)
+			budget--
+			continue
+		}
+		select {
+		case <-node.done:
+			budget = w.prefetchNodes(node.subList, budget)
+		default:
+		}
+	}
+	return budget
+}
+
+// emit 按深度优先顺序回调, 只在有序遍历时使用
+func (w *walker) emit(node *walkNode) bool {
+	w.start(node)
+	for waiting := true; waiting; {
+		w.prefetchAhead()
+		select {
+		case <-node.done:
+			waiting = false
+		case <-w.progress:
+		case <-w.ctx.Done():
+			return false
+		}
+	}
+	w.ahead--
+
+	if w.ctx.Err() != nil {
+		return false
+	}
+
+	if node.err != nil {
+		return w.callHandle(node.depth, node.path, nil, node.err) // 传递错误
+	}
+
+	frame := &walkFrame{
+		node: node,
+	}
+	w.stack = append(w.stack, frame)
+	defer func() {
+		w.stack = w.stack[:len(w.stack)-1]
+		// 释放已回调完成的子树
+		node.subNodes, node.subList = nil, nil
+		if node.depth > 0 && !w.opts.KeepChildren {
+			node.fd.Children = nil
+		}
+	}()
+	w.prefetchAhead()
+
+	for _, fd := range node.fd.Children {
+		if !w.callHandle(node.depth+1, fd.Path, fd, nil) {
+			return false
+		}
+
+		subNode, ok := node.subNodes[fd]
+		if !ok {
+			continue
+		}
+		frame.next++
+		if !w.emit(subNode) {
+			return false
+		}
+	}
+	return true
+}
+
+func (w *walker) callHandle(depth int, fdPath string, fd *FileDirectory, pcsError pcserror.Error) bool {
+	w.handleMu.Lock()
+	defer w.handleMu.Unlock()
+	if w.ctx.Err() != nil {
+		return false
+	}
+
+	ok := w.handle(depth, fdPath, fd, pcsError)
+	if !ok {
+		w.cancel()
+	}
+	return ok
+}
+
+// list 分页获取目录的全部条目, 取消时中止
+func (w *walker) list(path string) (fdl FileDirectoryList, pcsError pcserror.Error) {
+	it := w.pcs.ListIter(path, &ListOptions{
+		OrderOptions: w.opts.OrderOptions,
+		UseCache:     w.opts.UseCache,
+	})
+	for w.ctx.Err() == nil {
+		page, pcsError := it.NextPage()
+		if pcsError != nil {
+			return nil, pcsError
+		}
+		fdl = append(fdl, page...)
+		if !it.HasMore() {
+			break
+		}
+	}
+	return fdl, nil
+}
@@ -0,0 +1,137 @@
+package baidupcs_test
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/qjfoidnh/BaiduPCS-Go/baidupcs"
+	"github.com/qjfoidnh/BaiduPCS-Go/baidupcs/pcserror"
+)
+
+var (
+	walkTree = []string{"/r/a/1", "/r/a/2", "/r/a/x/3", "/r/b/4", "/r/c", "/r/d/"}
+	// walkOrder 串行深度优先遍历的顺序
+	walkOrder = []string{"/r", "/r/a", "/r/a/1", "/r/a/2", "/r/a/x", "/r/a/x/3", "/r/b", "/r/b/4", "/r/c", "/r/d"}
+)
+
+// walkPaths 遍历 /r, 返回回调的路径, stop 返回 true 时取消遍历
+func walkPaths(t *testing.T, pcs *baidupcs.BaiduPCS, opts *baidupcs.WalkOptions, stop func(fdPath string) bool) (paths []string, data baidupcs.FileDirectoryList) {
+	data = pcs.FilesDirectoriesWalk("/r", opts, func(depth int, fdPath string, fd *baidupcs.FileDirectory, pcsError pcserror.Error) bool {
+		if pcsError != nil {
+			t.Errorf("%s: %s", fdPath, pcsError)
+			return false
+		}
+		paths = append(paths, fdPath)
+		return stop == nil || !stop(fdPath)
+	})
+	return
+}
+
+func TestWalkOrdered(t *testing.T) {
+	_, pcs := newFakePCS(t, walkTree...)
+
+	for _, prefetch := range []int{1, 2, 64} {
+		paths, data := walkPaths(t, pcs, &baidupcs.WalkOptions{
+			Parallel: 4,
+			Ordered:  true,
+			Prefetch: prefetch,
+		}, nil)
+		if !reflect.DeepEqual(paths, walkOrder) {
+			t.Errorf("prefetch %d: order %v, want %v", prefetch, paths, walkOrder)
+		}
+
+		// 子目录回调完成后释放其子项, 返回的列表只包含根目录的子项
+		if fileN, dirN := data.Count(); fileN != 1 || dirN != 3 {
+			t.Errorf("prefetch %d: count %d files, %d dirs", prefetch, fileN, dirN)
+		}
+	}
+}
+
+func TestWalkUnordered(t *testing.T) {
+	_, pcs := newFakePCS(t, walkTree...)
+
+	paths, data := walkPaths(t, pcs, &baidupcs.WalkOptions{Parallel: 4}, nil)
+	sort.Strings(paths)
+	want := append([]string(nil), walkOrder...)
+	sort.Strings(want)
+	if !reflect.DeepEqual(paths, want) {
+		t.Errorf("paths %v, want %v", paths, want)
+	}
+	if fileN, dirN := data.Count(); fileN != 5 || dirN != 4 {
+		t.Errorf("count %d files, %d dirs", fileN, dirN)
+	}
+}
+
+func TestWalkCancel(t *testing.T) {
+	_, pcs := newFakePCS(t, walkTree...)
+
+	// 回调返回 false 后不再回调
+	paths, _ := walkPaths(t, pcs, &baidupcs.WalkOptions{
+		Parallel: 4,
+		Ordered:  true,
+	}, func(fdPath string) bool {
+		return fdPath == "/r/a/x"
+	})
+	if want := walkOrder[:5]; !reflect.DeepEqual(paths, want) {
+		t.Errorf("paths %v, want %v", paths, want)
+	}
+
+	// 取消 Context 后不再回调
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	paths, _ = walkPaths(t, pcs, &baidupcs.WalkOptions{
+		Parallel: 1,
+		Ordered:  true,
+		Prefetch: 1,
+		Context:  ctx,
+	}, func(fdPath string) bool {
+		if fdPath == "/r/a" {
+			cancel()
+		}
+		return false
+	})
+	if want := walkOrder[:2]; !reflect.DeepEqual(paths, want) {
+		t.Errorf("paths after cancel %v, want %v", paths, want)
+	}
+}
+
+func TestWalkMaxDepthSkipDir(t *testing.T) {
+	_, pcs := newFakePCS(t, walkTree...)
+
+	paths, _ := walkPaths(t, pcs, &baidupcs.WalkOptions{
+		Ordered:  true,
+		MaxDepth: 2,
+		SkipDir: func(fd *baidupcs.FileDirectory) bool {
+			return fd.Path == "/r/b"
+		},
+	}, nil)
+	if want := []string{"/r", "/r/a", "/r/a/1", "/r/a/2", "/r/a/x", "/r/b", "/r/c", "/r/d"}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("paths %v, want %v", paths, want)
+	}
+}
+
+func TestFilesDirectoriesRecurseList(t *testing.T) {
+	_, pcs := newFakePCS(t, walkTree...)
+
+	var paths []string
+	data := pcs.FilesDirectoriesRecurseList("/r", nil, func(depth int, fdPath string, fd *baidupcs.FileDirectory, pcsError pcserror.Error) bool {
+		paths = append(paths, fdPath)
+		return true
+	})
+	if !reflect.DeepEqual(paths, walkOrder) {
+		t.Errorf("order %v, want %v", paths, walkOrder)
+	}
+
+	// 返回完整的目录树
+	if fileN, dirN := data.Count(); fileN != 5 || dirN != 4 {
+		t.Errorf("count %d files, %d dirs", fileN, dirN)
+	}
+	if size := data.TotalSize(); size != 30 {
+		t.Errorf("total size %d, want 30", size)
+	}
+	if all := data.AllFilePaths(); !reflect.DeepEqual(all, walkOrder[1:]) {
+		t.Errorf("all paths %v", all)
+	}
+}
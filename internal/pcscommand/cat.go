@@ -0,0 +1,56 @@
+package pcscommand
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/qjfoidnh/BaiduPCS-Go/internal/pcsfunctions/pcsdownload"
+)
+
+// RunCat 执行 将网盘文件依次输出到标准输出, 错误信息输出到标准错误
+func RunCat(paths []string, opts *pcsdownload.StreamOptions) {
+	paths, err := matchPathByShellPattern(paths...)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+
+	for _, p := range paths {
+		if !streamToStdout(p, opts) {
+			return
+		}
+	}
+}
+
+// RunHead 执行 输出网盘文件的前 n 个字节
+func RunHead(path string, n int64, opts *pcsdownload.StreamOptions) {
+	err := matchPathByShellPatternOnce(&path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	if n <= 0 {
+		return
+	}
+
+	if opts == nil {
+		opts = &pcsdownload.StreamOptions{}
+	}
+	opts.Length = n
+	streamToStdout(path, opts)
+}
+
+func streamToStdout(pcspath string, opts *pcsdownload.StreamOptions) bool {
+	// 每次使用新的参数, 避免修改调用方的值
+	var o pcsdownload.StreamOptions
+	if opts != nil {
+		o = *opts
+	}
+
+	err := pcsdownload.StreamFile(GetBaiduPCS(), pcspath, os.Stdout, &o)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", pcspath, err)
+		return false
+	}
+	return true
+}
@@ -110,25 +110,9 @@ func runCpMvOp(op string, paths ...string) {
 
 	switch op {
 	case "copy":
-		err = pcs.Copy(cj.List...)
-		if err != nil {
-			fmt.Println(err)
-			fmt.Println("操作失败, 以下文件/目录拷贝失败: ")
-			fmt.Println(cj)
-			return
-		}
-		fmt.Println("操作成功, 以下文件/目录拷贝成功: ")
-		fmt.Println(cj)
+		printBatchOpResult("拷贝", "以下文件/目录拷贝成功", pcs.BatchCopy(cj.List...))
 	case "move":
-		err = pcs.Move(cj.List...)
-		if err != nil {
-			fmt.Println(err)
-			fmt.Println("操作失败, 以下文件/目录移动失败: ")
-			fmt.Println(cj)
-			return
-		}
-		fmt.Println("操作成功, 以下文件/目录移动成功: ")
-		fmt.Println(cj)
+		printBatchOpResult("移动", "以下文件/目录移动成功", pcs.BatchMove(cj.List...))
 	default:
 		panic("Unknown operation:" + op)
 	}
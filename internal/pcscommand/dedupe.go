@@ -0,0 +1,392 @@
+package pcscommand
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/qjfoidnh/BaiduPCS-Go/baidupcs"
+	"github.com/qjfoidnh/BaiduPCS-Go/baidupcs/pcserror"
+	"github.com/qjfoidnh/BaiduPCS-Go/pcsliner"
+	"github.com/qjfoidnh/BaiduPCS-Go/pcstable"
+	"github.com/qjfoidnh/BaiduPCS-Go/pcsutil/converter"
+	"github.com/qjfoidnh/BaiduPCS-Go/pcsutil/pcstime"
+)
+
+const (
+	// DedupeKeepOldest 保留修改日期最早的文件
+	DedupeKeepOldest = "oldest"
+	// DedupeKeepNewest 保留修改日期最新的文件
+	DedupeKeepNewest = "newest"
+	// DedupeKeepShortest 保留路径最短的文件
+	DedupeKeepShortest = "shortest"
+
+	dedupeActionKeep   = "keep"
+	dedupeActionRemove = "remove"
+	dedupeActionMove   = "move"
+	dedupeActionFailed = "failed"
+)
+
+type (
+	// DedupeOptions 查找重复文件可选参数
+	DedupeOptions struct {
+		Keep        string      // 保留规则, oldest, newest, shortest, 为空且非交互时只列出重复文件
+		Interactive bool        // 逐组选择要保留的文件
+		Quarantine  string      // 非空时将多余的文件移动到该网盘目录, 保留原来的目录结构, 否则删除
+		Yes         bool        // 按规则处理时不再确认
+		JSONPath    string      // 导出结果为 JSON 的本地路径, - 为标准输出
+		Filter      *FileFilter // 过滤条件, 为空则检查全部文件
+	}
+
+	// dedupeFile 重复文件组中的文件
+	dedupeFile struct {
+		Path   string `json:"path"`
+		Ctime  int64  `json:"ctime"`
+		Mtime  int64  `json:"mtime"`
+		Action string `json:"action,omitempty"` // keep, remove, move, failed, 为空代表未处理
+		To     string `json:"to,omitempty"`     // 移动到的路径
+		Error  string `json:"error,omitempty"`
+	}
+
+	// dedupeGroup 大小和md5相同的一组文件
+	dedupeGroup struct {
+		Size  int64         `json:"size"`
+		MD5   string        `json:"md5"`
+		Files []*dedupeFile `json:"files"`
+	}
+
+	// dedupeReport 导出的查找结果
+	dedupeReport struct {
+		Root            string         `json:"root"`
+		ScannedFiles    int            `json:"scanned_files"`
+		DuplicateFiles  int            `json:"duplicate_files"`  // 每组除保留的一个以外的文件数
+		ReclaimableSize int64          `json:"reclaimable_size"` // 删除多余文件可释放的空间
+		Groups          []*dedupeGroup `json:"groups"`
+	}
+)
+
+// ParseDedupeKeep 检查保留规则
+func ParseDedupeKeep(keep string) (string, error) {
+	switch strings.ToLower(keep) {
+	case "":
+		return "", nil
+	case DedupeKeepOldest:
+		return DedupeKeepOldest, nil
+	case DedupeKeepNewest:
+		return DedupeKeepNewest, nil
+	case DedupeKeepShortest:
+		return DedupeKeepShortest, nil
+	}
+	return "", fmt.Errorf("未知的保留规则: %s, 可选 %s, %s, %s", keep, DedupeKeepOldest, DedupeKeepNewest, DedupeKeepShortest)
+}
+
+// RunDedupe 根据网盘记录的文件大小和md5, 查找目录中的重复文件, 可按规则或逐组选择保留的文件,
+// 删除或移动多余的文件
+func RunDedupe(root string, opt *DedupeOptions) {
+	if opt == nil {
+		opt = &DedupeOptions{}
+	}
+	err := matchPathByShellPatternOnce(&root)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	var (
+		pcs        = GetBaiduPCS()
+		quarantine string
+	)
+	if opt.Quarantine != "" {
+		quarantine = GetActiveUser().PathJoin(opt.Quarantine)
+	}
+
+	report, ok := findDuplicates(pcs, root, quarantine, opt.Filter)
+	if !ok {
+		return
+	}
+	if len(report.Groups) == 0 {
+		fmt.Printf("共检查 %d 个文件, 未找到重复文件\n", report.ScannedFiles)
+		exportDedupeReport(report, opt.JSONPath)
+		return
+	}
+
+	quit := false
+	for k, group := range report.Groups {
+		if quit {
+			break
+		}
+		printDedupeGroup(k, group)
+
+		keep := -1
+		if opt.Keep != "" {
+			keep = dedupeKeepIndex(group, opt.Keep)
+		}
+		if opt.Interactive {
+			keep, quit = promptDedupeKeep(group, keep)
+		}
+		if keep < 0 {
+			continue
+		}
+		for i, file := range group.Files {
+			if i == keep {
+				file.Action = dedupeActionKeep
+			} else if quarantine != "" {
+				file.Action = dedupeActionMove
+				file.To = path.Join(quarantine, file.Path)
+			} else {
+				file.Action = dedupeActionRemove
+			}
+		}
+		fmt.Printf("保留: %s\n\n", group.Files[keep].Path)
+	}
+	fmt.Printf("共检查 %d 个文件, 找到 %d 组重复文件, 多余的文件 %d 个, 共 %s\n", report.ScannedFiles, len(report.Groups), report.DuplicateFiles, converter.ConvertFileSize(report.ReclaimableSize, 2))
+
+	resolveDuplicates(pcs, report, opt)
+	exportDedupeReport(report, opt.JSONPath)
+}
+
+// findDuplicates 遍历目录, 按大小和md5分组, 返回包含两个及以上文件的组
+func findDuplicates(pcs *baidupcs.BaiduPCS, root, quarantine string, filter *FileFilter) (report *dedupeReport, ok bool) {
+	var (
+		groups     = map[string]*dedupeGroup{}
+		listFailed bool
+	)
+	report = &dedupeReport{
+		Root: root,
+	}
+
+	fmt.Printf("正在获取网盘目录: %s\n", root)
+	pcs.FilesDirectoriesWalk(root, &baidupcs.WalkOptions{
+		OrderOptions: baidupcs.DefaultOrderOptions,
+		Ordered:      true,
+		SkipDir: func(fd *baidupcs.FileDirectory) bool {
+			// 跳过隔离目录, 避免重复运行时再次找到已隔离的文件
+			if quarantine != "" && fd.Path == quarantine {
+				return true
+			}
+			return filter.SkipDir(filterRelPath(root, fd.Path))
+		},
+	}, func(depth int, fdPath string, fd *baidupcs.FileDirectory, pcsError pcserror.Error) bool {
+		if pcsError != nil {
+			fmt.Printf("获取目录 %s 错误, %s\n", fdPath, pcsError)
+			listFailed = true
+			return true
+		}
+		// 空文件的md5都相同, 不视为重复
+		if fd.Isdir || fd.Size == 0 || fd.MD5 == "" {
+			return true
+		}
+		if !filter.MatchFileDirectory(filterRelPath(root, fd.Path), fd) {
+			return true
+		}
+
+		report.ScannedFiles++
+		key := strconv.FormatInt(fd.Size, 10) + ":" + strings.ToLower(fd.MD5)
+		group, ok := groups[key]
+		if !ok {
+			group = &dedupeGroup{
+				Size: fd.Size,
+				MD5:  strings.ToLower(fd.MD5),
+			}
+			groups[key] = group
+		}
+		group.Files = append(group.Files, &dedupeFile{
+			Path:  fd.Path,
+			Ctime: fd.Ctime,
+			Mtime: fd.Mtime,
+		})
+		return true
+	})
+	if listFailed {
+		fmt.Println("获取网盘目录时发生错误, 结果可能不完整")
+	}
+	if report.ScannedFiles == 0 && listFailed {
+		return nil, false
+	}
+
+	for _, group := range groups {
+		if len(group.Files) < 2 {
+			continue
+		}
+		sort.Slice(group.Files, func(i, j int) bool {
+			return group.Files[i].Path < group.Files[j].Path
+		})
+		report.Groups = append(report.Groups, group)
+		report.DuplicateFiles += len(group.Files) - 1
+		report.ReclaimableSize += group.Size * int64(len(group.Files)-1)
+	}
+	// 可释放空间最多的组排在前面
+	sort.Slice(report.Groups, func(i, j int) bool {
+		gi, gj := report.Groups[i], report.Groups[j]
+		si, sj := gi.Size*int64(len(gi.Files)-1), gj.Size*int64(len(gj.Files)-1)
+		if si != sj {
+			return si > sj
+		}
+		return gi.Files[0].Path < gj.Files[0].Path
+	})
+	return report, true
+}
+
+func printDedupeGroup(k int, group *dedupeGroup) {
+	fmt.Printf("[%d] 文件大小: %s, md5: %s, %d 个文件\n", k+1, converter.ConvertFileSize(group.Size, 2), group.MD5, len(group.Files))
+	tb := pcstable.NewTable(os.Stdout)
+	tb.SetHeader([]string{"#", "修改日期", "路径"})
+	for i, file := range group.Files {
+		tb.Append([]string{strconv.Itoa(i), pcstime.FormatTime(file.Mtime), file.Path})
+	}
+	tb.Render()
+}
+
+// dedupeKeepIndex 按保留规则选择要保留的文件
+func dedupeKeepIndex(group *dedupeGroup, keep string) int {
+	best := 0
+	for i, file := range group.Files[1:] {
+		b := group.Files[best]
+		switch keep {
+		case DedupeKeepOldest:
+			if file.Mtime < b.Mtime {
+				best = i + 1
+			}
+		case DedupeKeepNewest:
+			if file.Mtime > b.Mtime {
+				best = i + 1
+			}
+		case DedupeKeepShortest:
+			if len(file.Path) < len(b.Path) {
+				best = i + 1
+			}
+		}
+	}
+	return best
+}
+
+// promptDedupeKeep 询问要保留的文件, def 为直接回车时的选择, 小于0代表跳过
+func promptDedupeKeep(group *dedupeGroup, def int) (keep int, quit bool) {
+	line := pcsliner.NewLiner()
+	defer line.Close()
+
+	prompt := "输入要保留的文件序号, 回车跳过此组, q 结束选择 > "
+	if def >= 0 {
+		prompt = fmt.Sprintf("输入要保留的文件序号, 回车保留 %d, s 跳过此组, q 结束选择 > ", def)
+	}
+	for {
+		input, err := line.State.Prompt(prompt)
+		if err != nil {
+			fmt.Printf("输入错误: %s\n", err)
+			return -1, true
+		}
+		switch input = strings.TrimSpace(input); input {
+		case "":
+			return def, false
+		case "s", "S":
+			return -1, false
+		case "q", "Q":
+			return -1, true
+		}
+		i, err := strconv.Atoi(input)
+		if err != nil || i < 0 || i >= len(group.Files) {
+			fmt.Printf("序号错误, 请输入 0 ~ %d\n", len(group.Files)-1)
+			continue
+		}
+		return i, false
+	}
+}
+
+// resolveDuplicates 删除或移动标记为多余的文件, 并记录结果
+func resolveDuplicates(pcs *baidupcs.BaiduPCS, report *dedupeReport, opt *DedupeOptions) {
+	var (
+		extras []*dedupeFile
+		size   int64
+	)
+	for _, group := range report.Groups {
+		for _, file := range group.Files {
+			if file.Action == dedupeActionRemove || file.Action == dedupeActionMove {
+				extras = append(extras, file)
+				size += group.Size
+			}
+		}
+	}
+	if len(extras) == 0 {
+		return
+	}
+
+	opName := "删除"
+	if opt.Quarantine != "" {
+		opName = "移动到 " + GetActiveUser().PathJoin(opt.Quarantine)
+	}
+	if !opt.Interactive && !opt.Yes {
+		line := pcsliner.NewLiner()
+		y, err := line.State.Prompt(fmt.Sprintf("确认%s %d 个多余的文件, 共 %s ? (y/n) > ", opName, len(extras), converter.ConvertFileSize(size, 2)))
+		line.Close()
+		if err != nil || (y != "y" && y != "Y") {
+			fmt.Printf("已取消\n")
+			for _, file := range extras {
+				file.Action, file.To = "", ""
+			}
+			return
+		}
+	}
+
+	var results baidupcs.BatchOpResultList
+	if opt.Quarantine == "" {
+		paths := make([]string, len(extras))
+		for k, file := range extras {
+			paths[k] = file.Path
+		}
+		results = pcs.BatchRemove(paths...)
+		printBatchOpResult("删除", "以下文件已删除, 可在网盘文件回收站找回", results)
+	} else {
+		var (
+			cj   = make([]*baidupcs.CpMvJSON, len(extras))
+			dirs = map[string]bool{}
+		)
+		for k, file := range extras {
+			cj[k] = &baidupcs.CpMvJSON{
+				From: file.Path,
+				To:   file.To,
+			}
+			dirs[path.Dir(file.To)] = true
+		}
+		// 目录已存在时会出错, 忽略, 移动失败时会报告
+		for dir := range dirs {
+			pcs.Mkdir(dir)
+		}
+		results = pcs.BatchMove(cj...)
+		printBatchOpResult("移动", "以下文件已移动到隔离目录", results)
+	}
+
+	for k, r := range results {
+		if r.PCSError != nil {
+			extras[k].Action = dedupeActionFailed
+			extras[k].Error = r.PCSError.Error()
+		}
+	}
+}
+
+// exportDedupeReport 将结果导出为 JSON
+func exportDedupeReport(report *dedupeReport, jsonPath string) {
+	if jsonPath == "" {
+		return
+	}
+	if report.Groups == nil {
+		report.Groups = []*dedupeGroup{}
+	}
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		fmt.Printf("导出结果错误: %s\n", err)
+		return
+	}
+	if jsonPath == "-" {
+		fmt.Printf("%s\n", data)
+		return
+	}
+	err = os.WriteFile(jsonPath, append(data, '\n'), 0644)
+	if err != nil {
+		fmt.Printf("导出结果错误: %s\n", err)
+		return
+	}
+	fmt.Printf("结果已导出到: %s\n", jsonPath)
+}
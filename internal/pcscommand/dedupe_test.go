@@ -0,0 +1,202 @@
+package pcscommand
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/qjfoidnh/BaiduPCS-Go/baidupcs"
+	"github.com/qjfoidnh/BaiduPCS-Go/requester"
+)
+
+type fakeDedupeFile struct {
+	size  int64
+	md5   string
+	mtime int64
+}
+
+// fakeDedupeServer 模拟网盘的元信息和目录列表接口, 上级目录自动视为存在
+type fakeDedupeServer map[string]*fakeDedupeFile
+
+func (fs fakeDedupeServer) isDir(p string) bool {
+	for filePath := range fs {
+		if strings.HasPrefix(filePath, strings.TrimSuffix(p, "/")+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func (fs fakeDedupeServer) entry(p string) map[string]interface{} {
+	m := map[string]interface{}{
+		"fs_id":           len(p),
+		"path":            p,
+		"server_filename": path.Base(p),
+		"isdir":           1,
+	}
+	if f, ok := fs[p]; ok {
+		m["isdir"], m["size"], m["md5"], m["mtime"] = 0, f.size, f.md5, f.mtime
+	}
+	return m
+}
+
+func (fs fakeDedupeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var list []interface{}
+	switch r.URL.Query().Get("method") {
+	case "meta":
+		var param struct {
+			List []struct {
+				Path string `json:"path"`
+			} `json:"list"`
+		}
+		json.Unmarshal([]byte(r.FormValue("param")), &param)
+		for _, item := range param.List {
+			if !fs.isDir(item.Path) {
+				io.WriteString(w, `{"error_code":31066,"error_msg":"file does not exist"}`)
+				return
+			}
+			list = append(list, fs.entry(item.Path))
+		}
+	case "list":
+		dir := r.URL.Query().Get("path")
+		children := map[string]bool{}
+		for filePath := range fs {
+			for p := filePath; p != "/"; p = path.Dir(p) {
+				if path.Dir(p) == dir {
+					children[p] = true
+				}
+			}
+		}
+		names := make([]string, 0, len(children))
+		for p := range children {
+			names = append(names, p)
+		}
+		sort.Strings(names)
+		for _, p := range names {
+			list = append(list, fs.entry(p))
+		}
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	data, _ := json.Marshal(map[string]interface{}{"list": list})
+	w.Write(data)
+}
+
+func newDedupeTestPCS(t *testing.T, fs fakeDedupeServer) *baidupcs.BaiduPCS {
+	srv := httptest.NewServer(fs)
+	requester.SetGlobalProxy(srv.Listener.Addr().String())
+	t.Cleanup(func() {
+		requester.SetGlobalProxy("")
+		srv.Close()
+	})
+
+	pcs := baidupcs.NewPCS(0, "")
+	pcs.SetPanUserAgent(baidupcs.NetdiskUA)
+	pcs.GetClient()
+	pcs.SetHTTPS(false)
+	pcs.SetStaticPCSAddr(true)
+	return pcs
+}
+
+func dedupePaths(group *dedupeGroup) string {
+	paths := make([]string, len(group.Files))
+	for k, file := range group.Files {
+		paths[k] = file.Path
+	}
+	return strings.Join(paths, ",")
+}
+
+func TestFindDuplicates(t *testing.T) {
+	const (
+		md5A = "0cc175b9c0f1b6a831c399e269772661"
+		md5B = "92eb5ffee6ae2fec3ad71c777531578f"
+		md5C = "4a8a08f09d37b73795649038408b5f33"
+	)
+	pcs := newDedupeTestPCS(t, fakeDedupeServer{
+		"/r/a.txt":            {3, md5A, 2},
+		"/r/sub/b.txt":        {3, md5A, 1},
+		"/r/sub/deep/c.txt":   {3, md5A, 3},
+		"/r/big1":             {100, md5B, 1},
+		"/r/big2":             {100, strings.ToUpper(md5B), 1},
+		"/r/same-md5-size":    {4, md5A, 1}, // 大小不同, 不是重复文件
+		"/r/unique":           {5, md5C, 1},
+		"/r/empty1":           {0, md5C, 1}, // 空文件不视为重复
+		"/r/empty2":           {0, md5C, 1},
+		"/r/quarantine/a.txt": {3, md5A, 1}, // 隔离目录中的文件不参与查找
+	})
+
+	report, ok := findDuplicates(pcs, "/r", "/r/quarantine", nil)
+	if !ok {
+		t.Fatal("find failed")
+	}
+	if report.ScannedFiles != 7 || report.DuplicateFiles != 3 || report.ReclaimableSize != 106 {
+		t.Errorf("scanned %d, duplicates %d, reclaimable %d", report.ScannedFiles, report.DuplicateFiles, report.ReclaimableSize)
+	}
+
+	// 可释放空间多的组在前, 组内按路径排序, md5 不区分大小写
+	if len(report.Groups) != 2 {
+		t.Fatalf("%d groups", len(report.Groups))
+	}
+	if got := dedupePaths(report.Groups[0]); got != "/r/big1,/r/big2" || report.Groups[0].MD5 != md5B {
+		t.Errorf("group 0: %s, %s", got, report.Groups[0].MD5)
+	}
+	group := report.Groups[1]
+	if got := dedupePaths(group); got != "/r/a.txt,/r/sub/b.txt,/r/sub/deep/c.txt" {
+		t.Errorf("group 1: %s", got)
+	}
+
+	// 保留规则
+	for keep, want := range map[string]int{
+		DedupeKeepOldest:   1,
+		DedupeKeepNewest:   2,
+		DedupeKeepShortest: 0,
+	} {
+		if got := dedupeKeepIndex(group, keep); got != want {
+			t.Errorf("keep %s: %d, want %d", keep, got, want)
+		}
+	}
+	// 相同时保留第一个
+	if got := dedupeKeepIndex(report.Groups[0], DedupeKeepOldest); got != 0 {
+		t.Errorf("keep oldest with same mtime: %d", got)
+	}
+
+	// 过滤的文件不参与查找
+	filter, err := NewFileFilter(&FileFilterOptions{Excludes: []string{"sub"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, ok = findDuplicates(pcs, "/r", "", filter)
+	if !ok || len(report.Groups) != 2 {
+		t.Fatalf("filtered: %v, %+v", ok, report)
+	}
+	if got := dedupePaths(report.Groups[1]); got != "/r/a.txt,/r/quarantine/a.txt" {
+		t.Errorf("filtered group: %s", got)
+	}
+
+	// 目录不存在
+	if _, ok = findDuplicates(pcs, "/missing", "", nil); ok {
+		t.Error("missing root found")
+	}
+}
+
+func TestParseDedupeKeep(t *testing.T) {
+	for keep, want := range map[string]string{
+		"":         "",
+		"Oldest":   DedupeKeepOldest,
+		"newest":   DedupeKeepNewest,
+		"SHORTEST": DedupeKeepShortest,
+	} {
+		if got, err := ParseDedupeKeep(keep); err != nil || got != want {
+			t.Errorf("ParseDedupeKeep(%q) = %q, %v", keep, got, err)
+		}
+	}
+	if _, err := ParseDedupeKeep("largest"); err == nil {
+		t.Error("unknown rule accepted")
+	}
+}
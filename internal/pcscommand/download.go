@@ -5,6 +5,7 @@ import (
 	"github.com/qjfoidnh/BaiduPCS-Go/baidupcs"
 	"github.com/qjfoidnh/BaiduPCS-Go/baidupcs/pcserror"
 	"github.com/qjfoidnh/BaiduPCS-Go/internal/pcsconfig"
+	"github.com/qjfoidnh/BaiduPCS-Go/internal/pcsfunctions"
 	"github.com/qjfoidnh/BaiduPCS-Go/internal/pcsfunctions/pcsdownload"
 	"github.com/qjfoidnh/BaiduPCS-Go/pcstable"
 	"github.com/qjfoidnh/BaiduPCS-Go/pcsutil/converter"
@@ -12,9 +13,11 @@ import (
 	"github.com/qjfoidnh/BaiduPCS-Go/requester/downloader"
 	"github.com/qjfoidnh/BaiduPCS-Go/requester/transfer"
 	"os"
+	"path"
 	"path/filepath"
 	"runtime"
 	"sort"
+	"strconv"
 )
 
 type (
@@ -30,9 +33,13 @@ type (
 		Load                 int
 		MaxRetry             int
 		NoCheck              bool
+		Verify               string // 下载完成后的校验方式, none, size, md5, full, 为空时由 NoCheck 决定
+		Sidecars             int    // 下载完成后生成的校验文件, 见 pcsdownload.SidecarMD5
 		ModifyMTime          bool
 		FullPath             bool
 		LinkPrefer           int
+		ResumeQueue          bool        // 继续下载队列中未完成的文件, 忽略输入的路径
+		Filter               *FileFilter // 过滤条件, 为空则下载全部文件
 	}
 
 	// LocateDownloadOption 获取下载链接可选参数
@@ -48,8 +55,16 @@ func downloadPrintFormat(load int) string {
 	return "[%s] ↓ %s/%s %s/s in %s, left %s ...\n"
 }
 
-// RunDownload 执行下载网盘内文件
-func RunDownload(paths []string, options *DownloadOptions) {
+// downloadFile 待下载的文件或目录
+type downloadFile struct {
+	pcsPath  string
+	savePath string
+	size     int64
+	fd       *baidupcs.FileDirectory // 为空则在下载前获取
+}
+
+// fixDownloadOptions 填充下载参数的默认值
+func fixDownloadOptions(options *DownloadOptions) *DownloadOptions {
 	if options == nil {
 		options = &DownloadOptions{}
 	}
@@ -66,60 +81,230 @@ func RunDownload(paths []string, options *DownloadOptions) {
 		options.NoCheck = pcsconfig.Config.NoCheck
 	}
 
+	// 未指定校验方式时, 禁用md5校验则只校验大小
+	if options.Verify == "" {
+		if options.NoCheck {
+			options.Verify = pcsdownload.VerifyModeSize.String()
+		} else {
+			options.Verify = pcsdownload.VerifyModeMD5.String()
+		}
+	}
+
 	if runtime.GOOS == "windows" {
 		// windows下不加执行权限
 		options.IsExecutedPermission = false
 	}
 
-	// 设置下载配置
-	cfg := &downloader.Config{
-		Mode:                       transfer.RangeGenMode_BlockSize,
-		CacheSize:                  pcsconfig.Config.CacheSize,
-		BlockSize:                  baidupcs.InitRangeSize,
-		MaxRate:                    pcsconfig.Config.MaxDownloadRate,
-		InstanceStateStorageFormat: downloader.InstanceStateStorageFormatProto3,
-		IsTest:                     options.IsTest,
-		TryHTTP:                    !pcsconfig.Config.EnableHTTPS,
-	}
-
 	// 设置下载最大并发量
 	if options.Parallel < 1 {
 		options.Parallel = pcsconfig.Config.MaxParallel
 	}
+	return options
+}
 
-	paths, err := matchPathByShellPattern(paths...)
+// openDownloadQueue 打开下载队列数据库, 测试下载或打开失败时返回空
+func openDownloadQueue(options *DownloadOptions) *pcsdownload.DownloadQueueDatabase {
+	// 测试下载不记录下载队列
+	if options.IsTest {
+		return nil
+	}
+	queueDB, err := pcsdownload.NewDownloadQueueDatabase()
 	if err != nil {
-		fmt.Println(err)
-		return
+		fmt.Printf("打开下载队列数据库错误: %s, 本次下载不记录队列\n", err)
+		return nil
 	}
+	return queueDB
+}
 
-	fmt.Print("\n")
-	fmt.Printf("[0] 提示: 当前下载最大并发量为: %d, 下载缓存为: %d\n", options.Parallel, cfg.CacheSize)
+// RunDownload 执行下载网盘内文件
+func RunDownload(paths []string, options *DownloadOptions) {
+	options = fixDownloadOptions(options)
 
 	var (
-		pcs       = GetBaiduPCS()
-		loadCount = 0
+		pcs     = GetBaiduPCS()
+		uid     = GetActiveUser().UID
+		queueDB = openDownloadQueue(options)
+		files   []*downloadFile
+		err     error
 	)
+	if queueDB != nil {
+		defer queueDB.Close()
+	}
+
+	if options.ResumeQueue {
+		if queueDB == nil {
+			fmt.Println("无法读取下载队列")
+			return
+		}
+		queueItems := queueDB.List(uid, pcsdownload.QueueStatusPending)
+		if len(queueItems) == 0 {
+			fmt.Println("下载队列中没有未完成的文件")
+			return
+		}
+		for _, item := range queueItems {
+			files = append(files, &downloadFile{
+				pcsPath:  item.PcsPath,
+				savePath: item.SavePath,
+				size:     item.Size,
+			})
+		}
+		runDownloadFiles(files, options, queueDB, false)
+		return
+	}
+
+	paths, err = matchPathByShellPattern(paths...)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
 
-	// 预测要下载的文件数量
-	file_dir_list := make([]*baidupcs.FileDirectory,0,10)
 	for k := range paths {
-		pcs.FilesDirectoriesRecurseList(paths[k], baidupcs.DefaultOrderOptions, func(depth int, _ string, fd *baidupcs.FileDirectory, pcsError pcserror.Error) bool {
+		root := paths[k]
+		pcs.FilesDirectoriesWalk(root, &baidupcs.WalkOptions{
+			OrderOptions: baidupcs.DefaultOrderOptions,
+			Ordered:      true,
+			SkipDir: func(fd *baidupcs.FileDirectory) bool {
+				return options.Filter.SkipDir(filterRelPath(root, fd.Path))
+			},
+		}, func(depth int, _ string, fd *baidupcs.FileDirectory, pcsError pcserror.Error) bool {
 			if pcsError != nil {
 				pcsCommandVerbose.Warnf("%s\n", pcsError)
 				return true
 			}
-			file_dir_list = append(file_dir_list, fd)
-			// 忽略统计文件夹数量
-			if !fd.Isdir {
-				loadCount++
-				if loadCount >= options.Load {
-					loadCount = options.Load
-				}
+
+			// 有过滤条件时只下载满足条件的文件, 不创建目录
+			if !options.Filter.IsEmpty() && (fd.Isdir || !options.Filter.MatchFileDirectory(filterRelPath(root, fd.Path), fd)) {
+				return true
+			}
+
+			// 设置储存的路径
+			var savePath string
+			vPath := fd.Path
+			if !options.FullPath {
+				vPath = filepath.Join(fd.PreBase, filepath.Base(fd.Path))
 			}
+			if options.SaveTo != "" {
+				savePath = filepath.Join(options.SaveTo, vPath)
+			} else {
+				// 使用默认的保存路径
+				savePath = GetActiveUser().GetSavePath(vPath)
+			}
+			files = append(files, &downloadFile{
+				pcsPath:  fd.Path,
+				savePath: savePath,
+				size:     fd.Size,
+				fd:       fd,
+			})
 			return true
 		})
 	}
+
+	splits := expandSplitManifests(pcs, &files)
+	runDownloadFiles(files, options, queueDB, true)
+	if !options.IsTest {
+		joinSplitDownloads(splits, options.IsOverwrite)
+	}
+}
+
+// splitDownload 下载的分卷清单, 下载结束后合并分卷
+type splitDownload struct {
+	dir      string // 本地保存分卷的目录
+	manifest *pcsfunctions.SplitManifest
+}
+
+// expandSplitManifests 读取要下载的分卷清单, 将清单中未加入下载的分卷加入下载
+func expandSplitManifests(pcs *baidupcs.BaiduPCS, files *[]*downloadFile) (splits []*splitDownload) {
+	pcsPaths := make(map[string]bool, len(*files))
+	for _, f := range *files {
+		pcsPaths[f.pcsPath] = true
+	}
+
+	for _, f := range *files {
+		if f.fd == nil || f.fd.Isdir || !pcsfunctions.IsSplitManifest(f.fd.Filename) {
+			continue
+		}
+		m, err := pcsdownload.FetchSplitManifest(pcs, f.pcsPath)
+		if err != nil {
+			fmt.Printf("读取分卷清单 %s 错误: %s, 不合并分卷\n", f.pcsPath, err)
+			continue
+		}
+
+		dir := filepath.Dir(f.savePath)
+		for _, part := range m.Parts {
+			partPath := path.Join(path.Dir(f.pcsPath), part.Name)
+			if pcsPaths[partPath] {
+				continue
+			}
+			pcsPaths[partPath] = true
+			*files = append(*files, &downloadFile{
+				pcsPath:  partPath,
+				savePath: filepath.Join(dir, part.Name),
+				size:     part.Size,
+			})
+		}
+		splits = append(splits, &splitDownload{
+			dir:      dir,
+			manifest: m,
+		})
+	}
+	return
+}
+
+// joinSplitDownloads 合并已下载的分卷, 并校验原文件的md5
+func joinSplitDownloads(splits []*splitDownload, isOverwrite bool) {
+	for _, sd := range splits {
+		outPath := filepath.Join(sd.dir, sd.manifest.Name)
+		if _, err := os.Stat(outPath); err == nil && !isOverwrite {
+			fmt.Printf("文件已存在: %s, 跳过合并分卷\n", outPath)
+			continue
+		}
+
+		fmt.Printf("合并 %d 个分卷: %s\n", len(sd.manifest.Parts), outPath)
+		_, err := pcsdownload.JoinSplitParts(sd.dir, sd.manifest)
+		if err != nil {
+			fmt.Printf("合并分卷失败: %s, %s\n", outPath, err)
+			continue
+		}
+		fmt.Printf("合并分卷成功, md5校验通过: %s\n", outPath)
+	}
+}
+
+// runDownloadFiles 下载已确定保存路径的文件, enqueue 为 true 时先将文件加入下载队列, 返回下载失败的数量
+func runDownloadFiles(files []*downloadFile, options *DownloadOptions, queueDB *pcsdownload.DownloadQueueDatabase, enqueue bool) (failed int) {
+	// 设置下载配置
+	cfg := &downloader.Config{
+		Mode:                       transfer.RangeGenMode_BlockSize,
+		CacheSize:                  pcsconfig.Config.CacheSize,
+		BlockSize:                  baidupcs.InitRangeSize,
+		MaxRate:                    pcsconfig.Config.MaxDownloadRate,
+		MaxRateFunc:                pcsconfig.Config.DownloadRateFunc(),
+		InstanceStateStorageFormat: downloader.InstanceStateStorageFormatProto3,
+		IsTest:                     options.IsTest,
+		TryHTTP:                    !pcsconfig.Config.EnableHTTPS,
+		Adaptive:                   pcsconfig.Config.AdaptiveParallel,
+		MinParallel:                pcsconfig.Config.MinParallel,
+	}
+
+	var (
+		pcs       = GetBaiduPCS()
+		uid       = GetActiveUser().UID
+		loadCount = 0
+		err       error
+	)
+
+	fmt.Print("\n")
+	fmt.Printf("[0] 提示: 当前下载最大并发量为: %d, 下载缓存为: %d\n", options.Parallel, cfg.CacheSize)
+
+	// 预测要下载的文件数量, 忽略统计文件夹数量
+	for _, f := range files {
+		if f.fd == nil || !f.fd.Isdir {
+			loadCount++
+		}
+	}
+	if loadCount > options.Load {
+		loadCount = options.Load
+	}
+
 	// 修改Load, 设置MaxParallel
 	if loadCount > 0 {
 		options.Load = loadCount
@@ -128,21 +313,34 @@ func RunDownload(paths []string, options *DownloadOptions) {
 	} else {
 		cfg.MaxParallel = options.Parallel
 	}
+	if cfg.Adaptive {
+		fmt.Printf("[0] 提示: 已启用自适应下载并发量, 单个文件的并发量在 %d ~ %d 之间调整\n", min(cfg.MinParallel, cfg.MaxParallel), cfg.MaxParallel)
+	}
 
 	var (
 		executor = taskframework.TaskExecutor{
 			IsFailedDeque: true, // 统计失败的列表
 		}
-		statistic = &pcsdownload.DownloadStatistic{}
+		statistic  = &pcsdownload.DownloadStatistic{}
+		queueItems = make([]*pcsdownload.DownloadQueueItem, 0, len(files))
 	)
 
+	// 设置下载并发数
+	executor.SetParallel(loadCount)
+
+	verifyMode, err := pcsdownload.ParseVerifyMode(options.Verify)
+	if err != nil {
+		fmt.Println(err)
+		return len(files)
+	}
+
 	// 处理队列, 小文件优先下载
-	sort.Slice(file_dir_list, func(i, j int) bool {
-		return file_dir_list[i].Size < file_dir_list[j].Size
+	sort.SliceStable(files, func(i, j int) bool {
+		return files[i].size < files[j].size
 	})
-	for _,v := range file_dir_list {
+	for _, f := range files {
 		newCfg := *cfg
-		unit := pcsdownload.DownloadTaskUnit{
+		unit := &pcsdownload.DownloadTaskUnit{
 			Cfg:                  &newCfg, // 复制一份新的cfg
 			PCS:                  pcs,
 			VerbosePrinter:       pcsCommandVerbose,
@@ -152,28 +350,46 @@ func RunDownload(paths []string, options *DownloadOptions) {
 			IsPrintStatus:        options.IsPrintStatus,
 			IsExecutedPermission: options.IsExecutedPermission,
 			IsOverwrite:          options.IsOverwrite,
-			NoCheck:              options.NoCheck,
+			VerifyMode:           verifyMode,
+			Sidecars:             options.Sidecars,
 			DlinkPrefer:          options.LinkPrefer,
 			DownloadMode:         options.DownloadMode,
 			ModifyMTime:          options.ModifyMTime,
-			PcsPath:              v.Path,
-			FileInfo:             v,
-		}
-		// 设置下载并发数
-		executor.SetParallel(loadCount)
-		// 设置储存的路径
-		vPath := v.Path
-		if !options.FullPath {
-			vPath = filepath.Join(v.PreBase, filepath.Base(v.Path))
-		}
-		if options.SaveTo != "" {
-			unit.SavePath = filepath.Join(options.SaveTo, vPath)
-		} else {
-			// 使用默认的保存路径
-			unit.SavePath = GetActiveUser().GetSavePath(vPath)
+			QueueDatabase:        queueDB,
+			UID:                  uid,
+			PcsPath:              f.pcsPath,
+			SavePath:             f.savePath,
+			FileInfo:             f.fd,
+		}
+		info := executor.Append(unit, options.MaxRetry)
+		if !enqueue {
+			fmt.Printf("[%s] 从下载队列恢复: %s\n", info.Id(), f.pcsPath)
+			continue
+		}
+		fmt.Printf("[%s] 加入下载队列: %s\n", info.Id(), f.pcsPath)
+
+		// 目录只在本地创建, 不记录到下载队列, 继续下载时由其中的文件创建
+		if f.fd != nil && f.fd.Isdir {
+			continue
+		}
+
+		queueItems = append(queueItems, &pcsdownload.DownloadQueueItem{
+			UID:      uid,
+			PcsPath:  f.pcsPath,
+			SavePath: f.savePath,
+			Size:     f.size,
+		})
+	}
+
+	// 记录下载队列, 程序中途退出后可通过 download --resume-queue 继续
+	if queueDB != nil {
+		if enqueue {
+			queueDB.Enqueue(queueItems...)
+		}
+		err = queueDB.Save()
+		if err != nil {
+			fmt.Printf("保存下载队列错误: %s\n", err)
 		}
-		info := executor.Append(&unit, options.MaxRetry)
-		fmt.Printf("[%s] 加入下载队列: %s\n", info.Id(), v.Path)
 	}
 
 	// 开始计时
@@ -182,11 +398,19 @@ func RunDownload(paths []string, options *DownloadOptions) {
 	// 开始执行
 	executor.Execute()
 
+	if queueDB != nil {
+		err = queueDB.Save()
+		if err != nil {
+			fmt.Printf("保存下载队列错误: %s\n", err)
+		}
+	}
+
 	fmt.Printf("\n下载结束, 时间: %s, 数据总量: %s\n", statistic.Elapsed()/1e6*1e6, converter.ConvertFileSize(statistic.TotalSize()))
 
 	// 输出失败的文件列表
 	failedList := executor.FailedDeque()
-	if failedList.Size() != 0 {
+	failed = failedList.Size()
+	if failed != 0 {
 		fmt.Printf("以下文件下载失败: \n")
 		tb := pcstable.NewTable(os.Stdout)
 		for e := failedList.Shift(); e != nil; e = failedList.Shift() {
@@ -194,5 +418,73 @@ func RunDownload(paths []string, options *DownloadOptions) {
 			tb.Append([]string{item.Info.Id(), item.Unit.(*pcsdownload.DownloadTaskUnit).PcsPath})
 		}
 		tb.Render()
+		if queueDB != nil {
+			fmt.Printf("可运行 download queue retry-failed 重试下载失败的文件\n")
+		}
+	}
+	return failed
+}
+
+// RunDownloadQueue 管理下载队列, op 可选 list, clear, retry-failed
+func RunDownloadQueue(op string, options *DownloadOptions) {
+	queueDB, err := pcsdownload.NewDownloadQueueDatabase()
+	if err != nil {
+		fmt.Printf("打开下载队列数据库错误: %s\n", err)
+		return
+	}
+
+	uid := GetActiveUser().UID
+	switch op {
+	case "", "list":
+		items := queueDB.List(uid, "")
+		queueDB.Close()
+		if len(items) == 0 {
+			fmt.Println("下载队列为空")
+			return
+		}
+
+		var failed int
+		tb := pcstable.NewTable(os.Stdout)
+		tb.SetHeader([]string{"#", "状态", "文件大小", "网盘路径", "保存路径", "错误信息"})
+		for k, item := range items {
+			status := "等待下载"
+			if item.Status == pcsdownload.QueueStatusFailed {
+				status = "下载失败"
+				failed++
+			}
+			tb.Append([]string{strconv.Itoa(k), status, converter.ConvertFileSize(item.Size, 2), item.PcsPath, item.SavePath, item.Err})
+		}
+		tb.Render()
+		fmt.Printf("共 %d 个, 等待下载 %d 个, 下载失败 %d 个\n", len(items), len(items)-failed, failed)
+	case "clear":
+		n := queueDB.Clear(uid, "")
+		err = queueDB.Save()
+		queueDB.Close()
+		if err != nil {
+			fmt.Printf("保存下载队列错误: %s\n", err)
+			return
+		}
+		fmt.Printf("已清除下载队列, 共 %d 个\n", n)
+	case "retry-failed":
+		n := queueDB.RetryFailed(uid)
+		err = queueDB.Save()
+		queueDB.Close()
+		if err != nil {
+			fmt.Printf("保存下载队列错误: %s\n", err)
+			return
+		}
+		if n == 0 {
+			fmt.Println("下载队列中没有下载失败的文件")
+			return
+		}
+		fmt.Printf("已将 %d 个下载失败的文件重新加入队列\n", n)
+
+		if options == nil {
+			options = &DownloadOptions{}
+		}
+		options.ResumeQueue = true
+		RunDownload(nil, options)
+	default:
+		fmt.Printf("未知的操作: %s, 可选: list, clear, retry-failed\n", op)
 	}
 }
@@ -19,6 +19,7 @@ type (
 		*ListTask
 		path     string
 		rootPath string
+		basePath string // 指定导出的路径, 用于过滤
 		fd       *baidupcs.FileDirectory
 		err      pcserror.Error
 	}
@@ -31,6 +32,7 @@ type (
 		Recursive  bool
 		LinkFormat bool
 		StdOut     bool
+		Filter     *FileFilter // 过滤条件, 为空则导出全部文件
 	}
 )
 
@@ -75,6 +77,72 @@ func GetExportFilename() string {
 	return "BaiduPCS-Go_export_" + pcstime.BeijingTimeOption("") + ".txt"
 }
 
+// walkExportDir 递归获取目录下的文件, 返回文件任务和空目录.
+// 获取失败的子目录也作为任务返回, 以便重试.
+// 有过滤条件时不返回空目录.
+func walkExportDir(pcs *baidupcs.BaiduPCS, task *etask, filter *FileFilter) (subTasks []*etask, emptyDirs []string, pcsError pcserror.Error) {
+	var (
+		dirs       []*baidupcs.FileDirectory
+		failedDirs = map[string]bool{}
+		nonEmpty   = map[*baidupcs.FileDirectory]bool{} // 遍历完成后子目录的子项会被释放, 在回调时记录非空目录
+	)
+	pcs.FilesDirectoriesWalk(task.path, &baidupcs.WalkOptions{
+		OrderOptions: baidupcs.DefaultOrderOptions,
+		Ordered:      true,
+		SkipDir: func(fd *baidupcs.FileDirectory) bool {
+			return filter.SkipDir(filterRelPath(task.basePath, fd.Path))
+		},
+	}, func(depth int, fdPath string, fd *baidupcs.FileDirectory, err pcserror.Error) bool {
+		if err != nil {
+			if depth == 0 { // 根目录出错, 整个目录重试
+				pcsError = err
+				return false
+			}
+			failedDirs[fdPath] = true
+			subTasks = append(subTasks, &etask{
+				ListTask: &ListTask{
+					MaxRetry: task.MaxRetry,
+				},
+				path:     fdPath,
+				rootPath: task.rootPath,
+				basePath: task.basePath,
+			})
+			return true
+		}
+
+		if fd.Parent != nil {
+			nonEmpty[fd.Parent] = true
+		}
+		if fd.Isdir {
+			dirs = append(dirs, fd)
+			return true
+		}
+		subTasks = append(subTasks, &etask{
+			ListTask: &ListTask{
+				MaxRetry: task.MaxRetry,
+			},
+			path:     fd.Path,
+			fd:       fd,
+			rootPath: task.rootPath,
+			basePath: task.basePath,
+		})
+		return true
+	})
+	if pcsError != nil {
+		return nil, nil, pcsError
+	}
+	if !filter.IsEmpty() {
+		return
+	}
+
+	for _, dir := range dirs {
+		if !nonEmpty[dir] && !failedDirs[dir.Path] {
+			emptyDirs = append(emptyDirs, dir.Path)
+		}
+	}
+	return
+}
+
 // RunExport 执行导出文件和目录
 func RunExport(pcspaths []string, opt *ExportOptions) {
 	if opt == nil {
@@ -126,6 +194,7 @@ func RunExport(pcspaths []string, opt *ExportOptions) {
 			},
 			path:     pcspaths[id],
 			rootPath: rootPath,
+			basePath: pcspaths[id],
 		})
 	}
 
@@ -156,14 +225,61 @@ func RunExport(pcspaths []string, opt *ExportOptions) {
 				continue
 			}
 
-			fds, pcsError := pcs.FilesDirectoriesList(task.path, baidupcs.DefaultOrderOptions)
-			if pcsError != nil {
+			if opt.Recursive {
+				// 并发递归获取, 获取失败的子目录重新加入队列
+				subTasks, emptyDirs, pcsError := walkExportDir(pcs, task, opt.Filter)
+				if pcsError != nil {
+					task.err = pcsError
+					task.handleExportTaskError(l, failedList)
+					continue
+				}
+
+				if !opt.StdOut {
+					for _, dir := range emptyDirs {
+						_, writeErr = saveFile.Write(converter.ToBytes(fmt.Sprintf("BaiduPCS-Go mkdir \"%s\"\n", changeRootPath(task.rootPath, dir, opt.RootPath))))
+						if writeErr != nil {
+							fmt.Printf("写入文件失败: %s\n", writeErr)
+							return // 直接返回
+						}
+						fmt.Printf("[%d] - [%s] 导出成功\n", task.ID, dir)
+					}
+				}
+
+				// 加入队列
+				for _, subTask := range subTasks {
+					id++
+					subTask.ID = id
+					l.PushBack(subTask)
+				}
+				continue
+			}
+
+			// 分页获取, 中途出错时整个目录重试, 避免重复导出
+			var (
+				it = pcs.ListIter(task.path, &baidupcs.ListOptions{
+					OrderOptions: baidupcs.DefaultOrderOptions,
+				})
+				subTasks = list.New()
+			)
+			for it.Next() {
+				fd := it.Value()
+				subTasks.PushBack(&etask{
+					ListTask: &ListTask{
+						MaxRetry: opt.MaxRetry,
+					},
+					path:     fd.Path,
+					fd:       fd,
+					rootPath: task.rootPath,
+					basePath: task.basePath,
+				})
+			}
+			if pcsError := it.Err(); pcsError != nil {
 				task.err = pcsError
 				task.handleExportTaskError(l, failedList)
 				continue
 			}
 
-			if len(fds) == 0 && !opt.StdOut {
+			if subTasks.Len() == 0 && !opt.StdOut && opt.Filter.IsEmpty() {
 				_, writeErr = saveFile.Write(converter.ToBytes(fmt.Sprintf("BaiduPCS-Go mkdir \"%s\"\n", changeRootPath(task.rootPath, task.path, opt.RootPath))))
 				if writeErr != nil {
 					fmt.Printf("写入文件失败: %s\n", writeErr)
@@ -174,22 +290,19 @@ func RunExport(pcspaths []string, opt *ExportOptions) {
 			}
 
 			// 加入队列
-			for _, fd := range fds {
-				// 加入队列
+			for e := subTasks.Front(); e != nil; e = e.Next() {
 				id++
-				l.PushBack(&etask{
-					ListTask: &ListTask{
-						ID:       id,
-						MaxRetry: opt.MaxRetry,
-					},
-					path:     fd.Path,
-					fd:       fd,
-					rootPath: task.rootPath,
-				})
+				subTask := e.Value.(*etask)
+				subTask.ID = id
+				l.PushBack(subTask)
 			}
 			continue
 		}
 
+		if !opt.Filter.MatchFileDirectory(filterRelPath(task.basePath, task.path), task.fd) {
+			continue
+		}
+
 		rinfo, pcsError := pcs.ExportByFileInfo(task.fd)
 		if pcsError != nil {
 			task.err = pcsError
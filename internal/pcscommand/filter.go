@@ -0,0 +1,237 @@
+package pcscommand
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/qjfoidnh/BaiduPCS-Go/baidupcs"
+	"github.com/qjfoidnh/BaiduPCS-Go/pcsutil/converter"
+)
+
+type (
+	// FileFilterOptions 文件过滤条件, 用于递归下载, 上传, 导出和删除
+	FileFilterOptions struct {
+		Includes       []string // glob, 只处理匹配的文件
+		Excludes       []string // glob, 排除匹配的文件或目录
+		IncludeRegexps []string // 正则表达式, 只处理匹配的文件
+		ExcludeRegexps []string // 正则表达式, 排除匹配的文件或目录
+		MinSize        string   // 文件大小下限, 如 1GB
+		MaxSize        string   // 文件大小上限
+		Newer          string   // 只处理此时间之后修改的文件, 如 7d, 12h, 2006-01-02
+		Older          string   // 只处理此时间之前修改的文件
+		MaxDepth       int      // 最大深度, 指定目录的子项深度为1, 0代表不限制
+	}
+
+	// FileFilter 编译后的文件过滤条件.
+	// 路径均为相对于指定目录的路径, 以 / 分隔, 为空的 FileFilter 匹配所有文件.
+	FileFilter struct {
+		includes []*regexp.Regexp
+		excludes []*regexp.Regexp
+		minSize  int64
+		maxSize  int64 // 0代表不限制
+		newer    int64 // unix 时间, 0代表不限制
+		older    int64
+		maxDepth int
+	}
+)
+
+var (
+	// ErrFilterSizeRange 文件大小范围错误
+	ErrFilterSizeRange = errors.New("min-size 不能大于 max-size")
+
+	filterDurationRegexp = regexp.MustCompile(`^(\d+)([smhdw])$`)
+	filterTimeLayouts    = []string{"2006-01-02", "2006-01-02 15:04", "2006-01-02 15:04:05", time.RFC3339}
+)
+
+// NewFileFilter 解析过滤条件, 没有任何条件时返回空
+func NewFileFilter(opts *FileFilterOptions) (ff *FileFilter, err error) {
+	if opts == nil {
+		return nil, nil
+	}
+
+	ff = &FileFilter{
+		maxDepth: opts.MaxDepth,
+	}
+	for _, p := range opts.Includes {
+		ff.includes = appendGlobRegexp(ff.includes, p)
+	}
+	for _, p := range opts.Excludes {
+		ff.excludes = appendGlobRegexp(ff.excludes, p)
+	}
+	for _, p := range opts.IncludeRegexps {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("解析正则表达式 %s 错误: %s", p, err)
+		}
+		ff.includes = append(ff.includes, re)
+	}
+	for _, p := range opts.ExcludeRegexps {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("解析正则表达式 %s 错误: %s", p, err)
+		}
+		ff.excludes = append(ff.excludes, re)
+	}
+
+	if opts.MinSize != "" {
+		ff.minSize, err = converter.ParseFileSizeStr(opts.MinSize)
+		if err != nil {
+			return nil, fmt.Errorf("解析 min-size 错误: %s", err)
+		}
+	}
+	if opts.MaxSize != "" {
+		ff.maxSize, err = converter.ParseFileSizeStr(opts.MaxSize)
+		if err != nil {
+			return nil, fmt.Errorf("解析 max-size 错误: %s", err)
+		}
+		if ff.maxSize < ff.minSize {
+			return nil, ErrFilterSizeRange
+		}
+	}
+
+	now := time.Now()
+	if opts.Newer != "" {
+		t, err := parseFilterTime(opts.Newer, now)
+		if err != nil {
+			return nil, fmt.Errorf("解析 newer 错误: %s", err)
+		}
+		ff.newer = t.Unix()
+	}
+	if opts.Older != "" {
+		t, err := parseFilterTime(opts.Older, now)
+		if err != nil {
+			return nil, fmt.Errorf("解析 older 错误: %s", err)
+		}
+		ff.older = t.Unix()
+	}
+
+	if ff.IsEmpty() {
+		return nil, nil
+	}
+	return ff, nil
+}
+
+// appendGlobRegexp 将 glob 转换为正则表达式, * 不匹配 /, ** 匹配任意层级, 以 / 开头则只从指定目录开始匹配
+func appendGlobRegexp(res []*regexp.Regexp, p string) []*regexp.Regexp {
+	p = strings.TrimSpace(filepath.ToSlash(p))
+	if p == "" {
+		return res
+	}
+	re, err := regexp.Compile(patternToRegexp(strings.Trim(p, "/"), strings.HasPrefix(p, "/")))
+	if err != nil {
+		return res
+	}
+	return append(res, re)
+}
+
+// parseFilterTime 解析时间, 支持相对现在的时长如 30m, 12h, 7d, 2w, 以及本地日期如 2006-01-02
+func parseFilterTime(s string, now time.Time) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if sub := filterDurationRegexp.FindStringSubmatch(s); sub != nil {
+		n, _ := strconv.Atoi(sub[1])
+		unit := map[string]time.Duration{
+			"s": time.Second,
+			"m": time.Minute,
+			"h": time.Hour,
+			"d": 24 * time.Hour,
+			"w": 7 * 24 * time.Hour,
+		}[sub[2]]
+		return now.Add(-time.Duration(n) * unit), nil
+	}
+	for _, layout := range filterTimeLayouts {
+		t, err := time.ParseInLocation(layout, s, time.Local)
+		if err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("无法识别的时间: %s", s)
+}
+
+// filterRelPath 获取相对于 root 的路径, path 即为 root 时返回文件名
+func filterRelPath(root, p string) string {
+	if p == root {
+		return path.Base(p)
+	}
+	return strings.TrimPrefix(p, strings.TrimSuffix(root, "/")+"/")
+}
+
+// IsEmpty 是否没有任何过滤条件
+func (ff *FileFilter) IsEmpty() bool {
+	return ff == nil || (len(ff.includes) == 0 && len(ff.excludes) == 0 && ff.minSize == 0 && ff.maxSize == 0 && ff.newer == 0 && ff.older == 0 && ff.maxDepth == 0)
+}
+
+func filterDepth(rel string) int {
+	return strings.Count(rel, "/") + 1
+}
+
+// excluded 路径或其所在的目录是否被排除
+func (ff *FileFilter) excluded(rel string) bool {
+	if ff == nil {
+		return false
+	}
+	for p := rel; p != "." && p != "/" && p != ""; p = path.Dir(p) {
+		if matchAnyRegexp(ff.excludes, p) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchAnyRegexp(res []*regexp.Regexp, rel string) bool {
+	for _, re := range res {
+		if re.MatchString(rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// SkipDir 是否不进入该目录
+func (ff *FileFilter) SkipDir(rel string) bool {
+	if ff == nil || rel == "" {
+		return false
+	}
+	if ff.maxDepth > 0 && filterDepth(rel) >= ff.maxDepth {
+		return true
+	}
+	return ff.excluded(rel)
+}
+
+// MatchFile 文件是否满足过滤条件, mtime 为 unix 时间
+func (ff *FileFilter) MatchFile(rel string, size, mtime int64) bool {
+	if ff == nil {
+		return true
+	}
+	switch {
+	case ff.maxDepth > 0 && filterDepth(rel) > ff.maxDepth:
+		return false
+	case size < ff.minSize:
+		return false
+	case ff.maxSize > 0 && size > ff.maxSize:
+		return false
+	case ff.newer > 0 && mtime < ff.newer:
+		return false
+	case ff.older > 0 && mtime > ff.older:
+		return false
+	case ff.excluded(rel):
+		return false
+	}
+	return len(ff.includes) == 0 || matchAnyRegexp(ff.includes, rel)
+}
+
+// MatchFileDirectory 网盘文件是否满足过滤条件
+func (ff *FileFilter) MatchFileDirectory(rel string, fd *baidupcs.FileDirectory) bool {
+	return ff.MatchFile(rel, fd.Size, fd.Mtime)
+}
+
+// MatchFileInfo 本地文件是否满足过滤条件
+func (ff *FileFilter) MatchFileInfo(rel string, info os.FileInfo) bool {
+	return ff.MatchFile(rel, info.Size(), info.ModTime().Unix())
+}
@@ -0,0 +1,61 @@
+package pcscommand_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/qjfoidnh/BaiduPCS-Go/internal/pcscommand"
+)
+
+func TestFileFilter(t *testing.T) {
+	ff, err := pcscommand.NewFileFilter(&pcscommand.FileFilterOptions{
+		Includes:       []string{"*.mkv"},
+		Excludes:       []string{"tmp", "/a/b"},
+		ExcludeRegexps: []string{`^sample`},
+		MinSize:        "1mb",
+		Newer:          "7d",
+		MaxDepth:       4,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now().Unix()
+	for _, c := range []struct {
+		rel   string
+		size  int64
+		mtime int64
+		match bool
+	}{
+		{"1.mkv", 2 << 20, now, true},
+		{"d/1.mkv", 2 << 20, now, true},
+		{"d/1.mp4", 2 << 20, now, false},
+		{"d/1.mkv", 1 << 10, now, false},
+		{"d/1.mkv", 2 << 20, now - 30*86400, false},
+		{"d/tmp/1.mkv", 2 << 20, now, false},
+		{"a/b/1.mkv", 2 << 20, now, false},
+		{"c/a/b/1.mkv", 2 << 20, now, true},
+		{"sample/1.mkv", 2 << 20, now, false},
+		{"c/d/e/f/1.mkv", 2 << 20, now, false},
+	} {
+		if ff.MatchFile(c.rel, c.size, c.mtime) != c.match {
+			t.Errorf("MatchFile(%s, %d, %d) != %v", c.rel, c.size, c.mtime, c.match)
+		}
+	}
+
+	for rel, skip := range map[string]bool{"d": false, "d/tmp": true, "c/d": false, "c/d/e/f": true} {
+		if ff.SkipDir(rel) != skip {
+			t.Errorf("SkipDir(%s) != %v", rel, skip)
+		}
+	}
+
+	ff, err = pcscommand.NewFileFilter(&pcscommand.FileFilterOptions{})
+	if err != nil || ff != nil || !ff.IsEmpty() || !ff.MatchFile("any", 0, 0) {
+		t.Fatal("empty filter should match all files")
+	}
+
+	_, err = pcscommand.NewFileFilter(&pcscommand.FileFilterOptions{MinSize: "2gb", MaxSize: "1gb"})
+	if err != pcscommand.ErrFilterSizeRange {
+		t.Fatalf("expected ErrFilterSizeRange, got %v", err)
+	}
+}
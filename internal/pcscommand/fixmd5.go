@@ -16,11 +16,16 @@ func RunFixMD5(pcspaths ...string) {
 	pcs := GetBaiduPCS()
 	finfoList, err := pcs.FilesDirectoriesBatchMeta(absPaths...)
 	if err != nil {
+		// 继续处理获取成功的部分
 		fmt.Println(err)
-		return
 	}
 
 	for k, finfo := range finfoList {
+		if finfo == nil {
+			// 所在分片获取失败
+			continue
+		}
+
 		err := pcs.FixMD5ByFileInfo(finfo)
 		if err == nil {
 			fmt.Printf("[%d] - [%s] 修复md5成功\n", k, finfo.Path)
@@ -29,7 +29,7 @@ const (
 	opSearch
 )
 
-// RunLs 执行列目录
+// RunLs 执行列目录, 分页获取并逐页输出
 func RunLs(pcspath string, lsOptions *LsOptions, orderOptions *baidupcs.OrderOptions) {
 	err := matchPathByShellPatternOnce(&pcspath)
 	if err != nil {
@@ -37,19 +37,36 @@ func RunLs(pcspath string, lsOptions *LsOptions, orderOptions *baidupcs.OrderOpt
 		return
 	}
 
-	files, err := GetBaiduPCS().FilesDirectoriesList(pcspath, orderOptions)
-	if err != nil {
-		fmt.Println(err)
-		return
+	if lsOptions == nil {
+		lsOptions = &LsOptions{}
 	}
 
-	fmt.Printf("\n当前目录: %s\n----\n", pcspath)
+	var (
+		it = GetBaiduPCS().ListIter(pcspath, &baidupcs.ListOptions{
+			OrderOptions: orderOptions,
+			UseCache:     true,
+		})
+		ft = newFileTable(opLs, lsOptions.Total)
+	)
+	for {
+		page, pcsError := it.NextPage()
+		if pcsError != nil {
+			fmt.Println(pcsError)
+			return
+		}
 
-	if lsOptions == nil {
-		lsOptions = &LsOptions{}
+		if ft.count == 0 {
+			fmt.Printf("\n当前目录: %s\n----\n", pcspath)
+		}
+
+		if !it.HasMore() {
+			ft.renderPage(page, true)
+			break
+		}
+		ft.renderPage(page, false)
 	}
 
-	renderTable(opLs, lsOptions.Total, pcspath, files)
+	ft.renderFooter(pcspath)
 	return
 }
 
@@ -76,23 +93,66 @@ func RunSearch(targetPath, keyword string, opt *SearchOptions) {
 }
 
 func renderTable(op int, isTotal bool, path string, files baidupcs.FileDirectoryList) {
+	ft := newFileTable(op, isTotal)
+	ft.renderPage(files, true)
+	ft.renderFooter(path)
+}
+
+var (
+	// 除最后一列外每列的最小宽度, 按各列内容可能的最大宽度预先确定, 使分页输出时每页的列宽一致
+	fileTableTotalColWidths = []int{6, 19, 13, 13, 19, 19, 44}
+	fileTableColWidths      = []int{6, 13, 19}
+)
+
+// fileTable 文件列表表格, 支持分页逐步输出
+type fileTable struct {
+	op        int
+	isTotal   bool
+	count     int // 已输出的条目数量
+	fN, dN    int64
+	totalSize int64
+}
+
+func newFileTable(op int, isTotal bool) *fileTable {
+	return &fileTable{
+		op:      op,
+		isTotal: isTotal,
+	}
+}
+
+// renderPage 输出一页, 第一页输出表头, 最后一页输出统计
+func (ft *fileTable) renderPage(files baidupcs.FileDirectoryList, isLast bool) {
 	tb := pcstable.NewTable(os.Stdout)
 	var (
-		fN, dN   int64
-		showPath string
+		showPath  string
+		colWidths = fileTableColWidths
 	)
+	if ft.isTotal {
+		colWidths = fileTableTotalColWidths
+	}
+	for column, width := range colWidths {
+		tb.SetColMinWidth(column, width)
+	}
 
-	switch op {
+	switch ft.op {
 	case opLs:
 		showPath = "文件(目录)"
 	case opSearch:
 		showPath = "路径"
 	}
 
-	if isTotal {
-		tb.SetHeader([]string{"#", "fs_id", "app_id", "文件大小", "创建日期", "修改日期", "md5(截图请打码)", showPath})
+	fN, dN := files.Count()
+	ft.fN += fN
+	ft.dN += dN
+	ft.totalSize += files.TotalSize()
+
+	if ft.isTotal {
+		if ft.count == 0 {
+			tb.SetHeader([]string{"#", "fs_id", "app_id", "文件大小", "创建日期", "修改日期", "md5(截图请打码)", showPath})
+		}
 		tb.SetColumnAlignment([]int{tablewriter.ALIGN_DEFAULT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_LEFT, tablewriter.ALIGN_LEFT, tablewriter.ALIGN_LEFT, tablewriter.ALIGN_LEFT})
-		for k, file := range files {
+		for i, file := range files {
+			k := ft.count + i
 			if file.Isdir {
 				tb.Append([]string{strconv.Itoa(k), strconv.FormatInt(file.FsID, 10), strconv.FormatInt(file.AppID, 10), "-", pcstime.FormatTime(file.Ctime), pcstime.FormatTime(file.Mtime), file.MD5, file.Filename + baidupcs.PathSeparator})
 				continue
@@ -105,38 +165,46 @@ func renderTable(op int, isTotal bool, path string, files baidupcs.FileDirectory
 				md5 = file.MD5
 			}
 
-			switch op {
+			switch ft.op {
 			case opLs:
 				tb.Append([]string{strconv.Itoa(k), strconv.FormatInt(file.FsID, 10), strconv.FormatInt(file.AppID, 10), converter.ConvertFileSize(file.Size, 2), pcstime.FormatTime(file.Ctime), pcstime.FormatTime(file.Mtime), md5, file.Filename})
 			case opSearch:
 				tb.Append([]string{strconv.Itoa(k), strconv.FormatInt(file.FsID, 10), strconv.FormatInt(file.AppID, 10), converter.ConvertFileSize(file.Size, 2), pcstime.FormatTime(file.Ctime), pcstime.FormatTime(file.Mtime), md5, file.Path})
 			}
 		}
-		fN, dN = files.Count()
-		tb.Append([]string{"", "", "总: " + converter.ConvertFileSize(files.TotalSize(), 2), "", "", "", fmt.Sprintf("文件总数: %d, 目录总数: %d", fN, dN)})
+		if isLast {
+			tb.Append([]string{"", "", "总: " + converter.ConvertFileSize(ft.totalSize, 2), "", "", "", fmt.Sprintf("文件总数: %d, 目录总数: %d", ft.fN, ft.dN)})
+		}
 	} else {
-		tb.SetHeader([]string{"#", "文件大小", "修改日期", showPath})
+		if ft.count == 0 {
+			tb.SetHeader([]string{"#", "文件大小", "修改日期", showPath})
+		}
 		tb.SetColumnAlignment([]int{tablewriter.ALIGN_DEFAULT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_LEFT, tablewriter.ALIGN_LEFT})
-		for k, file := range files {
+		for i, file := range files {
+			k := ft.count + i
 			if file.Isdir {
 				tb.Append([]string{strconv.Itoa(k), "-", pcstime.FormatTime(file.Mtime), file.Filename + baidupcs.PathSeparator})
 				continue
 			}
 
-			switch op {
+			switch ft.op {
 			case opLs:
 				tb.Append([]string{strconv.Itoa(k), converter.ConvertFileSize(file.Size, 2), pcstime.FormatTime(file.Mtime), file.Filename})
 			case opSearch:
 				tb.Append([]string{strconv.Itoa(k), converter.ConvertFileSize(file.Size, 2), pcstime.FormatTime(file.Mtime), file.Path})
 			}
 		}
-		fN, dN = files.Count()
-		tb.Append([]string{"", "总: " + converter.ConvertFileSize(files.TotalSize(), 2), "", fmt.Sprintf("文件总数: %d, 目录总数: %d", fN, dN)})
+		if isLast {
+			tb.Append([]string{"", "总: " + converter.ConvertFileSize(ft.totalSize, 2), "", fmt.Sprintf("文件总数: %d, 目录总数: %d", ft.fN, ft.dN)})
+		}
 	}
 
+	ft.count += len(files)
 	tb.Render()
+}
 
-	if fN+dN >= 50 {
+func (ft *fileTable) renderFooter(path string) {
+	if ft.fN+ft.dN >= 50 {
 		fmt.Printf("\n当前目录: %s\n", path)
 	}
 
@@ -0,0 +1,269 @@
+package pcscommand
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/qjfoidnh/BaiduPCS-Go/baidupcs"
+	"github.com/qjfoidnh/BaiduPCS-Go/baidupcs/pcserror"
+	"github.com/qjfoidnh/BaiduPCS-Go/pcstable"
+	"github.com/qjfoidnh/BaiduPCS-Go/pcsutil/converter"
+)
+
+type (
+	// MirrorOptions 镜像可选参数
+	MirrorOptions struct {
+		DryRun   bool        // 只输出要执行的操作, 不下载也不删除
+		Delete   bool        // 删除本地多余的文件
+		Filter   *FileFilter // 过滤条件, 被过滤的本地文件不会被删除
+		Download *DownloadOptions
+	}
+
+	// mirrorSummary 镜像结果统计
+	mirrorSummary struct {
+		newCount, changedCount, sameCount, deleteCount, skipCount, failedCount int
+		newSize, changedSize                                                   int64
+	}
+)
+
+// mirrorFileChanged 比较网盘文件和本地文件, 依次比较大小, 修改时间和md5.
+// 大小相同而修改时间不同时, 网盘记录的md5可用才计算本地文件的md5, 否则视为有变化;
+// 试运行时不计算md5.
+func mirrorFileChanged(fd *baidupcs.FileDirectory, localPath string, info os.FileInfo, dryRun bool) bool {
+	if info.Size() != fd.Size {
+		return true
+	}
+	if info.ModTime().Unix() == fd.Mtime {
+		return false
+	}
+
+	// 网盘记录的md5不一定正确, 无法确认内容一致
+	if len(fd.BlockList) != 1 || dryRun {
+		return true
+	}
+
+	sum, err := md5sum(localPath)
+	if err != nil {
+		pcsCommandVerbose.Warnf("%s\n", err)
+		return true
+	}
+	if sum != fd.MD5 {
+		return true
+	}
+
+	// 内容一致, 修正本地文件的修改时间, 下次可直接比较
+	os.Chtimes(localPath, time.Unix(fd.Mtime, 0), time.Unix(fd.Mtime, 0))
+	return false
+}
+
+// RunMirror 将本地目录同步为网盘目录的镜像, 只下载新增或有变化的文件
+func RunMirror(remote, local string, options *MirrorOptions) {
+	if options == nil {
+		options = &MirrorOptions{}
+	}
+	if options.Download == nil {
+		options.Download = &DownloadOptions{}
+	}
+
+	var (
+		pcs    = GetBaiduPCS()
+		filter = options.Filter
+		err    error
+	)
+	remote = GetActiveUser().PathJoin(remote)
+	local, err = filepath.Abs(local)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	rootInfo, pcsError := pcs.FilesDirectoriesMeta(remote)
+	if pcsError != nil {
+		fmt.Println(pcsError)
+		return
+	}
+	if !rootInfo.Isdir {
+		fmt.Printf("%s 不是一个目录\n", remote)
+		return
+	}
+
+	if info, err := os.Stat(local); err == nil && !info.IsDir() {
+		fmt.Printf("%s 不是一个目录\n", local)
+		return
+	}
+
+	var (
+		summary     mirrorSummary
+		listFailed  bool
+		remoteFiles = map[string]bool{} // 网盘中存在的文件和目录的相对路径
+		remoteDirs  []string
+		files       []*downloadFile
+		prefix      = strings.TrimSuffix(remote, "/") + "/"
+	)
+
+	fmt.Printf("正在获取网盘目录: %s\n", remote)
+	pcs.FilesDirectoriesWalk(remote, &baidupcs.WalkOptions{
+		OrderOptions: baidupcs.DefaultOrderOptions,
+		Ordered:      true,
+		SkipDir: func(fd *baidupcs.FileDirectory) bool {
+			return filter.SkipDir(filterRelPath(remote, fd.Path))
+		},
+	}, func(depth int, _ string, fd *baidupcs.FileDirectory, pcsError pcserror.Error) bool {
+		if pcsError != nil {
+			pcsCommandVerbose.Warnf("%s\n", pcsError)
+			listFailed = true
+			return true
+		}
+		if depth == 0 {
+			return true
+		}
+
+		rel := strings.TrimPrefix(fd.Path, prefix)
+		remoteFiles[rel] = true
+		if fd.Isdir {
+			if !filter.excluded(rel) {
+				remoteDirs = append(remoteDirs, rel)
+			}
+			return true
+		}
+		if !filter.MatchFileDirectory(rel, fd) {
+			summary.skipCount++
+			return true
+		}
+
+		localPath := filepath.Join(local, filepath.FromSlash(rel))
+		info, err := os.Stat(localPath)
+		switch {
+		case err != nil:
+			summary.newCount++
+			summary.newSize += fd.Size
+			fmt.Printf("[新增] %s\n", rel)
+		case info.IsDir():
+			fmt.Printf("[跳过] %s, 本地存在同名目录\n", rel)
+			summary.skipCount++
+			return true
+		case mirrorFileChanged(fd, localPath, info, options.DryRun):
+			summary.changedCount++
+			summary.changedSize += fd.Size
+			fmt.Printf("[更新] %s\n", rel)
+		default:
+			summary.sameCount++
+			return true
+		}
+
+		files = append(files, &downloadFile{
+			pcsPath:  fd.Path,
+			savePath: localPath,
+			size:     fd.Size,
+			fd:       fd,
+		})
+		return true
+	})
+
+	// 删除本地多余的文件, 获取网盘目录出错时不删除, 防止误删
+	var extras []string
+	if options.Delete {
+		if listFailed {
+			fmt.Println("获取网盘目录时发生错误, 不删除本地多余的文件")
+		} else {
+			extras = mirrorLocalExtras(local, remoteFiles, filter)
+		}
+	}
+	for _, rel := range extras {
+		fmt.Printf("[删除] %s\n", rel)
+	}
+
+	if !options.DryRun {
+		// 创建网盘中的空目录
+		for _, rel := range remoteDirs {
+			err = os.MkdirAll(filepath.Join(local, filepath.FromSlash(rel)), 0777)
+			if err != nil {
+				pcsCommandVerbose.Warnf("%s\n", err)
+			}
+		}
+
+		if len(files) > 0 {
+			// 文件有变化时直接覆盖, 并保持与网盘一致的修改时间, 下次比较时可跳过md5
+			do := fixDownloadOptions(options.Download)
+			do.IsOverwrite = true
+			do.ModifyMTime = true
+			do.IsTest = false
+
+			queueDB := openDownloadQueue(do)
+			summary.failedCount = runDownloadFiles(files, do, queueDB, true)
+			if queueDB != nil {
+				queueDB.Close()
+			}
+		}
+
+		// 先删除文件, 再从深到浅删除空目录.
+		// 多余的目录中还有被排除的文件时, 删除目录会失败, 保留即可
+		for k := len(extras) - 1; k >= 0; k-- {
+			err = os.Remove(filepath.Join(local, filepath.FromSlash(extras[k])))
+			if err != nil {
+				pcsCommandVerbose.Warnf("%s\n", err)
+				continue
+			}
+			summary.deleteCount++
+		}
+	} else {
+		summary.deleteCount = len(extras)
+	}
+
+	summary.print(options.DryRun)
+}
+
+// mirrorLocalExtras 列出本地存在但网盘中不存在的文件和目录, 目录排在其包含的文件之前
+func mirrorLocalExtras(local string, remoteFiles map[string]bool, filter *FileFilter) (extras []string) {
+	filepath.Walk(local, func(localPath string, info os.FileInfo, err error) error {
+		if err != nil {
+			pcsCommandVerbose.Warnf("%s\n", err)
+			return nil
+		}
+		rel, err := filepath.Rel(local, localPath)
+		if err != nil || rel == "." {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+
+		if info.IsDir() {
+			if !remoteFiles[rel] && !filter.excluded(rel) {
+				extras = append(extras, rel)
+			}
+			if filter.SkipDir(rel) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if remoteFiles[rel] || !filter.MatchFileInfo(rel, info) {
+			return nil
+		}
+		extras = append(extras, rel)
+		return nil
+	})
+
+	return
+}
+
+func (ms *mirrorSummary) print(dryRun bool) {
+	if dryRun {
+		fmt.Printf("\n试运行, 未下载或删除任何文件\n")
+	} else {
+		fmt.Printf("\n镜像结束\n")
+	}
+	tb := pcstable.NewTable(os.Stdout)
+	tb.SetHeader([]string{"类型", "数量", "大小"})
+	tb.AppendBulk([][]string{
+		[]string{"新增", strconv.Itoa(ms.newCount), converter.ConvertFileSize(ms.newSize, 2)},
+		[]string{"更新", strconv.Itoa(ms.changedCount), converter.ConvertFileSize(ms.changedSize, 2)},
+		[]string{"未变", strconv.Itoa(ms.sameCount), "-"},
+		[]string{"删除", strconv.Itoa(ms.deleteCount), "-"},
+		[]string{"跳过", strconv.Itoa(ms.skipCount), "-"},
+		[]string{"下载失败", strconv.Itoa(ms.failedCount), "-"},
+	})
+	tb.Render()
+}
@@ -0,0 +1,72 @@
+package pcscommand
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/qjfoidnh/BaiduPCS-Go/baidupcs"
+	"github.com/qjfoidnh/BaiduPCS-Go/internal/pcsconfig"
+)
+
+func TestMirrorFileChanged(t *testing.T) {
+	t.Setenv(pcsconfig.EnvConfigDir, t.TempDir())
+
+	var (
+		localPath = filepath.Join(t.TempDir(), "f")
+		data      = []byte("mirror")
+		sum       = md5.Sum(data)
+		mtime     = time.Unix(1600000000, 0)
+	)
+	if err := os.WriteFile(localPath, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	stat := func() os.FileInfo {
+		info, err := os.Stat(localPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return info
+	}
+	remote := func(size, mtime int64, md5Str string, blocks int) *baidupcs.FileDirectory {
+		fd := &baidupcs.FileDirectory{Size: size, Mtime: mtime, MD5: md5Str}
+		fd.BlockList = make([]string, blocks)
+		return fd
+	}
+	os.Chtimes(localPath, mtime, mtime)
+
+	for _, c := range []struct {
+		name    string
+		fd      *baidupcs.FileDirectory
+		dryRun  bool
+		changed bool
+	}{
+		{"size differs", remote(7, mtime.Unix(), hex.EncodeToString(sum[:]), 1), false, true},
+		{"same size and mtime", remote(6, mtime.Unix(), "", 0), false, false},
+		{"mtime differs, md5 unreliable", remote(6, mtime.Unix()+1, hex.EncodeToString(sum[:]), 2), false, true},
+		{"mtime differs, dry run", remote(6, mtime.Unix()+1, hex.EncodeToString(sum[:]), 1), true, true},
+		{"mtime differs, md5 differs", remote(6, mtime.Unix()+1, "00000000000000000000000000000000", 1), false, true},
+	} {
+		if changed := mirrorFileChanged(c.fd, localPath, stat(), c.dryRun); changed != c.changed {
+			t.Errorf("%s: changed %v, want %v", c.name, changed, c.changed)
+		}
+	}
+	if !stat().ModTime().Equal(mtime) {
+		t.Error("mtime modified without matching md5")
+	}
+
+	// 内容一致时修正本地文件的修改时间
+	fd := remote(6, mtime.Unix()+3600, hex.EncodeToString(sum[:]), 1)
+	if mirrorFileChanged(fd, localPath, stat(), false) {
+		t.Error("same content reported as changed")
+	}
+	if stat().ModTime().Unix() != fd.Mtime {
+		t.Errorf("local mtime %s not fixed", stat().ModTime())
+	}
+	if mirrorFileChanged(fd, localPath, stat(), true) {
+		t.Error("changed after mtime fixed")
+	}
+}
@@ -2,9 +2,8 @@ package pcscommand
 
 import (
 	"fmt"
-	"github.com/qjfoidnh/BaiduPCS-Go/pcstable"
-	"os"
-	"strconv"
+	"github.com/qjfoidnh/BaiduPCS-Go/baidupcs"
+	"github.com/qjfoidnh/BaiduPCS-Go/baidupcs/pcserror"
 )
 
 // RunRemove 执行 批量删除文件/目录
@@ -15,25 +14,65 @@ func RunRemove(paths ...string) {
 		return
 	}
 
-	pnt := func() {
-		tb := pcstable.NewTable(os.Stdout)
-		tb.SetHeader([]string{"#", "文件/目录"})
-		for k := range paths {
-			tb.Append([]string{strconv.Itoa(k), paths[k]})
-		}
-		tb.Render()
-	}
+	results := GetBaiduPCS().BatchRemove(paths...)
+	printBatchOpResult("删除", "以下文件/目录已删除, 可在网盘文件回收站找回", results)
+}
 
-	err = GetBaiduPCS().Remove(paths...)
+// RunRemoveByFilter 执行 删除目录内满足过滤条件的文件, 不删除目录
+func RunRemoveByFilter(filter *FileFilter, paths ...string) {
+	paths, err := matchPathByShellPattern(paths...)
 	if err != nil {
 		fmt.Println(err)
-		fmt.Println("操作失败, 以下文件/目录删除失败: ")
-		pnt()
 		return
 	}
 
-	fmt.Println("操作成功, 以下文件/目录已删除, 可在网盘文件回收站找回: ")
-	pnt()
+	var (
+		pcs       = GetBaiduPCS()
+		filePaths []string
+	)
+	for _, root := range paths {
+		pcs.FilesDirectoriesWalk(root, &baidupcs.WalkOptions{
+			OrderOptions: baidupcs.DefaultOrderOptions,
+			SkipDir: func(fd *baidupcs.FileDirectory) bool {
+				return filter.SkipDir(filterRelPath(root, fd.Path))
+			},
+		}, func(depth int, fdPath string, fd *baidupcs.FileDirectory, pcsError pcserror.Error) bool {
+			if pcsError != nil {
+				fmt.Printf("获取目录 %s 错误, %s\n", fdPath, pcsError)
+				return true
+			}
+			if !fd.Isdir && filter.MatchFileDirectory(filterRelPath(root, fd.Path), fd) {
+				filePaths = append(filePaths, fd.Path)
+			}
+			return true
+		})
+	}
+
+	if len(filePaths) == 0 {
+		fmt.Println("没有满足过滤条件的文件")
+		return
+	}
+
+	results := pcs.BatchRemove(filePaths...)
+	printBatchOpResult("删除", "以下文件已删除, 可在网盘文件回收站找回", results)
+}
+
+// printBatchOpResult 输出批量操作中每个路径的执行结果
+func printBatchOpResult(opName, successMsg string, results baidupcs.BatchOpResultList) {
+	var (
+		succeededN = len(results.Succeeded())
+		failedN    = len(results.Failed())
+	)
+	switch {
+	case failedN == 0:
+		fmt.Printf("操作成功, %s: \n", successMsg)
+	case succeededN == 0:
+		fmt.Println(results.Err())
+		fmt.Printf("操作失败, 以下文件/目录%s失败: \n", opName)
+	default:
+		fmt.Printf("部分操作失败, %s成功 %d 个, 失败 %d 个: \n", opName, succeededN, failedN)
+	}
+	fmt.Print(results)
 }
 
 // RunMkdir 执行 创建目录
@@ -0,0 +1,123 @@
+package pcscommand
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/qjfoidnh/BaiduPCS-Go/baidupcs"
+	"github.com/qjfoidnh/BaiduPCS-Go/internal/pcsconfig"
+	"github.com/qjfoidnh/BaiduPCS-Go/internal/pcsfunctions/pcsdownload"
+	"github.com/qjfoidnh/BaiduPCS-Go/internal/pcsfunctions/pcsserve"
+	"github.com/qjfoidnh/BaiduPCS-Go/internal/pcsfunctions/pcsupload"
+	"github.com/qjfoidnh/BaiduPCS-Go/pcsutil/checksum"
+	"github.com/qjfoidnh/BaiduPCS-Go/pcsutil/taskframework"
+)
+
+type (
+	// ServeWebDAVOptions WebDAV 服务可选项
+	ServeWebDAVOptions struct {
+		Username       string // basic auth 用户名, 与密码均为空时不认证
+		Password       string
+		UploadParallel int // 上传单个文件的最大并发量
+		UploadMaxRetry int // 上传失败的最大重试次数
+	}
+)
+
+// serveRoot 检查服务的网盘根目录, 返回绝对路径
+func serveRoot(root string) (string, bool) {
+	root = GetActiveUser().PathJoin(root)
+	fd, pcsError := GetBaiduPCS().FilesDirectoriesMeta(root)
+	if pcsError != nil {
+		fmt.Println(pcsError)
+		return "", false
+	}
+	if !fd.Isdir {
+		fmt.Printf("%s 不是一个目录\n", root)
+		return "", false
+	}
+	return root, true
+}
+
+// RunServeHTTP 执行 启动 HTTP 服务, 提供网盘 root 目录下文件的访问
+func RunServeHTTP(addr, root string, opts *pcsdownload.StreamOptions) {
+	root, ok := serveRoot(root)
+	if !ok {
+		return
+	}
+
+	fmt.Printf("HTTP 服务已启动: http://%s/, 网盘目录: %s\n", addr, root)
+	err := http.ListenAndServe(addr, &pcsserve.HTTPHandler{
+		PCS:           GetBaiduPCS(),
+		Root:          root,
+		StreamOptions: opts,
+	})
+	if err != nil {
+		fmt.Printf("HTTP 服务错误: %s\n", err)
+	}
+}
+
+// RunServeWebDAV 执行 启动 WebDAV 服务, 提供网盘 root 目录下文件的读写
+func RunServeWebDAV(addr, root string, streamOpts *pcsdownload.StreamOptions, opt *ServeWebDAVOptions) {
+	if opt == nil {
+		opt = &ServeWebDAVOptions{}
+	}
+	if opt.UploadParallel <= 0 {
+		opt.UploadParallel = pcsconfig.Config.MaxUploadParallel
+	}
+	if opt.UploadMaxRetry < 0 {
+		opt.UploadMaxRetry = DefaultUploadMaxRetry
+	}
+
+	pcs := GetBaiduPCS()
+
+	root, ok := serveRoot(root)
+	if !ok {
+		return
+	}
+
+	uploadDatabase, err := pcsupload.NewUploadingDatabase()
+	if err != nil {
+		fmt.Printf("打开上传未完成数据库错误: %s\n", err)
+		return
+	}
+	defer uploadDatabase.Close()
+
+	var (
+		statistic = &pcsupload.UploadStatistic{}
+		fs        = &pcsserve.WebDAVFileSystem{
+			PCS:           pcs,
+			Root:          root,
+			StreamOptions: streamOpts,
+			Upload: func(localPath, pcspath string) error {
+				executor := &taskframework.TaskExecutor{
+					IsFailedDeque: true,
+				}
+				executor.Append(&pcsupload.UploadTaskUnit{
+					LocalFileChecksum: checksum.NewLocalFileChecksum(localPath, int(baidupcs.SliceMD5Size)),
+					SavePath:          pcspath,
+					PCS:               pcs,
+					UploadingDatabase: uploadDatabase,
+					Parallel:          opt.UploadParallel,
+					PrintFormat:       uploadPrintFormat(2), // 可能同时上传多个文件, 逐行输出
+					UploadStatistic:   statistic,
+					Policy:            baidupcs.OverWritePolicy,
+				}, opt.UploadMaxRetry)
+				executor.Execute()
+				if executor.FailedDeque().Size() != 0 {
+					return errors.New(pcsupload.StrUploadFailed)
+				}
+				return nil
+			},
+		}
+	)
+
+	if opt.Username == "" && opt.Password == "" {
+		fmt.Printf("警告: 未设置用户名和密码, 任何能访问 %s 的人都可以读写网盘目录\n", addr)
+	}
+	fmt.Printf("WebDAV 服务已启动: http://%s/, 网盘目录: %s\n", addr, root)
+	err = http.ListenAndServe(addr, pcsserve.NewWebDAVHandler(fs, opt.Username, opt.Password))
+	if err != nil {
+		fmt.Printf("WebDAV 服务错误: %s\n", err)
+	}
+}
@@ -1,11 +1,9 @@
 package pcscommand
 
 import (
-	"crypto/md5"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
-	"io"
 	"io/ioutil"
 	"os"
 	"os/signal"
@@ -16,7 +14,9 @@ import (
 	"time"
 
 	"github.com/qjfoidnh/BaiduPCS-Go/baidupcs"
+	"github.com/qjfoidnh/BaiduPCS-Go/internal/pcsconfig"
 	"github.com/qjfoidnh/BaiduPCS-Go/pcsutil"
+	"github.com/qjfoidnh/BaiduPCS-Go/pcsutil/checksum"
 )
 
 // syncFileState 记录文件的修改时间、大小和MD5
@@ -242,15 +242,9 @@ func encryptFileForSync(inputPath, outputPath, key, method string) error {
 
 // md5sum 计算文件的MD5哈希值
 func md5sum(filePath string) (string, error) {
-	f, err := os.Open(filePath)
+	lfc, err := checksum.GetFileSumWithCache(filePath, checksum.CHECKSUM_MD5, pcsconfig.OpenSumCache())
 	if err != nil {
 		return "", err
 	}
-	defer f.Close()
-
-	h := md5.New()
-	if _, err := io.Copy(h, f); err != nil {
-		return "", err
-	}
-	return hex.EncodeToString(h.Sum(nil)), nil
+	return hex.EncodeToString(lfc.MD5), nil
 }
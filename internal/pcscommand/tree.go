@@ -3,6 +3,8 @@ package pcscommand
 import (
 	"fmt"
 	"github.com/qjfoidnh/BaiduPCS-Go/baidupcs"
+	"github.com/qjfoidnh/BaiduPCS-Go/baidupcs/pcserror"
+	"path"
 	"strings"
 )
 
@@ -20,43 +22,51 @@ type (
 )
 
 func getTree(pcspath string, depth int, option *TreeOptions) {
-	var (
-		err   error
-		files baidupcs.FileDirectoryList
-	)
-	if depth == 0 {
-		err := matchPathByShellPatternOnce(&pcspath)
-		if err != nil {
-			fmt.Println(err)
-			return
-		}
-	}
-
-	files, err = GetBaiduPCS().FilesDirectoriesList(pcspath, baidupcs.DefaultOrderOptions)
+	err := matchPathByShellPatternOnce(&pcspath)
 	if err != nil {
 		fmt.Println(err)
 		return
 	}
 
-	var (
-		prefix          = pathPrefix
-		fN              = len(files)
-		indentPrefixStr = strings.Repeat(indentPrefix, depth)
-	)
-	for i, file := range files {
+	maxDepth := 0
+	if option.Depth >= 0 {
+		maxDepth = option.Depth + 1
+	}
+
+	// 并发预读子目录, 按深度优先顺序输出
+	GetBaiduPCS().FilesDirectoriesWalk(pcspath, &baidupcs.WalkOptions{
+		OrderOptions: baidupcs.DefaultOrderOptions,
+		Ordered:      true,
+		MaxDepth:     maxDepth,
+		UseCache:     true,
+	}, func(fdDepth int, _ string, file *baidupcs.FileDirectory, pcsError pcserror.Error) bool {
+		if pcsError != nil {
+			fmt.Println(pcsError)
+			return true
+		}
+		if fdDepth == 0 {
+			if !file.Isdir { // 不是目录, 输出文件本身
+				if option.ShowFsid {
+					fmt.Printf("%v %v: %v\n", lastFilePrefix, path.Base(file.Path), file.FsID)
+				} else {
+					fmt.Printf("%v %v\n", lastFilePrefix, path.Base(file.Path))
+				}
+			}
+			return true
+		}
+
+		indentPrefixStr := strings.Repeat(indentPrefix, depth+fdDepth-1)
 		if file.Isdir {
 			if option.ShowFsid {
 				fmt.Printf("%v%v %v/: %v\n", indentPrefixStr, pathPrefix, file.Filename, file.FsID)
 			} else {
 				fmt.Printf("%v%v %v/\n", indentPrefixStr, pathPrefix, file.Filename)
 			}
-			if option.Depth < 0 || depth < option.Depth {
-				getTree(file.Path, depth+1, option)
-			}
-			continue
+			return true
 		}
 
-		if i+1 == fN {
+		prefix := pathPrefix
+		if siblings := file.Parent.Children; siblings[len(siblings)-1] == file {
 			prefix = lastFilePrefix
 		}
 		if option.ShowFsid {
@@ -64,9 +74,8 @@ func getTree(pcspath string, depth int, option *TreeOptions) {
 		} else {
 			fmt.Printf("%v%v %v\n", indentPrefixStr, prefix, file.Filename)
 		}
-	}
-
-	return
+		return true
+	})
 }
 
 // RunTree 列出树形图
@@ -0,0 +1,144 @@
+package pcscommand
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/qjfoidnh/BaiduPCS-Go/internal/pcsfunctions"
+	"github.com/qjfoidnh/BaiduPCS-Go/internal/pcsfunctions/pcsdownload"
+	"github.com/qjfoidnh/BaiduPCS-Go/pcstable"
+	"github.com/qjfoidnh/BaiduPCS-Go/pcsutil/converter"
+)
+
+type (
+	// UnpackOptions 从打包上传的分卷中取出文件的可选参数
+	UnpackOptions struct {
+		SaveTo      string // 保存的本地目录, 默认为当前目录
+		List        bool   // 只列出文件
+		IsOverwrite bool   // 覆盖已存在的文件
+	}
+)
+
+// RunUnpack 根据打包索引, 从网盘的分卷中取出文件, patterns 为包内的路径或通配符, 为空则取出全部文件
+func RunUnpack(indexPath string, patterns []string, opt *UnpackOptions) {
+	if opt == nil {
+		opt = &UnpackOptions{}
+	}
+	err := matchPathByShellPatternOnce(&indexPath)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	if !pcsfunctions.IsPackIndex(path.Base(indexPath)) {
+		fmt.Printf("%s 不是打包索引文件 (*%s)\n", indexPath, pcsfunctions.PackIndexSuffix)
+		return
+	}
+
+	pr, err := pcsdownload.NewPackReader(GetBaiduPCS(), indexPath)
+	if err != nil {
+		fmt.Printf("读取打包索引错误: %s\n", err)
+		return
+	}
+
+	var members []*pcsfunctions.PackMember
+	for _, member := range pr.Index.Members {
+		if matchPackMember(member.Path, patterns) {
+			members = append(members, member)
+		}
+	}
+	if len(members) == 0 {
+		fmt.Printf("未找到匹配的文件\n")
+		return
+	}
+
+	if opt.List {
+		tb := pcstable.NewTable(os.Stdout)
+		tb.SetHeader([]string{"#", "文件大小", "修改日期", "分卷", "路径"})
+		for k, member := range members {
+			tb.Append([]string{strconv.Itoa(k), converter.ConvertFileSize(member.Size, 2), time.Unix(member.MTime, 0).Format("2006-01-02 15:04:05"), pr.Index.Volumes[member.Volume].Name, member.Path})
+		}
+		tb.Render()
+		return
+	}
+
+	saveTo := opt.SaveTo
+	if saveTo == "" {
+		saveTo = "."
+	}
+	var okCount, skipCount, failedCount int
+	for _, member := range members {
+		localPath, err := packMemberLocalPath(saveTo, member.Path)
+		if err != nil {
+			fmt.Printf("[跳过] %s, %s\n", member.Path, err)
+			skipCount++
+			continue
+		}
+		if _, err = os.Stat(localPath); err == nil && !opt.IsOverwrite {
+			fmt.Printf("[跳过] %s, 本地文件已存在\n", member.Path)
+			skipCount++
+			continue
+		}
+
+		err = unpackMember(pr, member, localPath)
+		if err != nil {
+			fmt.Printf("[失败] %s, %s\n", member.Path, err)
+			failedCount++
+			continue
+		}
+		fmt.Printf("[成功] %s -> %s\n", member.Path, localPath)
+		okCount++
+	}
+	fmt.Printf("\n取出文件结束, 成功 %d 个, 跳过 %d 个, 失败 %d 个\n", okCount, skipCount, failedCount)
+}
+
+// matchPackMember 包内的路径是否匹配, 支持通配符和目录前缀
+func matchPackMember(memberPath string, patterns []string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		pattern = strings.Trim(pattern, "/")
+		if memberPath == pattern || strings.HasPrefix(memberPath, pattern+"/") {
+			return true
+		}
+		if ok, _ := path.Match(pattern, memberPath); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// packMemberLocalPath 返回文件保存的本地路径, 不允许保存到 saveTo 之外
+func packMemberLocalPath(saveTo, memberPath string) (string, error) {
+	cleaned := path.Clean("/" + memberPath)
+	if cleaned == "/" || cleaned != "/"+strings.Trim(memberPath, "/") {
+		return "", fmt.Errorf("非法的路径")
+	}
+	return filepath.Join(saveTo, filepath.FromSlash(cleaned[1:])), nil
+}
+
+func unpackMember(pr *pcsdownload.PackReader, member *pcsfunctions.PackMember, localPath string) error {
+	err := os.MkdirAll(filepath.Dir(localPath), 0777)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(localPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(member.Mode&0777|0200))
+	if err != nil {
+		return err
+	}
+	err = pr.WriteMember(member, f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(localPath)
+		return err
+	}
+	mtime := time.Unix(member.MTime, 0)
+	return os.Chtimes(localPath, mtime, mtime)
+}
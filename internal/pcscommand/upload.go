@@ -4,16 +4,22 @@ import (
 	"fmt"
 	"github.com/qjfoidnh/BaiduPCS-Go/baidupcs"
 	"github.com/qjfoidnh/BaiduPCS-Go/internal/pcsconfig"
+	"github.com/qjfoidnh/BaiduPCS-Go/internal/pcsfunctions"
 	"github.com/qjfoidnh/BaiduPCS-Go/internal/pcsfunctions/pcsupload"
 	"github.com/qjfoidnh/BaiduPCS-Go/pcstable"
 	"github.com/qjfoidnh/BaiduPCS-Go/pcsutil"
 	"github.com/qjfoidnh/BaiduPCS-Go/pcsutil/checksum"
 	"github.com/qjfoidnh/BaiduPCS-Go/pcsutil/converter"
 	"github.com/qjfoidnh/BaiduPCS-Go/pcsutil/taskframework"
+	"github.com/qjfoidnh/BaiduPCS-Go/requester/uploader"
+	"io"
 	"os"
 	"path"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 )
 
 const (
@@ -27,10 +33,29 @@ type (
 		Parallel        int
 		MaxRetry        int
 		Load            int
+		HashParallel    int // 上传前同时计算摘要的文件数量
+		HashIOParallel  int // 同一磁盘上同时读取计算摘要的文件数量
 		NoRapidUpload   bool
-		NoSplitFile     bool   // 禁用分片上传
-		Policy          string // 同名文件处理策略
-		NoFilenameCheck bool   // 禁用文件名合法性检查
+		NoSplitFile     bool        // 禁用分片上传
+		Policy          string      // 同名文件处理策略
+		NoFilenameCheck bool        // 禁用文件名合法性检查
+		Filter          *FileFilter // 过滤条件, 为空则上传全部文件
+		BlockSize       int64       // 从标准输入上传时的分片大小
+		SplitSize       int64       // 大于0时, 超过该大小的文件分卷上传
+		Pack            string      // 打包格式, tar 或 tar.zst, 非空时小于 PackThreshold 的文件打包上传
+		PackThreshold   int64
+		PackVolumeSize  int64  // 打包的分卷大小
+		RemoveSource    bool   // 上传成功并校验后删除本地文件, 并删除因此变为空的目录
+		VerifyUpload    bool   // 上传完成后校验网盘文件, 不一致则重新上传
+		VerifySample    int64  // 校验时下载比较的随机范围大小, 大于0时启用 VerifyUpload
+		Session         string // 上传会话名称, 非空时记录每个文件的状态, 再次运行时跳过已完成的文件
+		ExistsScope     string // 非空时, 先在该网盘目录中查找相同的文件
+		CopyExisting    bool   // 找到相同的文件时, 在服务器端拷贝到保存路径, 否则跳过上传
+	}
+
+	// splitUploadFile 需要分卷上传的文件
+	splitUploadFile struct {
+		localPath, savePath string
 	}
 )
 
@@ -41,6 +66,20 @@ func uploadPrintFormat(load int) string {
 	return "[%s] ↑ %s/%s %s/s in %s ...\n"
 }
 
+// matchLocalFile 本地文件是否满足过滤条件, 路径为相对于 root 的路径
+func matchLocalFile(filter *FileFilter, root, filePath string) bool {
+	filePath = filepath.FromSlash(filePath)
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return false
+	}
+	rel := filepath.Base(filePath)
+	if r, err := filepath.Rel(filepath.Clean(root), filePath); err == nil && r != "." {
+		rel = filepath.ToSlash(r)
+	}
+	return filter.MatchFileInfo(rel, info)
+}
+
 // RunUpload 执行文件上传
 func RunUpload(localPaths []string, savePath string, opt *UploadOptions) {
 	if opt == nil {
@@ -62,6 +101,14 @@ func RunUpload(localPaths []string, savePath string, opt *UploadOptions) {
 		opt.Load = pcsconfig.Config.MaxUploadLoad
 	}
 
+	if opt.HashParallel <= 0 {
+		opt.HashParallel = pcsconfig.Config.HashParallel
+	}
+
+	if opt.HashIOParallel <= 0 {
+		opt.HashIOParallel = pcsconfig.Config.HashIOParallel
+	}
+
 	if opt.Policy != baidupcs.SkipPolicy && opt.Policy != baidupcs.OverWritePolicy && opt.Policy != baidupcs.RsyncPolicy {
 		opt.Policy = pcsconfig.Config.UPolicy
 	}
@@ -71,10 +118,37 @@ func RunUpload(localPaths []string, savePath string, opt *UploadOptions) {
 		fmt.Printf("警告: 上传文件, 获取网盘路径 %s 错误, %s\n", savePath, err)
 	}
 
+	if opt.Pack != "" {
+		opt.Pack, err = pcsfunctions.ParsePackFormat(opt.Pack)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		if opt.PackThreshold <= 0 {
+			opt.PackThreshold = pcsupload.DefaultPackThreshold
+		}
+	}
+
 	switch len(localPaths) {
 	case 0:
 		fmt.Printf("本地路径为空\n")
 		return
+	case 1:
+		if localPaths[0] == "-" {
+			runStreamUpload(os.Stdin, savePath, opt)
+			return
+		}
+	}
+
+	var session *pcsupload.UploadSession
+	if opt.Session != "" {
+		session, err = pcsupload.OpenUploadSession(opt.Session, true)
+		if err != nil {
+			fmt.Printf("打开上传会话错误: %s\n", err)
+			return
+		}
+		session.SetPaths(localPaths, savePath)
+		defer finishUploadSession(session)
 	}
 
 	// 打开上传状态
@@ -92,9 +166,33 @@ func RunUpload(localPaths []string, savePath string, opt *UploadOptions) {
 			IsFailedDeque: true, // 失败统计
 		}
 		subSavePath string
+		sumCache    = pcsconfig.OpenSumCache() // 本地文件摘要缓存
+		// 秒传和删除源文件需要文件的md5, 在上传的同时预先计算
+		hashPipeline *pcsupload.HashPipeline
+		// 在网盘目录中查找相同的文件
+		remoteIndex *pcsupload.RemoteIndex
+		// 会话中已完成的文件数
+		sessionFinished int
 		// 统计
-		statistic = &pcsupload.UploadStatistic{}
+		statistic  = &pcsupload.UploadStatistic{}
+		splitFiles []*splitUploadFile
+		packFiles  []*pcsupload.PackFile
 	)
+	if opt.ExistsScope != "" {
+		scope := GetActiveUser().PathJoin(opt.ExistsScope)
+		fmt.Printf("[0] 正在建立网盘目录 %s 的文件索引...\n", scope)
+		remoteIndex, err = pcsupload.NewRemoteIndex(pcs, scope)
+		if err != nil {
+			fmt.Printf("建立网盘目录 %s 的文件索引错误: %s\n", scope, err)
+			return
+		}
+		fmt.Printf("[0] 索引建立完成, 共 %d 个文件\n", remoteIndex.Len())
+	}
+
+	if !opt.NoRapidUpload || opt.RemoveSource || remoteIndex != nil {
+		hashPipeline = pcsupload.NewHashPipeline(opt.HashParallel, opt.HashIOParallel, sumCache)
+		defer hashPipeline.Close()
+	}
 	fmt.Print("\n")
 	fmt.Printf("[0] 提示: 当前上传单个文件最大并发量为: %d, 最大同时上传文件数为: %d\n", opt.Parallel, opt.Load)
 
@@ -127,13 +225,55 @@ func RunUpload(localPaths []string, savePath string, opt *UploadOptions) {
 				opt.Load = 1
 			}
 			subSavePath = strings.TrimPrefix(walkedFiles[k3], localPathDir)
+			if !opt.Filter.IsEmpty() && !matchLocalFile(opt.Filter, localPaths[k], walkedFiles[k3]) {
+				continue
+			}
 			if !opt.NoFilenameCheck && !pcsutil.ChPathLegal(walkedFiles[k3]) {
 				fmt.Printf("[0] %s 文件路径含有非法字符，已跳过!\n", walkedFiles[k3])
 				continue
 			}
+			if opt.SplitSize > 0 || opt.Pack != "" {
+				if info, err := os.Stat(walkedFiles[k3]); err == nil {
+					switch {
+					case opt.SplitSize > 0 && info.Size() > opt.SplitSize:
+						splitFiles = append(splitFiles, &splitUploadFile{
+							localPath: walkedFiles[k3],
+							savePath:  path.Clean(savePath + baidupcs.PathSeparator + subSavePath),
+						})
+						fmt.Printf("[0] 加入分卷上传队列: %s\n", walkedFiles[k3])
+						continue
+					case opt.Pack != "" && info.Size() < opt.PackThreshold:
+						// 小文件打包上传, 不逐个输出
+						packFiles = append(packFiles, &pcsupload.PackFile{
+							LocalPath: walkedFiles[k3],
+							Path:      strings.TrimPrefix(path.Clean(subSavePath), "/"),
+						})
+						continue
+					}
+				}
+			}
+			var sessionFile *pcsupload.UploadSessionFile
+			if session != nil {
+				info, err := os.Stat(walkedFiles[k3])
+				if err != nil {
+					fmt.Printf("[0] %s 文件不可读, 错误信息: %s, 跳过...\n", walkedFiles[k3], err)
+					continue
+				}
+				sessionFile = session.Add(walkedFiles[k3], path.Clean(savePath+baidupcs.PathSeparator+subSavePath), info)
+				if sessionFile.Finished() {
+					sessionFinished++
+					continue
+				}
+			}
 			LoadCount++
+			lfc := checksum.NewLocalFileChecksum(walkedFiles[k3], int(baidupcs.SliceMD5Size))
+			lfc.SetSumCache(sumCache)
+			var prehash *pcsupload.HashJob
+			if hashPipeline != nil {
+				prehash = hashPipeline.Submit(walkedFiles[k3])
+			}
 			info := executor.Append(&pcsupload.UploadTaskUnit{
-				LocalFileChecksum: checksum.NewLocalFileChecksum(walkedFiles[k3], int(baidupcs.SliceMD5Size)),
+				LocalFileChecksum: lfc,
 				SavePath:          path.Clean(savePath + baidupcs.PathSeparator + subSavePath),
 				PCS:               pcs,
 				UploadingDatabase: uploadDatabase,
@@ -143,6 +283,14 @@ func RunUpload(localPaths []string, savePath string, opt *UploadOptions) {
 				NoSplitFile:       opt.NoSplitFile,
 				UploadStatistic:   statistic,
 				Policy:            opt.Policy,
+				RemoveSource:      opt.RemoveSource,
+				VerifyUpload:      opt.VerifyUpload || opt.VerifySample > 0,
+				VerifySampleSize:  opt.VerifySample,
+				Prehash:           prehash,
+				RemoteIndex:       remoteIndex,
+				CopyExisting:      opt.CopyExisting,
+				Session:           session,
+				SessionFile:       sessionFile,
 			}, opt.MaxRetry)
 			if LoadCount >= opt.Load {
 				LoadCount = opt.Load
@@ -151,8 +299,15 @@ func RunUpload(localPaths []string, savePath string, opt *UploadOptions) {
 		}
 	}
 
+	if session != nil {
+		session.PruneUnseen()
+	}
+	if sessionFinished > 0 {
+		fmt.Printf("[0] 会话 %s 中已完成 %d 个文件, 跳过\n", session.Name, sessionFinished)
+	}
+
 	// 没有添加任何任务
-	if executor.Count() == 0 {
+	if executor.Count() == 0 && len(splitFiles) == 0 && len(packFiles) == 0 {
 		fmt.Printf("未检测到上传的文件.\n")
 		return
 	}
@@ -162,6 +317,53 @@ func RunUpload(localPaths []string, savePath string, opt *UploadOptions) {
 	// 执行上传任务
 	executor.Execute()
 
+	// 小文件打包上传
+	if len(packFiles) > 0 {
+		// 包名包含文件的摘要, 文件不变时再次上传跳过已上传的分卷, 不同的文件不会覆盖已有的包
+		packPrefix := "pack"
+		if len(localPaths) == 1 {
+			if absPath, err := filepath.Abs(localPaths[0]); err == nil && filepath.Base(absPath) != string(os.PathSeparator) {
+				packPrefix = filepath.Base(absPath)
+			}
+		}
+		packOpt := &pcsupload.PackUploadOptions{
+			StreamUploadOptions: pcsupload.StreamUploadOptions{
+				Parallel:            opt.Parallel,
+				Policy:              opt.Policy,
+				OnUploadStatusEvent: streamUploadStatus("-"),
+			},
+			Format:     opt.Pack,
+			VolumeSize: opt.PackVolumeSize,
+		}
+		packName := pcsupload.PackName(packPrefix, packFiles, packOpt)
+		fmt.Printf("[0] 打包上传 %d 个小文件: %s\n", len(packFiles), packName)
+		index, err := pcsupload.UploadPack(pcs, packFiles, savePath, packName, packOpt)
+		if err != nil {
+			fmt.Printf("[0] 打包上传失败: %s\n", err)
+		} else if index != nil {
+			for _, member := range index.Members {
+				statistic.AddTotalSize(member.Size)
+			}
+		}
+	}
+
+	// 分卷上传, 逐个文件进行
+	var splitFailed []string
+	for _, sf := range splitFiles {
+		manifest, err := pcsupload.UploadSplitFile(pcs, sf.localPath, sf.savePath, opt.SplitSize, &pcsupload.SplitUploadOptions{
+			Parallel:          opt.Parallel,
+			MaxRetry:          opt.MaxRetry,
+			NoRapidUpload:     opt.NoRapidUpload,
+			UploadingDatabase: uploadDatabase,
+		})
+		if err != nil {
+			fmt.Printf("[0] 分卷上传 %s 失败: %s\n", sf.localPath, err)
+			splitFailed = append(splitFailed, sf.localPath)
+			continue
+		}
+		statistic.AddTotalSize(manifest.Size)
+	}
+
 	fmt.Printf("\n")
 	fmt.Printf("上传结束, 时间: %s, 总大小: %s\n", statistic.Elapsed()/1e6*1e6, converter.ConvertFileSize(statistic.TotalSize()))
 
@@ -176,4 +378,129 @@ func RunUpload(localPaths []string, savePath string, opt *UploadOptions) {
 		}
 		tb.Render()
 	}
+	if len(splitFailed) != 0 {
+		fmt.Printf("以下文件分卷上传失败: \n")
+		tb := pcstable.NewTable(os.Stdout)
+		for _, localPath := range splitFailed {
+			tb.Append([]string{"-", localPath})
+		}
+		tb.Render()
+	}
+
+	if opt.RemoveSource {
+		removed := statistic.Removed()
+		prunedDirs := pruneEmptySourceDirs(localPaths, removed)
+		fmt.Printf("已删除 %d 个源文件, %d 个空目录\n", len(removed), len(prunedDirs))
+		if len(removed)+len(prunedDirs) > 0 {
+			tb := pcstable.NewTable(os.Stdout)
+			tb.SetHeader([]string{"#", "类型", "本地路径"})
+			for k, localPath := range removed {
+				tb.Append([]string{strconv.Itoa(k), "文件", localPath})
+			}
+			for k, dir := range prunedDirs {
+				tb.Append([]string{strconv.Itoa(len(removed) + k), "目录", dir})
+			}
+			tb.Render()
+		}
+	}
+}
+
+// pruneEmptySourceDirs 删除已删除文件所在的, 位于上传目录中的空目录, 不删除上传目录本身, 返回删除的目录
+func pruneEmptySourceDirs(localPaths, removed []string) (pruned []string) {
+	var roots []string
+	for _, localPath := range localPaths {
+		if info, err := os.Stat(localPath); err == nil && info.IsDir() {
+			roots = append(roots, filepath.Clean(localPath))
+		}
+	}
+
+	// 收集上传目录中, 已删除文件的所有上级目录
+	dirs := map[string]bool{}
+	for _, filePath := range removed {
+		filePath = filepath.Clean(filepath.FromSlash(filePath))
+		for _, root := range roots {
+			if !strings.HasPrefix(filePath, root+string(os.PathSeparator)) {
+				continue
+			}
+			for dir := filepath.Dir(filePath); len(dir) > len(root); dir = filepath.Dir(dir) {
+				dirs[dir] = true
+			}
+			break
+		}
+	}
+
+	// 先删除较深的目录, 非空目录删除失败, 忽略
+	sorted := make([]string, 0, len(dirs))
+	for dir := range dirs {
+		sorted = append(sorted, dir)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return len(sorted[i]) > len(sorted[j])
+	})
+	for _, dir := range sorted {
+		if os.Remove(dir) == nil {
+			pruned = append(pruned, dir)
+		}
+	}
+	return
+}
+
+// runStreamUpload 从标准输入上传, savePath 为网盘中的文件路径
+func runStreamUpload(r io.Reader, savePath string, opt *UploadOptions) {
+	if opt.BlockSize <= 0 {
+		opt.BlockSize = baidupcs.MinUploadBlockSize
+	}
+	fmt.Printf("[-] 从标准输入上传到网盘路径: %s, 分片大小: %s\n", savePath, converter.ConvertFileSize(opt.BlockSize))
+
+	startTime := time.Now()
+	size, md5Str, err := pcsupload.UploadStream(GetBaiduPCS(), r, savePath, &pcsupload.StreamUploadOptions{
+		Parallel:            opt.Parallel,
+		BlockSize:           opt.BlockSize,
+		Policy:              opt.Policy,
+		OnUploadStatusEvent: streamUploadStatus("-"),
+	})
+	fmt.Printf("\n")
+	if err != nil {
+		if err == pcsupload.ErrStreamTargetExists {
+			fmt.Printf("[-] %s %s\n", savePath, err)
+			return
+		}
+		fmt.Printf("[-] %s, 已读取: %s, %s\n", pcsupload.StrUploadFailed, converter.ConvertFileSize(size, 2), err)
+		return
+	}
+	fmt.Printf("[-] 上传文件成功, 保存到网盘路径: %s\n", savePath)
+	fmt.Printf("上传结束, 时间: %s, 总大小: %s, md5: %s\n", time.Since(startTime)/1e6*1e6, converter.ConvertFileSize(size), md5Str)
+}
+
+// streamUploadStatus 输出流式上传的进度, 总大小为已读取的数据量
+func streamUploadStatus(id string) uploader.UploadStatusFunc {
+	return func(status uploader.Status, _ <-chan struct{}) {
+		fmt.Printf(pcsupload.DefaultPrintFormat, id,
+			converter.ConvertFileSize(status.Uploaded(), 2),
+			converter.ConvertFileSize(status.TotalSize(), 2),
+			converter.ConvertFileSize(status.SpeedsPerSecond(), 2),
+			status.TimeElapsed(),
+		)
+	}
+}
+
+// finishUploadSession 保存上传会话并输出进度, 全部文件完成后删除会话
+func finishUploadSession(session *pcsupload.UploadSession) {
+	p := session.Progress()
+	if p.Pending == 0 && p.Failed == 0 {
+		err := session.Remove()
+		if err != nil {
+			fmt.Printf("删除上传会话错误: %s\n", err)
+			return
+		}
+		fmt.Printf("上传会话 %s 已全部完成, 共 %d 个文件, 会话已删除\n", session.Name, p.Total)
+		return
+	}
+
+	err := session.Save()
+	if err != nil {
+		fmt.Printf("保存上传会话错误: %s\n", err)
+		return
+	}
+	fmt.Printf("上传会话 %s: 完成 %d/%d 个文件, 失败 %d 个, 未完成 %d 个, 使用 upload --session %s 继续上传\n", session.Name, p.Done+p.Skipped, p.Total, p.Failed, p.Pending, session.Name)
 }
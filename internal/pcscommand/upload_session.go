@@ -0,0 +1,58 @@
+package pcscommand
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/qjfoidnh/BaiduPCS-Go/internal/pcsfunctions/pcsupload"
+	"github.com/qjfoidnh/BaiduPCS-Go/pcstable"
+	"github.com/qjfoidnh/BaiduPCS-Go/pcsutil/converter"
+)
+
+// RunUploadSessions 列出上传会话的进度
+func RunUploadSessions() {
+	sessions, err := pcsupload.ListUploadSessions()
+	if err != nil {
+		fmt.Printf("获取上传会话错误: %s\n", err)
+		return
+	}
+	if len(sessions) == 0 {
+		fmt.Printf("没有未完成的上传会话\n")
+		return
+	}
+
+	tb := pcstable.NewTable(os.Stdout)
+	tb.SetHeader([]string{"#", "名称", "文件", "失败", "大小", "更新日期", "本地路径", "网盘目录"})
+	for k, session := range sessions {
+		p := session.Progress()
+		tb.Append([]string{
+			strconv.Itoa(k),
+			session.Name,
+			fmt.Sprintf("%d/%d", p.Done+p.Skipped, p.Total),
+			strconv.Itoa(p.Failed),
+			converter.ConvertFileSize(p.FinishedSize, 2) + "/" + converter.ConvertFileSize(p.TotalSize, 2),
+			time.Unix(session.Updated, 0).Format("2006-01-02 15:04:05"),
+			strings.Join(session.LocalPaths, ", "),
+			session.SavePath,
+		})
+	}
+	tb.Render()
+}
+
+// ResumeUploadSession 使用会话中记录的本地路径和网盘目录继续上传
+func ResumeUploadSession(opt *UploadOptions) {
+	session, err := pcsupload.OpenUploadSession(opt.Session, false)
+	if err != nil {
+		fmt.Printf("打开上传会话 %s 错误: %s\n", opt.Session, err)
+		return
+	}
+	if len(session.LocalPaths) == 0 || session.SavePath == "" {
+		fmt.Printf("上传会话 %s 中没有记录上传路径\n", opt.Session)
+		return
+	}
+	fmt.Printf("继续上传会话 %s: %s -> %s\n", session.Name, strings.Join(session.LocalPaths, ", "), session.SavePath)
+	RunUpload(session.LocalPaths, session.SavePath, opt)
+}
@@ -0,0 +1,68 @@
+package pcscommand
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestPruneEmptySourceDirs(t *testing.T) {
+	var (
+		root  = filepath.Join(t.TempDir(), "photos")
+		other = filepath.Join(t.TempDir(), "other")
+	)
+	for _, localPath := range []string{
+		filepath.Join(root, "a", "b", "1.jpg"),
+		filepath.Join(root, "a", "2.jpg"),
+		filepath.Join(root, "c", "3.jpg"),
+		filepath.Join(root, "c", "keep.jpg"),
+		filepath.Join(root, "d", "4.jpg"),
+		filepath.Join(other, "5.jpg"),
+	} {
+		if err := os.MkdirAll(filepath.Dir(localPath), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(localPath, nil, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 模拟上传后已删除的文件, 其中 photos/c/keep.jpg 和 photos/d/4.jpg 未删除
+	removed := []string{
+		filepath.Join(root, "a", "b", "1.jpg"),
+		filepath.Join(root, "a", "2.jpg"),
+		filepath.Join(root, "c", "3.jpg"),
+		filepath.Join(other, "5.jpg"),
+	}
+	for _, localPath := range removed {
+		if err := os.Remove(localPath); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 只删除上传目录中变为空的目录, 不删除上传目录本身, 也不删除单独上传的文件所在的目录
+	pruned := pruneEmptySourceDirs([]string{root, filepath.Join(other, "5.jpg")}, removed)
+	sort.Strings(pruned)
+	want := []string{filepath.Join(root, "a"), filepath.Join(root, "a", "b")}
+	if strings.Join(pruned, ",") != strings.Join(want, ",") {
+		t.Errorf("pruned %v, want %v", pruned, want)
+	}
+	for _, dir := range []string{root, filepath.Join(root, "c"), filepath.Join(root, "d"), other} {
+		if _, err := os.Stat(dir); err != nil {
+			t.Errorf("%s: %s", dir, err)
+		}
+	}
+
+	// 上传目录已为空时仍保留
+	os.Remove(filepath.Join(root, "c", "keep.jpg"))
+	os.Remove(filepath.Join(root, "d", "4.jpg"))
+	pruned = pruneEmptySourceDirs([]string{root}, []string{filepath.Join(root, "c", "keep.jpg"), filepath.Join(root, "d", "4.jpg")})
+	if len(pruned) != 2 {
+		t.Errorf("pruned %v", pruned)
+	}
+	if _, err := os.Stat(root); err != nil {
+		t.Error("upload root removed")
+	}
+}
@@ -0,0 +1,173 @@
+package pcscommand
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/qjfoidnh/BaiduPCS-Go/baidupcs"
+	"github.com/qjfoidnh/BaiduPCS-Go/baidupcs/pcserror"
+	"github.com/qjfoidnh/BaiduPCS-Go/internal/pcsfunctions/pcsdownload"
+	"github.com/qjfoidnh/BaiduPCS-Go/pcstable"
+)
+
+type (
+	// VerifyOptions 校验本地文件可选参数
+	VerifyOptions struct {
+		Mode   pcsdownload.VerifyMode // 校验方式, VerifyModeNone 只检查文件是否存在
+		Extra  bool                   // 列出本地存在但网盘中不存在的文件
+		Filter *FileFilter
+	}
+
+	// verifySummary 校验结果统计
+	verifySummary struct {
+		okCount, missingCount, sizeCount, checksumCount, unsupportedCount, extraCount, errCount, skipCount int
+	}
+)
+
+// RunVerify 校验本地文件与网盘文件是否一致, 输出不一致的文件
+func RunVerify(remote, local string, options *VerifyOptions) {
+	if options == nil {
+		options = &VerifyOptions{}
+	}
+
+	var (
+		pcs    = GetBaiduPCS()
+		filter = options.Filter
+		err    error
+	)
+	remote = GetActiveUser().PathJoin(remote)
+	local, err = filepath.Abs(local)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	rootInfo, pcsError := pcs.FilesDirectoriesMeta(remote)
+	if pcsError != nil {
+		fmt.Println(pcsError)
+		return
+	}
+
+	var summary verifySummary
+	if !rootInfo.Isdir {
+		// 单个文件, 本地路径为目录时校验目录中的同名文件
+		if info, err := os.Stat(local); err == nil && info.IsDir() {
+			local = filepath.Join(local, rootInfo.Filename)
+		}
+		summary.verifyFile(pcs, rootInfo, rootInfo.Filename, local, options.Mode)
+		summary.print()
+		return
+	}
+
+	if info, err := os.Stat(local); err != nil {
+		fmt.Println(err)
+		return
+	} else if !info.IsDir() {
+		fmt.Printf("%s 不是一个目录\n", local)
+		return
+	}
+
+	var (
+		listFailed  bool
+		remoteFiles = map[string]bool{} // 网盘中存在的文件和目录的相对路径
+		prefix      = strings.TrimSuffix(remote, "/") + "/"
+	)
+
+	fmt.Printf("正在获取网盘目录: %s\n", remote)
+	pcs.FilesDirectoriesWalk(remote, &baidupcs.WalkOptions{
+		OrderOptions: baidupcs.DefaultOrderOptions,
+		Ordered:      true,
+		SkipDir: func(fd *baidupcs.FileDirectory) bool {
+			return filter.SkipDir(filterRelPath(remote, fd.Path))
+		},
+	}, func(depth int, _ string, fd *baidupcs.FileDirectory, pcsError pcserror.Error) bool {
+		if pcsError != nil {
+			pcsCommandVerbose.Warnf("%s\n", pcsError)
+			listFailed = true
+			return true
+		}
+		if depth == 0 {
+			return true
+		}
+
+		rel := strings.TrimPrefix(fd.Path, prefix)
+		remoteFiles[rel] = true
+		if fd.Isdir {
+			return true
+		}
+		if !filter.MatchFileDirectory(rel, fd) {
+			summary.skipCount++
+			return true
+		}
+
+		summary.verifyFile(pcs, fd, rel, filepath.Join(local, filepath.FromSlash(rel)), options.Mode)
+		return true
+	})
+
+	if options.Extra {
+		if listFailed {
+			fmt.Println("获取网盘目录时发生错误, 不列出本地多余的文件")
+		} else {
+			for _, rel := range mirrorLocalExtras(local, remoteFiles, filter) {
+				fmt.Printf("[多余] %s\n", rel)
+				summary.extraCount++
+			}
+		}
+	}
+
+	summary.print()
+}
+
+// verifyFile 校验单个文件, 输出不一致的结果
+func (vs *verifySummary) verifyFile(pcs *baidupcs.BaiduPCS, fd *baidupcs.FileDirectory, rel, localPath string, mode pcsdownload.VerifyMode) {
+	info, err := os.Stat(localPath)
+	switch {
+	case err != nil:
+		fmt.Printf("[缺失] %s\n", rel)
+		vs.missingCount++
+		return
+	case info.IsDir():
+		fmt.Printf("[缺失] %s, 本地存在同名目录\n", rel)
+		vs.missingCount++
+		return
+	}
+
+	_, err = pcsdownload.VerifyFile(pcs, localPath, fd, mode)
+	switch err {
+	case nil:
+		vs.okCount++
+	case pcsdownload.ErrDownloadSizeMismatch:
+		fmt.Printf("[大小不一致] %s, 本地: %d, 网盘: %d\n", rel, info.Size(), fd.Size)
+		vs.sizeCount++
+	case pcsdownload.ErrDownloadChecksumFailed, pcsdownload.ErrDownloadCRC32Failed:
+		fmt.Printf("[校验失败] %s, %s\n", rel, err)
+		vs.checksumCount++
+	case pcsdownload.ErrDownloadNotSupportChecksum:
+		// 大小一致, 网盘记录的md5不可靠
+		fmt.Printf("[无法校验] %s, 网盘记录的md5不可靠, 仅大小一致, 可使用 --mode full\n", rel)
+		vs.unsupportedCount++
+	default:
+		fmt.Printf("[错误] %s, %s\n", rel, err)
+		vs.errCount++
+	}
+}
+
+func (vs *verifySummary) print() {
+	fmt.Printf("\n校验结束\n")
+	tb := pcstable.NewTable(os.Stdout)
+	tb.SetHeader([]string{"类型", "数量"})
+	tb.AppendBulk([][]string{
+		[]string{"一致", strconv.Itoa(vs.okCount)},
+		[]string{"缺失", strconv.Itoa(vs.missingCount)},
+		[]string{"大小不一致", strconv.Itoa(vs.sizeCount)},
+		[]string{"校验失败", strconv.Itoa(vs.checksumCount)},
+		[]string{"无法校验", strconv.Itoa(vs.unsupportedCount)},
+		[]string{"多余", strconv.Itoa(vs.extraCount)},
+		[]string{"错误", strconv.Itoa(vs.errCount)},
+		[]string{"跳过", strconv.Itoa(vs.skipCount)},
+	})
+	tb.Render()
+}
@@ -62,9 +62,29 @@ func (baidu *Baidu) BaiduPCS() *baidupcs.BaiduPCS {
 	pcs.SetPanUserAgent(Config.PanUA)
 	pcs.SetUID(baidu.UID)
 	pcs.SetaccessToken(baidu.AccessToken)
+	pcs.SetBatchSize(Config.BatchSize)
+	pcs.SetBatchParallel(Config.BatchParallel)
+	pcs.SetListPageSize(Config.ListPageSize)
+	pcs.SetRecurseParallel(Config.RecurseParallel)
+	pcs.SetAPIRate(baidupcs.APIClassPCS, Config.APIRatePCS)
+	pcs.SetAPIRate(baidupcs.APIClassPan, Config.APIRatePan)
+	pcs.SetAPIRate(baidupcs.APIClassXPan, Config.APIRateXPan)
+	if Config.MetaCache {
+		pcs.SetMetaCache(baidu.openMetaCache())
+	}
 	return pcs
 }
 
+// openMetaCache 打开帐号的持久化缓存, 出错时返回空
+func (baidu *Baidu) openMetaCache() *baidupcs.MetaCache {
+	mc, err := baidupcs.OpenMetaCache(filepath.Join(GetConfigDir(), fmt.Sprintf(MetaCacheName, baidu.UID)))
+	if err != nil {
+		pcsConfigVerbose.Warnf("open meta cache error: %s\n", err)
+		return nil
+	}
+	return mc
+}
+
 // GetSavePath 根据提供的网盘文件路径 pcspath, 返回本地储存路径,
 // 返回绝对路径, 获取绝对路径出错时才返回相对路径...
 func (baidu *Baidu) GetSavePath(pcspath string) string {
@@ -0,0 +1,267 @@
+package pcsconfig
+
+import (
+	"errors"
+	"fmt"
+	"github.com/qjfoidnh/BaiduPCS-Go/pcsutil/converter"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrBandwidthScheduleSyntax 时间段限速的语法错误
+	ErrBandwidthScheduleSyntax = errors.New("时间段限速格式错误")
+
+	weekdayNames = map[string]time.Weekday{
+		"sun": time.Sunday,
+		"mon": time.Monday,
+		"tue": time.Tuesday,
+		"wed": time.Wednesday,
+		"thu": time.Thursday,
+		"fri": time.Friday,
+		"sat": time.Saturday,
+	}
+)
+
+type (
+	// BandwidthRule 时间段限速规则
+	BandwidthRule struct {
+		Weekdays     [7]bool
+		Start, End   int   // 一天中的分钟数, End 小于等于 Start 表示跨越午夜
+		DownloadRate int64 // 小于0表示该规则不涉及此方向, 使用默认的限速; 0表示不限制
+		UploadRate   int64
+	}
+
+	// BandwidthSchedule 时间段限速, 按顺序匹配, 使用第一个匹配的规则
+	BandwidthSchedule []*BandwidthRule
+)
+
+// ParseBandwidthSchedule 解析时间段限速, 规则之间用 ; 分隔, 每条规则的格式为
+//
+//	[星期] HH:MM-HH:MM 速度
+//
+// 星期可选, 如 Mon-Fri, Sat,Sun, 省略或 * 表示每天;
+// 速度如 2MB 同时限制下载和上传, down=2MB up=512KB 分别限制, 0 或 unlimited 表示不限制.
+// 例: "Mon-Fri 09:00-18:00 2MB; 23:00-07:00 unlimited"
+func ParseBandwidthSchedule(str string) (BandwidthSchedule, error) {
+	var schedule BandwidthSchedule
+	for _, ruleStr := range strings.Split(str, ";") {
+		ruleStr = strings.TrimSpace(ruleStr)
+		if ruleStr == "" {
+			continue
+		}
+		rule, err := parseBandwidthRule(ruleStr)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s, %s", ErrBandwidthScheduleSyntax, ruleStr, err)
+		}
+		schedule = append(schedule, rule)
+	}
+	return schedule, nil
+}
+
+func parseBandwidthRule(ruleStr string) (*BandwidthRule, error) {
+	fields := strings.Fields(ruleStr)
+	rule := &BandwidthRule{
+		DownloadRate: -1,
+		UploadRate:   -1,
+	}
+
+	// 星期
+	if len(fields) > 0 && !strings.Contains(fields[0], ":") {
+		err := parseWeekdays(fields[0], &rule.Weekdays)
+		if err != nil {
+			return nil, err
+		}
+		fields = fields[1:]
+	} else {
+		for i := range rule.Weekdays {
+			rule.Weekdays[i] = true
+		}
+	}
+
+	// 时间段
+	if len(fields) < 2 {
+		return nil, errors.New("缺少时间段或速度")
+	}
+	window := strings.SplitN(fields[0], "-", 2)
+	if len(window) != 2 {
+		return nil, fmt.Errorf("时间段格式错误: %s", fields[0])
+	}
+	var err error
+	rule.Start, err = parseClock(window[0])
+	if err != nil {
+		return nil, err
+	}
+	rule.End, err = parseClock(window[1])
+	if err != nil {
+		return nil, err
+	}
+
+	// 速度
+	for _, rateStr := range fields[1:] {
+		key, value := "", rateStr
+		if i := strings.Index(rateStr, "="); i >= 0 {
+			key, value = strings.ToLower(rateStr[:i]), rateStr[i+1:]
+		}
+		rate, err := parseScheduleRate(value)
+		if err != nil {
+			return nil, err
+		}
+		switch key {
+		case "":
+			rule.DownloadRate, rule.UploadRate = rate, rate
+		case "down", "download":
+			rule.DownloadRate = rate
+		case "up", "upload":
+			rule.UploadRate = rate
+		default:
+			return nil, fmt.Errorf("未知的速度类型: %s", key)
+		}
+	}
+	return rule, nil
+}
+
+func parseWeekdays(str string, weekdays *[7]bool) error {
+	if str == "*" {
+		for i := range weekdays {
+			weekdays[i] = true
+		}
+		return nil
+	}
+	for _, part := range strings.Split(str, ",") {
+		bounds := strings.SplitN(part, "-", 2)
+		from, ok := weekdayNames[strings.ToLower(bounds[0])]
+		if !ok {
+			return fmt.Errorf("星期格式错误: %s", part)
+		}
+		to := from
+		if len(bounds) == 2 {
+			to, ok = weekdayNames[strings.ToLower(bounds[1])]
+			if !ok {
+				return fmt.Errorf("星期格式错误: %s", part)
+			}
+		}
+		// 支持 Fri-Mon 这样跨越周末的范围
+		for d := from; ; d = (d + 1) % 7 {
+			weekdays[d] = true
+			if d == to {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// parseClock 解析 HH:MM, 返回一天中的分钟数, 允许 24:00
+func parseClock(str string) (int, error) {
+	parts := strings.SplitN(str, ":", 2)
+	if len(parts) != 2 {
+		return 0, fmt.Errorf("时间格式错误: %s", str)
+	}
+	hour, err1 := strconv.Atoi(parts[0])
+	minute, err2 := strconv.Atoi(parts[1])
+	if err1 != nil || err2 != nil || hour < 0 || minute < 0 || minute > 59 || hour*60+minute > 24*60 {
+		return 0, fmt.Errorf("时间格式错误: %s", str)
+	}
+	return hour*60 + minute, nil
+}
+
+func parseScheduleRate(str string) (int64, error) {
+	switch strings.ToLower(str) {
+	case "unlimited", "none":
+		return 0, nil
+	}
+	rate, err := converter.ParseFileSizeStr(stripPerSecond(str))
+	if err != nil {
+		return 0, err
+	}
+	if rate < 0 {
+		return 0, fmt.Errorf("速度不能为负数: %s", str)
+	}
+	return rate, nil
+}
+
+// Match 规则是否匹配时间 t. 跨越午夜的时间段, 以开始的那天匹配星期
+func (rule *BandwidthRule) Match(t time.Time) bool {
+	minute := t.Hour()*60 + t.Minute()
+	weekday := t.Weekday()
+	if rule.End > rule.Start {
+		return rule.Weekdays[weekday] && minute >= rule.Start && minute < rule.End
+	}
+	if minute >= rule.Start {
+		return rule.Weekdays[weekday]
+	}
+	if minute < rule.End {
+		return rule.Weekdays[(weekday+6)%7] // 前一天开始的时间段
+	}
+	return false
+}
+
+// Rates 返回时间 t 的下载和上传限速, 没有匹配的规则时返回 defaultDownload, defaultUpload
+func (schedule BandwidthSchedule) Rates(t time.Time, defaultDownload, defaultUpload int64) (download, upload int64) {
+	download, upload = defaultDownload, defaultUpload
+	for _, rule := range schedule {
+		if !rule.Match(t) {
+			continue
+		}
+		if rule.DownloadRate >= 0 {
+			download = rule.DownloadRate
+		}
+		if rule.UploadRate >= 0 {
+			upload = rule.UploadRate
+		}
+		return
+	}
+	return
+}
+
+// SetBandwidthSchedule 设置时间段限速, 空字符串表示不使用
+func (c *PCSConfig) SetBandwidthSchedule(str string) error {
+	schedule, err := ParseBandwidthSchedule(str)
+	if err != nil {
+		return err
+	}
+	c.scheduleMu.Lock()
+	defer c.scheduleMu.Unlock()
+	c.BandwidthSchedule = strings.TrimSpace(str)
+	c.bandwidthSchedule, c.bandwidthScheduleSource = schedule, c.BandwidthSchedule
+	return nil
+}
+
+// getBandwidthSchedule 返回解析后的时间段限速, 配置重载后重新解析, 格式错误则忽略
+func (c *PCSConfig) getBandwidthSchedule() BandwidthSchedule {
+	c.scheduleMu.Lock()
+	defer c.scheduleMu.Unlock()
+	if c.bandwidthScheduleSource != c.BandwidthSchedule {
+		schedule, err := ParseBandwidthSchedule(c.BandwidthSchedule)
+		if err != nil {
+			pcsConfigVerbose.Warnf("%s\n", err)
+		}
+		c.bandwidthSchedule, c.bandwidthScheduleSource = schedule, c.BandwidthSchedule
+	}
+	return c.bandwidthSchedule
+}
+
+// RatesAt 返回时间 t 的下载和上传限速, 0代表不限制
+func (c *PCSConfig) RatesAt(t time.Time) (download, upload int64) {
+	return c.getBandwidthSchedule().Rates(t, c.MaxDownloadRate, c.MaxUploadRate)
+}
+
+// DownloadRateFunc 返回当前下载限速的函数, 每次调用时读取最新的时间段限速和默认限速,
+// 用于在下载过程中调整限速, 0代表不限制
+func (c *PCSConfig) DownloadRateFunc() func() int64 {
+	return func() int64 {
+		download, _ := c.RatesAt(time.Now())
+		return download
+	}
+}
+
+// UploadRateFunc 返回当前上传限速的函数, 每次调用时读取最新的时间段限速和默认限速,
+// 用于在上传过程中调整限速, 0代表不限制
+func (c *PCSConfig) UploadRateFunc() func() int64 {
+	return func() int64 {
+		_, upload := c.RatesAt(time.Now())
+		return upload
+	}
+}
@@ -0,0 +1,110 @@
+package pcsconfig_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/qjfoidnh/BaiduPCS-Go/internal/pcsconfig"
+)
+
+func TestParseBandwidthSchedule(t *testing.T) {
+	schedule, err := pcsconfig.ParseBandwidthSchedule("Mon-Fri 09:00-18:00 2MB; Fri-Mon 23:00-07:00 down=unlimited up=512KB; * 12:00-13:00 1MB")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(schedule) != 3 {
+		t.Fatalf("expected 3 rules, got %d", len(schedule))
+	}
+
+	// 2024-01-01 为星期一
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2024, 1, day, hour, minute, 0, 0, time.Local)
+	}
+	for _, c := range []struct {
+		t              time.Time
+		download, upld int64
+	}{
+		{at(1, 9, 0), 2 << 20, 2 << 20},   // 星期一工作时间
+		{at(1, 17, 59), 2 << 20, 2 << 20}, // 结束时间之前
+		{at(1, 18, 0), 100, 200},          // 结束时间不包含在内
+		{at(6, 10, 0), 100, 200},          // 星期六不在 Mon-Fri
+		{at(6, 12, 30), 1 << 20, 1 << 20}, // * 表示每天
+		{at(5, 23, 30), 0, 512 << 10},     // 星期五开始, 跨越午夜
+		{at(6, 6, 59), 0, 512 << 10},      // 前一天 (星期五) 开始的时间段
+		{at(3, 23, 30), 100, 200},         // 星期三不在 Fri-Mon
+		{at(4, 6, 0), 100, 200},           // 星期四的凌晨属于星期三开始的时间段
+		{at(2, 6, 0), 0, 512 << 10},       // 星期二的凌晨属于星期一开始的时间段
+	} {
+		download, upload := schedule.Rates(c.t, 100, 200)
+		if download != c.download || upload != c.upld {
+			t.Errorf("Rates(%s) = %d, %d, expected %d, %d", c.t.Format("Mon 15:04"), download, upload, c.download, c.upld)
+		}
+	}
+
+	schedule, err = pcsconfig.ParseBandwidthSchedule(" ; ")
+	if err != nil || len(schedule) != 0 {
+		t.Fatalf("empty schedule: %v, %v", schedule, err)
+	}
+
+	for _, str := range []string{
+		"09:00-18:00",
+		"9:00 2MB",
+		"Mon-Fri",
+		"Foo 09:00-18:00 2MB",
+		"Mon-Xyz 09:00-18:00 2MB",
+		"09:60-18:00 2MB",
+		"09:00-24:01 2MB",
+		"09:00-18:00 -1MB",
+		"09:00-18:00 side=1MB",
+		"09:00-18:00 2MB; 10:00 1MB",
+	} {
+		if _, err = pcsconfig.ParseBandwidthSchedule(str); err == nil {
+			t.Errorf("expected error for %q", str)
+		}
+	}
+}
+
+func TestBandwidthRuleMatch(t *testing.T) {
+	schedule, err := pcsconfig.ParseBandwidthSchedule("Sun 22:00-02:00 1MB")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rule := schedule[0]
+	for _, c := range []struct {
+		t     time.Time
+		match bool
+	}{
+		{time.Date(2024, 1, 7, 22, 0, 0, 0, time.Local), true},   // 星期日
+		{time.Date(2024, 1, 8, 1, 59, 0, 0, time.Local), true},   // 星期一凌晨, 星期日开始
+		{time.Date(2024, 1, 8, 2, 0, 0, 0, time.Local), false},   // 结束时间
+		{time.Date(2024, 1, 7, 1, 0, 0, 0, time.Local), false},   // 星期日凌晨, 星期六开始
+		{time.Date(2024, 1, 8, 22, 30, 0, 0, time.Local), false}, // 星期一晚上
+	} {
+		if rule.Match(c.t) != c.match {
+			t.Errorf("Match(%s) != %v", c.t.Format("Mon 15:04"), c.match)
+		}
+	}
+}
+
+func TestRateFunc(t *testing.T) {
+	c := &pcsconfig.PCSConfig{
+		MaxDownloadRate: 100,
+		MaxUploadRate:   200,
+	}
+	download, upload := c.DownloadRateFunc(), c.UploadRateFunc()
+	if download == nil || upload == nil {
+		t.Fatal("rate func should not be nil without schedule")
+	}
+	if download() != 100 || upload() != 200 {
+		t.Fatalf("expected default rates, got %d, %d", download(), upload())
+	}
+
+	// 已返回的函数读取之后设置的限速
+	c.MaxDownloadRate = 300
+	if err := c.SetBandwidthSchedule("00:00-24:00 up=1KB"); err != nil {
+		t.Fatal(err)
+	}
+	if download() != 300 || upload() != 1<<10 {
+		t.Fatalf("expected updated rates, got %d, %d", download(), upload())
+	}
+}
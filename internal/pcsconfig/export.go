@@ -69,11 +69,24 @@ func (c *PCSConfig) PrintTable() {
 		[]string{"appid", fmt.Sprint(c.AppID), "", "百度 PCS 应用ID"},
 		[]string{"cache_size", converter.ConvertFileSize(int64(c.CacheSize), 2), "1KB ~ 256KB", "下载缓存, 如果硬盘占用高或下载速度慢, 请尝试调大此值"},
 		[]string{"max_parallel", strconv.Itoa(c.MaxParallel), "1 ~ 20", "下载总最大并发量, 非svip不可>1"},
+		[]string{"min_parallel", strconv.Itoa(c.MinParallel), "1 ~ max_parallel", "自适应下载并发量的下限, 也是初始的并发量"},
+		[]string{"adaptive_parallel", fmt.Sprint(c.AdaptiveParallel), "false", "根据实测的下载速度自动增减连接数, 速度不再提升或遇到 403/429 时减少, 上限为 max_parallel"},
 		[]string{"max_upload_parallel", strconv.Itoa(c.MaxUploadParallel), "1 ~ 100", "上传单文件最大并发量"},
 		[]string{"max_download_load", strconv.Itoa(c.MaxDownloadLoad), "1 ~ 5", "同时进行下载文件的最大数量"},
 		[]string{"max_download_rate", showMaxRate(c.MaxDownloadRate), "", "限制最大下载速度, 0代表不限制"},
 		[]string{"max_upload_rate", showMaxRate(c.MaxUploadRate), "", "限制最大上传速度, 0代表不限制"},
+		[]string{"bandwidth_schedule", c.BandwidthSchedule, "", "按时间段限速, 匹配时覆盖 max_download_rate 和 max_upload_rate, 对进行中的传输实时生效. 规则用 ; 分隔, 格式为 [星期] HH:MM-HH:MM 速度, 如 Mon-Fri 09:00-18:00 2MB; 23:00-07:00 down=0 up=1MB"},
 		[]string{"max_upload_load", strconv.Itoa(c.MaxUploadLoad), "1 ~ 4", "同时进行上传文件的最大数量"},
+		[]string{"hash_parallel", strconv.Itoa(c.HashParallel), "1 ~ 8", "上传前预先计算文件摘要的并发量, 上传的同时计算后续文件, 计算完成即可开始上传"},
+		[]string{"hash_io_parallel", strconv.Itoa(c.HashIOParallel), "1 ~ hash_parallel", "同一磁盘上同时读取计算摘要的文件数量, 机械硬盘建议为1, 固态硬盘可调大"},
+		[]string{"batch_size", strconv.Itoa(c.BatchSize), "50 ~ 500", "批量删除/拷贝/移动/获取元信息时, 单次请求的最大路径数量, 超出则自动分片"},
+		[]string{"batch_parallel", strconv.Itoa(c.BatchParallel), "1 ~ 8", "批量操作分片的最大并发量"},
+		[]string{"list_page_size", strconv.Itoa(c.ListPageSize), "100 ~ 10000", "分页获取目录列表时每页的条目数量, 超大目录会分页逐步输出"},
+		[]string{"recurse_parallel", strconv.Itoa(c.RecurseParallel), "1 ~ 10", "递归获取目录列表 (下载目录, tree, export 等) 时同时进行的请求数量"},
+		[]string{"api_rate_pcs", showAPIRate(c.APIRatePCS), "5 ~ 50", "PCS 接口每秒最大请求数, 0代表不限制, 被服务器限流时自动降低"},
+		[]string{"api_rate_pan", showAPIRate(c.APIRatePan), "5 ~ 20", "网盘首页接口每秒最大请求数, 0代表不限制, 被服务器限流时自动降低"},
+		[]string{"api_rate_xpan", showAPIRate(c.APIRateXPan), "5 ~ 20", "开放平台接口每秒最大请求数, 0代表不限制, 被服务器限流时自动降低"},
+		[]string{"meta_cache", fmt.Sprint(c.MetaCache), "false", "启用持久化的目录列表和元信息缓存, 跨会话保留, 通过服务器的文件变更记录自动失效, 数据最多可能滞后30秒, 只用于 ls, tree 等浏览命令; 首次同步在后台进行, 完成前不使用缓存"},
 		[]string{"savedir", c.SaveDir, "", "下载文件的储存目录"},
 		[]string{"enable_https", fmt.Sprint(c.EnableHTTPS), "true", "启用 https"},
 		[]string{"force_login_username", fmt.Sprint(c.ForceLogin), "留空", "强制登录指定用户名, 适用于tieba用户信息接口不可用的情况, 如登录正常请留空"},
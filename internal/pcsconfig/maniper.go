@@ -4,6 +4,7 @@ import (
 	"regexp"
 	"strings"
 
+	"github.com/qjfoidnh/BaiduPCS-Go/baidupcs"
 	"github.com/qjfoidnh/BaiduPCS-Go/pcsutil/converter"
 	"github.com/qjfoidnh/BaiduPCS-Go/requester"
 )
@@ -182,6 +183,38 @@ func (c *PCSConfig) SetMaxUploadRateByStr(sizeStr string) error {
 	return nil
 }
 
+// SetBatchSize 设置批量操作单次请求的最大路径数量
+func (c *PCSConfig) SetBatchSize(size int) {
+	c.BatchSize = size
+	if c.pcs != nil {
+		c.pcs.SetBatchSize(size)
+	}
+}
+
+// SetBatchParallel 设置批量操作分片的最大并发量
+func (c *PCSConfig) SetBatchParallel(parallel int) {
+	c.BatchParallel = parallel
+	if c.pcs != nil {
+		c.pcs.SetBatchParallel(parallel)
+	}
+}
+
+// SetListPageSize 设置分页获取目录列表时每页的条目数量
+func (c *PCSConfig) SetListPageSize(size int) {
+	c.ListPageSize = size
+	if c.pcs != nil {
+		c.pcs.SetListPageSize(size)
+	}
+}
+
+// SetRecurseParallel 设置递归获取目录列表时同时进行的请求数量
+func (c *PCSConfig) SetRecurseParallel(parallel int) {
+	c.RecurseParallel = parallel
+	if c.pcs != nil {
+		c.pcs.SetRecurseParallel(parallel)
+	}
+}
+
 // SetUserAgent 设置User-Agent
 func (c *PCSConfig) SetUserAgent(userAgent string) {
 	c.UserAgent = userAgent
@@ -232,6 +265,34 @@ func (c *PCSConfig) SetEnableHTTPS(https bool) {
 	}
 }
 
+// SetAPIRate 设置接口类别每秒最大请求数, 0代表不限制
+func (c *PCSConfig) SetAPIRate(class baidupcs.APIClass, rate int) {
+	switch class {
+	case baidupcs.APIClassPCS:
+		c.APIRatePCS = rate
+	case baidupcs.APIClassPan:
+		c.APIRatePan = rate
+	case baidupcs.APIClassXPan:
+		c.APIRateXPan = rate
+	}
+	if c.pcs != nil {
+		c.pcs.SetAPIRate(class, rate)
+	}
+}
+
+// SetMetaCache 设置是否启用持久化的目录列表和元信息缓存
+func (c *PCSConfig) SetMetaCache(enable bool) {
+	c.MetaCache = enable
+	if c.pcs == nil {
+		return
+	}
+	if enable {
+		c.pcs.SetMetaCache(c.ActiveUser().openMetaCache())
+	} else {
+		c.pcs.SetMetaCache(nil)
+	}
+}
+
 func (c *PCSConfig) SetNoCheck(nocheck bool) {
 	c.NoCheck = nocheck
 }
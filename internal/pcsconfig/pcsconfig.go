@@ -5,9 +5,10 @@ import (
 	"github.com/json-iterator/go"
 	"github.com/qjfoidnh/BaiduPCS-Go/baidupcs"
 	"github.com/qjfoidnh/BaiduPCS-Go/pcsutil"
-	"github.com/qjfoidnh/BaiduPCS-Go/pcsutil/jsonhelper"
+	"github.com/qjfoidnh/BaiduPCS-Go/pcsutil/checksum"
 	"github.com/qjfoidnh/BaiduPCS-Go/pcsverbose"
 	"github.com/qjfoidnh/BaiduPCS-Go/requester"
+	"io"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -20,6 +21,10 @@ const (
 	EnvConfigDir = "BAIDUPCS_GO_CONFIG_DIR"
 	// ConfigName 配置文件名
 	ConfigName = "pcs_config.json"
+	// MetaCacheName 持久化缓存文件名, 每个帐号一个文件
+	MetaCacheName = "pcs_meta_cache_%d.log"
+	// SumCacheName 本地文件摘要缓存文件名
+	SumCacheName = "pcs_sum_cache.json"
 )
 
 var (
@@ -28,6 +33,9 @@ var (
 
 	// Config 配置信息, 由外部调用
 	Config = NewConfig(configFilePath)
+
+	sumCache     *checksum.SumCache
+	sumCacheOnce sync.Once
 )
 
 // PCSConfig 配置详情
@@ -39,13 +47,29 @@ type PCSConfig struct {
 
 	CacheSize         int `json:"cache_size"`          // 下载缓存
 	MaxParallel       int `json:"max_parallel"`        // 最大下载并发量
+	MinParallel       int `json:"min_parallel"`        // 自适应下载并发量的下限
 	MaxUploadParallel int `json:"max_upload_parallel"` // 最大上传并发量
 	MaxDownloadLoad   int `json:"max_download_load"`   // 同时进行下载文件的最大数量
 	MaxUploadLoad     int `json:"max_upload_load"`     // 同时进行上传文件的最大数量
+	HashParallel      int `json:"hash_parallel"`       // 上传前同时计算摘要的文件数量
+	HashIOParallel    int `json:"hash_io_parallel"`    // 同一磁盘上同时读取计算摘要的文件数量
 
 	MaxDownloadRate int64 `json:"max_download_rate"` // 限制最大下载速度
 	MaxUploadRate   int64 `json:"max_upload_rate"`   // 限制最大上传速度
 
+	BandwidthSchedule string `json:"bandwidth_schedule"` // 按时间段限速, 覆盖 MaxDownloadRate 和 MaxUploadRate
+
+	AdaptiveParallel bool `json:"adaptive_parallel"` // 根据实测的速度自动调整下载并发量
+
+	BatchSize       int `json:"batch_size"`       // 批量操作单次请求的最大路径数量
+	BatchParallel   int `json:"batch_parallel"`   // 批量操作分片的最大并发量
+	ListPageSize    int `json:"list_page_size"`   // 分页获取目录列表时每页的条目数量
+	RecurseParallel int `json:"recurse_parallel"` // 递归获取目录列表时同时进行的请求数量
+
+	APIRatePCS  int `json:"api_rate_pcs"`  // PCS 接口每秒最大请求数
+	APIRatePan  int `json:"api_rate_pan"`  // 网盘首页接口每秒最大请求数
+	APIRateXPan int `json:"api_rate_xpan"` // 开放平台接口每秒最大请求数
+
 	UserAgent      string `json:"user_agent"`           // 浏览器标识
 	PCSUA          string `json:"pcs_ua"`               // PCS浏览器标识
 	PCSAddr        string `json:"pcs_addr"`             // PCS服务器域名
@@ -60,12 +84,17 @@ type PCSConfig struct {
 	NoCheck        bool   `json:"no_check"`             // 禁用下载md5校验
 	IgnoreIllegal  bool   `json:"ignore_illegal"`       // 禁用上传文件名非法字符检查
 	UPolicy        string `json:"u_policy"`             // 上传重名文件处理策略
+	MetaCache      bool   `json:"meta_cache"`           // 启用持久化的目录列表和元信息缓存
 
 	configFilePath string
 	configFile     *os.File
 	fileMu         sync.Mutex
 	activeUser     *Baidu
 	pcs            *baidupcs.BaiduPCS
+
+	scheduleMu              sync.Mutex
+	bandwidthSchedule       BandwidthSchedule // 解析后的时间段限速
+	bandwidthScheduleSource string            // 解析时的 BandwidthSchedule, 用于检测配置重载
 }
 
 // NewConfig 返回 PCSConfig 指针对象
@@ -88,6 +117,18 @@ func (c *PCSConfig) Reload() error {
 
 // Close 关闭配置文件
 func (c *PCSConfig) Close() error {
+	if c.pcs != nil && c.pcs.MetaCache() != nil {
+		err := c.pcs.MetaCache().Save()
+		if err != nil {
+			pcsConfigVerbose.Warnf("save meta cache error: %s\n", err)
+		}
+	}
+	if sumCache != nil {
+		err := sumCache.Save()
+		if err != nil {
+			pcsConfigVerbose.Warnf("save sum cache error: %s\n", err)
+		}
+	}
 	if c.configFile != nil {
 		err := c.configFile.Close()
 		c.configFile = nil
@@ -221,20 +262,52 @@ func (c *PCSConfig) loadConfigFromFile() (err error) {
 		return err
 	}
 
-	err = jsonhelper.UnmarshalData(c.configFile, c)
+	data, err := io.ReadAll(c.configFile)
+	if err != nil {
+		return err
+	}
+	err = jsoniter.Unmarshal(data, c)
 	if err != nil {
 		return ErrConfigContentsParseError
 	}
+	c.fillMissingAPIRates(data)
 	return nil
 }
 
+// fillMissingAPIRates 旧版本的配置文件没有接口限速配置项, 使用默认值, 而不是 0 (不限制)
+func (c *PCSConfig) fillMissingAPIRates(data []byte) {
+	for _, item := range []struct {
+		key         string
+		rate        *int
+		defaultRate int
+	}{
+		{"api_rate_pcs", &c.APIRatePCS, baidupcs.DefaultAPIRatePCS},
+		{"api_rate_pan", &c.APIRatePan, baidupcs.DefaultAPIRatePan},
+		{"api_rate_xpan", &c.APIRateXPan, baidupcs.DefaultAPIRateXPan},
+	} {
+		if jsoniter.Get(data, item.key).ValueType() == jsoniter.InvalidValue {
+			*item.rate = item.defaultRate
+		}
+	}
+}
+
 func (c *PCSConfig) InitDefaultConfig() {
 	c.AppID = 266719
 	c.CacheSize = 65536
 	c.MaxParallel = 1
+	c.MinParallel = 1
 	c.MaxUploadParallel = 4
 	c.MaxUploadLoad = 4
 	c.MaxDownloadLoad = 1
+	c.HashParallel = 2
+	c.HashIOParallel = 1
+	c.BatchSize = baidupcs.DefaultBatchSize
+	c.BatchParallel = baidupcs.DefaultBatchParallel
+	c.ListPageSize = baidupcs.DefaultListPageSize
+	c.RecurseParallel = baidupcs.DefaultRecurseParallel
+	c.APIRatePCS = baidupcs.DefaultAPIRatePCS
+	c.APIRatePan = baidupcs.DefaultAPIRatePan
+	c.APIRateXPan = baidupcs.DefaultAPIRateXPan
 	c.UserAgent = requester.UserAgent
 	c.PCSUA = ""
 	c.PCSAddr = "pcs.baidu.com"
@@ -319,6 +392,9 @@ func (c *PCSConfig) fix() {
 	if c.MaxParallel < 1 {
 		c.MaxParallel = 1
 	}
+	if c.MinParallel < 1 {
+		c.MinParallel = 1
+	}
 	if c.MaxUploadParallel < 1 {
 		c.MaxUploadParallel = 1
 	}
@@ -328,7 +404,47 @@ func (c *PCSConfig) fix() {
 	if c.MaxUploadLoad < 1 {
 		c.MaxUploadLoad = 1
 	}
+	if c.HashParallel < 1 {
+		c.HashParallel = 2
+	}
+	if c.HashIOParallel < 1 {
+		c.HashIOParallel = 1
+	}
+	if c.BatchSize < 1 {
+		c.BatchSize = baidupcs.DefaultBatchSize
+	}
+	if c.BatchParallel < 1 {
+		c.BatchParallel = baidupcs.DefaultBatchParallel
+	}
+	if c.ListPageSize < 1 {
+		c.ListPageSize = baidupcs.DefaultListPageSize
+	}
+	if c.RecurseParallel < 1 {
+		c.RecurseParallel = baidupcs.DefaultRecurseParallel
+	}
+	if c.APIRatePCS < 0 {
+		c.APIRatePCS = 0
+	}
+	if c.APIRatePan < 0 {
+		c.APIRatePan = 0
+	}
+	if c.APIRateXPan < 0 {
+		c.APIRateXPan = 0
+	}
 	if c.UPolicy != baidupcs.SkipPolicy && c.UPolicy != baidupcs.OverWritePolicy && c.UPolicy != baidupcs.RsyncPolicy {
 		c.UPolicy = baidupcs.SkipPolicy
 	}
 }
+
+// OpenSumCache 打开本地文件摘要缓存, 出错时返回空
+func OpenSumCache() *checksum.SumCache {
+	sumCacheOnce.Do(func() {
+		sc, err := checksum.OpenSumCache(filepath.Join(GetConfigDir(), SumCacheName))
+		if err != nil {
+			pcsConfigVerbose.Warnf("open sum cache error: %s\n", err)
+			return
+		}
+		sumCache = sc
+	})
+	return sumCache
+}
@@ -0,0 +1,48 @@
+package pcsconfig_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/qjfoidnh/BaiduPCS-Go/baidupcs"
+	"github.com/qjfoidnh/BaiduPCS-Go/internal/pcsconfig"
+)
+
+func loadConfig(t *testing.T, data string) *pcsconfig.PCSConfig {
+	configPath := filepath.Join(t.TempDir(), pcsconfig.ConfigName)
+	if err := os.WriteFile(configPath, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+	c := pcsconfig.NewConfig(configPath)
+	if err := c.Init(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		c.Close()
+	})
+	return c
+}
+
+func TestAPIRateConfig(t *testing.T) {
+	// 旧版本的配置文件没有接口限速配置项, 使用默认值
+	c := loadConfig(t, `{"appid":266719,"batch_size":50}`)
+	if c.APIRatePCS != baidupcs.DefaultAPIRatePCS || c.APIRatePan != baidupcs.DefaultAPIRatePan || c.APIRateXPan != baidupcs.DefaultAPIRateXPan {
+		t.Errorf("missing api rates: %d %d %d", c.APIRatePCS, c.APIRatePan, c.APIRateXPan)
+	}
+	if c.BatchSize != 50 {
+		t.Errorf("batch size %d", c.BatchSize)
+	}
+
+	// 0 代表不限制, 保留用户的设置, 负数修正为 0
+	c = loadConfig(t, `{"appid":266719,"api_rate_pcs":0,"api_rate_pan":5,"api_rate_xpan":-1}`)
+	if c.APIRatePCS != 0 || c.APIRatePan != 5 || c.APIRateXPan != -1 {
+		t.Errorf("api rates: %d %d %d", c.APIRatePCS, c.APIRatePan, c.APIRateXPan)
+	}
+	if err := c.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if c.APIRateXPan != 0 {
+		t.Errorf("negative api rate fixed to %d", c.APIRateXPan)
+	}
+}
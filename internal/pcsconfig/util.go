@@ -2,6 +2,7 @@ package pcsconfig
 
 import (
 	"github.com/qjfoidnh/BaiduPCS-Go/pcsutil/converter"
+	"strconv"
 	"strings"
 )
 
@@ -32,3 +33,10 @@ func showMaxRate(size int64) string {
 	}
 	return converter.ConvertFileSize(size, 2) + "/s"
 }
+
+func showAPIRate(rate int) string {
+	if rate <= 0 {
+		return "不限制"
+	}
+	return strconv.Itoa(rate) + "/s"
+}
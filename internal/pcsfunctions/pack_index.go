@@ -0,0 +1,122 @@
+package pcsfunctions
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/klauspost/compress/zstd"
+	"strings"
+)
+
+const (
+	// PackFormatTar 打包为 tar
+	PackFormatTar = "tar"
+	// PackFormatTarZst 打包为 tar 并使用 zstd 压缩, 每个数据块为独立的 zstd frame, 可以单独解压
+	PackFormatTarZst = "tar.zst"
+
+	// PackIndexSuffix 打包上传的索引文件后缀, 索引文件名为 <包名>.pcspack.json
+	PackIndexSuffix = ".pcspack.json"
+	// PackIndexVersion 索引文件的版本
+	PackIndexVersion = 1
+)
+
+var (
+	// ErrPackIndexInvalid 索引文件格式错误
+	ErrPackIndexInvalid = errors.New("打包索引格式错误")
+
+	// EncodeAll 和 DecodeAll 可以并发调用
+	zstdEncoder, _ = zstd.NewWriter(nil)
+	zstdDecoder, _ = zstd.NewReader(nil)
+)
+
+type (
+	// PackVolume 打包的分卷
+	PackVolume struct {
+		Name string `json:"name"` // 分卷文件名, 与索引文件位于同一目录
+		Size int64  `json:"size"`
+	}
+
+	// PackMember 打包的文件.
+	// 读取分卷中 [Offset, Offset+Length) 的数据, tar.zst 格式需要先解压,
+	// 文件内容为其中 [DataOffset, DataOffset+Size) 的数据
+	PackMember struct {
+		Path       string `json:"path"` // 相对于上传目录的路径
+		Size       int64  `json:"size"`
+		Mode       int64  `json:"mode"`
+		MTime      int64  `json:"mtime"`
+		Volume     int    `json:"volume"` // 所在分卷的序号, 从0开始
+		Offset     int64  `json:"offset"`
+		Length     int64  `json:"length"`
+		DataOffset int64  `json:"data_offset"`
+	}
+
+	// PackIndex 打包上传的索引, 记录各个文件所在的分卷和偏移量, 用于单独取出文件
+	PackIndex struct {
+		Version int           `json:"version"`
+		Format  string        `json:"format"`
+		Volumes []*PackVolume `json:"volumes"`
+		Members []*PackMember `json:"members"`
+	}
+)
+
+// ParsePackFormat 检查打包格式
+func ParsePackFormat(format string) (string, error) {
+	switch strings.ToLower(format) {
+	case PackFormatTar:
+		return PackFormatTar, nil
+	case PackFormatTarZst, "tzst":
+		return PackFormatTarZst, nil
+	}
+	return "", fmt.Errorf("未知的打包格式: %s, 可选 %s, %s", format, PackFormatTar, PackFormatTarZst)
+}
+
+// IsPackIndex 文件名是否为打包索引
+func IsPackIndex(name string) bool {
+	return strings.HasSuffix(name, PackIndexSuffix) && len(name) > len(PackIndexSuffix)
+}
+
+// PackIndexName 返回包名对应的索引文件名
+func PackIndexName(packName string) string {
+	return packName + PackIndexSuffix
+}
+
+// PackVolumeName 返回第 i 个分卷的文件名, i 从0开始
+func PackVolumeName(packName, format string, i int) string {
+	return fmt.Sprintf("%s.vol%03d.%s", packName, i+1, format)
+}
+
+// ParsePackIndex 解析打包索引
+func ParsePackIndex(data []byte) (*PackIndex, error) {
+	index := &PackIndex{}
+	err := json.Unmarshal(data, index)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", ErrPackIndexInvalid, err)
+	}
+	if index.Version != PackIndexVersion {
+		return nil, fmt.Errorf("%s: 不支持的版本 %d", ErrPackIndexInvalid, index.Version)
+	}
+	if index.Format != PackFormatTar && index.Format != PackFormatTarZst {
+		return nil, fmt.Errorf("%s: 未知的打包格式 %s", ErrPackIndexInvalid, index.Format)
+	}
+	for _, m := range index.Members {
+		if m == nil || m.Volume < 0 || m.Volume >= len(index.Volumes) || m.Offset < 0 || m.DataOffset < 0 || m.Size < 0 {
+			return nil, ErrPackIndexInvalid
+		}
+	}
+	return index, nil
+}
+
+// Marshal 序列化索引
+func (index *PackIndex) Marshal() ([]byte, error) {
+	return json.Marshal(index)
+}
+
+// ZstdCompress 压缩数据, 输出为一个完整的 zstd frame
+func ZstdCompress(data []byte) ([]byte, error) {
+	return zstdEncoder.EncodeAll(data, nil), nil
+}
+
+// ZstdDecompress 解压数据
+func ZstdDecompress(data []byte) ([]byte, error) {
+	return zstdDecoder.DecodeAll(data, nil)
+}
@@ -0,0 +1,37 @@
+package pcsfunctions_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/qjfoidnh/BaiduPCS-Go/internal/pcsfunctions"
+)
+
+func TestZstd(t *testing.T) {
+	data := bytes.Repeat([]byte("BaiduPCS-Go pack "), 10000)
+	compressed, err := pcsfunctions.ZstdCompress(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(compressed) >= len(data) {
+		t.Errorf("compressed size %d, original %d", len(compressed), len(data))
+	}
+
+	// 续传时依赖相同数据的压缩结果相同
+	again, _ := pcsfunctions.ZstdCompress(data)
+	if !bytes.Equal(compressed, again) {
+		t.Error("compression is not deterministic")
+	}
+
+	decompressed, err := pcsfunctions.ZstdDecompress(compressed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decompressed, data) {
+		t.Error("decompressed data mismatch")
+	}
+
+	if _, err = pcsfunctions.ZstdDecompress([]byte("not zstd")); err == nil {
+		t.Error("invalid data decompressed")
+	}
+}
@@ -0,0 +1,253 @@
+package pcsdownload
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/qjfoidnh/BaiduPCS-Go/internal/pcsconfig"
+	"github.com/qjfoidnh/BaiduPCS-Go/pcsutil/converter"
+	"github.com/qjfoidnh/BaiduPCS-Go/pcsutil/jsonhelper"
+)
+
+const (
+	// DownloadQueueFileName 下载队列数据库的文件名
+	DownloadQueueFileName = "pcs_download_queue.json"
+
+	// QueueStatusPending 等待下载
+	QueueStatusPending = "pending"
+	// QueueStatusFailed 下载失败
+	QueueStatusFailed = "failed"
+
+	// queueSaveInterval 状态变更时写入文件的最小间隔, 避免文件过多时频繁写入
+	queueSaveInterval = 1 * time.Second
+)
+
+type (
+	// DownloadQueueItem 下载队列中的文件或目录, 下载成功后从队列中移除
+	DownloadQueueItem struct {
+		UID      uint64 `json:"uid"`       // 所属的百度帐号
+		PcsPath  string `json:"pcs_path"`  // 网盘文件路径
+		SavePath string `json:"save_path"` // 保存的路径
+		Size     int64  `json:"size"`      // 文件大小
+		Status   string `json:"status"`    // 状态
+		Err      string `json:"err,omitempty"`
+		Time     int64  `json:"time"` // 加入队列或状态变更的时间
+	}
+
+	// DownloadQueueDatabase 下载队列数据库, 程序中途退出后可继续下载
+	DownloadQueueDatabase struct {
+		lock      sync.Mutex
+		QueueList []*DownloadQueueItem `json:"queue"`
+		Timestamp int64                `json:"timestamp"`
+
+		index    map[queueKey]int // 帐号和保存路径在 QueueList 中的位置
+		removed  int              // QueueList 中已移除, 置为空的数量
+		dataFile *os.File
+		lastSave time.Time
+	}
+
+	queueKey struct {
+		uid      uint64
+		savePath string
+	}
+)
+
+// NewDownloadQueueDatabase 初始化下载队列数据库, 从库中读取内容
+func NewDownloadQueueDatabase() (dqd *DownloadQueueDatabase, err error) {
+	file, err := os.OpenFile(filepath.Join(pcsconfig.GetConfigDir(), DownloadQueueFileName), os.O_CREATE|os.O_RDWR, 0777)
+	if err != nil {
+		return nil, err
+	}
+
+	dqd = &DownloadQueueDatabase{
+		index:    map[queueKey]int{},
+		dataFile: file,
+	}
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	if info.Size() <= 0 {
+		return dqd, nil
+	}
+
+	err = jsonhelper.UnmarshalData(file, dqd)
+	if err != nil {
+		// 数据损坏, 清空
+		dqd.QueueList = nil
+		pcsDownloadVerbose.Warnf("download queue database parse error: %s\n", err)
+	}
+
+	dqd.compact()
+	return dqd, nil
+}
+
+// compact 移除 QueueList 中已置为空的项目, 重建索引, 调用时需持有锁
+func (dqd *DownloadQueueDatabase) compact() {
+	list := dqd.QueueList[:0]
+	for _, item := range dqd.QueueList {
+		if item != nil {
+			list = append(list, item)
+		}
+	}
+	for k := len(list); k < len(dqd.QueueList); k++ {
+		dqd.QueueList[k] = nil
+	}
+	dqd.QueueList = list
+	dqd.removed = 0
+
+	dqd.index = make(map[queueKey]int, len(list))
+	for k, item := range list {
+		dqd.index[queueKey{item.UID, item.SavePath}] = k
+	}
+}
+
+// Save 保存内容
+func (dqd *DownloadQueueDatabase) Save() error {
+	dqd.lock.Lock()
+	defer dqd.lock.Unlock()
+	return dqd.save()
+}
+
+func (dqd *DownloadQueueDatabase) save() error {
+	if dqd.dataFile == nil {
+		return errors.New("dataFile is nil")
+	}
+
+	if dqd.removed > 0 {
+		dqd.compact()
+	}
+	dqd.Timestamp = time.Now().Unix()
+	dqd.lastSave = time.Now()
+
+	var (
+		builder = &strings.Builder{}
+		err     = jsonhelper.MarshalData(builder, dqd)
+	)
+	if err != nil {
+		panic(err)
+	}
+
+	err = dqd.dataFile.Truncate(int64(builder.Len()))
+	if err != nil {
+		return err
+	}
+
+	_, err = dqd.dataFile.WriteAt(converter.ToBytes(builder.String()), 0)
+	return err
+}
+
+// lazySave 距上次保存超过 queueSaveInterval 才写入文件, 调用时需持有锁
+func (dqd *DownloadQueueDatabase) lazySave() {
+	if time.Since(dqd.lastSave) < queueSaveInterval {
+		return
+	}
+	err := dqd.save()
+	if err != nil {
+		pcsDownloadVerbose.Warnf("save download queue error: %s\n", err)
+	}
+}
+
+func (dqd *DownloadQueueDatabase) indexOf(uid uint64, savePath string) int {
+	k, ok := dqd.index[queueKey{uid, savePath}]
+	if !ok {
+		return -1
+	}
+	return k
+}
+
+// Enqueue 加入队列, 保存路径相同的旧记录会被覆盖
+func (dqd *DownloadQueueDatabase) Enqueue(items ...*DownloadQueueItem) {
+	dqd.lock.Lock()
+	defer dqd.lock.Unlock()
+
+	now := time.Now().Unix()
+	for _, item := range items {
+		item.Status, item.Err, item.Time = QueueStatusPending, "", now
+		key := queueKey{item.UID, item.SavePath}
+		if k, ok := dqd.index[key]; ok {
+			dqd.QueueList[k] = item
+			continue
+		}
+		dqd.index[key] = len(dqd.QueueList)
+		dqd.QueueList = append(dqd.QueueList, item)
+	}
+}
+
+// Done 下载成功, 从队列中移除
+func (dqd *DownloadQueueDatabase) Done(uid uint64, savePath string) {
+	dqd.lock.Lock()
+	defer dqd.lock.Unlock()
+	k := dqd.indexOf(uid, savePath)
+	if k < 0 {
+		return
+	}
+	// 置为空, 保存时再移除, 避免逐个移除时移动整个列表
+	dqd.QueueList[k] = nil
+	delete(dqd.index, queueKey{uid, savePath})
+	dqd.removed++
+	dqd.lazySave()
+}
+
+// Fail 下载失败, 记录错误信息
+func (dqd *DownloadQueueDatabase) Fail(uid uint64, savePath string, errMsg string) {
+	dqd.lock.Lock()
+	defer dqd.lock.Unlock()
+	k := dqd.indexOf(uid, savePath)
+	if k < 0 {
+		return
+	}
+	item := dqd.QueueList[k]
+	item.Status, item.Err, item.Time = QueueStatusFailed, errMsg, time.Now().Unix()
+	dqd.lazySave()
+}
+
+// List 列出帐号的队列, status 为空则列出全部
+func (dqd *DownloadQueueDatabase) List(uid uint64, status string) (items []*DownloadQueueItem) {
+	dqd.lock.Lock()
+	defer dqd.lock.Unlock()
+	for _, item := range dqd.QueueList {
+		if item != nil && item.UID == uid && (status == "" || item.Status == status) {
+			items = append(items, item)
+		}
+	}
+	return
+}
+
+// Clear 清除帐号的队列, status 为空则清除全部, 返回清除的数量
+func (dqd *DownloadQueueDatabase) Clear(uid uint64, status string) (n int) {
+	dqd.lock.Lock()
+	defer dqd.lock.Unlock()
+	for k, item := range dqd.QueueList {
+		if item != nil && item.UID == uid && (status == "" || item.Status == status) {
+			dqd.QueueList[k] = nil
+			n++
+		}
+	}
+	dqd.compact()
+	return
+}
+
+// RetryFailed 将帐号下载失败的项目重新标记为等待下载, 返回标记的数量
+func (dqd *DownloadQueueDatabase) RetryFailed(uid uint64) (n int) {
+	dqd.lock.Lock()
+	defer dqd.lock.Unlock()
+	now := time.Now().Unix()
+	for _, item := range dqd.QueueList {
+		if item != nil && item.UID == uid && item.Status == QueueStatusFailed {
+			item.Status, item.Err, item.Time = QueueStatusPending, "", now
+			n++
+		}
+	}
+	return
+}
+
+// Close 关闭数据库
+func (dqd *DownloadQueueDatabase) Close() error {
+	return dqd.dataFile.Close()
+}
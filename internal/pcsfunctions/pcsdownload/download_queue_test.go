@@ -0,0 +1,104 @@
+package pcsdownload_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/qjfoidnh/BaiduPCS-Go/internal/pcsconfig"
+	"github.com/qjfoidnh/BaiduPCS-Go/internal/pcsfunctions/pcsdownload"
+)
+
+func openQueue(t *testing.T) *pcsdownload.DownloadQueueDatabase {
+	dqd, err := pcsdownload.NewDownloadQueueDatabase()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dqd
+}
+
+func queuePaths(items []*pcsdownload.DownloadQueueItem) (paths []string) {
+	for _, item := range items {
+		paths = append(paths, item.PcsPath)
+	}
+	return
+}
+
+func TestDownloadQueue(t *testing.T) {
+	t.Setenv(pcsconfig.EnvConfigDir, t.TempDir())
+
+	dqd := openQueue(t)
+	dqd.Enqueue(
+		&pcsdownload.DownloadQueueItem{UID: 1, PcsPath: "/a", SavePath: "/save/a", Size: 1},
+		&pcsdownload.DownloadQueueItem{UID: 1, PcsPath: "/b", SavePath: "/save/b", Size: 2},
+		&pcsdownload.DownloadQueueItem{UID: 1, PcsPath: "/c", SavePath: "/save/c", Size: 3},
+		&pcsdownload.DownloadQueueItem{UID: 2, PcsPath: "/a", SavePath: "/save/a", Size: 4},
+	)
+	dqd.Done(1, "/save/a")
+	dqd.Fail(1, "/save/b", "network")
+	// 保存路径相同的记录被覆盖
+	dqd.Enqueue(&pcsdownload.DownloadQueueItem{UID: 1, PcsPath: "/c2", SavePath: "/save/c", Size: 5})
+	if err := dqd.Save(); err != nil {
+		t.Fatal(err)
+	}
+	dqd.Close()
+
+	// 重新打开后继续下载
+	dqd = openQueue(t)
+	defer dqd.Close()
+	if paths := queuePaths(dqd.List(1, "")); len(paths) != 2 || paths[0] != "/b" || paths[1] != "/c2" {
+		t.Errorf("queue of uid 1: %v", paths)
+	}
+	failed := dqd.List(1, pcsdownload.QueueStatusFailed)
+	if len(failed) != 1 || failed[0].Err != "network" {
+		t.Errorf("failed items: %v", queuePaths(failed))
+	}
+	if len(dqd.List(2, pcsdownload.QueueStatusPending)) != 1 {
+		t.Error("queue of uid 2 lost")
+	}
+
+	// 重试失败的项目, 完成后从队列中移除
+	if n := dqd.RetryFailed(1); n != 1 {
+		t.Errorf("retried %d", n)
+	}
+	if items := dqd.List(1, pcsdownload.QueueStatusPending); len(items) != 2 || items[0].Err != "" {
+		t.Errorf("pending after retry: %v", queuePaths(items))
+	}
+	dqd.Done(1, "/save/b")
+	dqd.Done(1, "/save/missing")
+	if paths := queuePaths(dqd.List(1, "")); len(paths) != 1 || paths[0] != "/c2" {
+		t.Errorf("queue after done: %v", paths)
+	}
+
+	// 清除只影响指定帐号
+	if n := dqd.Clear(1, ""); n != 1 {
+		t.Errorf("cleared %d", n)
+	}
+	if len(dqd.List(1, "")) != 0 || len(dqd.List(2, "")) != 1 {
+		t.Error("clear removed other accounts")
+	}
+	dqd.Enqueue(&pcsdownload.DownloadQueueItem{UID: 1, PcsPath: "/d", SavePath: "/save/d"})
+	if paths := queuePaths(dqd.List(1, "")); len(paths) != 1 || paths[0] != "/d" {
+		t.Errorf("enqueue after clear: %v", paths)
+	}
+}
+
+func TestDownloadQueueCorrupted(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv(pcsconfig.EnvConfigDir, dir)
+	err := os.WriteFile(filepath.Join(dir, pcsdownload.DownloadQueueFileName), []byte(`{"queue":[{"uid":1,`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 数据损坏时使用空队列
+	dqd := openQueue(t)
+	defer dqd.Close()
+	if items := dqd.List(1, ""); len(items) != 0 {
+		t.Errorf("items from corrupted file: %v", queuePaths(items))
+	}
+	dqd.Enqueue(&pcsdownload.DownloadQueueItem{UID: 1, PcsPath: "/a", SavePath: "/save/a"})
+	if err = dqd.Save(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -40,15 +40,19 @@ type (
 		// 可选项
 		VerbosePrinter       *pcsverbose.PCSVerbose
 		PrintFormat          string
-		IsPrintStatus        bool // 是否输出各个下载线程的详细信息
-		IsExecutedPermission bool // 下载成功后是否加上执行权限
-		IsOverwrite          bool // 是否覆盖已存在的文件
-		NoCheck              bool // 不校验文件
-		DlinkPrefer          int  // 使用所有备选下载链接中的第几个链接
-		ModifyMTime          bool // 下载的文件mtime修改为与网盘一致
+		IsPrintStatus        bool       // 是否输出各个下载线程的详细信息
+		IsExecutedPermission bool       // 下载成功后是否加上执行权限
+		IsOverwrite          bool       // 是否覆盖已存在的文件
+		VerifyMode           VerifyMode // 下载完成后校验文件的方式
+		Sidecars             int        // 下载完成后生成的校验文件, 见 SidecarMD5, SidecarSHA256
+		DlinkPrefer          int        // 使用所有备选下载链接中的第几个链接
+		ModifyMTime          bool       // 下载的文件mtime修改为与网盘一致
 
 		DownloadMode DownloadMode // 下载模式
 
+		QueueDatabase *DownloadQueueDatabase // 下载队列数据库, 为空则不记录
+		UID           uint64                 // 所属的百度帐号, 用于记录下载队列
+
 		PcsPath  string // 要下载的网盘文件路径
 		SavePath string // 保存的路径
 
@@ -94,7 +98,7 @@ func (dtu *DownloadTaskUnit) verboseInfof(format string, a ...interface{}) {
 }
 
 // download 执行下载
-func (dtu *DownloadTaskUnit) download(downloadURL string, client *requester.HTTPClient) (err error) {
+func (dtu *DownloadTaskUnit) download(downloadURL string, client *requester.HTTPClient, loadBalancers ...string) (err error) {
 	var (
 		writer downloader.Writer
 		file   *os.File
@@ -130,6 +134,7 @@ func (dtu *DownloadTaskUnit) download(downloadURL string, client *requester.HTTP
 	der := downloader.NewDownloader(downloadURL, writer, dtu.Cfg)
 	der.SetClient(client)
 	der.SetDURLCheckFunc(BaiduPCSURLCheckFunc)
+	der.AddLoadBalanceServer(loadBalancers...)
 	//der.SetFileContentLength(dtu.FileInfo.Size)
 	der.SetStatusCodeBodyCheckFunc(func(respBody io.Reader) error {
 		// 返回的错误可能是pcs的json
@@ -162,6 +167,21 @@ func (dtu *DownloadTaskUnit) download(downloadURL string, client *requester.HTTP
 			// 先空两行
 			builder.WriteString("\n\n")
 			tb.Render()
+
+			// 输出各个下载服务器的状态
+			if hostStats := der.HostStats(); len(hostStats) > 1 {
+				htb := pcstable.NewTable(builder)
+				htb.SetHeader([]string{"host", "conns", "requests", "failures", "latency", "speeds", "score"})
+				for _, stat := range hostStats {
+					host := stat.Host
+					if stat.Demoted {
+						host += " (降级)"
+					}
+					htb.Append([]string{host, strconv.Itoa(stat.Connections), strconv.FormatInt(stat.Requests, 10), strconv.FormatInt(stat.Failures, 10), stat.Latency.Round(time.Millisecond).String(), converter.ConvertFileSize(stat.Speeds, 2) + "/s", strconv.FormatFloat(stat.Score, 'f', 0, 64)})
+				}
+				builder.WriteString("\n")
+				htb.Render()
+			}
 		}
 
 		// 如果下载速度为0, 剩余下载时间未知, 则用 - 代替
@@ -172,6 +192,10 @@ func (dtu *DownloadTaskUnit) download(downloadURL string, client *requester.HTTP
 		} else {
 			leftStr = left.String()
 		}
+		if dtu.Cfg.Adaptive {
+			// 输出自适应调整后的并发量
+			leftStr += fmt.Sprintf(", 并发 %d", status.Parallel())
+		}
 
 		fmt.Fprintf(builder, dtu.PrintFormat, dtu.taskInfo.Id(),
 			converter.ConvertFileSize(status.Downloaded(), 2),
@@ -279,15 +303,15 @@ func (dtu *DownloadTaskUnit) handleError(result *taskframework.TaskUnitRunResult
 	}
 }
 
-func (dtu *DownloadTaskUnit) execPanDownload(dlink string, result *taskframework.TaskUnitRunResult, okPtr *bool) {
+func (dtu *DownloadTaskUnit) execPanDownload(dlink string, loadBalancers []string, result *taskframework.TaskUnitRunResult, okPtr *bool) {
 	dtu.verboseInfof("[%s] 获取到下载链接: %s\n", dtu.taskInfo.Id(), dlink)
 
 	client := dtu.panHTTPClient()
 	activePCS := pcsconfig.Config.ActiveUserBaiduPCS()
 	cookieJar := activePCS.GetClient().Jar
-	newCookieJar, _ := CloneJarWithDomain(cookieJar, dlink)
+	newCookieJar, _ := CloneJarWithDomain(cookieJar, append([]string{dlink}, loadBalancers...)...)
 	client.SetCookiejar(newCookieJar)
-	err := dtu.download(dlink, client)
+	err := dtu.download(dlink, client, loadBalancers...)
 	if err != nil {
 		result.ResultMessage = StrDownloadFailed
 		result.Err = err
@@ -318,7 +342,17 @@ func (dtu *DownloadTaskUnit) locateDownload(result *taskframework.TaskUnitRunRes
 	FixHTTPLinkURL(raw_dlink)
 	dlink := raw_dlink.String()
 
-	dtu.execPanDownload(dlink, result, &ok)
+	// 其他的下载链接作为负载均衡服务器, 下载过程中根据状态转移连接
+	loadBalancers := make([]string, 0, len(rawDlinks)-1)
+	for _, link := range rawDlinks {
+		if link == raw_dlink || strings.HasPrefix(link.Host, "nb.cache") {
+			continue
+		}
+		FixHTTPLinkURL(link)
+		loadBalancers = append(loadBalancers, link.String())
+	}
+
+	dtu.execPanDownload(dlink, loadBalancers, result, &ok)
 	return
 }
 
@@ -353,43 +387,43 @@ func (dtu *DownloadTaskUnit) pcsOrStreamingDownload(mode DownloadMode, result *t
 
 // checkFileValid 检测文件有效性
 func (dtu *DownloadTaskUnit) checkFileValid(result *taskframework.TaskUnitRunResult) (ok bool) {
-	fi, err := os.Stat(dtu.SavePath)
-	if err == nil {
-		if fi.Size() != dtu.FileInfo.Size {
-			result.ResultMessage = StrDownloadCheckLengthFailed
-			result.NeedNextdindex = true
-			result.NeedRetry = true
-			return
-		}
-	}
-	if dtu.Cfg.IsTest || dtu.NoCheck {
+	if dtu.Cfg.IsTest || dtu.VerifyMode == VerifyModeNone {
 		// 不检测文件有效性
 		fmt.Printf("[%s] 跳过文件有效性检验\n", dtu.taskInfo.Id())
+		dtu.writeSidecars("")
 		return true
 	}
 
-	if dtu.FileInfo.Size >= 128*converter.MB {
+	if dtu.VerifyMode != VerifyModeSize && dtu.FileInfo.Size >= 128*converter.MB {
 		// 大文件, 输出一句提示消息
 		fmt.Printf("[%s] 开始检验文件有效性, 请稍候...\n", dtu.taskInfo.Id())
 	}
 
 	// 就在这里处理校验出错
-	err = CheckFileValid(dtu.SavePath, dtu.FileInfo)
+	md5Str, err := VerifyFile(dtu.PCS, dtu.SavePath, dtu.FileInfo, dtu.VerifyMode)
 	if err != nil {
 		result.ResultMessage = StrDownloadChecksumFailed
 		result.Err = err
 		switch err {
+		case ErrDownloadSizeMismatch:
+			// 大小不一致, 换下一个下载链接重试
+			result.ResultMessage = StrDownloadCheckLengthFailed
+			result.Err = nil
+			result.NeedNextdindex = true
+			result.NeedRetry = true
+			return
 		case ErrDownloadNotSupportChecksum:
 			// 文件不支持校验
 			result.ResultMessage = "检验文件有效性"
 			result.Err = err
 			fmt.Printf("[%s] 检验文件有效性: %s\n", dtu.taskInfo.Id(), err)
+			dtu.writeSidecars(md5Str)
 			return true
 		case ErrDownloadFileBanned:
 			// 违规文件
 			result.NeedRetry = false
 			return
-		case ErrDownloadChecksumFailed:
+		case ErrDownloadChecksumFailed, ErrDownloadCRC32Failed:
 			// 校验失败, 需要重新下载
 			result.NeedRetry = true
 			// 设置允许覆盖
@@ -401,10 +435,26 @@ func (dtu *DownloadTaskUnit) checkFileValid(result *taskframework.TaskUnitRunRes
 		}
 	}
 
-	fmt.Printf("[%s] 检验文件有效性成功: %s\n", dtu.taskInfo.Id(), dtu.SavePath)
+	if dtu.VerifyMode == VerifyModeSize {
+		fmt.Printf("[%s] 检验文件大小成功: %s\n", dtu.taskInfo.Id(), dtu.SavePath)
+	} else {
+		fmt.Printf("[%s] 检验文件有效性成功: %s\n", dtu.taskInfo.Id(), dtu.SavePath)
+	}
+	dtu.writeSidecars(md5Str)
 	return true
 }
 
+// writeSidecars 生成校验文件, 出错时只输出警告
+func (dtu *DownloadTaskUnit) writeSidecars(md5Str string) {
+	if dtu.Cfg.IsTest || dtu.Sidecars == 0 {
+		return
+	}
+	err := WriteSidecars(dtu.SavePath, dtu.Sidecars, md5Str)
+	if err != nil {
+		fmt.Printf("[%s] 警告, 生成校验文件错误: %s\n", dtu.taskInfo.Id(), err)
+	}
+}
+
 func (dtu *DownloadTaskUnit) OnRetry(lastRunResult *taskframework.TaskUnitRunResult) {
 	// 输出错误信息
 	if lastRunResult.Err == nil {
@@ -416,9 +466,20 @@ func (dtu *DownloadTaskUnit) OnRetry(lastRunResult *taskframework.TaskUnitRunRes
 }
 
 func (dtu *DownloadTaskUnit) OnSuccess(lastRunResult *taskframework.TaskUnitRunResult) {
+	if dtu.QueueDatabase != nil {
+		dtu.QueueDatabase.Done(dtu.UID, dtu.SavePath)
+	}
 }
 
 func (dtu *DownloadTaskUnit) OnFailed(lastRunResult *taskframework.TaskUnitRunResult) {
+	if dtu.QueueDatabase != nil {
+		errMsg := lastRunResult.ResultMessage
+		if lastRunResult.Err != nil {
+			errMsg += ", " + lastRunResult.Err.Error()
+		}
+		dtu.QueueDatabase.Fail(dtu.UID, dtu.SavePath, errMsg)
+	}
+
 	// 失败
 	if lastRunResult.Err == nil {
 		// result中不包含Err, 忽略输出
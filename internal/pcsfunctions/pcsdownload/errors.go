@@ -7,6 +7,10 @@ var (
 	ErrDownloadNotSupportChecksum = errors.New("该文件不支持校验")
 	// ErrDownloadChecksumFailed 文件校验失败
 	ErrDownloadChecksumFailed = errors.New("该文件校验失败, 文件md5值与服务器记录的不匹配")
+	// ErrDownloadCRC32Failed 文件crc32校验失败
+	ErrDownloadCRC32Failed = errors.New("该文件校验失败, 文件crc32值与服务器记录的不匹配")
+	// ErrDownloadSizeMismatch 文件大小不一致
+	ErrDownloadSizeMismatch = errors.New("文件大小与服务器记录的不一致")
 	// ErrDownloadFileBanned 违规文件
 	ErrDownloadFileBanned = errors.New("该文件可能是违规文件, 不支持校验")
 	// ErrDlinkNotFound 未取得下载链接
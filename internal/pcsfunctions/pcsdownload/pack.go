@@ -0,0 +1,76 @@
+package pcsdownload
+
+import (
+	"fmt"
+	"github.com/qjfoidnh/BaiduPCS-Go/baidupcs"
+	"github.com/qjfoidnh/BaiduPCS-Go/internal/pcsfunctions"
+	"io"
+	"path"
+)
+
+type (
+	// PackReader 根据打包索引, 以范围请求从网盘的分卷中读取单个文件
+	PackReader struct {
+		Index *pcsfunctions.PackIndex
+
+		pcs *baidupcs.BaiduPCS
+		dir string // 索引文件所在的网盘目录
+
+		// tar.zst 格式, 缓存最近解压的 frame, 同一个 frame 中的文件只需请求一次
+		frameVolume int
+		frameOffset int64
+		frame       []byte
+	}
+)
+
+// NewPackReader 读取网盘中的打包索引
+func NewPackReader(pcs *baidupcs.BaiduPCS, indexPath string) (*PackReader, error) {
+	data, err := readRemoteFile(pcs, indexPath, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	index, err := pcsfunctions.ParsePackIndex(data)
+	if err != nil {
+		return nil, err
+	}
+	return &PackReader{
+		Index:       index,
+		pcs:         pcs,
+		dir:         path.Dir(indexPath),
+		frameVolume: -1,
+	}, nil
+}
+
+// WriteMember 读取分卷中的文件内容, 写入 w
+func (pr *PackReader) WriteMember(member *pcsfunctions.PackMember, w io.Writer) error {
+	if member.Size == 0 {
+		return nil
+	}
+	volumePath := path.Join(pr.dir, pr.Index.Volumes[member.Volume].Name)
+
+	if pr.Index.Format == pcsfunctions.PackFormatTar {
+		return StreamFile(pr.pcs, volumePath, w, &StreamOptions{
+			Offset:   member.Offset + member.DataOffset,
+			Length:   member.Size,
+			MaxRetry: DefaultDownloadMaxRetry,
+		})
+	}
+
+	if pr.frame == nil || pr.frameVolume != member.Volume || pr.frameOffset != member.Offset {
+		compressed, err := readRemoteFile(pr.pcs, volumePath, member.Offset, member.Length)
+		if err != nil {
+			return err
+		}
+		pr.frame, err = pcsfunctions.ZstdDecompress(compressed)
+		if err != nil {
+			pr.frame = nil
+			return err
+		}
+		pr.frameVolume, pr.frameOffset = member.Volume, member.Offset
+	}
+	if member.DataOffset+member.Size > int64(len(pr.frame)) {
+		return fmt.Errorf("%s: %s 超出数据块范围", pcsfunctions.ErrPackIndexInvalid, member.Path)
+	}
+	_, err := w.Write(pr.frame[member.DataOffset : member.DataOffset+member.Size])
+	return err
+}
@@ -2,11 +2,16 @@ package pcsdownload
 
 import (
 	"github.com/qjfoidnh/BaiduPCS-Go/baidupcs"
+	"github.com/qjfoidnh/BaiduPCS-Go/pcsverbose"
 	"github.com/qjfoidnh/BaiduPCS-Go/requester"
 	"net/http"
 	"strconv"
 )
 
+var (
+	pcsDownloadVerbose = pcsverbose.New("PCSDOWNLOAD")
+)
+
 // IsSkipMd5Checksum 是否忽略某些校验
 func IsSkipMd5Checksum(size int64, md5Str string) bool {
 	switch {
@@ -0,0 +1,179 @@
+package pcsdownload
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/qjfoidnh/BaiduPCS-Go/baidupcs"
+	"github.com/qjfoidnh/BaiduPCS-Go/baidupcs/pcserror"
+	"github.com/qjfoidnh/BaiduPCS-Go/internal/pcsfunctions"
+	"github.com/qjfoidnh/BaiduPCS-Go/requester"
+)
+
+var (
+	// ErrRangeReaderSeek seek 的位置无效
+	ErrRangeReaderSeek = errors.New("seek: invalid offset")
+)
+
+// RangeReader 通过分段请求随机读取网盘文件, 实现 io.ReadSeeker.
+// 顺序读取时复用同一个请求, seek 后重新发起请求, 下载链接在首次读取时获取.
+type RangeReader struct {
+	pcs     *baidupcs.BaiduPCS
+	pcspath string
+	size    int64
+	opts    *StreamOptions
+
+	mu      sync.Mutex
+	client  *requester.HTTPClient
+	dlink   string
+	pos     int64
+	body    io.ReadCloser
+	bodyPos int64
+}
+
+// NewRangeReader 初始化 RangeReader, fd 为要读取的文件
+func NewRangeReader(pcs *baidupcs.BaiduPCS, fd *baidupcs.FileDirectory, opts *StreamOptions) *RangeReader {
+	if opts == nil {
+		opts = &StreamOptions{}
+	}
+	if opts.MaxRetry < 0 {
+		opts.MaxRetry = DefaultDownloadMaxRetry
+	}
+	return &RangeReader{
+		pcs:     pcs,
+		pcspath: fd.Path,
+		size:    fd.Size,
+		opts:    opts,
+	}
+}
+
+// Read 实现 io.Reader
+func (rr *RangeReader) Read(p []byte) (n int, err error) {
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+
+	if rr.pos >= rr.size {
+		return 0, io.EOF
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	for retry := 0; ; retry++ {
+		err = nil
+		if rr.body == nil || rr.bodyPos != rr.pos {
+			err = rr.open(retry > 0)
+		}
+		if err == nil {
+			n, err = rr.body.Read(p)
+			rr.pos += int64(n)
+			rr.bodyPos += int64(n)
+			if err == io.EOF && rr.pos < rr.size {
+				err = io.ErrUnexpectedEOF
+			}
+			if err == nil || err == io.EOF {
+				return n, err
+			}
+
+			// 读取出错, 下次从当前位置重新请求
+			rr.closeBody()
+			if n > 0 {
+				return n, nil
+			}
+		}
+
+		if retry >= rr.opts.MaxRetry {
+			return 0, err
+		}
+		pcsDownloadVerbose.Warnf("read %s at %d error: %s, retry %d/%d\n", rr.pcspath, rr.pos, err, retry+1, rr.opts.MaxRetry)
+		time.Sleep(pcsfunctions.RetryWait(retry + 1))
+	}
+}
+
+// Seek 实现 io.Seeker, 不发起请求
+func (rr *RangeReader) Seek(offset int64, whence int) (int64, error) {
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += rr.pos
+	case io.SeekEnd:
+		offset += rr.size
+	default:
+		return 0, ErrRangeReaderSeek
+	}
+	if offset < 0 {
+		return 0, ErrRangeReaderSeek
+	}
+	rr.pos = offset
+	return offset, nil
+}
+
+// Size 文件大小
+func (rr *RangeReader) Size() int64 {
+	return rr.size
+}
+
+// Close 关闭当前的请求
+func (rr *RangeReader) Close() error {
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+	rr.closeBody()
+	return nil
+}
+
+func (rr *RangeReader) closeBody() {
+	if rr.body != nil {
+		rr.body.Close()
+		rr.body = nil
+	}
+}
+
+// open 从当前位置发起请求, refresh 为 true 时重新获取下载链接
+func (rr *RangeReader) open(refresh bool) (err error) {
+	rr.closeBody()
+	if rr.dlink == "" || refresh {
+		rr.client, rr.dlink, err = streamDownloadLink(rr.pcs, rr.pcspath, rr.opts)
+		if err != nil {
+			return err
+		}
+	}
+
+	resp, err := rr.client.Req(http.MethodGet, rr.dlink, nil, map[string]string{
+		"Range": "bytes=" + strconv.FormatInt(rr.pos, 10) + "-" + strconv.FormatInt(rr.size-1, 10),
+	})
+	if err != nil {
+		if resp != nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+	case http.StatusOK:
+		if rr.pos != 0 {
+			resp.Body.Close()
+			return ErrStreamRangeNotSupported
+		}
+	default:
+		defer resp.Body.Close()
+		// 返回的错误可能是pcs的json
+		pcsError := pcserror.DecodePCSJSONError(baidupcs.OperationDownloadFile, resp.Body)
+		if pcsError != nil {
+			return pcsError
+		}
+		return fmt.Errorf("http status: %s", resp.Status)
+	}
+
+	rr.body = resp.Body
+	rr.bodyPos = rr.pos
+	return nil
+}
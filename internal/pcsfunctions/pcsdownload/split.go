@@ -0,0 +1,106 @@
+package pcsdownload
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"github.com/qjfoidnh/BaiduPCS-Go/baidupcs"
+	"github.com/qjfoidnh/BaiduPCS-Go/internal/pcsfunctions"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FetchSplitManifest 读取网盘中的分卷清单
+func FetchSplitManifest(pcs *baidupcs.BaiduPCS, pcspath string) (*pcsfunctions.SplitManifest, error) {
+	data, err := readRemoteFile(pcs, pcspath, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	return pcsfunctions.ParseSplitManifest(data)
+}
+
+// readRemoteFile 读取网盘文件 [offset, offset+length) 的数据到内存, length 小于等于0代表到文件末尾
+func readRemoteFile(pcs *baidupcs.BaiduPCS, pcspath string, offset, length int64) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	err := StreamFile(pcs, pcspath, buf, &StreamOptions{
+		Offset:   offset,
+		Length:   length,
+		Parallel: 1,
+		MaxRetry: DefaultDownloadMaxRetry,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// JoinSplitParts 将目录 dir 中已下载的分卷按清单合并为原文件, 校验原文件的大小和md5,
+// 合并成功后删除分卷, 返回原文件的路径
+func JoinSplitParts(dir string, m *pcsfunctions.SplitManifest) (string, error) {
+	err := m.Validate()
+	if err != nil {
+		return "", err
+	}
+	for _, part := range m.Parts {
+		info, err := os.Stat(filepath.Join(dir, part.Name))
+		if err != nil {
+			return "", fmt.Errorf("分卷 %s 不存在", part.Name)
+		}
+		if info.Size() != part.Size {
+			return "", fmt.Errorf("分卷 %s 大小 %d 与清单记录的 %d 不一致", part.Name, info.Size(), part.Size)
+		}
+	}
+
+	var (
+		outPath = filepath.Join(dir, m.Name)
+		tmpPath = outPath + ".joining"
+	)
+	out, err := os.Create(tmpPath)
+	if err != nil {
+		return "", err
+	}
+
+	h := md5.New()
+	w := io.MultiWriter(out, h)
+	for _, part := range m.Parts {
+		err = appendFile(w, filepath.Join(dir, part.Name))
+		if err != nil {
+			break
+		}
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		if md5Str := hex.EncodeToString(h.Sum(nil)); !strings.EqualFold(md5Str, m.MD5) {
+			err = fmt.Errorf("合并后的md5 %s 与清单记录的 %s 不一致", md5Str, m.MD5)
+		}
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return "", err
+	}
+
+	err = os.Rename(tmpPath, outPath)
+	if err != nil {
+		os.Remove(tmpPath)
+		return "", err
+	}
+	for _, part := range m.Parts {
+		os.Remove(filepath.Join(dir, part.Name))
+	}
+	return outPath, nil
+}
+
+func appendFile(w io.Writer, filePath string) error {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(w, f)
+	return err
+}
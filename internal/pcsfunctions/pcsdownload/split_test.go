@@ -0,0 +1,123 @@
+package pcsdownload_test
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/qjfoidnh/BaiduPCS-Go/internal/pcsfunctions"
+	"github.com/qjfoidnh/BaiduPCS-Go/internal/pcsfunctions/pcsdownload"
+)
+
+// writeSplitParts 将 data 按 partSize 写入 dir 中的分卷, 返回清单
+func writeSplitParts(t *testing.T, dir, name string, data []byte, partSize int) *pcsfunctions.SplitManifest {
+	sum := md5.Sum(data)
+	m := &pcsfunctions.SplitManifest{
+		Version:  pcsfunctions.SplitManifestVersion,
+		Name:     name,
+		Size:     int64(len(data)),
+		MD5:      hex.EncodeToString(sum[:]),
+		PartSize: int64(partSize),
+	}
+	for i := 0; i*partSize < len(data); i++ {
+		part := data[i*partSize : min((i+1)*partSize, len(data))]
+		partSum := md5.Sum(part)
+		m.Parts = append(m.Parts, &pcsfunctions.SplitPart{
+			Name: pcsfunctions.SplitPartName(name, i),
+			Size: int64(len(part)),
+			MD5:  hex.EncodeToString(partSum[:]),
+		})
+		if err := os.WriteFile(filepath.Join(dir, m.Parts[i].Name), part, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return m
+}
+
+func TestJoinSplitParts(t *testing.T) {
+	dir := t.TempDir()
+	data := bytes.Repeat([]byte("0123456789"), 25)
+	m := writeSplitParts(t, dir, "a.bin", data, 100)
+
+	outPath, err := pcsdownload.JoinSplitParts(dir, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if outPath != filepath.Join(dir, "a.bin") {
+		t.Errorf("outPath: %s", outPath)
+	}
+	joined, err := os.ReadFile(outPath)
+	if err != nil || !bytes.Equal(joined, data) {
+		t.Fatalf("joined data mismatch, err: %v", err)
+	}
+
+	// 合并成功后删除分卷
+	for _, part := range m.Parts {
+		if _, err := os.Stat(filepath.Join(dir, part.Name)); !os.IsNotExist(err) {
+			t.Errorf("part %s not removed", part.Name)
+		}
+	}
+}
+
+func TestJoinSplitPartsMismatch(t *testing.T) {
+	dir := t.TempDir()
+	data := bytes.Repeat([]byte("abc"), 50)
+	m := writeSplitParts(t, dir, "b.bin", data, 64)
+
+	// md5 不一致时不生成原文件, 保留分卷
+	m.MD5 = "00000000000000000000000000000000"
+	if _, err := pcsdownload.JoinSplitParts(dir, m); err == nil {
+		t.Fatal("md5 mismatch not detected")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "b.bin")); !os.IsNotExist(err) {
+		t.Error("output file created on md5 mismatch")
+	}
+	for _, part := range m.Parts {
+		if _, err := os.Stat(filepath.Join(dir, part.Name)); err != nil {
+			t.Errorf("part %s removed: %s", part.Name, err)
+		}
+	}
+
+	// 分卷缺失
+	os.Remove(filepath.Join(dir, m.Parts[1].Name))
+	if _, err := pcsdownload.JoinSplitParts(dir, m); err == nil {
+		t.Fatal("missing part not detected")
+	}
+}
+
+func TestJoinSplitPartsTraversal(t *testing.T) {
+	var (
+		root = t.TempDir()
+		dir  = filepath.Join(root, "download")
+	)
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	// 分卷位于下载目录之外
+	if err := os.WriteFile(filepath.Join(root, "secret"), []byte("abc"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	sum := md5.Sum([]byte("abc"))
+	m := &pcsfunctions.SplitManifest{
+		Version: pcsfunctions.SplitManifestVersion,
+		Name:    "c.bin",
+		Size:    3,
+		MD5:     hex.EncodeToString(sum[:]),
+		Parts: []*pcsfunctions.SplitPart{
+			{Name: "../secret", Size: 3},
+		},
+	}
+	if _, err := pcsdownload.JoinSplitParts(dir, m); err == nil {
+		t.Fatal("traversal part name accepted")
+	}
+	if _, err := os.Stat(filepath.Join(root, "secret")); err != nil {
+		t.Errorf("file outside of dir removed: %s", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "c.bin")); !os.IsNotExist(err) {
+		t.Error("output file created")
+	}
+}
@@ -0,0 +1,287 @@
+package pcsdownload
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/qjfoidnh/BaiduPCS-Go/baidupcs"
+	"github.com/qjfoidnh/BaiduPCS-Go/baidupcs/pcserror"
+	"github.com/qjfoidnh/BaiduPCS-Go/internal/pcsconfig"
+	"github.com/qjfoidnh/BaiduPCS-Go/internal/pcsfunctions"
+	"github.com/qjfoidnh/BaiduPCS-Go/pcsutil/converter"
+	"github.com/qjfoidnh/BaiduPCS-Go/requester"
+)
+
+const (
+	// DefaultStreamBlockSize 流式输出时每个分段请求的大小
+	DefaultStreamBlockSize = 2 * converter.MB
+)
+
+var (
+	// ErrStreamIsDir 目录无法输出
+	ErrStreamIsDir = errors.New("is a directory")
+	// ErrStreamRangeNotSupported 服务器不支持分段请求
+	ErrStreamRangeNotSupported = errors.New("server does not support range requests")
+)
+
+type (
+	// StreamOptions 流式输出可选参数
+	StreamOptions struct {
+		Offset       int64        // 起始位置
+		Length       int64        // 输出的长度, 小于等于0代表到文件末尾
+		Parallel     int          // 同时进行的分段请求数量, 小于1则使用 max_parallel
+		BlockSize    int64        // 每个分段请求的大小, 小于等于0则使用默认值
+		MaxRetry     int          // 每个分段请求失败的最大重试次数
+		DownloadMode DownloadMode // 下载模式
+		DlinkPrefer  int          // 使用所有备选下载链接中的第几个链接
+	}
+
+	// streamBlock 分段请求的结果, 按顺序写出
+	streamBlock struct {
+		offset, end int64 // [offset, end)
+		data        []byte
+		err         error
+		done        chan struct{}
+	}
+
+	streamer struct {
+		ctx    context.Context
+		client *requester.HTTPClient
+		dlink  string
+		opts   *StreamOptions
+	}
+)
+
+// StreamFile 以分段请求获取网盘文件, 多个分段并发请求, 按顺序写入 w, 不落盘
+func StreamFile(pcs *baidupcs.BaiduPCS, pcspath string, w io.Writer, opts *StreamOptions) error {
+	if opts == nil {
+		opts = &StreamOptions{}
+	}
+	if opts.Parallel < 1 {
+		opts.Parallel = pcsconfig.Config.MaxParallel
+	}
+	if opts.Parallel < 1 {
+		opts.Parallel = 1
+	}
+	if opts.BlockSize <= 0 {
+		opts.BlockSize = DefaultStreamBlockSize
+	}
+	if opts.MaxRetry < 0 {
+		opts.MaxRetry = DefaultDownloadMaxRetry
+	}
+
+	fd, pcsError := pcs.FilesDirectoriesMeta(pcspath)
+	if pcsError != nil {
+		return pcsError
+	}
+	if fd.Isdir {
+		return fmt.Errorf("%s: %s", pcspath, ErrStreamIsDir)
+	}
+
+	end := fd.Size
+	if opts.Offset >= end {
+		return nil
+	}
+	if opts.Length > 0 && opts.Offset+opts.Length < end {
+		end = opts.Offset + opts.Length
+	}
+
+	client, dlink, err := streamDownloadLink(pcs, pcspath, opts)
+	if err != nil {
+		return err
+	}
+	pcsDownloadVerbose.Infof("stream %s, range: %d-%d, link: %s\n", pcspath, opts.Offset, end, dlink)
+
+	s := &streamer{
+		client: client,
+		dlink:  dlink,
+		opts:   opts,
+	}
+	return s.copyRange(w, opts.Offset, end)
+}
+
+// copyRange 并发请求 [start, end) 的各个分段, 按顺序写入 w.
+// 同时进行的请求不超过 Parallel 个, 等待写出的分段数量也有上限, 以限制内存占用.
+func (s *streamer) copyRange(w io.Writer, start, end int64) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.ctx = ctx
+
+	var (
+		opts   = s.opts
+		err    error
+		failed int32 // 有分段请求失败后, 不再发起新的请求
+		wg     sync.WaitGroup
+		blocks = make(chan *streamBlock, opts.Parallel)
+		sem    = make(chan struct{}, opts.Parallel)
+	)
+	// 返回前等待进行中的请求结束, 避免返回后仍有请求发出
+	defer func() {
+		cancel()
+		for range blocks {
+		}
+		wg.Wait()
+	}()
+	go func() {
+		defer close(blocks)
+		for offset := start; offset < end; offset += opts.BlockSize {
+			b := &streamBlock{
+				offset: offset,
+				end:    offset + opts.BlockSize,
+				done:   make(chan struct{}),
+			}
+			if b.end > end {
+				b.end = end
+			}
+
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			if atomic.LoadInt32(&failed) != 0 {
+				<-sem
+				return
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer func() { <-sem }()
+				defer close(b.done)
+				b.data, b.err = s.fetch(b.offset, b.end)
+				if b.err != nil {
+					atomic.StoreInt32(&failed, 1)
+				}
+			}()
+
+			select {
+			case blocks <- b:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	for b := range blocks {
+		<-b.done
+		if b.err != nil {
+			return b.err
+		}
+		_, err = w.Write(b.data)
+		if err != nil {
+			return err
+		}
+		b.data = nil
+	}
+	return nil
+}
+
+// streamDownloadLink 根据下载模式获取下载链接和对应的 HTTPClient
+func streamDownloadLink(pcs *baidupcs.BaiduPCS, pcspath string, opts *StreamOptions) (client *requester.HTTPClient, dlink string, err error) {
+	switch opts.DownloadMode {
+	case DownloadModePCS, DownloadModeStreaming:
+		dfunc := func(downloadURL string, jar http.CookieJar) error {
+			client = pcsconfig.Config.PCSHTTPClient()
+			client.SetCookiejar(jar)
+			dlink = downloadURL
+			return nil
+		}
+		if opts.DownloadMode == DownloadModePCS {
+			err = pcs.DownloadFile(pcspath, dfunc)
+		} else {
+			err = pcs.DownloadStreamFile(pcspath, dfunc)
+		}
+		if err != nil {
+			return nil, "", err
+		}
+	default:
+		rawDlinks, err := GetLocateDownloadLinks(pcs, pcspath)
+		if err != nil {
+			return nil, "", err
+		}
+		prefer := opts.DlinkPrefer
+		if prefer < 0 || prefer >= len(rawDlinks) {
+			prefer = len(rawDlinks) - 1
+		}
+		rawDlink := rawDlinks[prefer]
+		// 跳过nb.cache这种还没有证书的
+		if strings.HasPrefix(rawDlink.Host, "nb.cache") && len(rawDlinks) > prefer+1 {
+			rawDlink = rawDlinks[prefer+1]
+		}
+		FixHTTPLinkURL(rawDlink)
+		dlink = rawDlink.String()
+
+		client = pcsconfig.Config.PanHTTPClient()
+		jar, _ := CloneJarWithDomain(pcs.GetClient().Jar, dlink)
+		client.SetCookiejar(jar)
+	}
+
+	client.SetKeepAlive(true)
+	client.SetTimeout(2 * time.Minute)
+	return client, dlink, nil
+}
+
+// fetch 获取 [offset, end) 的数据, 失败时重试
+func (s *streamer) fetch(offset, end int64) (data []byte, err error) {
+	for retry := 0; ; retry++ {
+		if s.ctx.Err() != nil {
+			return nil, s.ctx.Err()
+		}
+		data, err = s.fetchOnce(offset, end)
+		if err == nil || err == ErrStreamRangeNotSupported || retry >= s.opts.MaxRetry {
+			return
+		}
+		pcsDownloadVerbose.Warnf("stream range %d-%d error: %s, retry %d/%d\n", offset, end-1, err, retry+1, s.opts.MaxRetry)
+		select {
+		case <-time.After(pcsfunctions.RetryWait(retry + 1)):
+		case <-s.ctx.Done():
+			return nil, s.ctx.Err()
+		}
+	}
+}
+
+func (s *streamer) fetchOnce(offset, end int64) ([]byte, error) {
+	resp, err := s.client.Req(http.MethodGet, s.dlink, nil, map[string]string{
+		"Range": "bytes=" + strconv.FormatInt(offset, 10) + "-" + strconv.FormatInt(end-1, 10),
+	})
+	if resp != nil {
+		defer resp.Body.Close()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+	case http.StatusOK:
+		// 整个文件只有这一段时, 服务器可能忽略 Range
+		if offset != 0 || resp.ContentLength != end {
+			return nil, ErrStreamRangeNotSupported
+		}
+	default:
+		// 返回的错误可能是pcs的json
+		pcsError := pcserror.DecodePCSJSONError(baidupcs.OperationDownloadFile, resp.Body)
+		if pcsError != nil {
+			return nil, pcsError
+		}
+		return nil, fmt.Errorf("http status: %s", resp.Status)
+	}
+
+	buf := bytes.NewBuffer(make([]byte, 0, end-offset))
+	_, err = io.Copy(buf, io.LimitReader(resp.Body, end-offset))
+	if err != nil {
+		return nil, err
+	}
+	if int64(buf.Len()) != end-offset {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,199 @@
+package pcsdownload_test
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/qjfoidnh/BaiduPCS-Go/baidupcs"
+	"github.com/qjfoidnh/BaiduPCS-Go/internal/pcsfunctions/pcsdownload"
+	"github.com/qjfoidnh/BaiduPCS-Go/requester"
+)
+
+// fakeDownload 模拟网盘的元信息和 PCS 下载接口, 只有一个文件 /f
+type fakeDownload struct {
+	data []byte
+
+	mu      sync.Mutex
+	ranges  []string // 收到的下载请求的 Range
+	fail    int      // 接下来的下载请求返回错误的次数
+	noRange bool     // 忽略 Range, 返回整个文件
+}
+
+func (fd *fakeDownload) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Query().Get("method") {
+	case "meta":
+		io.WriteString(w, `{"list":[{"fs_id":1,"path":"/f","server_filename":"f","size":`+strconv.Itoa(len(fd.data))+`,"isdir":0}]}`)
+	case "download":
+		fd.mu.Lock()
+		fd.ranges = append(fd.ranges, r.Header.Get("Range"))
+		fail := fd.fail > 0
+		if fail {
+			fd.fail--
+		}
+		fd.mu.Unlock()
+
+		if fail {
+			w.WriteHeader(http.StatusInternalServerError)
+			io.WriteString(w, `{"error_code":31326,"error_msg":"anti hotlinking"}`)
+			return
+		}
+		if fd.noRange {
+			r.Header.Del("Range")
+		}
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(fd.data))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (fd *fakeDownload) takeRanges() []string {
+	fd.mu.Lock()
+	defer fd.mu.Unlock()
+	ranges := fd.ranges
+	fd.ranges = nil
+	return ranges
+}
+
+// newFakeDownload 启动模拟服务器, 通过代理将所有请求发送到模拟服务器
+func newFakeDownload(t *testing.T, size int) (*fakeDownload, *baidupcs.BaiduPCS) {
+	fd := &fakeDownload{data: make([]byte, size)}
+	rand.Read(fd.data)
+	srv := httptest.NewServer(fd)
+	requester.SetGlobalProxy(srv.Listener.Addr().String())
+	t.Cleanup(func() {
+		requester.SetGlobalProxy("")
+		srv.Close()
+	})
+
+	pcs := baidupcs.NewPCS(0, "")
+	pcs.SetPanUserAgent(baidupcs.NetdiskUA)
+	pcs.GetClient()
+	pcs.SetHTTPS(false)
+	pcs.SetStaticPCSAddr(true)
+	return fd, pcs
+}
+
+func TestStreamFile(t *testing.T) {
+	fd, pcs := newFakeDownload(t, 10000)
+
+	// 分段并发请求, 按顺序写出
+	buf := &bytes.Buffer{}
+	err := pcsdownload.StreamFile(pcs, "/f", buf, &pcsdownload.StreamOptions{
+		Offset:       100,
+		Length:       5000,
+		Parallel:     3,
+		BlockSize:    1024,
+		DownloadMode: pcsdownload.DownloadModePCS,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), fd.data[100:5100]) {
+		t.Errorf("got %d bytes, content mismatch", buf.Len())
+	}
+	if ranges := fd.takeRanges(); len(ranges) != 5 {
+		t.Errorf("ranges %v", ranges)
+	}
+
+	// 长度超出文件末尾时输出到文件末尾
+	buf.Reset()
+	err = pcsdownload.StreamFile(pcs, "/f", buf, &pcsdownload.StreamOptions{
+		Offset:       9000,
+		Length:       5000,
+		BlockSize:    4096,
+		Parallel:     1,
+		DownloadMode: pcsdownload.DownloadModePCS,
+	})
+	if err != nil || !bytes.Equal(buf.Bytes(), fd.data[9000:]) {
+		t.Errorf("tail: %d bytes, err %v", buf.Len(), err)
+	}
+
+	// 服务器不支持 Range 时不重试
+	fd.takeRanges()
+	fd.noRange = true
+	err = pcsdownload.StreamFile(pcs, "/f", io.Discard, &pcsdownload.StreamOptions{
+		Offset:       1024,
+		BlockSize:    1024,
+		Parallel:     1,
+		MaxRetry:     3,
+		DownloadMode: pcsdownload.DownloadModePCS,
+	})
+	if err != pcsdownload.ErrStreamRangeNotSupported {
+		t.Errorf("err %v", err)
+	}
+	if ranges := fd.takeRanges(); len(ranges) != 1 {
+		t.Errorf("ranges without range support %v", ranges)
+	}
+}
+
+func TestRangeReader(t *testing.T) {
+	fd, pcs := newFakeDownload(t, 10000)
+	meta, pcsError := pcs.FilesDirectoriesMeta("/f")
+	if pcsError != nil {
+		t.Fatal(pcsError)
+	}
+
+	rr := pcsdownload.NewRangeReader(pcs, meta, &pcsdownload.StreamOptions{
+		MaxRetry:     1,
+		DownloadMode: pcsdownload.DownloadModePCS,
+	})
+	defer rr.Close()
+	if rr.Size() != 10000 {
+		t.Errorf("size %d", rr.Size())
+	}
+
+	// 顺序读取复用同一个请求
+	var (
+		p   = make([]byte, 100)
+		err error
+	)
+	for i := 0; i < 2; i++ {
+		if _, err = io.ReadFull(rr, p); err != nil || !bytes.Equal(p, fd.data[i*100:(i+1)*100]) {
+			t.Fatalf("read %d: %v", i, err)
+		}
+	}
+	if ranges := fd.takeRanges(); len(ranges) != 1 || ranges[0] != "bytes=0-9999" {
+		t.Errorf("sequential ranges %v", ranges)
+	}
+
+	// seek 后从新的位置请求
+	if pos, err := rr.Seek(5000, io.SeekStart); err != nil || pos != 5000 {
+		t.Fatalf("seek: %d, %v", pos, err)
+	}
+	rest, err := io.ReadAll(rr)
+	if err != nil || !bytes.Equal(rest, fd.data[5000:]) {
+		t.Errorf("read after seek: %d bytes, err %v", len(rest), err)
+	}
+	if pos, _ := rr.Seek(-10, io.SeekEnd); pos != 9990 {
+		t.Errorf("seek end: %d", pos)
+	}
+	if _, err = io.ReadFull(rr, p[:10]); err != nil || !bytes.Equal(p[:10], fd.data[9990:]) {
+		t.Errorf("read tail: %v", err)
+	}
+	if ranges := fd.takeRanges(); len(ranges) != 2 || ranges[0] != "bytes=5000-9999" || ranges[1] != "bytes=9990-9999" {
+		t.Errorf("ranges after seek %v", ranges)
+	}
+	if n, err := rr.Read(p); n != 0 || err != io.EOF {
+		t.Errorf("read at end: %d, %v", n, err)
+	}
+	if _, err = rr.Seek(-1, io.SeekStart); err != pcsdownload.ErrRangeReaderSeek {
+		t.Errorf("seek before start: %v", err)
+	}
+
+	// 请求出错时重新获取下载链接并重试
+	fd.fail = 1
+	rr.Seek(100, io.SeekStart)
+	if _, err = io.ReadFull(rr, p); err != nil || !bytes.Equal(p, fd.data[100:200]) {
+		t.Errorf("read after error: %v", err)
+	}
+	if ranges := fd.takeRanges(); len(ranges) != 2 {
+		t.Errorf("ranges with retry %v", ranges)
+	}
+}
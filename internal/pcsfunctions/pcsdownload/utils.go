@@ -1,11 +1,9 @@
 package pcsdownload
 
 import (
-	"encoding/hex"
 	"fmt"
 	"github.com/qjfoidnh/BaiduPCS-Go/baidupcs"
 	"github.com/qjfoidnh/BaiduPCS-Go/internal/pcsconfig"
-	"github.com/qjfoidnh/BaiduPCS-Go/pcsutil/checksum"
 	"golang.org/x/net/publicsuffix"
 	"net/http"
 	"net/http/cookiejar"
@@ -13,33 +11,10 @@ import (
 	"os"
 )
 
-// CheckFileValid 检测文件有效性
+// CheckFileValid 检测文件有效性, 校验大小和网盘记录的md5
 func CheckFileValid(filePath string, fileInfo *baidupcs.FileDirectory) error {
-	if len(fileInfo.BlockList) != 1 {
-		return ErrDownloadNotSupportChecksum
-	}
-
-	f := checksum.NewLocalFileChecksum(filePath, int(baidupcs.SliceMD5Size))
-	err := f.OpenPath()
-	if err != nil {
-		return err
-	}
-	defer f.Close()
-
-	err = f.Sum(checksum.CHECKSUM_MD5)
-	if err != nil {
-		return err
-	}
-	md5Str := hex.EncodeToString(f.MD5)
-
-	if md5Str != fileInfo.MD5 { // md5不一致
-		// 检测是否为违规文件
-		if IsSkipMd5Checksum(f.Length, md5Str) {
-			return ErrDownloadFileBanned
-		}
-		return ErrDownloadChecksumFailed
-	}
-	return nil
+	_, err := VerifyFile(nil, filePath, fileInfo, VerifyModeMD5)
+	return err
 }
 
 // FileExist 检查文件是否存在,
@@ -66,7 +41,8 @@ func FixHTTPLinkURL(linkURL *url.URL) {
 	}
 }
 
-func CloneJarWithDomain(srcJar http.CookieJar, newURL string) (http.CookieJar, error) {
+// CloneJarWithDomain 复制 PCS 的 cookie 到各个下载链接的域名
+func CloneJarWithDomain(srcJar http.CookieJar, newURLs ...string) (http.CookieJar, error) {
 	if srcJar == nil {
 		return nil, fmt.Errorf("srcJar is nil")
 	}
@@ -75,15 +51,20 @@ func CloneJarWithDomain(srcJar http.CookieJar, newURL string) (http.CookieJar, e
 		return nil, err
 	}
 
-	u, _ := url.Parse(newURL)
-	newDomain := u.Hostname()
-	u, _ = url.Parse("https://" + pcsconfig.Config.PCSAddr + "/")
+	u, _ := url.Parse("https://" + pcsconfig.Config.PCSAddr + "/")
 	cookies := srcJar.Cookies(u)
-	for _, c := range cookies {
-		nc := *c
-		nc.Domain = newDomain
-		newURL, _ := url.Parse("https://" + newDomain + "/")
-		dstJar.SetCookies(newURL, []*http.Cookie{&nc})
+	for _, newURL := range newURLs {
+		u, err := url.Parse(newURL)
+		if err != nil {
+			continue
+		}
+		newDomain := u.Hostname()
+		for _, c := range cookies {
+			nc := *c
+			nc.Domain = newDomain
+			newURL, _ := url.Parse("https://" + newDomain + "/")
+			dstJar.SetCookies(newURL, []*http.Cookie{&nc})
+		}
 	}
 	return dstJar, nil
 }
@@ -0,0 +1,246 @@
+package pcsdownload
+
+import (
+	"bufio"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/qjfoidnh/BaiduPCS-Go/baidupcs"
+	"github.com/qjfoidnh/BaiduPCS-Go/pcsutil/checksum"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type (
+	// VerifyMode 下载完成后校验文件的方式
+	VerifyMode int
+)
+
+const (
+	// VerifyModeMD5 校验大小和网盘记录的md5, 网盘记录的md5不可靠时只校验大小
+	VerifyModeMD5 VerifyMode = iota
+	// VerifyModeNone 不校验
+	VerifyModeNone
+	// VerifyModeSize 只校验大小
+	VerifyModeSize
+	// VerifyModeFull 校验大小和md5, 网盘记录的md5不可靠或不一致时, 通过下载链接获取实际的md5和crc32再比较
+	VerifyModeFull
+)
+
+const (
+	// SidecarMD5 下载完成后生成 <文件名>.md5
+	SidecarMD5 = 1 << iota
+	// SidecarSHA256 下载完成后在所在目录的 SHA256SUMS 中记录
+	SidecarSHA256
+
+	// SHA256SumsName 记录 sha256 的文件名
+	SHA256SumsName = "SHA256SUMS"
+)
+
+var (
+	// sha256SumsMu 同一个目录中的文件可能同时下载完成, 更新 SHA256SUMS 时加锁
+	sha256SumsMu sync.Mutex
+)
+
+// ParseVerifyMode 解析校验方式, 可选 none, size, md5, full
+func ParseVerifyMode(str string) (VerifyMode, error) {
+	switch strings.ToLower(str) {
+	case "none":
+		return VerifyModeNone, nil
+	case "size":
+		return VerifyModeSize, nil
+	case "md5":
+		return VerifyModeMD5, nil
+	case "full":
+		return VerifyModeFull, nil
+	}
+	return VerifyModeMD5, fmt.Errorf("未知的校验方式: %s, 可选 none, size, md5, full", str)
+}
+
+func (mode VerifyMode) String() string {
+	switch mode {
+	case VerifyModeNone:
+		return "none"
+	case VerifyModeSize:
+		return "size"
+	case VerifyModeFull:
+		return "full"
+	}
+	return "md5"
+}
+
+// ParseSidecars 解析要生成的校验文件, 多个用逗号分隔, 可选 md5, sha256
+func ParseSidecars(str string) (sidecars int, err error) {
+	for _, name := range strings.Split(str, ",") {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "":
+		case "md5":
+			sidecars |= SidecarMD5
+		case "sha256":
+			sidecars |= SidecarSHA256
+		default:
+			return 0, fmt.Errorf("未知的校验文件类型: %s, 可选 md5, sha256", name)
+		}
+	}
+	return sidecars, nil
+}
+
+// VerifyFile 按照 mode 校验本地文件, 返回校验过程中计算的本地文件md5, 未计算则为空.
+// VerifyModeFull 需要 pcs 获取下载链接
+func VerifyFile(pcs *baidupcs.BaiduPCS, filePath string, fileInfo *baidupcs.FileDirectory, mode VerifyMode) (md5Str string, err error) {
+	if mode == VerifyModeNone {
+		return "", nil
+	}
+
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return "", err
+	}
+	if info.Size() != fileInfo.Size {
+		return "", ErrDownloadSizeMismatch
+	}
+	if mode == VerifyModeSize {
+		return "", nil
+	}
+
+	trusted := len(fileInfo.BlockList) == 1
+	if mode == VerifyModeMD5 && !trusted {
+		return "", ErrDownloadNotSupportChecksum
+	}
+
+	flag := checksum.CHECKSUM_MD5
+	if mode == VerifyModeFull {
+		flag |= checksum.CHECKSUM_CRC32
+	}
+	f := checksum.NewLocalFileChecksum(filePath, int(baidupcs.SliceMD5Size))
+	err = f.OpenPath()
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	err = f.Sum(flag)
+	if err != nil {
+		return "", err
+	}
+	md5Str = hex.EncodeToString(f.MD5)
+
+	if trusted && md5Str == fileInfo.MD5 {
+		return md5Str, nil
+	}
+	if mode == VerifyModeMD5 {
+		// 检测是否为违规文件
+		if IsSkipMd5Checksum(f.Length, md5Str) {
+			return md5Str, ErrDownloadFileBanned
+		}
+		return md5Str, ErrDownloadChecksumFailed
+	}
+
+	// 网盘记录的md5不可靠, 通过下载链接获取实际的md5和crc32
+	rinfo, pcsError := pcs.GetRapidUploadInfoByFileInfo(fileInfo)
+	if pcsError != nil {
+		if IsSkipMd5Checksum(f.Length, md5Str) {
+			return md5Str, ErrDownloadFileBanned
+		}
+		return md5Str, pcsError
+	}
+	if !strings.EqualFold(rinfo.ContentMD5, md5Str) {
+		return md5Str, ErrDownloadChecksumFailed
+	}
+	if rinfo.ContentCrc32 != "" && rinfo.ContentCrc32 != "0" && rinfo.ContentCrc32 != strconv.FormatUint(uint64(f.CRC32), 10) {
+		return md5Str, ErrDownloadCRC32Failed
+	}
+	return md5Str, nil
+}
+
+// WriteSidecars 生成校验文件, md5Str 为已计算的md5, 为空则重新计算
+func WriteSidecars(filePath string, sidecars int, md5Str string) error {
+	if sidecars == 0 {
+		return nil
+	}
+
+	var (
+		hashes    []hash.Hash
+		md5Hash   hash.Hash
+		sha256Sum hash.Hash
+	)
+	if sidecars&SidecarMD5 != 0 && md5Str == "" {
+		md5Hash = md5.New()
+		hashes = append(hashes, md5Hash)
+	}
+	if sidecars&SidecarSHA256 != 0 {
+		sha256Sum = sha256.New()
+		hashes = append(hashes, sha256Sum)
+	}
+	if len(hashes) > 0 {
+		err := hashFile(filePath, hashes...)
+		if err != nil {
+			return err
+		}
+	}
+
+	filename := filepath.Base(filePath)
+	if sidecars&SidecarMD5 != 0 {
+		if md5Hash != nil {
+			md5Str = hex.EncodeToString(md5Hash.Sum(nil))
+		}
+		// 与 md5sum 的输出格式一致
+		err := os.WriteFile(filePath+".md5", []byte(md5Str+"  "+filename+"\n"), 0666)
+		if err != nil {
+			return err
+		}
+	}
+	if sha256Sum != nil {
+		return updateSHA256Sums(filepath.Join(filepath.Dir(filePath), SHA256SumsName), filename, hex.EncodeToString(sha256Sum.Sum(nil)))
+	}
+	return nil
+}
+
+func hashFile(filePath string, hashes ...hash.Hash) error {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	writers := make([]io.Writer, 0, len(hashes))
+	for _, h := range hashes {
+		writers = append(writers, h)
+	}
+	_, err = io.Copy(io.MultiWriter(writers...), f)
+	return err
+}
+
+// updateSHA256Sums 在 SHA256SUMS 中记录文件的 sha256, 替换同名文件原有的记录
+func updateSHA256Sums(sumsPath, filename, sum string) error {
+	sha256SumsMu.Lock()
+	defer sha256SumsMu.Unlock()
+
+	var lines []string
+	if f, err := os.Open(sumsPath); err == nil {
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			line := scanner.Text()
+			fields := strings.SplitN(line, "  ", 2)
+			if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == filename {
+				continue
+			}
+			lines = append(lines, line)
+		}
+		f.Close()
+		if err = scanner.Err(); err != nil {
+			return err
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	lines = append(lines, sum+"  "+filename)
+	return os.WriteFile(sumsPath, []byte(strings.Join(lines, "\n")+"\n"), 0666)
+}
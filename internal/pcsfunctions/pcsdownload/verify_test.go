@@ -0,0 +1,170 @@
+package pcsdownload_test
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/qjfoidnh/BaiduPCS-Go/baidupcs"
+	"github.com/qjfoidnh/BaiduPCS-Go/internal/pcsfunctions/pcsdownload"
+	"github.com/qjfoidnh/BaiduPCS-Go/requester"
+)
+
+// fakeLocate 模拟获取下载链接的接口, 下载链接返回文件实际的 md5 和 crc32
+type fakeLocate struct {
+	md5, crc32 string
+	size       int
+	requests   int
+}
+
+func (fl *fakeLocate) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	fl.requests++
+	if r.URL.Query().Get("method") == "locatedownload" {
+		io.WriteString(w, `{"urls":[{"url":"http://d.pcs.example.com/file/f","encrypt":0}]}`)
+		return
+	}
+	header := w.Header()
+	header.Set("Content-MD5", fl.md5)
+	header.Set("Content-Disposition", `attachment; filename="f"`)
+	header.Set("Content-Length", strconv.Itoa(fl.size))
+	header.Set("x-bs-meta-crc32", fl.crc32)
+	w.Write(make([]byte, fl.size))
+}
+
+func TestVerifyFile(t *testing.T) {
+	var (
+		data     = []byte("verify file content")
+		sum      = md5.Sum(data)
+		md5Str   = hex.EncodeToString(sum[:])
+		crc32Str = strconv.FormatUint(uint64(crc32.ChecksumIEEE(data)), 10)
+		filePath = filepath.Join(t.TempDir(), "f")
+		fl       = &fakeLocate{size: len(data)}
+	)
+	if err := os.WriteFile(filePath, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(fl)
+	requester.SetGlobalProxy(srv.Listener.Addr().String())
+	t.Cleanup(func() {
+		requester.SetGlobalProxy("")
+		srv.Close()
+	})
+	pcs := baidupcs.NewPCS(0, "")
+	pcs.SetUID(1)
+	pcs.SetPanUserAgent(baidupcs.NetdiskUA)
+	pcs.SetHTTPS(false)
+	pcs.SetStaticPCSAddr(true)
+
+	// blocks 为分片数量, 多于一个分片时网盘记录的md5不可靠
+	info := func(size int, md5Str string, blocks int) *baidupcs.FileDirectory {
+		fd := &baidupcs.FileDirectory{
+			Path:     "/f",
+			Filename: "f",
+			Size:     int64(size),
+			MD5:      md5Str,
+		}
+		fd.BlockList = make([]string, blocks)
+		return fd
+	}
+	otherMD5 := "00000000000000000000000000000000"
+
+	for _, c := range []struct {
+		name     string
+		info     *baidupcs.FileDirectory
+		mode     pcsdownload.VerifyMode
+		remote   [2]string // 下载链接返回的 md5 和 crc32
+		err      error
+		md5Str   string
+		requests int
+	}{
+		{"none", info(0, otherMD5, 1), pcsdownload.VerifyModeNone, [2]string{}, nil, "", 0},
+		{"size", info(len(data), otherMD5, 1), pcsdownload.VerifyModeSize, [2]string{}, nil, "", 0},
+		{"size mismatch", info(len(data)+1, md5Str, 1), pcsdownload.VerifyModeMD5, [2]string{}, pcsdownload.ErrDownloadSizeMismatch, "", 0},
+		{"md5", info(len(data), md5Str, 1), pcsdownload.VerifyModeMD5, [2]string{}, nil, md5Str, 0},
+		{"md5 mismatch", info(len(data), otherMD5, 1), pcsdownload.VerifyModeMD5, [2]string{}, pcsdownload.ErrDownloadChecksumFailed, md5Str, 0},
+		{"md5 unreliable", info(len(data), otherMD5, 2), pcsdownload.VerifyModeMD5, [2]string{}, pcsdownload.ErrDownloadNotSupportChecksum, "", 0},
+		{"full trusted", info(len(data), md5Str, 1), pcsdownload.VerifyModeFull, [2]string{}, nil, md5Str, 0},
+		{"full", info(len(data), otherMD5, 2), pcsdownload.VerifyModeFull, [2]string{md5Str, crc32Str}, nil, md5Str, 2},
+		{"full md5 mismatch", info(len(data), otherMD5, 2), pcsdownload.VerifyModeFull, [2]string{otherMD5, crc32Str}, pcsdownload.ErrDownloadChecksumFailed, md5Str, 2},
+		{"full crc32 mismatch", info(len(data), otherMD5, 2), pcsdownload.VerifyModeFull, [2]string{md5Str, "1"}, pcsdownload.ErrDownloadCRC32Failed, md5Str, 2},
+	} {
+		fl.md5, fl.crc32, fl.requests = c.remote[0], c.remote[1], 0
+		got, err := pcsdownload.VerifyFile(pcs, filePath, c.info, c.mode)
+		if err != c.err || got != c.md5Str {
+			t.Errorf("%s: %q, %v, want %q, %v", c.name, got, err, c.md5Str, c.err)
+		}
+		if fl.requests != c.requests {
+			t.Errorf("%s: %d requests, want %d", c.name, fl.requests, c.requests)
+		}
+	}
+}
+
+func TestParseVerifyMode(t *testing.T) {
+	for str, want := range map[string]pcsdownload.VerifyMode{
+		"none": pcsdownload.VerifyModeNone,
+		"Size": pcsdownload.VerifyModeSize,
+		"md5":  pcsdownload.VerifyModeMD5,
+		"FULL": pcsdownload.VerifyModeFull,
+	} {
+		mode, err := pcsdownload.ParseVerifyMode(str)
+		if err != nil || mode != want {
+			t.Errorf("ParseVerifyMode(%s) = %s, %v", str, mode, err)
+		}
+	}
+	if _, err := pcsdownload.ParseVerifyMode("sha1"); err == nil {
+		t.Error("unknown mode accepted")
+	}
+}
+
+func TestWriteSidecars(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		filePath := filepath.Join(dir, name)
+		if err := os.WriteFile(filePath, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return filePath
+	}
+	read := func(name string) string {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+
+	// 未给出md5时重新计算, 格式与 md5sum 一致
+	a := write("a", "a")
+	if err := pcsdownload.WriteSidecars(a, pcsdownload.SidecarMD5|pcsdownload.SidecarSHA256, ""); err != nil {
+		t.Fatal(err)
+	}
+	if got := read("a.md5"); got != "0cc175b9c0f1b6a831c399e269772661  a\n" {
+		t.Errorf("a.md5: %q", got)
+	}
+
+	// 同名文件的记录被替换, 其他文件的记录保留
+	b := write("b", "b")
+	if err := pcsdownload.WriteSidecars(b, pcsdownload.SidecarSHA256, ""); err != nil {
+		t.Fatal(err)
+	}
+	write("a", "aa")
+	if err := pcsdownload.WriteSidecars(a, pcsdownload.SidecarMD5|pcsdownload.SidecarSHA256, "given"); err != nil {
+		t.Fatal(err)
+	}
+	if got := read("a.md5"); got != "given  a\n" {
+		t.Errorf("a.md5 with given md5: %q", got)
+	}
+	want := "3e23e8160039594a33894f6564e1b1348bbd7a0088d42c4acb73eeaed59c009d  b\n" +
+		"961b6dd3ede3cb8ecbaacbd68de040cd78eb2ed5889130cceb4c49268ea4d506  a\n"
+	if got := read(pcsdownload.SHA256SumsName); got != want {
+		t.Errorf("SHA256SUMS:\n%s", got)
+	}
+}
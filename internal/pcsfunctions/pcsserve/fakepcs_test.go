@@ -0,0 +1,266 @@
+package pcsserve_test
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/qjfoidnh/BaiduPCS-Go/baidupcs"
+	"github.com/qjfoidnh/BaiduPCS-Go/requester"
+)
+
+type (
+	// fakePCS 模拟网盘的 PCS 文件接口, 文件和目录保存在内存中
+	fakePCS struct {
+		t     *testing.T
+		mu    sync.Mutex
+		files map[string][]byte
+		dirs  map[string]bool
+
+		methods []string // 收到的请求的 method
+		ranges  []string // 收到的下载请求的 Range
+	}
+
+	fakeBatchItem struct {
+		Path string `json:"path"`
+		From string `json:"from"`
+		To   string `json:"to"`
+	}
+)
+
+// newFakePCS 启动模拟服务器, 返回通过代理将所有请求发送到模拟服务器的 BaiduPCS
+func newFakePCS(t *testing.T) (*fakePCS, *baidupcs.BaiduPCS) {
+	fp := &fakePCS{
+		t:     t,
+		files: map[string][]byte{},
+		dirs:  map[string]bool{"/": true},
+	}
+
+	srv := httptest.NewServer(fp)
+	requester.SetGlobalProxy(srv.Listener.Addr().String())
+	t.Cleanup(func() {
+		requester.SetGlobalProxy("")
+		srv.Close()
+	})
+
+	pcs := baidupcs.NewPCS(0, "")
+	pcs.SetPanUserAgent(baidupcs.NetdiskUA)
+	pcs.GetClient()
+	pcs.SetHTTPS(false)
+	pcs.SetStaticPCSAddr(true)
+	return fp, pcs
+}
+
+// add 添加文件, 自动创建上级目录, data 为 nil 时添加目录
+func (fp *fakePCS) add(p string, data []byte) {
+	fp.mu.Lock()
+	defer fp.mu.Unlock()
+	if data == nil {
+		fp.dirs[p] = true
+	} else {
+		fp.files[p] = data
+	}
+	for dir := path.Dir(p); dir != "/"; dir = path.Dir(dir) {
+		fp.dirs[dir] = true
+	}
+}
+
+func (fp *fakePCS) file(p string) ([]byte, bool) {
+	fp.mu.Lock()
+	defer fp.mu.Unlock()
+	data, ok := fp.files[p]
+	return data, ok
+}
+
+// takeMethods 返回并清空收到的请求的 method
+func (fp *fakePCS) takeMethods() []string {
+	fp.mu.Lock()
+	defer fp.mu.Unlock()
+	methods := fp.methods
+	fp.methods = nil
+	return methods
+}
+
+func (fp *fakePCS) takeRanges() []string {
+	fp.mu.Lock()
+	defer fp.mu.Unlock()
+	ranges := fp.ranges
+	fp.ranges = nil
+	return ranges
+}
+
+func (fp *fakePCS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/api/batch/filediff" {
+		// 持久化缓存的同步, 服务器端没有变更
+		io.WriteString(w, `{"errno":0,"entries":[],"cursor":"c","has_more":false}`)
+		return
+	}
+	if r.URL.Path != "/rest/2.0/pcs/file" {
+		fp.t.Errorf("unexpected request: %s %s", r.Method, r.URL)
+		http.NotFound(w, r)
+		return
+	}
+
+	var (
+		query  = r.URL.Query()
+		method = query.Get("method")
+		items  []*fakeBatchItem
+	)
+	if param := r.FormValue("param"); param != "" {
+		var list struct {
+			List []*fakeBatchItem `json:"list"`
+		}
+		if err := json.Unmarshal([]byte(param), &list); err != nil {
+			fp.t.Errorf("param %s: %s", param, err)
+		}
+		items = list.List
+	}
+
+	fp.mu.Lock()
+	fp.methods = append(fp.methods, method)
+	if method == "download" {
+		// 在锁外传输文件内容
+		fp.ranges = append(fp.ranges, r.Header.Get("Range"))
+		data, ok := fp.files[query.Get("path")]
+		fp.mu.Unlock()
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, `{"error_code":31066,"error_msg":"file does not exist"}`)
+			return
+		}
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
+		return
+	}
+	defer fp.mu.Unlock()
+
+	switch method {
+	case "meta":
+		fp.meta(w, items)
+	case "list":
+		fp.list(w, query.Get("path"))
+	case "delete", "copy":
+		fp.batch(w, method, items)
+	default:
+		fp.t.Errorf("unexpected method: %s", method)
+		http.NotFound(w, r)
+	}
+}
+
+func (fp *fakePCS) fileJSON(p string) map[string]interface{} {
+	m := map[string]interface{}{
+		"fs_id":           len(p),
+		"path":            p,
+		"server_filename": path.Base(p),
+		"mtime":           1600000000,
+		"isdir":           1,
+	}
+	if data, ok := fp.files[p]; ok {
+		sum := md5.Sum(data)
+		m["isdir"], m["size"], m["md5"] = 0, len(data), hex.EncodeToString(sum[:])
+	}
+	return m
+}
+
+func (fp *fakePCS) writeJSON(w http.ResponseWriter, v interface{}) {
+	data, _ := json.Marshal(v)
+	w.Write(data)
+}
+
+func (fp *fakePCS) exists(p string) bool {
+	_, ok := fp.files[p]
+	return ok || fp.dirs[p]
+}
+
+func (fp *fakePCS) meta(w http.ResponseWriter, items []*fakeBatchItem) {
+	list := make([]interface{}, 0, len(items))
+	for _, item := range items {
+		if !fp.exists(item.Path) {
+			io.WriteString(w, `{"error_code":31066,"error_msg":"file does not exist"}`)
+			return
+		}
+		list = append(list, fp.fileJSON(item.Path))
+	}
+	fp.writeJSON(w, map[string]interface{}{"list": list})
+}
+
+func (fp *fakePCS) list(w http.ResponseWriter, dir string) {
+	if !fp.dirs[dir] {
+		io.WriteString(w, `{"error_code":31066,"error_msg":"file does not exist"}`)
+		return
+	}
+
+	var names []string
+	for p := range fp.dirs {
+		if p != "/" && path.Dir(p) == dir {
+			names = append(names, p)
+		}
+	}
+	for p := range fp.files {
+		if path.Dir(p) == dir {
+			names = append(names, p)
+		}
+	}
+	sort.Strings(names)
+
+	list := make([]interface{}, 0, len(names))
+	for _, p := range names {
+		list = append(list, fp.fileJSON(p))
+	}
+	fp.writeJSON(w, map[string]interface{}{"list": list})
+}
+
+// under 判断 p 是否为 root 或其子孙
+func under(p, root string) bool {
+	return p == root || strings.HasPrefix(p, root+"/")
+}
+
+func (fp *fakePCS) batch(w http.ResponseWriter, method string, items []*fakeBatchItem) {
+	for _, item := range items {
+		switch {
+		case !fp.exists(item.Path + item.From):
+			io.WriteString(w, `{"error_code":31066,"error_msg":"file does not exist"}`)
+			return
+		case method == "copy" && fp.exists(item.To):
+			io.WriteString(w, `{"error_code":31061,"error_msg":"file already exists"}`)
+			return
+		}
+	}
+
+	for _, item := range items {
+		switch method {
+		case "delete":
+			for p := range fp.files {
+				if under(p, item.Path) {
+					delete(fp.files, p)
+				}
+			}
+			for p := range fp.dirs {
+				if under(p, item.Path) {
+					delete(fp.dirs, p)
+				}
+			}
+		case "copy":
+			for p, data := range fp.files {
+				if under(p, item.From) {
+					fp.files[item.To+strings.TrimPrefix(p, item.From)] = data
+				}
+			}
+			for p := range fp.dirs {
+				if under(p, item.From) {
+					fp.dirs[item.To+strings.TrimPrefix(p, item.From)] = true
+				}
+			}
+		}
+	}
+	io.WriteString(w, `{"extra":{},"request_id":1}`)
+}
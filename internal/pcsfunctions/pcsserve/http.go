@@ -0,0 +1,158 @@
+package pcsserve
+
+import (
+	"html/template"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/qjfoidnh/BaiduPCS-Go/baidupcs"
+	"github.com/qjfoidnh/BaiduPCS-Go/internal/pcsfunctions/pcsdownload"
+	"github.com/qjfoidnh/BaiduPCS-Go/pcsutil/converter"
+	"github.com/qjfoidnh/BaiduPCS-Go/pcsutil/jsonhelper"
+)
+
+type (
+	// HTTPHandler 将网盘目录通过 HTTP 提供访问, 文件支持 Range 请求, 目录输出 HTML 或 JSON 列表
+	HTTPHandler struct {
+		PCS           *baidupcs.BaiduPCS
+		Root          string                     // 网盘根目录
+		StreamOptions *pcsdownload.StreamOptions // 读取文件的参数
+	}
+
+	// ListEntry 目录列表中的条目
+	ListEntry struct {
+		Name  string `json:"name"`
+		URL   string `json:"url"` // 相对于当前目录的地址
+		Size  int64  `json:"size"`
+		IsDir bool   `json:"isdir"`
+		Mtime int64  `json:"mtime"`
+		MD5   string `json:"md5,omitempty"`
+	}
+)
+
+var listTemplate = template.Must(template.New("list").Funcs(template.FuncMap{
+	"size": func(size int64) string {
+		return converter.ConvertFileSize(size, 2)
+	},
+	"time": func(mtime int64) string {
+		return time.Unix(mtime, 0).Format("2006-01-02 15:04:05")
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Path}}</title>
+</head>
+<body>
+<h1>{{.Path}}</h1>
+<table>
+<tr><th align="left">名称</th><th align="right">大小</th><th align="left">修改时间</th></tr>
+{{if ne .Path "/"}}<tr><td><a href="../">../</a></td><td></td><td></td></tr>
+{{end}}{{range .Entries}}<tr><td><a href="{{.URL}}">{{.Name}}{{if .IsDir}}/{{end}}</a></td><td align="right">{{if not .IsDir}}{{size .Size}}{{end}}</td><td>{{time .Mtime}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+// ServeHTTP 实现 http.Handler
+func (h *HTTPHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	pcspath := joinPCSPath(h.Root, r.URL.Path)
+	fd, pcsError := h.PCS.FilesDirectoriesMeta(pcspath)
+	if pcsError != nil {
+		pcsServeVerbose.Warnf("%s %s: %s\n", r.Method, r.URL.Path, pcsError)
+		http.Error(w, pcsError.Error(), errorStatus(pcsError))
+		return
+	}
+
+	if fd.Isdir {
+		// 与 http.FileServer 一致, 目录地址以 / 结尾, 以便使用相对地址
+		if !strings.HasSuffix(r.URL.Path, "/") {
+			http.Redirect(w, r, path.Base(r.URL.Path)+"/", http.StatusMovedPermanently)
+			return
+		}
+		h.serveDir(w, r, pcspath)
+		return
+	}
+	h.serveFile(w, r, fd)
+}
+
+func (h *HTTPHandler) serveFile(w http.ResponseWriter, r *http.Request, fd *baidupcs.FileDirectory) {
+	var opts pcsdownload.StreamOptions
+	if h.StreamOptions != nil {
+		opts = *h.StreamOptions
+	}
+	rr := pcsdownload.NewRangeReader(h.PCS, fd, &opts)
+	defer rr.Close()
+
+	header := w.Header()
+	header.Set("Content-Type", contentType(fd.Filename))
+	if tag := etag(fd); tag != "" {
+		header.Set("ETag", tag)
+	}
+	// 处理 Range, Content-Length, Last-Modified 和条件请求
+	http.ServeContent(w, r, fd.Filename, time.Unix(fd.Mtime, 0), rr)
+}
+
+func (h *HTTPHandler) serveDir(w http.ResponseWriter, r *http.Request, pcspath string) {
+	fdl, pcsError := h.PCS.FilesDirectoriesList(pcspath, baidupcs.DefaultOrderOptions)
+	if pcsError != nil {
+		pcsServeVerbose.Warnf("%s %s: %s\n", r.Method, r.URL.Path, pcsError)
+		http.Error(w, pcsError.Error(), errorStatus(pcsError))
+		return
+	}
+
+	entries := make([]*ListEntry, 0, len(fdl))
+	for _, fd := range fdl {
+		u := (&url.URL{Path: fd.Filename}).String()
+		if fd.Isdir {
+			u += "/"
+		}
+		entries = append(entries, &ListEntry{
+			Name:  fd.Filename,
+			URL:   u,
+			Size:  fd.Size,
+			IsDir: fd.Isdir,
+			Mtime: fd.Mtime,
+			MD5:   fd.MD5,
+		})
+	}
+
+	if wantJSON(r) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		if r.Method == http.MethodHead {
+			return
+		}
+		jsonhelper.MarshalData(w, entries)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if r.Method == http.MethodHead {
+		return
+	}
+	err := listTemplate.Execute(w, map[string]interface{}{
+		"Path":    r.URL.Path,
+		"Entries": entries,
+	})
+	if err != nil {
+		pcsServeVerbose.Warnf("render list error: %s\n", err)
+	}
+}
+
+// wantJSON 请求参数 format=json 或 Accept 只接受 json 时输出 JSON 列表
+func wantJSON(r *http.Request) bool {
+	if r.URL.Query().Get("format") == "json" {
+		return true
+	}
+	accept := r.Header.Get("Accept")
+	return strings.Contains(accept, "application/json") && !strings.Contains(accept, "text/html")
+}
@@ -0,0 +1,132 @@
+package pcsserve_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/qjfoidnh/BaiduPCS-Go/internal/pcsfunctions/pcsdownload"
+	"github.com/qjfoidnh/BaiduPCS-Go/internal/pcsfunctions/pcsserve"
+)
+
+func newHTTPHandler(t *testing.T) (*fakePCS, *pcsserve.HTTPHandler) {
+	fp, pcs := newFakePCS(t)
+	return fp, &pcsserve.HTTPHandler{
+		PCS:  pcs,
+		Root: "/share",
+		StreamOptions: &pcsdownload.StreamOptions{
+			DownloadMode: pcsdownload.DownloadModePCS,
+		},
+	}
+}
+
+func serve(h http.Handler, method, target string, header http.Header) *httptest.ResponseRecorder {
+	return serveBody(h, method, target, "", header)
+}
+
+func serveBody(h http.Handler, method, target, body string, header http.Header) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	for k, v := range header {
+		r.Header[k] = v
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestHTTPHandlerRange(t *testing.T) {
+	fp, h := newHTTPHandler(t)
+	data := make([]byte, 10000)
+	rand.Read(data)
+	fp.add("/share/a.txt", data)
+
+	// 完整读取
+	w := serve(h, http.MethodGet, "/a.txt", nil)
+	if w.Code != http.StatusOK || !bytes.Equal(w.Body.Bytes(), data) {
+		t.Fatalf("get: %d, %d bytes", w.Code, w.Body.Len())
+	}
+	if ctype := w.Header().Get("Content-Type"); !strings.HasPrefix(ctype, "text/plain") {
+		t.Errorf("content type %s", ctype)
+	}
+	if w.Header().Get("ETag") == "" || w.Header().Get("Accept-Ranges") != "bytes" {
+		t.Errorf("header %v", w.Header())
+	}
+	fp.takeRanges()
+
+	// Range 请求只从服务器读取对应的部分
+	w = serve(h, http.MethodGet, "/a.txt", http.Header{"Range": {"bytes=100-199"}})
+	if w.Code != http.StatusPartialContent || !bytes.Equal(w.Body.Bytes(), data[100:200]) {
+		t.Errorf("range: %d, %d bytes", w.Code, w.Body.Len())
+	}
+	if cr := w.Header().Get("Content-Range"); cr != "bytes 100-199/10000" {
+		t.Errorf("content range %s", cr)
+	}
+	if ranges := fp.takeRanges(); len(ranges) != 1 || ranges[0] != "bytes=100-9999" {
+		t.Errorf("ranges %v", ranges)
+	}
+
+	w = serve(h, http.MethodGet, "/a.txt", http.Header{"Range": {"bytes=-10"}})
+	if w.Code != http.StatusPartialContent || !bytes.Equal(w.Body.Bytes(), data[9990:]) {
+		t.Errorf("suffix range: %d, %d bytes", w.Code, w.Body.Len())
+	}
+	w = serve(h, http.MethodGet, "/a.txt", http.Header{"Range": {"bytes=20000-"}})
+	if w.Code != http.StatusRequestedRangeNotSatisfiable {
+		t.Errorf("unsatisfiable range: %d", w.Code)
+	}
+
+	// HEAD 和条件请求不下载文件内容
+	fp.takeRanges()
+	w = serve(h, http.MethodHead, "/a.txt", nil)
+	if w.Code != http.StatusOK || w.Header().Get("Content-Length") != "10000" || w.Body.Len() != 0 {
+		t.Errorf("head: %d, %v", w.Code, w.Header())
+	}
+	w = serve(h, http.MethodGet, "/a.txt", http.Header{"If-None-Match": {w.Header().Get("ETag")}})
+	if w.Code != http.StatusNotModified {
+		t.Errorf("if-none-match: %d", w.Code)
+	}
+	if ranges := fp.takeRanges(); len(ranges) != 0 {
+		t.Errorf("ranges without body %v", ranges)
+	}
+}
+
+func TestHTTPHandlerDir(t *testing.T) {
+	fp, h := newHTTPHandler(t)
+	fp.add("/share/dir/b c.txt", []byte("b"))
+	fp.add("/share/dir/sub", nil)
+	fp.add("/other.txt", []byte("other"))
+
+	// 目录地址补全 /
+	w := serve(h, http.MethodGet, "/dir", nil)
+	if w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != "/dir/" {
+		t.Errorf("redirect: %d, %s", w.Code, w.Header().Get("Location"))
+	}
+
+	w = serve(h, http.MethodGet, "/dir/?format=json", nil)
+	var entries []*pcsserve.ListEntry
+	if err := json.Unmarshal(w.Body.Bytes(), &entries); err != nil {
+		t.Fatalf("json list: %s, %s", err, w.Body)
+	}
+	if len(entries) != 2 || entries[0].URL != "b%20c.txt" || entries[0].Size != 1 || entries[1].URL != "sub/" || !entries[1].IsDir {
+		t.Errorf("entries %s", w.Body)
+	}
+
+	w = serve(h, http.MethodGet, "/dir/", http.Header{"Accept": {"text/html,application/json"}})
+	if ctype := w.Header().Get("Content-Type"); !strings.HasPrefix(ctype, "text/html") || !strings.Contains(w.Body.String(), `href="sub/"`) {
+		t.Errorf("html list: %s, %s", ctype, w.Body)
+	}
+
+	// 不存在的路径返回 404, 请求路径不能超出根目录
+	if w = serve(h, http.MethodGet, "/missing", nil); w.Code != http.StatusNotFound {
+		t.Errorf("missing: %d", w.Code)
+	}
+	if w = serve(h, http.MethodGet, "/../other.txt", nil); w.Code != http.StatusNotFound {
+		t.Errorf("outside root: %d", w.Code)
+	}
+	if w = serve(h, http.MethodPut, "/dir/b%20c.txt", nil); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("put: %d", w.Code)
+	}
+}
@@ -0,0 +1,47 @@
+// Package pcsserve 将网盘文件通过本地服务提供访问
+package pcsserve
+
+import (
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/qjfoidnh/BaiduPCS-Go/baidupcs"
+	"github.com/qjfoidnh/BaiduPCS-Go/baidupcs/pcserror"
+	"github.com/qjfoidnh/BaiduPCS-Go/pcsverbose"
+)
+
+var (
+	pcsServeVerbose = pcsverbose.New("PCSSERVE")
+)
+
+// joinPCSPath 将请求路径映射为 root 下的网盘路径, 不会超出 root
+func joinPCSPath(root, urlPath string) string {
+	return path.Join(root, path.Clean("/"+urlPath))
+}
+
+// errorStatus 网盘接口错误对应的 HTTP 状态码
+func errorStatus(pcsError pcserror.Error) int {
+	if pcserror.IsNotExist(pcsError) {
+		return http.StatusNotFound
+	}
+	return http.StatusBadGateway
+}
+
+// contentType 根据文件名获取 Content-Type, 避免为探测类型而发起请求
+func contentType(name string) string {
+	ctype := mime.TypeByExtension(path.Ext(name))
+	if ctype == "" {
+		return "application/octet-stream"
+	}
+	return ctype
+}
+
+// etag 使用文件的md5作为 ETag
+func etag(fd *baidupcs.FileDirectory) string {
+	if fd.MD5 == "" {
+		return ""
+	}
+	return `"` + strings.ToLower(fd.MD5) + `"`
+}
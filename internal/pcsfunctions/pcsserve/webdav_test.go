@@ -0,0 +1,198 @@
+package pcsserve_test
+
+import (
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/qjfoidnh/BaiduPCS-Go/baidupcs"
+	"github.com/qjfoidnh/BaiduPCS-Go/internal/pcsfunctions/pcsdownload"
+	"github.com/qjfoidnh/BaiduPCS-Go/internal/pcsfunctions/pcsserve"
+)
+
+const lockBody = `<?xml version="1.0" encoding="utf-8"?>
+<D:lockinfo xmlns:D="DAV:"><D:lockscope><D:exclusive/></D:lockscope><D:locktype><D:write/></D:locktype></D:lockinfo>`
+
+func newWebDAVHandler(t *testing.T) (*fakePCS, *pcsserve.WebDAVHandler) {
+	fp, pcs := newFakePCS(t)
+	return fp, pcsserve.NewWebDAVHandler(&pcsserve.WebDAVFileSystem{
+		PCS:  pcs,
+		Root: "/share",
+		StreamOptions: &pcsdownload.StreamOptions{
+			DownloadMode: pcsdownload.DownloadModePCS,
+		},
+	}, "", "")
+}
+
+func copyHeader(dst, overwrite string) http.Header {
+	header := http.Header{"Destination": {"http://example.com" + dst}}
+	if overwrite != "" {
+		header.Set("Overwrite", overwrite)
+	}
+	return header
+}
+
+func TestWebDAVCopy(t *testing.T) {
+	fp, h := newWebDAVHandler(t)
+	fp.add("/share/a.txt", []byte("a"))
+	fp.add("/share/b.txt", []byte("b"))
+	fp.add("/share/dir/c.txt", []byte("c"))
+
+	// 在服务器端复制, 不下载文件内容
+	w := serve(h, "COPY", "/a.txt", copyHeader("/a2.txt", ""))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("copy: %d, %s", w.Code, w.Body)
+	}
+	if data, ok := fp.file("/share/a2.txt"); !ok || string(data) != "a" {
+		t.Errorf("copied file %q", data)
+	}
+	for _, method := range fp.takeMethods() {
+		if method == "download" {
+			t.Error("copy downloaded the file")
+		}
+	}
+
+	// 目标已存在
+	if w = serve(h, "COPY", "/a.txt", copyHeader("/b.txt", "F")); w.Code != http.StatusPreconditionFailed {
+		t.Errorf("overwrite F: %d", w.Code)
+	}
+	if w = serve(h, "COPY", "/a.txt", copyHeader("/b.txt", "T")); w.Code != http.StatusNoContent {
+		t.Errorf("overwrite T: %d, %s", w.Code, w.Body)
+	}
+	if data, _ := fp.file("/share/b.txt"); string(data) != "a" {
+		t.Errorf("overwritten file %q", data)
+	}
+
+	// 复制目录
+	if w = serve(h, "COPY", "/dir", copyHeader("/dir2", "")); w.Code != http.StatusCreated {
+		t.Errorf("copy dir: %d, %s", w.Code, w.Body)
+	}
+	if _, ok := fp.file("/share/dir2/c.txt"); !ok {
+		t.Error("dir children not copied")
+	}
+
+	for _, c := range []struct {
+		name   string
+		src    string
+		header http.Header
+		code   int
+	}{
+		{"missing source", "/missing", copyHeader("/x", ""), http.StatusNotFound},
+		{"missing parent", "/a.txt", copyHeader("/missing/a.txt", ""), http.StatusConflict},
+		{"same path", "/a.txt", copyHeader("/a.txt", ""), http.StatusForbidden},
+		{"root", "/a.txt", copyHeader("/", ""), http.StatusForbidden},
+		{"other host", "/a.txt", http.Header{"Destination": {"http://other.com/x"}}, http.StatusBadGateway},
+		{"no destination", "/a.txt", nil, http.StatusBadRequest},
+	} {
+		if w = serve(h, "COPY", c.src, c.header); w.Code != c.code {
+			t.Errorf("%s: %d, want %d", c.name, w.Code, c.code)
+		}
+	}
+}
+
+func TestWebDAVCopyLocked(t *testing.T) {
+	fp, h := newWebDAVHandler(t)
+	fp.add("/share/a.txt", []byte("a"))
+	fp.add("/share/b.txt", []byte("b"))
+
+	w := serveBody(h, "LOCK", "/b.txt", lockBody, http.Header{"Timeout": {"Second-3600"}})
+	token := w.Header().Get("Lock-Token")
+	if w.Code != http.StatusOK || token == "" {
+		t.Fatalf("lock: %d, %s", w.Code, w.Body)
+	}
+
+	// 目标被锁定时, 需要在 If 请求头中提供锁
+	if w = serve(h, "COPY", "/a.txt", copyHeader("/b.txt", "T")); w.Code != http.StatusLocked {
+		t.Errorf("copy to locked: %d", w.Code)
+	}
+	if w = serve(h, "COPY", "/a.txt", http.Header{
+		"Destination": {"http://example.com/b.txt"},
+		"If":          {"(<opaquelocktoken:wrong>)"},
+	}); w.Code != http.StatusPreconditionFailed {
+		t.Errorf("copy with wrong token: %d", w.Code)
+	}
+	if w = serve(h, "COPY", "/a.txt", http.Header{
+		"Destination": {"http://example.com/b.txt"},
+		"If":          {"(" + token + ")"},
+	}); w.Code != http.StatusNoContent {
+		t.Errorf("copy with token: %d, %s", w.Code, w.Body)
+	}
+	if data, _ := fp.file("/share/b.txt"); string(data) != "a" {
+		t.Errorf("locked file %q", data)
+	}
+	if w = serve(h, "COPY", "/a.txt", http.Header{
+		"Destination": {"http://example.com/b.txt"},
+		"If":          {"(" + token},
+	}); w.Code != http.StatusBadRequest {
+		t.Errorf("copy with invalid If: %d", w.Code)
+	}
+
+	// 锁只作用于目标路径
+	if w = serve(h, "COPY", "/b.txt", copyHeader("/c.txt", "")); w.Code != http.StatusCreated {
+		t.Errorf("copy from locked: %d", w.Code)
+	}
+}
+
+func TestWebDAVPropfind(t *testing.T) {
+	fp, h := newWebDAVHandler(t)
+	fp.add("/share/a.txt", []byte("abc"))
+	fp.add("/share/dir", nil)
+
+	w := serve(h, "PROPFIND", "/", http.Header{"Depth": {"1"}})
+	if w.Code != http.StatusMultiStatus {
+		t.Fatalf("propfind: %d, %s", w.Code, w.Body)
+	}
+	for _, s := range []string{"/a.txt", "/dir/", "<D:getcontentlength>3</D:getcontentlength>", "<D:getcontenttype>text/plain"} {
+		if !strings.Contains(w.Body.String(), s) {
+			t.Errorf("propfind missing %s: %s", s, w.Body)
+		}
+	}
+
+	// 只读文件系统不允许写入
+	if w = serveBody(h, http.MethodPut, "/new.txt", "new", nil); w.Code < 400 {
+		t.Errorf("put without upload: %d", w.Code)
+	}
+	if w = serve(h, "PROPFIND", "/missing", http.Header{"Depth": {"0"}}); w.Code != http.StatusNotFound {
+		t.Errorf("propfind missing: %d", w.Code)
+	}
+}
+
+func TestWebDAVPropfindMetaCache(t *testing.T) {
+	fp, h := newWebDAVHandler(t)
+	fp.add("/share/a.txt", []byte("abc"))
+	fp.add("/share/dir/b.txt", []byte("b"))
+
+	mc, err := baidupcs.OpenMetaCache(filepath.Join(t.TempDir(), "meta.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.FileSystem.PCS.SetMetaCache(mc)
+	t.Cleanup(func() {
+		mc.Save()
+	})
+
+	// 启用持久化缓存时, 首次同步完成后, 重复的 PROPFIND 不再请求服务器
+	for i := 0; ; i++ {
+		fp.takeMethods()
+		if w := serve(h, "PROPFIND", "/dir/", http.Header{"Depth": {"1"}}); w.Code != http.StatusMultiStatus {
+			t.Fatalf("propfind: %d, %s", w.Code, w.Body)
+		}
+		if methods := fp.takeMethods(); len(methods) == 0 {
+			break
+		}
+		if i == 200 {
+			t.Fatal("propfind is never served from the meta cache")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// 写入前的检查仍从服务器获取
+	if w := serve(h, "COPY", "/a.txt", copyHeader("/dir/b.txt", "F")); w.Code != http.StatusPreconditionFailed {
+		t.Errorf("copy: %d", w.Code)
+	}
+	if methods := fp.takeMethods(); len(methods) == 0 {
+		t.Error("copy checked the destination from the meta cache")
+	}
+}
@@ -0,0 +1,174 @@
+package pcsupload
+
+import (
+	"errors"
+	"github.com/qjfoidnh/BaiduPCS-Go/baidupcs"
+	"github.com/qjfoidnh/BaiduPCS-Go/pcsutil/checksum"
+	"os"
+	"sync"
+)
+
+const (
+	// DefaultHashParallel 默认同时计算摘要的文件数量
+	DefaultHashParallel = 2
+	// DefaultHashIOParallel 默认同一磁盘上同时读取计算摘要的文件数量
+	DefaultHashIOParallel = 1
+)
+
+var (
+	// ErrHashPipelineClosed 摘要计算已停止
+	ErrHashPipelineClosed = errors.New("摘要计算已停止")
+)
+
+type (
+	// HashPipeline 在上传之前预先计算文件的 md5, 前256KB切片的 md5 和秒传使用的分块md5,
+	// 由独立的 worker 按加入的顺序计算, 上传任务只需等待自己的文件计算完成.
+	// 同一磁盘上同时读取的文件数量另外限制, 避免机械硬盘来回寻道
+	HashPipeline struct {
+		ioParallel int
+		sumCache   *checksum.SumCache
+
+		mu     sync.Mutex
+		cond   *sync.Cond
+		queue  []*HashJob
+		closed bool
+		ioSems map[string]chan struct{} // 设备 -> 读取文件的信号量
+		wg     sync.WaitGroup
+	}
+
+	// HashJob 一个文件的摘要计算任务
+	HashJob struct {
+		LocalPath string
+
+		done chan struct{}
+		meta checksum.LocalFileMeta
+		err  error
+	}
+)
+
+// NewHashPipeline 创建并启动摘要计算, parallel 为 worker 数量, ioParallel 为同一磁盘上同时读取的文件数量,
+// sc 不为空时优先从摘要缓存中读取
+func NewHashPipeline(parallel, ioParallel int, sc *checksum.SumCache) *HashPipeline {
+	if parallel < 1 {
+		parallel = DefaultHashParallel
+	}
+	if ioParallel < 1 {
+		ioParallel = DefaultHashIOParallel
+	}
+	hp := &HashPipeline{
+		ioParallel: ioParallel,
+		sumCache:   sc,
+		ioSems:     map[string]chan struct{}{},
+	}
+	hp.cond = sync.NewCond(&hp.mu)
+	hp.wg.Add(parallel)
+	for i := 0; i < parallel; i++ {
+		go hp.worker()
+	}
+	return hp
+}
+
+// Submit 加入要计算摘要的文件, 不阻塞
+func (hp *HashPipeline) Submit(localPath string) *HashJob {
+	job := &HashJob{
+		LocalPath: localPath,
+		done:      make(chan struct{}),
+	}
+	hp.mu.Lock()
+	defer hp.mu.Unlock()
+	if hp.closed {
+		job.finish(ErrHashPipelineClosed)
+		return job
+	}
+	hp.queue = append(hp.queue, job)
+	hp.cond.Signal()
+	return job
+}
+
+// Close 停止计算, 尚未开始的任务返回 ErrHashPipelineClosed, 等待进行中的任务结束
+func (hp *HashPipeline) Close() {
+	hp.mu.Lock()
+	hp.closed = true
+	for _, job := range hp.queue {
+		job.finish(ErrHashPipelineClosed)
+	}
+	hp.queue = nil
+	hp.cond.Broadcast()
+	hp.mu.Unlock()
+	hp.wg.Wait()
+}
+
+func (hp *HashPipeline) worker() {
+	defer hp.wg.Done()
+	for {
+		hp.mu.Lock()
+		for len(hp.queue) == 0 && !hp.closed {
+			hp.cond.Wait()
+		}
+		if hp.closed {
+			hp.mu.Unlock()
+			return
+		}
+		job := hp.queue[0]
+		hp.queue[0] = nil
+		hp.queue = hp.queue[1:]
+		hp.mu.Unlock()
+
+		job.finish(hp.sum(job))
+	}
+}
+
+// ioSem 返回文件所在设备的信号量
+func (hp *HashPipeline) ioSem(device string) chan struct{} {
+	hp.mu.Lock()
+	defer hp.mu.Unlock()
+	sem, ok := hp.ioSems[device]
+	if !ok {
+		sem = make(chan struct{}, hp.ioParallel)
+		hp.ioSems[device] = sem
+	}
+	return sem
+}
+
+func (hp *HashPipeline) sum(job *HashJob) error {
+	info, err := os.Stat(job.LocalPath)
+	if err != nil {
+		return err
+	}
+
+	lfc := checksum.NewLocalFileChecksum(job.LocalPath, int(baidupcs.SliceMD5Size))
+	lfc.SetSumCache(hp.sumCache)
+	err = lfc.OpenPath()
+	if err != nil {
+		return err
+	}
+	defer lfc.Close()
+
+	sem := hp.ioSem(checksum.DeviceKey(job.LocalPath, info))
+	sem <- struct{}{}
+	err = lfc.Sum(checksum.CHECKSUM_MD5 | checksum.CHECKSUM_SLICE_MD5)
+	if err == nil {
+		err = lfc.CalculateChunkedSum(getBlockSize(lfc.Length))
+	}
+	<-sem
+	if err != nil {
+		return err
+	}
+	job.meta = lfc.LocalFileMeta
+	return nil
+}
+
+func (job *HashJob) finish(err error) {
+	job.err = err
+	close(job.done)
+}
+
+// Wait 等待计算完成, 返回文件的元信息
+func (job *HashJob) Wait() (*checksum.LocalFileMeta, error) {
+	<-job.done
+	if job.err != nil {
+		return nil, job.err
+	}
+	meta := job.meta
+	return &meta, nil
+}
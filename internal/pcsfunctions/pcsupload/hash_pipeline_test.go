@@ -0,0 +1,63 @@
+package pcsupload_test
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/qjfoidnh/BaiduPCS-Go/baidupcs"
+	"github.com/qjfoidnh/BaiduPCS-Go/internal/pcsfunctions/pcsupload"
+)
+
+func TestHashPipeline(t *testing.T) {
+	var (
+		dir   = t.TempDir()
+		sizes = []int{1000, int(baidupcs.MinUploadBlockSize) + 1000}
+		hp    = pcsupload.NewHashPipeline(2, 1, nil)
+		jobs  []*pcsupload.HashJob
+		datas [][]byte
+	)
+	defer hp.Close()
+	for k, size := range sizes {
+		data := make([]byte, size)
+		rand.Read(data)
+		localPath := filepath.Join(dir, string(rune('a'+k)))
+		if err := os.WriteFile(localPath, data, 0600); err != nil {
+			t.Fatal(err)
+		}
+		datas = append(datas, data)
+		jobs = append(jobs, hp.Submit(localPath))
+	}
+
+	for k, job := range jobs {
+		meta, err := job.Wait()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data := datas[k]
+		sum := md5.Sum(data)
+		sliceSum := md5.Sum(data[:min(len(data), int(baidupcs.SliceMD5Size))])
+		if meta.Length != int64(len(data)) || !bytes.Equal(meta.MD5, sum[:]) || !bytes.Equal(meta.SliceMD5, sliceSum[:]) {
+			t.Errorf("%s: length %d, md5 %x, slice md5 %x", job.LocalPath, meta.Length, meta.MD5, meta.SliceMD5)
+		}
+
+		// 秒传使用的分块md5
+		var blockList []string
+		for offset := 0; offset < len(data); offset += int(baidupcs.MinUploadBlockSize) {
+			blockSum := md5.Sum(data[offset:min(len(data), offset+int(baidupcs.MinUploadBlockSize))])
+			blockList = append(blockList, hex.EncodeToString(blockSum[:]))
+		}
+		if len(meta.BlocksList) != len(blockList) {
+			t.Fatalf("%s: block list %v, expected %v", job.LocalPath, meta.BlocksList, blockList)
+		}
+		for i := range blockList {
+			if meta.BlocksList[i] != blockList[i] {
+				t.Errorf("%s: block %d md5 %s, expected %s", job.LocalPath, i, meta.BlocksList[i], blockList[i])
+			}
+		}
+	}
+}
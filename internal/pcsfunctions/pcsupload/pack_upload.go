@@ -0,0 +1,335 @@
+package pcsupload
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"github.com/qjfoidnh/BaiduPCS-Go/baidupcs"
+	"github.com/qjfoidnh/BaiduPCS-Go/baidupcs/pcserror"
+	"github.com/qjfoidnh/BaiduPCS-Go/internal/pcsfunctions"
+	"github.com/qjfoidnh/BaiduPCS-Go/pcsutil/converter"
+	"io"
+	"os"
+	"path"
+	"strconv"
+)
+
+const (
+	// DefaultPackThreshold 默认打包上传的文件大小阈值, 小于该大小的文件打包上传
+	DefaultPackThreshold = 1 * converter.MB
+	// DefaultPackVolumeSize 默认打包的分卷大小
+	DefaultPackVolumeSize = 1 * converter.GB
+
+	// packChunkSize tar.zst 格式每个 zstd frame 压缩前的大小
+	packChunkSize = 4 * converter.MB
+	// packNameHashLen 包名中内容摘要的长度
+	packNameHashLen = 12
+)
+
+type (
+	// PackFile 要打包上传的本地文件
+	PackFile struct {
+		LocalPath string
+		Path      string // 包内的路径, 相对于上传目录
+	}
+
+	// PackUploadOptions 打包上传可选项, StreamUploadOptions.Policy 为索引文件已存在时的处理策略
+	PackUploadOptions struct {
+		StreamUploadOptions
+		Format     string // pcsfunctions.PackFormatTar 或 pcsfunctions.PackFormatTarZst
+		VolumeSize int64  // 分卷大小
+	}
+
+	// packVolumeWriter 将文件写入一个分卷, 记录文件在分卷中的偏移量
+	packVolumeWriter struct {
+		w       *countWriter
+		tw      *tar.Writer
+		chunk   *bytes.Buffer // tar.zst 格式, 未压缩的数据
+		pending []*pcsfunctions.PackMember
+		volume  int
+	}
+
+	countWriter struct {
+		w io.Writer
+		n int64
+	}
+)
+
+func (cw *countWriter) Write(p []byte) (n int, err error) {
+	n, err = cw.w.Write(p)
+	cw.n += int64(n)
+	return
+}
+
+// PackName 返回打包的包名 <prefix>-<摘要>, 摘要根据打包格式, 分卷大小和文件的路径, 大小, 修改时间计算,
+// 文件不变时包名不变, 以便中断后继续上传; 文件改变或打包其他目录时包名不同, 不会覆盖已有的包
+func PackName(prefix string, files []*PackFile, opt *PackUploadOptions) string {
+	opt = opt.withDefaults()
+	h := md5.New()
+	io.WriteString(h, opt.Format+"\n"+strconv.FormatInt(opt.VolumeSize, 10)+"\n")
+	for _, pf := range files {
+		var size, mtime int64
+		if info, err := os.Lstat(pf.LocalPath); err == nil {
+			size, mtime = info.Size(), info.ModTime().UnixNano()
+		}
+		fmt.Fprintf(h, "%q %d %d\n", pf.Path, size, mtime)
+	}
+	return prefix + "-" + hex.EncodeToString(h.Sum(nil))[:packNameHashLen]
+}
+
+// withDefaults 返回填充了默认值的可选项
+func (opt *PackUploadOptions) withDefaults() *PackUploadOptions {
+	o := PackUploadOptions{}
+	if opt != nil {
+		o = *opt
+	}
+	if o.Format == "" {
+		o.Format = pcsfunctions.PackFormatTar
+	}
+	if o.VolumeSize <= 0 {
+		o.VolumeSize = DefaultPackVolumeSize
+	}
+	if o.Policy == "" {
+		o.Policy = baidupcs.OverWritePolicy
+	}
+	return &o
+}
+
+// UploadPack 将文件打包上传到网盘目录 saveDir, 分卷命名为 <包名>.vol001.tar, .vol002.tar ...,
+// 全部分卷上传成功后上传索引文件 <包名>.pcspack.json, 记录每个文件所在的分卷和偏移量.
+// 包名应由 PackName 生成, 同一包名的文件打包的结果相同, 网盘中已存在大小和md5都一致的分卷时跳过,
+// 因此中断后可以使用相同的包名继续上传, 不一致的分卷为上次中断时未上传完成的, 会被覆盖.
+// 索引文件已存在时, 即上次已上传完成, 按照 opt.Policy 处理: skip 和 rsync 跳过, 返回的索引为 nil; overwrite 重新上传
+func UploadPack(pcs *baidupcs.BaiduPCS, files []*PackFile, saveDir, packName string, opt *PackUploadOptions) (*pcsfunctions.PackIndex, error) {
+	opt = opt.withDefaults()
+
+	indexPath := path.Join(saveDir, pcsfunctions.PackIndexName(packName))
+	if opt.Policy != baidupcs.OverWritePolicy {
+		_, pcsError := pcs.FilesDirectoriesMeta(indexPath)
+		if pcsError == nil {
+			fmt.Printf("[%s] 打包索引 %s 已存在, 跳过\n", packName, indexPath)
+			return nil, nil
+		}
+		if !pcserror.IsNotExist(pcsError) {
+			return nil, fmt.Errorf("检查打包索引 %s 失败: %s", indexPath, pcsError)
+		}
+	}
+
+	var (
+		index = &pcsfunctions.PackIndex{
+			Version: pcsfunctions.PackIndexVersion,
+			Format:  opt.Format,
+		}
+		next int // 下一个要写入的文件
+	)
+	for volume := 0; next < len(files); volume++ {
+		var (
+			volumeName = pcsfunctions.PackVolumeName(packName, opt.Format, volume)
+			volumePath = path.Join(saveDir, volumeName)
+			pr, pw     = io.Pipe()
+			done       = make(chan error, 1)
+			streamOpt  = opt.StreamUploadOptions
+		)
+		streamOpt.BlockSize = getBlockSize(opt.VolumeSize)
+		streamOpt.Policy = baidupcs.OverWritePolicy // 已存在的分卷为上次未上传完成的
+
+		if meta, pcsError := pcs.FilesDirectoriesMeta(volumePath); pcsError == nil {
+			members, size, ok, err := matchPackVolume(pcs, meta, files, &next, volume, opt)
+			if err != nil {
+				return nil, fmt.Errorf("打包分卷 %s 失败: %s", volumeName, err)
+			}
+			if ok {
+				fmt.Printf("[%s] 打包分卷 %d: %s 已存在且内容一致, 跳过\n", packName, volume+1, volumePath)
+				index.Members = append(index.Members, members...)
+				index.Volumes = append(index.Volumes, &pcsfunctions.PackVolume{
+					Name: volumeName,
+					Size: size,
+				})
+				continue
+			}
+		}
+
+		go func() {
+			members, err := writePackVolume(pw, files, &next, volume, opt)
+			index.Members = append(index.Members, members...)
+			pw.CloseWithError(err)
+			done <- err
+		}()
+
+		fmt.Printf("[%s] 上传打包分卷 %d: %s\n", packName, volume+1, volumePath)
+		size, _, err := UploadStream(pcs, pr, volumePath, &streamOpt)
+		fmt.Printf("\n")
+		pr.CloseWithError(io.ErrClosedPipe) // 上传失败时结束写入
+		werr := <-done
+		if err != nil {
+			// 打包失败时, 上传的错误中包含打包的错误
+			return nil, fmt.Errorf("上传打包分卷 %s 失败: %s", volumePath, err)
+		}
+		if werr != nil {
+			return nil, fmt.Errorf("打包分卷 %s 失败: %s", volumeName, werr)
+		}
+		index.Volumes = append(index.Volumes, &pcsfunctions.PackVolume{
+			Name: volumeName,
+			Size: size,
+		})
+	}
+
+	data, err := index.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	_, _, err = UploadStream(pcs, bytes.NewReader(data), indexPath, &StreamUploadOptions{
+		Parallel:  opt.Parallel,
+		BlockSize: getBlockSize(int64(len(data))),
+		Policy:    opt.Policy,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("上传打包索引 %s 失败: %s", indexPath, err)
+	}
+	fmt.Printf("[%s] 打包上传成功, 共 %d 个文件, %d 个分卷, 索引: %s\n", packName, len(index.Members), len(index.Volumes), indexPath)
+	return index, nil
+}
+
+// matchPackVolume 打包分卷并计算md5, 与网盘中已存在的分卷 meta 比较. 不一致时不移动 next, 以便重新打包上传
+func matchPackVolume(pcs *baidupcs.BaiduPCS, meta *baidupcs.FileDirectory, files []*PackFile, next *int, volume int, opt *PackUploadOptions) (members []*pcsfunctions.PackMember, size int64, ok bool, err error) {
+	var (
+		start = *next
+		h     = md5.New()
+		cw    = &countWriter{w: h}
+	)
+	members, err = writePackVolume(cw, files, next, volume, opt)
+	if err != nil {
+		return nil, 0, false, err
+	}
+	if !remoteFileMatch(pcs, meta, cw.n, hex.EncodeToString(h.Sum(nil))) {
+		*next = start
+		return nil, 0, false, nil
+	}
+	return members, cw.n, true, nil
+}
+
+// writePackVolume 从 files[*next] 开始, 将文件写入分卷, 直到分卷大小超过 opt.VolumeSize
+func writePackVolume(w io.Writer, files []*PackFile, next *int, volume int, opt *PackUploadOptions) (members []*pcsfunctions.PackMember, err error) {
+	pvw := &packVolumeWriter{
+		w:      &countWriter{w: w},
+		volume: volume,
+	}
+	if opt.Format == pcsfunctions.PackFormatTarZst {
+		pvw.chunk = &bytes.Buffer{}
+		pvw.tw = tar.NewWriter(pvw.chunk)
+	} else {
+		pvw.tw = tar.NewWriter(pvw.w)
+	}
+
+	for *next < len(files) {
+		pf := files[*next]
+		info, err := os.Lstat(pf.LocalPath)
+		if err != nil || !info.Mode().IsRegular() {
+			fmt.Printf("[0] 跳过无法打包的文件: %s\n", pf.LocalPath)
+			*next++
+			continue
+		}
+		if len(members) > 0 && pvw.size()+info.Size() > opt.VolumeSize {
+			break // 下一个分卷
+		}
+		*next++
+
+		member, err := pvw.writeFile(pf, info)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, member)
+	}
+
+	err = pvw.tw.Close()
+	if err != nil {
+		return nil, err
+	}
+	return members, pvw.flushChunk()
+}
+
+// size 返回分卷当前的大小, 包括未压缩的数据
+func (pvw *packVolumeWriter) size() int64 {
+	if pvw.chunk != nil {
+		return pvw.w.n + int64(pvw.chunk.Len())
+	}
+	return pvw.w.n
+}
+
+func (pvw *packVolumeWriter) writeFile(pf *PackFile, info os.FileInfo) (*pcsfunctions.PackMember, error) {
+	f, err := os.Open(pf.LocalPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	hdr, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return nil, err
+	}
+	hdr.Name = pf.Path
+	err = pvw.tw.WriteHeader(hdr)
+	if err != nil {
+		return nil, err
+	}
+
+	member := &pcsfunctions.PackMember{
+		Path:   pf.Path,
+		Size:   hdr.Size,
+		Mode:   hdr.Mode,
+		MTime:  hdr.ModTime.Unix(),
+		Volume: pvw.volume,
+	}
+	if pvw.chunk != nil {
+		member.DataOffset = int64(pvw.chunk.Len())
+	} else {
+		member.Offset = pvw.w.n
+		member.Length = hdr.Size
+	}
+
+	// 文件大小在打包过程中改变会破坏 tar 的结构
+	_, err = io.CopyN(pvw.tw, f, hdr.Size)
+	if err != nil {
+		return nil, fmt.Errorf("读取 %s 错误, 文件可能被修改: %s", pf.LocalPath, err)
+	}
+	err = pvw.tw.Flush()
+	if err != nil {
+		return nil, err
+	}
+
+	if pvw.chunk != nil {
+		pvw.pending = append(pvw.pending, member)
+		if int64(pvw.chunk.Len()) >= packChunkSize {
+			err = pvw.flushChunk()
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return member, nil
+}
+
+// flushChunk 将未压缩的数据压缩为一个 zstd frame 写入分卷, 记录 frame 的位置
+func (pvw *packVolumeWriter) flushChunk() error {
+	if pvw.chunk == nil || pvw.chunk.Len() == 0 {
+		return nil
+	}
+	compressed, err := pcsfunctions.ZstdCompress(pvw.chunk.Bytes())
+	if err != nil {
+		return err
+	}
+	offset := pvw.w.n
+	_, err = pvw.w.Write(compressed)
+	if err != nil {
+		return err
+	}
+	for _, member := range pvw.pending {
+		member.Offset = offset
+		member.Length = int64(len(compressed))
+	}
+	pvw.pending = pvw.pending[:0]
+	pvw.chunk.Reset()
+	return nil
+}
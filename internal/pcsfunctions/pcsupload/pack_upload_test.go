@@ -0,0 +1,91 @@
+package pcsupload_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/qjfoidnh/BaiduPCS-Go/baidupcs"
+	"github.com/qjfoidnh/BaiduPCS-Go/internal/pcsfunctions"
+	"github.com/qjfoidnh/BaiduPCS-Go/internal/pcsfunctions/pcsupload"
+	"github.com/qjfoidnh/BaiduPCS-Go/requester"
+)
+
+func TestPackName(t *testing.T) {
+	dir := t.TempDir()
+	files := []*pcsupload.PackFile{
+		{LocalPath: filepath.Join(dir, "a"), Path: "photos/a"},
+		{LocalPath: filepath.Join(dir, "b"), Path: "photos/b"},
+	}
+	for _, pf := range files {
+		if err := os.WriteFile(pf.LocalPath, []byte(pf.Path), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 文件不变时包名不变
+	name := pcsupload.PackName("photos", files, nil)
+	if !strings.HasPrefix(name, "photos-") || len(name) != len("photos-")+12 {
+		t.Fatalf("name %s", name)
+	}
+	if again := pcsupload.PackName("photos", files, &pcsupload.PackUploadOptions{Format: pcsfunctions.PackFormatTar}); again != name {
+		t.Errorf("name changed: %s, %s", name, again)
+	}
+
+	// 文件, 路径或打包参数改变时包名不同
+	names := map[string]string{"": name}
+	names["format"] = pcsupload.PackName("photos", files, &pcsupload.PackUploadOptions{Format: pcsfunctions.PackFormatTarZst})
+	names["volume size"] = pcsupload.PackName("photos", files, &pcsupload.PackUploadOptions{VolumeSize: 1024})
+	names["member path"] = pcsupload.PackName("photos", []*pcsupload.PackFile{files[0], {LocalPath: files[1].LocalPath, Path: "photos/c"}}, nil)
+	names["member count"] = pcsupload.PackName("photos", files[:1], nil)
+	mtime := time.Now().Add(time.Hour)
+	os.Chtimes(files[1].LocalPath, mtime, mtime)
+	names["mtime"] = pcsupload.PackName("photos", files, nil)
+	seen := map[string]string{}
+	for change, n := range names {
+		if other, ok := seen[n]; ok {
+			t.Errorf("%q and %q share name %s", change, other, n)
+		}
+		seen[n] = change
+	}
+}
+
+func TestUploadPackExistingIndex(t *testing.T) {
+	var metaPaths []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("method") != "meta" {
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL)
+			http.NotFound(w, r)
+			return
+		}
+		metaPaths = append(metaPaths, r.FormValue("param"))
+		io.WriteString(w, `{"list":[{"fs_id":1,"path":"/backup/photos-0.pcspack.json","server_filename":"photos-0.pcspack.json","size":2,"isdir":0}]}`)
+	}))
+	defer srv.Close()
+	requester.SetGlobalProxy(srv.Listener.Addr().String())
+	defer requester.SetGlobalProxy("")
+
+	pcs := baidupcs.NewPCS(0, "")
+	pcs.SetPanUserAgent(baidupcs.NetdiskUA)
+	pcs.SetHTTPS(false)
+	pcs.SetStaticPCSAddr(true)
+
+	// 索引已存在时, skip 和 rsync 跳过整个包, 不上传分卷
+	files := []*pcsupload.PackFile{{LocalPath: filepath.Join(t.TempDir(), "a"), Path: "a"}}
+	for _, policy := range []string{baidupcs.SkipPolicy, baidupcs.RsyncPolicy} {
+		index, err := pcsupload.UploadPack(pcs, files, "/backup", "photos-0", &pcsupload.PackUploadOptions{
+			StreamUploadOptions: pcsupload.StreamUploadOptions{Policy: policy},
+		})
+		if index != nil || err != nil {
+			t.Errorf("%s: index %v, err %v", policy, index, err)
+		}
+	}
+	if len(metaPaths) != 2 || !strings.Contains(metaPaths[0], "/backup/photos-0.pcspack.json") {
+		t.Errorf("meta requests %v", metaPaths)
+	}
+}
@@ -7,6 +7,8 @@ import (
 
 const (
 	UploadingFileName = "pcs_uploading.json"
+	// UploadSessionDirName 上传会话的目录名, 每个会话一个文件
+	UploadSessionDirName = "upload_sessions"
 )
 
 var (
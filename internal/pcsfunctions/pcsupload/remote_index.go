@@ -0,0 +1,104 @@
+package pcsupload
+
+import (
+	"fmt"
+	"github.com/qjfoidnh/BaiduPCS-Go/baidupcs"
+	"github.com/qjfoidnh/BaiduPCS-Go/baidupcs/pcserror"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type (
+	// RemoteIndex 网盘目录中文件的索引, 以文件大小和md5查找相同的文件
+	RemoteIndex struct {
+		Scope string // 索引的网盘目录
+
+		mu    sync.RWMutex
+		files map[string]*RemoteIndexEntry
+	}
+
+	// RemoteIndexEntry 索引中的文件
+	RemoteIndexEntry struct {
+		Path      string
+		BlockList []string // 多于一个时, 网盘记录的md5可能不正确, 需要比较分块md5
+	}
+)
+
+// NewRemoteIndex 遍历网盘目录 scope, 建立文件索引.
+// 部分子目录获取失败时仍返回索引, 只有 scope 本身获取失败才返回错误
+func NewRemoteIndex(pcs *baidupcs.BaiduPCS, scope string) (*RemoteIndex, error) {
+	ri := &RemoteIndex{
+		Scope: scope,
+		files: map[string]*RemoteIndexEntry{},
+	}
+
+	var rootErr pcserror.Error
+	pcs.FilesDirectoriesWalk(scope, &baidupcs.WalkOptions{
+		OrderOptions: baidupcs.DefaultOrderOptions,
+		Ordered:      true,
+	}, func(depth int, fdPath string, fd *baidupcs.FileDirectory, pcsError pcserror.Error) bool {
+		if pcsError != nil {
+			if fdPath == scope {
+				rootErr = pcsError
+				return false
+			}
+			fmt.Printf("获取目录 %s 错误, %s\n", fdPath, pcsError)
+			return true
+		}
+		// 空文件不需要查找
+		if fd.Isdir || fd.Size == 0 || fd.MD5 == "" {
+			return true
+		}
+		ri.add(fd.Size, fd.MD5, &RemoteIndexEntry{
+			Path:      fd.Path,
+			BlockList: fd.BlockList,
+		})
+		return true
+	})
+	if rootErr != nil {
+		return nil, rootErr
+	}
+	return ri, nil
+}
+
+func remoteIndexKey(size int64, md5 string) string {
+	return strconv.FormatInt(size, 10) + ":" + strings.ToLower(md5)
+}
+
+func (ri *RemoteIndex) add(size int64, md5 string, entry *RemoteIndexEntry) {
+	ri.mu.Lock()
+	defer ri.mu.Unlock()
+	key := remoteIndexKey(size, md5)
+	if _, ok := ri.files[key]; !ok {
+		ri.files[key] = entry
+	}
+}
+
+// Len 返回索引中的文件数量
+func (ri *RemoteIndex) Len() int {
+	ri.mu.RLock()
+	defer ri.mu.RUnlock()
+	return len(ri.files)
+}
+
+// Lookup 查找大小和md5相同的文件
+func (ri *RemoteIndex) Lookup(size int64, md5 string) (entry *RemoteIndexEntry, ok bool) {
+	ri.mu.RLock()
+	defer ri.mu.RUnlock()
+	entry, ok = ri.files[remoteIndexKey(size, md5)]
+	return
+}
+
+// Add 加入上传成功的文件, 文件不在索引的目录中则忽略
+func (ri *RemoteIndex) Add(size int64, md5, pcspath string) {
+	if size == 0 || md5 == "" {
+		return
+	}
+	if ri.Scope != baidupcs.PathSeparator && pcspath != ri.Scope && !strings.HasPrefix(pcspath, ri.Scope+baidupcs.PathSeparator) {
+		return
+	}
+	ri.add(size, md5, &RemoteIndexEntry{
+		Path: pcspath,
+	})
+}
@@ -0,0 +1,117 @@
+package pcsupload_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/qjfoidnh/BaiduPCS-Go/baidupcs"
+	"github.com/qjfoidnh/BaiduPCS-Go/internal/pcsfunctions/pcsupload"
+	"github.com/qjfoidnh/BaiduPCS-Go/requester"
+)
+
+const (
+	indexMD5A = "0cc175b9c0f1b6a831c399e269772661"
+	indexMD5B = "92eb5ffee6ae2fec3ad71c777531578f"
+)
+
+// serveRemoteIndex 模拟网盘目录 /backup, 子目录 /backup/broken 获取失败
+func serveRemoteIndex(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	switch {
+	case query.Get("method") == "meta" && strings.Contains(r.FormValue("param"), `"/backup"`):
+		io.WriteString(w, `{"list":[{"fs_id":1,"path":"/backup","server_filename":"backup","isdir":1}]}`)
+	case query.Get("method") == "list" && query.Get("path") == "/backup":
+		io.WriteString(w, `{"list":[`+
+			`{"fs_id":2,"path":"/backup/a","server_filename":"a","isdir":0,"size":3,"md5":"`+indexMD5A+`"},`+
+			`{"fs_id":3,"path":"/backup/b","server_filename":"b","isdir":0,"size":3,"md5":"`+strings.ToUpper(indexMD5B)+`"},`+
+			`{"fs_id":4,"path":"/backup/empty","server_filename":"empty","isdir":0,"size":0,"md5":"`+indexMD5A+`"},`+
+			`{"fs_id":5,"path":"/backup/broken","server_filename":"broken","isdir":1},`+
+			`{"fs_id":6,"path":"/backup/sub","server_filename":"sub","isdir":1}]}`)
+	case query.Get("method") == "list" && query.Get("path") == "/backup/sub":
+		io.WriteString(w, `{"list":[`+
+			`{"fs_id":7,"path":"/backup/sub/a2","server_filename":"a2","isdir":0,"size":3,"md5":"`+indexMD5A+`"},`+
+			`{"fs_id":8,"path":"/backup/sub/big","server_filename":"big","isdir":0,"size":100,"md5":"`+indexMD5A+`","block_list":["`+indexMD5A+`","`+indexMD5B+`"]}]}`)
+	default:
+		io.WriteString(w, `{"error_code":31066,"error_msg":"file does not exist"}`)
+	}
+}
+
+func TestRemoteIndex(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(serveRemoteIndex))
+	requester.SetGlobalProxy(srv.Listener.Addr().String())
+	t.Cleanup(func() {
+		requester.SetGlobalProxy("")
+		srv.Close()
+	})
+	pcs := baidupcs.NewPCS(0, "")
+	pcs.SetPanUserAgent(baidupcs.NetdiskUA)
+	pcs.GetClient()
+	pcs.SetHTTPS(false)
+	pcs.SetStaticPCSAddr(true)
+
+	// 目录本身获取失败时返回错误
+	if _, err := pcsupload.NewRemoteIndex(pcs, "/missing"); err == nil {
+		t.Error("index of missing scope built")
+	}
+
+	// 子目录获取失败时仍建立索引, 空文件不加入索引
+	ri, err := pcsupload.NewRemoteIndex(pcs, "/backup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ri.Len() != 3 {
+		t.Errorf("%d files", ri.Len())
+	}
+
+	// 以大小和md5查找, md5 不区分大小写, 相同的文件保留先找到的
+	for _, c := range []struct {
+		size int64
+		md5  string
+		path string
+	}{
+		{3, indexMD5A, "/backup/a"},
+		{3, strings.ToUpper(indexMD5A), "/backup/a"},
+		{3, indexMD5B, "/backup/b"},
+		{100, indexMD5A, "/backup/sub/big"},
+		{4, indexMD5A, ""},
+		{0, indexMD5A, ""},
+	} {
+		entry, ok := ri.Lookup(c.size, c.md5)
+		if c.path == "" {
+			if ok {
+				t.Errorf("lookup %d %s: found %s", c.size, c.md5, entry.Path)
+			}
+			continue
+		}
+		if !ok || entry.Path != c.path {
+			t.Errorf("lookup %d %s: %v, %v", c.size, c.md5, entry, ok)
+		}
+	}
+	if entry, _ := ri.Lookup(100, indexMD5A); len(entry.BlockList) != 2 {
+		t.Errorf("block list %v", entry.BlockList)
+	}
+
+	// 只加入索引目录中上传的文件
+	ri.Add(5, indexMD5A, "/backup/new")
+	ri.Add(6, indexMD5A, "/backup2/new")
+	ri.Add(7, indexMD5A, "/other")
+	ri.Add(8, "", "/backup/nomd5")
+	if _, ok := ri.Lookup(5, indexMD5A); !ok {
+		t.Error("file in scope not added")
+	}
+	for _, size := range []int64{6, 7, 8} {
+		if _, ok := ri.Lookup(size, indexMD5A); ok {
+			t.Errorf("file of size %d added", size)
+		}
+	}
+
+	// 索引整个网盘时, 任意路径都加入
+	ri.Scope = baidupcs.PathSeparator
+	ri.Add(9, indexMD5A, "/other")
+	if _, ok := ri.Lookup(9, indexMD5A); !ok {
+		t.Error("file not added to root index")
+	}
+}
@@ -0,0 +1,167 @@
+package pcsupload
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/qjfoidnh/BaiduPCS-Go/baidupcs"
+	"github.com/qjfoidnh/BaiduPCS-Go/internal/pcsfunctions"
+	"github.com/qjfoidnh/BaiduPCS-Go/pcsutil/checksum"
+	"github.com/qjfoidnh/BaiduPCS-Go/pcsutil/taskframework"
+	"io"
+	"os"
+	"path"
+)
+
+type (
+	// SplitUploadOptions 分卷上传可选项
+	SplitUploadOptions struct {
+		Parallel          int
+		MaxRetry          int
+		NoRapidUpload     bool
+		UploadingDatabase *UploadingDatabase
+	}
+
+	// splitPartUnit 上传单个分卷的任务单元, 记录是否成功
+	splitPartUnit struct {
+		*UploadTaskUnit
+		succeed bool
+	}
+)
+
+func (spu *splitPartUnit) OnSuccess(lastRunResult *taskframework.TaskUnitRunResult) {
+	spu.succeed = true
+	spu.UploadTaskUnit.OnSuccess(lastRunResult)
+}
+
+// UploadSplitFile 将本地文件按 splitSize 分卷上传到 savePath 所在的目录, 分卷命名为 <文件名>.part001, .part002 ...,
+// 全部分卷上传成功后, 上传清单文件 <文件名>.pcsparts.json, 下载清单文件时会自动合并分卷.
+// 网盘中已存在大小和md5都与本地一致的分卷时跳过, 否则将分卷复制到临时文件, 按普通文件上传 (秒传, 失败重试), 覆盖网盘中的分卷
+func UploadSplitFile(pcs *baidupcs.BaiduPCS, localPath, savePath string, splitSize int64, opt *SplitUploadOptions) (*pcsfunctions.SplitManifest, error) {
+	if splitSize <= 0 {
+		return nil, fmt.Errorf("分卷大小错误: %d", splitSize)
+	}
+	if opt == nil {
+		opt = &SplitUploadOptions{}
+	}
+	if opt.UploadingDatabase == nil {
+		return nil, errors.New("未设置上传数据库")
+	}
+
+	f, err := os.Open(localPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		panDir, name = path.Split(savePath)
+		partCount    = int((info.Size() + splitSize - 1) / splitSize)
+		contentMD5   = md5.New()
+		manifest     = &pcsfunctions.SplitManifest{
+			Version:  pcsfunctions.SplitManifestVersion,
+			Name:     name,
+			Size:     info.Size(),
+			PartSize: splitSize,
+			Parts:    make([]*pcsfunctions.SplitPart, 0, partCount),
+		}
+	)
+	for i := 0; i < partCount; i++ {
+		var (
+			offset   = int64(i) * splitSize
+			partSize = min(splitSize, info.Size()-offset)
+			partName = pcsfunctions.SplitPartName(name, i)
+			partPath = path.Join(panDir, partName)
+			h        = md5.New()
+		)
+
+		size, err := io.Copy(io.MultiWriter(h, contentMD5), io.NewSectionReader(f, offset, partSize))
+		if err != nil {
+			return nil, err
+		}
+		if size != partSize {
+			return nil, fmt.Errorf("读取分卷 %s 失败: 读取的大小 %d 与预期 %d 不一致, 文件可能被修改", partPath, size, partSize)
+		}
+		partMD5 := hex.EncodeToString(h.Sum(nil))
+
+		if meta, pcsError := pcs.FilesDirectoriesMeta(partPath); pcsError == nil && remoteFileMatch(pcs, meta, partSize, partMD5) {
+			fmt.Printf("[%s] 分卷 %d/%d: %s 已存在且内容一致, 跳过\n", name, i+1, partCount, partPath)
+		} else {
+			fmt.Printf("[%s] 上传分卷 %d/%d: %s\n", name, i+1, partCount, partPath)
+			err = uploadSplitPart(pcs, f, offset, partSize, partMD5, partPath, opt)
+			if err != nil {
+				return nil, fmt.Errorf("上传分卷 %s 失败: %s", partPath, err)
+			}
+		}
+		manifest.Parts = append(manifest.Parts, &pcsfunctions.SplitPart{
+			Name: partName,
+			Size: partSize,
+			MD5:  partMD5,
+		})
+	}
+	manifest.MD5 = hex.EncodeToString(contentMD5.Sum(nil))
+
+	data, err := manifest.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	manifestPath := path.Join(panDir, pcsfunctions.SplitManifestName(name))
+	_, _, err = UploadStream(pcs, bytes.NewReader(data), manifestPath, &StreamUploadOptions{
+		Parallel: 1,
+		Policy:   baidupcs.OverWritePolicy, // 清单总是以本次上传为准
+	})
+	if err != nil {
+		return nil, fmt.Errorf("上传分卷清单 %s 失败: %s", manifestPath, err)
+	}
+	fmt.Printf("[%s] 分卷上传成功, 共 %d 个分卷, 清单: %s\n", name, partCount, manifestPath)
+	return manifest, nil
+}
+
+// uploadSplitPart 将 f 中 [offset, offset+size) 的数据复制到临时文件, 按普通文件上传到 partPath
+func uploadSplitPart(pcs *baidupcs.BaiduPCS, f *os.File, offset, size int64, partMD5, partPath string, opt *SplitUploadOptions) error {
+	tmp, err := os.CreateTemp("", "BaiduPCS-Go-part-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	h := md5.New()
+	n, err := io.Copy(io.MultiWriter(tmp, h), io.NewSectionReader(f, offset, size))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if n != size || hex.EncodeToString(h.Sum(nil)) != partMD5 {
+		return errors.New("分卷数据在读取期间发生变化, 文件可能被修改")
+	}
+
+	unit := &splitPartUnit{
+		UploadTaskUnit: &UploadTaskUnit{
+			LocalFileChecksum: checksum.NewLocalFileChecksum(tmp.Name(), int(baidupcs.SliceMD5Size)),
+			SavePath:          partPath,
+			PrintFormat:       DefaultPrintFormat,
+			PCS:               pcs,
+			UploadingDatabase: opt.UploadingDatabase,
+			Parallel:          opt.Parallel,
+			NoRapidUpload:     opt.NoRapidUpload,
+			Policy:            baidupcs.OverWritePolicy, // 已确认网盘中的分卷与本地不一致
+			UploadStatistic:   &UploadStatistic{},
+		},
+	}
+	executor := taskframework.NewTaskExecutor()
+	executor.SetParallel(1)
+	executor.Append(unit, opt.MaxRetry)
+	executor.Execute()
+	if !unit.succeed {
+		return errors.New(StrUploadFailed)
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package pcsupload
+
+import (
+	"errors"
+	"github.com/qjfoidnh/BaiduPCS-Go/baidupcs"
+	"github.com/qjfoidnh/BaiduPCS-Go/internal/pcsconfig"
+	"github.com/qjfoidnh/BaiduPCS-Go/requester/uploader"
+	"io"
+)
+
+type (
+	// StreamUploadOptions 流式上传可选项
+	StreamUploadOptions struct {
+		Parallel  int
+		BlockSize int64  // 分片大小, 默认为 baidupcs.MinUploadBlockSize
+		Policy    string // 上传重名文件策略
+		Size      int64  // 已知的数据大小, 大于0时用于 rsync 策略的判断
+
+		OnUploadStatusEvent uploader.UploadStatusFunc
+	}
+)
+
+var (
+	// ErrStreamTargetExists 流式上传的目标文件已存在, 按照策略跳过
+	ErrStreamTargetExists = errors.New("目标文件已存在, 跳过")
+)
+
+// UploadStream 从数据流 r 上传文件到网盘路径 savePath, 不需要事先知道数据的大小.
+// 读取的分片只缓存在内存中, 返回上传的文件大小和md5
+func UploadStream(pcs *baidupcs.BaiduPCS, r io.Reader, savePath string, opt *StreamUploadOptions) (size int64, md5Str string, err error) {
+	if opt == nil {
+		opt = &StreamUploadOptions{}
+	}
+	if opt.BlockSize <= 0 {
+		opt.BlockSize = baidupcs.MinUploadBlockSize
+	}
+
+	// 大小未知时只检测同名文件, rsync 策略不会跳过
+	knownSize := opt.Size
+	if knownSize <= 0 {
+		knownSize = -1
+	}
+	pcsError, jsonData := pcs.FakeRapidUpload(savePath, opt.Policy, knownSize)
+	if pcsError != nil {
+		switch pcsError.GetRemoteErrCode() {
+		case 114514, 1919810:
+			return 0, "", ErrStreamTargetExists
+		}
+		return 0, "", pcsError
+	}
+
+	su := uploader.NewStreamUploader(NewPCSUpload(pcs, savePath), r, jsonData.UploadID, &uploader.StreamUploaderConfig{
+		Parallel:  opt.Parallel,
+		BlockSize: opt.BlockSize,
+		MaxBlocks: baidupcs.MaxUploadBlockCount,
+		MaxRate:   pcsconfig.Config.MaxUploadRate,
+		Policy:    opt.Policy,
+
+		MaxRateFunc: pcsconfig.Config.UploadRateFunc(),
+	}, savePath)
+	su.OnUploadStatusEvent(opt.OnUploadStatusEvent)
+
+	err = su.Execute()
+	if err != nil {
+		return su.Size(), "", err
+	}
+	return su.Size(), su.MD5(), nil
+}
@@ -0,0 +1,136 @@
+package pcsupload_test
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/qjfoidnh/BaiduPCS-Go/baidupcs"
+	"github.com/qjfoidnh/BaiduPCS-Go/internal/pcsfunctions/pcsupload"
+	"github.com/qjfoidnh/BaiduPCS-Go/requester"
+)
+
+// fakePan 模拟网盘的 precreate, superfile2 和 create 接口
+type fakePan struct {
+	t         *testing.T
+	mu        sync.Mutex
+	precreate url.Values
+	parts     map[int][]byte
+	create    url.Values
+}
+
+func (fp *fakePan) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	fp.mu.Lock()
+	defer fp.mu.Unlock()
+
+	switch {
+	case r.URL.Path == "/rest/2.0/pcs/file" && r.URL.Query().Get("method") == "meta":
+		// 目标文件不存在
+		io.WriteString(w, `{"error_code":31066,"error_msg":"file does not exist"}`)
+	case r.URL.Path == "/api/precreate":
+		r.ParseForm()
+		fp.precreate = r.PostForm
+		io.WriteString(w, `{"errno":0,"uploadid":"UPLOADID","return_type":1,"block_list":[]}`)
+	case r.URL.Path == "/rest/2.0/pcs/superfile2":
+		if fp.precreate == nil || r.URL.Query().Get("uploadid") != "UPLOADID" {
+			fp.t.Errorf("superfile2 before precreate: %s", r.URL)
+		}
+		// 分片的文件名为空, 不能通过 FormFile 获取
+		mr, err := r.MultipartReader()
+		if err != nil {
+			fp.t.Error(err)
+			return
+		}
+		part, err := mr.NextPart()
+		if err != nil || part.FormName() != "uploadedfile" {
+			fp.t.Errorf("superfile2 part: %v", err)
+			return
+		}
+		data, _ := io.ReadAll(part)
+		partseq, _ := strconv.Atoi(r.URL.Query().Get("partseq"))
+		fp.parts[partseq] = data
+		sum := md5.Sum(data)
+		io.WriteString(w, `{"md5":"`+hex.EncodeToString(sum[:])+`"}`)
+	case r.URL.Path == "/api/create":
+		r.ParseForm()
+		fp.create = r.PostForm
+		io.WriteString(w, `{"errno":0}`)
+	default:
+		fp.t.Errorf("unexpected request: %s %s", r.Method, r.URL)
+		http.NotFound(w, r)
+	}
+}
+
+func TestUploadStream(t *testing.T) {
+	fp := &fakePan{t: t, parts: map[int][]byte{}}
+	srv := httptest.NewServer(fp)
+	defer srv.Close()
+
+	// 通过代理将所有请求发送到 srv
+	requester.SetGlobalProxy(srv.Listener.Addr().String())
+	defer requester.SetGlobalProxy("")
+
+	pcs := baidupcs.NewPCS(0, "")
+	pcs.SetPanUserAgent(baidupcs.NetdiskUA)
+	pcs.GetClient()
+	pcs.SetHTTPS(false)
+	pcs.SetStaticPCSAddr(true)
+
+	data := make([]byte, 2500)
+	rand.Read(data)
+	size, md5Str, err := pcsupload.UploadStream(pcs, bytes.NewReader(data), "/d/stream.bin", &pcsupload.StreamUploadOptions{
+		Parallel:  1, // 分片上传共用同一个 http client, 不并发
+		BlockSize: 1024,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sum := md5.Sum(data)
+	if size != int64(len(data)) || md5Str != hex.EncodeToString(sum[:]) {
+		t.Fatalf("got size %d, md5 %s", size, md5Str)
+	}
+
+	// 大小未知, precreate 只提交一个占位的分片
+	if fp.precreate.Get("path") != "/d/stream.bin" {
+		t.Errorf("precreate path: %s", fp.precreate.Get("path"))
+	}
+	var precreateBlocks []string
+	if err = json.Unmarshal([]byte(fp.precreate.Get("block_list")), &precreateBlocks); err != nil || len(precreateBlocks) != 1 {
+		t.Errorf("precreate block_list: %s", fp.precreate.Get("block_list"))
+	}
+
+	// create 提交实际的大小和分片md5
+	var (
+		merged    []byte
+		blockList []string
+	)
+	for i := 0; i < len(fp.parts); i++ {
+		merged = append(merged, fp.parts[i]...)
+		partSum := md5.Sum(fp.parts[i])
+		blockList = append(blockList, hex.EncodeToString(partSum[:]))
+	}
+	if !bytes.Equal(merged, data) || len(fp.parts) != 3 {
+		t.Errorf("uploaded %d parts, merged data mismatch", len(fp.parts))
+	}
+	wantBlockList, _ := json.Marshal(blockList)
+	switch {
+	case fp.create == nil:
+		t.Fatal("create not called")
+	case fp.create.Get("uploadid") != "UPLOADID":
+		t.Errorf("create uploadid: %s", fp.create.Get("uploadid"))
+	case fp.create.Get("size") != strconv.Itoa(len(data)):
+		t.Errorf("create size: %s", fp.create.Get("size"))
+	case fp.create.Get("block_list") != string(wantBlockList):
+		t.Errorf("create block_list: %s, expected %s", fp.create.Get("block_list"), wantBlockList)
+	}
+}
@@ -0,0 +1,313 @@
+package pcsupload
+
+import (
+	"errors"
+	"github.com/qjfoidnh/BaiduPCS-Go/internal/pcsconfig"
+	"github.com/qjfoidnh/BaiduPCS-Go/pcsutil/jsonhelper"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// UploadSessionPending 等待上传
+	UploadSessionPending = "pending"
+	// UploadSessionDone 上传成功
+	UploadSessionDone = "done"
+	// UploadSessionSkipped 跳过, 如目标文件已存在
+	UploadSessionSkipped = "skipped"
+	// UploadSessionFailed 上传失败, 恢复会话时重新上传
+	UploadSessionFailed = "failed"
+
+	// uploadSessionSaveDelay 状态变更后延迟写入文件的时间, 合并多次写入
+	uploadSessionSaveDelay = 2 * time.Second
+)
+
+var (
+	// ErrUploadSessionName 会话名称不合法
+	ErrUploadSessionName = errors.New("会话名称不能为空, 且不能包含路径分隔符")
+	// ErrUploadSessionNotFound 会话不存在
+	ErrUploadSessionNotFound = errors.New("上传会话不存在")
+)
+
+type (
+	// UploadSessionFile 上传会话中的文件
+	UploadSessionFile struct {
+		LocalPath string `json:"local_path"` // 绝对路径
+		SavePath  string `json:"save_path"`
+		Size      int64  `json:"size"`
+		ModTime   int64  `json:"mtime"`
+		Status    string `json:"status"`
+		Error     string `json:"error,omitempty"`
+	}
+
+	// UploadSession 命名的上传会话, 记录要上传的文件列表和每个文件的状态,
+	// 中断后恢复时跳过已完成的文件, 不再计算其md5
+	UploadSession struct {
+		Name       string               `json:"name"`
+		LocalPaths []string             `json:"local_paths"` // 上传的本地路径, 绝对路径
+		SavePath   string               `json:"save_path"`   // 网盘目录
+		Created    int64                `json:"created"`
+		Updated    int64                `json:"updated"`
+		Files      []*UploadSessionFile `json:"files"`
+
+		mu        sync.Mutex
+		filePath  string
+		index     map[string]*UploadSessionFile
+		seen      map[string]bool // 本次运行加入的文件
+		saveTimer *time.Timer
+		removed   bool
+	}
+
+	// UploadSessionProgress 上传会话的进度
+	UploadSessionProgress struct {
+		Total, Done, Skipped, Failed, Pending int
+		TotalSize, FinishedSize               int64
+	}
+)
+
+func uploadSessionDir() string {
+	return filepath.Join(pcsconfig.GetConfigDir(), UploadSessionDirName)
+}
+
+func uploadSessionPath(name string) (string, error) {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return "", ErrUploadSessionName
+	}
+	return filepath.Join(uploadSessionDir(), name+".json"), nil
+}
+
+// OpenUploadSession 打开上传会话, 会话不存在且 create 为 true 时创建新的会话
+func OpenUploadSession(name string, create bool) (us *UploadSession, err error) {
+	filePath, err := uploadSessionPath(name)
+	if err != nil {
+		return nil, err
+	}
+	us, err = loadUploadSession(filePath)
+	switch {
+	case err == nil:
+	case os.IsNotExist(err) && create:
+		now := time.Now().Unix()
+		us = &UploadSession{
+			Name:    name,
+			Created: now,
+			Updated: now,
+		}
+	case os.IsNotExist(err):
+		return nil, ErrUploadSessionNotFound
+	default:
+		return nil, err
+	}
+	us.filePath = filePath
+	us.seen = map[string]bool{}
+	us.index = make(map[string]*UploadSessionFile, len(us.Files))
+	for _, f := range us.Files {
+		us.index[f.LocalPath] = f
+	}
+	return us, nil
+}
+
+func loadUploadSession(filePath string) (*UploadSession, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	us := &UploadSession{}
+	err = jsonhelper.UnmarshalData(file, us)
+	if err != nil {
+		return nil, err
+	}
+	return us, nil
+}
+
+// ListUploadSessions 列出全部上传会话, 按更新时间排序
+func ListUploadSessions() ([]*UploadSession, error) {
+	entries, err := os.ReadDir(uploadSessionDir())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var sessions []*UploadSession
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		us, err := loadUploadSession(filepath.Join(uploadSessionDir(), entry.Name()))
+		if err != nil {
+			pcsUploadVerbose.Warnf("load upload session %s error: %s\n", entry.Name(), err)
+			continue
+		}
+		sessions = append(sessions, us)
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].Updated > sessions[j].Updated
+	})
+	return sessions, nil
+}
+
+// SetPaths 设置上传的本地路径和网盘目录, 本地路径转换为绝对路径
+func (us *UploadSession) SetPaths(localPaths []string, savePath string) {
+	us.mu.Lock()
+	defer us.mu.Unlock()
+	us.LocalPaths = make([]string, len(localPaths))
+	for k, localPath := range localPaths {
+		us.LocalPaths[k] = absPath(localPath)
+	}
+	us.SavePath = savePath
+	us.scheduleSave()
+}
+
+// Add 加入要上传的文件, 返回会话中的记录.
+// 已记录的文件大小, 修改时间或保存路径改变时, 重新等待上传
+func (us *UploadSession) Add(localPath, savePath string, info os.FileInfo) *UploadSessionFile {
+	localPath = absPath(localPath)
+
+	us.mu.Lock()
+	defer us.mu.Unlock()
+	us.seen[localPath] = true
+	f, ok := us.index[localPath]
+	if !ok {
+		f = &UploadSessionFile{
+			LocalPath: localPath,
+			Status:    UploadSessionPending,
+		}
+		us.Files = append(us.Files, f)
+		us.index[localPath] = f
+	}
+	if f.SavePath != savePath || f.Size != info.Size() || f.ModTime != info.ModTime().Unix() {
+		f.SavePath = savePath
+		f.Size = info.Size()
+		f.ModTime = info.ModTime().Unix()
+		f.Status, f.Error = UploadSessionPending, ""
+	}
+	us.scheduleSave()
+	return f
+}
+
+// PruneUnseen 删除本次运行没有加入, 且未完成的文件, 如已被删除的本地文件
+func (us *UploadSession) PruneUnseen() {
+	us.mu.Lock()
+	defer us.mu.Unlock()
+	files := us.Files[:0]
+	for _, f := range us.Files {
+		if !us.seen[f.LocalPath] && !f.Finished() {
+			delete(us.index, f.LocalPath)
+			continue
+		}
+		files = append(files, f)
+	}
+	us.Files = files
+	us.scheduleSave()
+}
+
+// SetStatus 更新文件的状态
+func (us *UploadSession) SetStatus(f *UploadSessionFile, status string, err error) {
+	us.mu.Lock()
+	defer us.mu.Unlock()
+	f.Status, f.Error = status, ""
+	if err != nil {
+		f.Error = err.Error()
+	}
+	us.scheduleSave()
+}
+
+// Finished 文件是否已完成, 包括跳过的文件
+func (f *UploadSessionFile) Finished() bool {
+	return f.Status == UploadSessionDone || f.Status == UploadSessionSkipped
+}
+
+// Progress 统计会话的进度
+func (us *UploadSession) Progress() (p UploadSessionProgress) {
+	us.mu.Lock()
+	defer us.mu.Unlock()
+	for _, f := range us.Files {
+		p.Total++
+		p.TotalSize += f.Size
+		switch f.Status {
+		case UploadSessionDone:
+			p.Done++
+		case UploadSessionSkipped:
+			p.Skipped++
+		case UploadSessionFailed:
+			p.Failed++
+		default:
+			p.Pending++
+		}
+		if f.Finished() {
+			p.FinishedSize += f.Size
+		}
+	}
+	return
+}
+
+// Save 立即将会话写入文件
+func (us *UploadSession) Save() error {
+	us.mu.Lock()
+	if us.saveTimer != nil {
+		us.saveTimer.Stop()
+		us.saveTimer = nil
+	}
+	if us.removed {
+		us.mu.Unlock()
+		return nil
+	}
+	us.Updated = time.Now().Unix()
+	builder := &strings.Builder{}
+	err := jsonhelper.MarshalData(builder, us)
+	us.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(us.filePath), 0700)
+	if err != nil {
+		return err
+	}
+	// 先写入临时文件, 避免写入中途退出损坏会话
+	tmpPath := us.filePath + ".tmp"
+	err = os.WriteFile(tmpPath, []byte(builder.String()), 0600)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, us.filePath)
+}
+
+// Remove 删除会话文件
+func (us *UploadSession) Remove() error {
+	us.mu.Lock()
+	defer us.mu.Unlock()
+	if us.saveTimer != nil {
+		us.saveTimer.Stop()
+		us.saveTimer = nil
+	}
+	us.removed = true
+	err := os.Remove(us.filePath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// scheduleSave 延迟写入文件, 调用时需持有锁
+func (us *UploadSession) scheduleSave() {
+	if us.saveTimer != nil {
+		return
+	}
+	us.saveTimer = time.AfterFunc(uploadSessionSaveDelay, func() {
+		us.Save()
+	})
+}
+
+func absPath(p string) string {
+	abs, err := filepath.Abs(p)
+	if err != nil {
+		return p
+	}
+	return abs
+}
@@ -0,0 +1,108 @@
+package pcsupload_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/qjfoidnh/BaiduPCS-Go/internal/pcsconfig"
+	"github.com/qjfoidnh/BaiduPCS-Go/internal/pcsfunctions/pcsupload"
+)
+
+func TestUploadSession(t *testing.T) {
+	t.Setenv(pcsconfig.EnvConfigDir, t.TempDir())
+	dir := t.TempDir()
+	var paths []string
+	for _, name := range []string{"a", "b", "c"} {
+		localPath := filepath.Join(dir, name)
+		if err := os.WriteFile(localPath, []byte(name), 0600); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, localPath)
+	}
+	stat := func(localPath string) os.FileInfo {
+		info, err := os.Stat(localPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return info
+	}
+
+	for _, name := range []string{"", ".", "..", "a/b", `a\b`} {
+		if _, err := pcsupload.OpenUploadSession(name, true); !errors.Is(err, pcsupload.ErrUploadSessionName) {
+			t.Errorf("session name %q: %v", name, err)
+		}
+	}
+	if _, err := pcsupload.OpenUploadSession("s", false); !errors.Is(err, pcsupload.ErrUploadSessionNotFound) {
+		t.Fatalf("open missing session: %v", err)
+	}
+
+	us, err := pcsupload.OpenUploadSession("s", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	us.SetPaths([]string{dir}, "/backup")
+	files := make([]*pcsupload.UploadSessionFile, len(paths))
+	for k, localPath := range paths {
+		files[k] = us.Add(localPath, "/backup/"+filepath.Base(localPath), stat(localPath))
+	}
+	us.SetStatus(files[0], pcsupload.UploadSessionDone, nil)
+	us.SetStatus(files[1], pcsupload.UploadSessionSkipped, nil)
+	us.SetStatus(files[2], pcsupload.UploadSessionFailed, errors.New("network"))
+	if err = us.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 重新打开会话, 状态和路径与保存时一致
+	us, err = pcsupload.OpenUploadSession("s", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(us.LocalPaths) != 1 || us.LocalPaths[0] != dir || us.SavePath != "/backup" {
+		t.Errorf("paths %v -> %s", us.LocalPaths, us.SavePath)
+	}
+	p := us.Progress()
+	if p.Total != 3 || p.Done != 1 || p.Skipped != 1 || p.Failed != 1 || p.Pending != 0 || p.TotalSize != 3 || p.FinishedSize != 2 {
+		t.Errorf("progress %+v", p)
+	}
+
+	// 未改变的已完成文件保持完成, 修改过的文件重新等待上传
+	if f := us.Add(paths[0], "/backup/a", stat(paths[0])); !f.Finished() {
+		t.Errorf("unchanged file status %s", f.Status)
+	}
+	mtime := time.Now().Add(time.Hour)
+	os.Chtimes(paths[1], mtime, mtime)
+	if f := us.Add(paths[1], "/backup/b", stat(paths[1])); f.Status != pcsupload.UploadSessionPending {
+		t.Errorf("modified file status %s", f.Status)
+	}
+
+	// 本次没有加入的未完成文件被删除
+	us.PruneUnseen()
+	p = us.Progress()
+	if p.Total != 2 || p.Done != 1 || p.Pending != 1 || p.Failed != 0 {
+		t.Errorf("progress after prune %+v", p)
+	}
+	if err = us.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	sessions, err := pcsupload.ListUploadSessions()
+	if err != nil || len(sessions) != 1 || sessions[0].Name != "s" {
+		t.Fatalf("sessions %v, err %v", sessions, err)
+	}
+
+	// 删除后不再写入
+	if err = us.Remove(); err != nil {
+		t.Fatal(err)
+	}
+	us.SetStatus(us.Files[0], pcsupload.UploadSessionDone, nil)
+	if err = us.Save(); err != nil {
+		t.Fatal(err)
+	}
+	sessions, err = pcsupload.ListUploadSessions()
+	if err != nil || len(sessions) != 0 {
+		t.Fatalf("sessions after remove %v, err %v", sessions, err)
+	}
+}
@@ -2,10 +2,28 @@ package pcsupload
 
 import (
 	"github.com/qjfoidnh/BaiduPCS-Go/internal/pcsfunctions"
+	"sync"
 )
 
 type (
 	UploadStatistic struct {
 		pcsfunctions.Statistic
+
+		removed   []string // 上传成功后删除的本地文件
+		removedMu sync.Mutex
 	}
 )
+
+// AddRemoved 记录上传成功后删除的本地文件
+func (us *UploadStatistic) AddRemoved(localPath string) {
+	us.removedMu.Lock()
+	defer us.removedMu.Unlock()
+	us.removed = append(us.removed, localPath)
+}
+
+// Removed 返回上传成功后删除的本地文件
+func (us *UploadStatistic) Removed() []string {
+	us.removedMu.Lock()
+	defer us.removedMu.Unlock()
+	return append([]string(nil), us.removed...)
+}
@@ -10,11 +10,14 @@ import (
 	"github.com/qjfoidnh/BaiduPCS-Go/baidupcs/pcserror"
 	"github.com/qjfoidnh/BaiduPCS-Go/internal/pcsconfig"
 	"github.com/qjfoidnh/BaiduPCS-Go/internal/pcsfunctions"
+	"github.com/qjfoidnh/BaiduPCS-Go/internal/pcsfunctions/pcsdownload"
 	"github.com/qjfoidnh/BaiduPCS-Go/pcsutil/checksum"
 	"github.com/qjfoidnh/BaiduPCS-Go/pcsutil/converter"
 	"github.com/qjfoidnh/BaiduPCS-Go/pcsutil/taskframework"
 	"github.com/qjfoidnh/BaiduPCS-Go/requester/rio"
 	"github.com/qjfoidnh/BaiduPCS-Go/requester/uploader"
+	"math/rand/v2"
+	"os"
 	"path"
 	"strings"
 	"time"
@@ -34,9 +37,17 @@ type (
 		PCS               *baidupcs.BaiduPCS
 		UploadingDatabase *UploadingDatabase // 数据库
 		Parallel          int
-		NoRapidUpload     bool   // 禁用秒传
-		NoSplitFile       bool   // 禁用分片上传
-		Policy            string // 上传重名文件策略
+		NoRapidUpload     bool               // 禁用秒传
+		NoSplitFile       bool               // 禁用分片上传
+		Policy            string             // 上传重名文件策略
+		RemoveSource      bool               // 上传成功并校验网盘文件后, 删除本地文件
+		VerifyUpload      bool               // 上传完成后校验网盘文件的大小和分块md5, 不一致则重新上传
+		VerifySampleSize  int64              // 大于0时, 校验时再下载一段随机范围的数据与本地比较
+		Prehash           *HashJob           // 预先计算摘要的任务, 为空则在上传前计算
+		RemoteIndex       *RemoteIndex       // 不为空时, 先在索引中查找相同的文件
+		CopyExisting      bool               // 找到相同的文件时, 在服务器端拷贝到保存路径, 否则跳过上传
+		Session           *UploadSession     // 不为空时, 在会话中记录文件的状态
+		SessionFile       *UploadSessionFile // 文件在会话中的记录
 
 		UploadStatistic *UploadStatistic
 
@@ -44,6 +55,7 @@ type (
 		panDir   string
 		panFile  string
 		state    *uploader.InstanceState
+		uploaded bool // 本次执行了上传 (包括秒传), 而不是跳过
 	}
 )
 
@@ -159,6 +171,8 @@ func (utu *UploadTaskUnit) rapidUpload() (isContinue bool, result *taskframework
 
 	fmt.Printf("[%s] 开始计算文件元信息, 请稍候...\n", utu.taskInfo.Id())
 
+	utu.usePrehash()
+
 	// 经测试, 文件的 crc32 值并非秒传文件所必需
 	if utu.LocalFileChecksum.LocalFileMeta.MD5 == nil || utu.LocalFileChecksum.LocalFileMeta.SliceMD5 == nil {
 		err := utu.LocalFileChecksum.Sum(checksum.CHECKSUM_MD5 | checksum.CHECKSUM_SLICE_MD5)
@@ -208,8 +222,9 @@ func (utu *UploadTaskUnit) rapidUpload() (isContinue bool, result *taskframework
 
 	blockSize := getBlockSize(utu.LocalFileChecksum.Length)
 
-	fmt.Printf("[%s] 开始计算文件分块md5, 请稍候...\n", utu.taskInfo.Id())
-	if utu.LocalFileChecksum.LocalFileMeta.BlocksList == nil || len(utu.LocalFileChecksum.LocalFileMeta.BlocksList) == 0 {
+	// 分块md5通常已由 Prehash 计算
+	if len(utu.LocalFileChecksum.LocalFileMeta.BlocksList) == 0 {
+		fmt.Printf("[%s] 开始计算文件分块md5, 请稍候...\n", utu.taskInfo.Id())
 		err = utu.LocalFileChecksum.CalculateChunkedSum(blockSize)
 		if err != nil {
 			// 不重试
@@ -225,6 +240,7 @@ func (utu *UploadTaskUnit) rapidUpload() (isContinue bool, result *taskframework
 	if pcsError == nil {
 		if jsonData.ReturnType == 2 {
 			fmt.Printf("[%s] 秒传成功, 保存到网盘路径: %s\n\n", utu.taskInfo.Id(), utu.SavePath)
+			utu.uploaded = true
 			// 统计
 			utu.UploadStatistic.AddTotalSize(utu.LocalFileChecksum.Length)
 			result.Succeed = true // 成功
@@ -287,6 +303,8 @@ func (utu *UploadTaskUnit) upload() (result *taskframework.TaskUnitRunResult) {
 		BlockSize: blockSize,
 		MaxRate:   pcsconfig.Config.MaxUploadRate,
 		Policy:    utu.Policy,
+
+		MaxRateFunc: pcsconfig.Config.UploadRateFunc(),
 	}, utu.SavePath)
 
 	// 设置断点续传
@@ -316,6 +334,7 @@ func (utu *UploadTaskUnit) upload() (result *taskframework.TaskUnitRunResult) {
 	muer.OnSuccess(func() {
 		fmt.Printf("\n")
 		fmt.Printf("[%s] 上传文件成功, 保存到网盘路径: %s\n", utu.taskInfo.Id(), utu.SavePath)
+		utu.uploaded = true
 		// 统计
 		utu.UploadStatistic.AddTotalSize(utu.LocalFileChecksum.Length)
 		utu.UploadingDatabase.Delete(&utu.LocalFileChecksum.LocalFileMeta) // 删除
@@ -413,9 +432,29 @@ func (utu *UploadTaskUnit) OnRetry(lastRunResult *taskframework.TaskUnitRunResul
 }
 
 func (utu *UploadTaskUnit) OnSuccess(lastRunResult *taskframework.TaskUnitRunResult) {
+	if utu.Session != nil {
+		if utu.uploaded {
+			utu.Session.SetStatus(utu.SessionFile, UploadSessionDone, nil)
+		} else {
+			utu.Session.SetStatus(utu.SessionFile, UploadSessionSkipped, nil)
+		}
+	}
 }
 
 func (utu *UploadTaskUnit) OnFailed(lastRunResult *taskframework.TaskUnitRunResult) {
+	if utu.Session != nil {
+		switch lastRunResult.Extra {
+		case baidupcs.SkipPolicy, baidupcs.RsyncPolicy:
+			utu.Session.SetStatus(utu.SessionFile, UploadSessionSkipped, nil)
+		default:
+			err := lastRunResult.Err
+			if err == nil {
+				err = errors.New(lastRunResult.ResultMessage)
+			}
+			utu.Session.SetStatus(utu.SessionFile, UploadSessionFailed, err)
+		}
+	}
+
 	// 失败
 	if lastRunResult.Err == nil {
 		// result中不包含Err, 忽略输出
@@ -426,6 +465,10 @@ func (utu *UploadTaskUnit) OnFailed(lastRunResult *taskframework.TaskUnitRunResu
 }
 
 func (utu *UploadTaskUnit) OnComplete(lastRunResult *taskframework.TaskUnitRunResult) {
+	// 返回结果为空时, 文件已跳过, 如目标文件已存在
+	if lastRunResult == nil && utu.Session != nil {
+		utu.Session.SetStatus(utu.SessionFile, UploadSessionSkipped, nil)
+	}
 }
 
 func (utu *UploadTaskUnit) RetryWait() time.Duration {
@@ -446,10 +489,35 @@ func (utu *UploadTaskUnit) Run() (result *taskframework.TaskUnitRunResult) {
 		return
 	}
 	defer utu.LocalFileChecksum.Close() // 关闭文件
+	if utu.RemoveSource {
+		// 在关闭文件之前执行, 需要读取文件计算md5
+		defer func() {
+			if result != nil && result.Succeed && utu.uploaded {
+				utu.removeSource()
+			}
+		}()
+	}
+
+	if utu.RemoteIndex != nil {
+		// 上传成功的文件加入索引, 之后相同的文件不再上传
+		defer func() {
+			if result != nil && result.Succeed && utu.uploaded {
+				utu.RemoteIndex.Add(utu.LocalFileChecksum.Length, hex.EncodeToString(utu.LocalFileChecksum.MD5), utu.SavePath)
+			}
+		}()
+	}
 
 	// 准备文件
+	utu.uploaded = false
 	utu.prepareFile()
 
+	if utu.RemoteIndex != nil && utu.Step != JustGoon {
+		isContinue, existingResult := utu.existingUpload()
+		if !isContinue {
+			return existingResult
+		}
+	}
+
 	switch utu.Step {
 	case StepUploadRapidUpload:
 		goto stepUploadRapidUpload
@@ -472,6 +540,221 @@ stepUploadRapidUpload:
 stepUploadUpload:
 	// 正常上传流程
 	uploadResult := utu.upload()
+	if uploadResult.Succeed && utu.VerifyUpload {
+		utu.verifyUpload(uploadResult)
+	}
 
 	return uploadResult
 }
+
+// existingUpload 在索引中查找大小和md5相同的网盘文件, 找到时跳过上传, 或在服务器端拷贝到保存路径
+func (utu *UploadTaskUnit) existingUpload() (isContinue bool, result *taskframework.TaskUnitRunResult) {
+	result = &taskframework.TaskUnitRunResult{}
+
+	utu.usePrehash()
+	if utu.LocalFileChecksum.MD5 == nil || utu.LocalFileChecksum.SliceMD5 == nil {
+		err := utu.LocalFileChecksum.Sum(checksum.CHECKSUM_MD5 | checksum.CHECKSUM_SLICE_MD5)
+		if err != nil {
+			// 不重试
+			result.ResultMessage = "计算文件md5错误"
+			result.Err = err
+			return
+		}
+	}
+
+	entry, ok := utu.RemoteIndex.Lookup(utu.LocalFileChecksum.Length, hex.EncodeToString(utu.LocalFileChecksum.MD5))
+	if !ok {
+		return true, nil
+	}
+
+	// 网盘记录的md5可能不正确, 比较分块md5
+	if len(entry.BlockList) > 1 {
+		if len(utu.LocalFileChecksum.BlocksList) == 0 {
+			err := utu.LocalFileChecksum.CalculateChunkedSum(getBlockSize(utu.LocalFileChecksum.Length))
+			if err != nil {
+				return true, nil
+			}
+		}
+		if len(entry.BlockList) != len(utu.LocalFileChecksum.BlocksList) {
+			return true, nil
+		}
+		for k := range entry.BlockList {
+			if !strings.EqualFold(entry.BlockList[k], utu.LocalFileChecksum.BlocksList[k]) {
+				return true, nil
+			}
+		}
+	}
+
+	if entry.Path == utu.SavePath {
+		fmt.Printf("[%s] 目标文件, %s, 已存在且内容相同, 跳过...\n", utu.taskInfo.Id(), utu.SavePath)
+		result.Succeed = true
+		return
+	}
+	if !utu.CopyExisting {
+		fmt.Printf("[%s] 网盘中已存在相同的文件, %s, 跳过...\n", utu.taskInfo.Id(), entry.Path)
+		result.Succeed = true
+		return
+	}
+
+	// 目录已存在时会出错, 忽略
+	utu.PCS.Mkdir(utu.panDir)
+	pcsError := utu.PCS.Copy(&baidupcs.CpMvJSON{
+		From: entry.Path,
+		To:   utu.SavePath,
+	})
+	if pcsError != nil {
+		fmt.Printf("[%s] 拷贝网盘中相同的文件 %s 失败, %s, 继续上传...\n", utu.taskInfo.Id(), entry.Path, pcsError)
+		return true, nil
+	}
+	fmt.Printf("[%s] 网盘中已存在相同的文件, 已拷贝 %s 到 %s\n\n", utu.taskInfo.Id(), entry.Path, utu.SavePath)
+	utu.uploaded = true
+	utu.UploadStatistic.AddTotalSize(utu.LocalFileChecksum.Length)
+	result.Succeed = true
+	return
+}
+
+// usePrehash 等待预先计算的摘要和分块md5, 文件的大小和修改时间不变时使用
+func (utu *UploadTaskUnit) usePrehash() {
+	lfc := utu.LocalFileChecksum
+	if utu.Prehash == nil || (lfc.MD5 != nil && lfc.SliceMD5 != nil && (len(lfc.BlocksList) > 0 || lfc.Length == 0)) {
+		return
+	}
+	meta, err := utu.Prehash.Wait()
+	if err != nil {
+		return
+	}
+	if meta.Length != lfc.Length || meta.ModTime != lfc.ModTime {
+		return
+	}
+	lfc.MD5, lfc.SliceMD5 = meta.MD5, meta.SliceMD5
+	if len(lfc.BlocksList) == 0 {
+		lfc.BlocksList = meta.BlocksList
+	}
+}
+
+// verifyUpload 校验合并分片后的网盘文件, 比较大小和分块md5, 可选下载一段随机范围的数据与本地比较.
+// 不一致时删除网盘文件, 设置 result 为失败并重试
+func (utu *UploadTaskUnit) verifyUpload(result *taskframework.TaskUnitRunResult) {
+	fmt.Printf("[%s] 校验网盘文件...\n", utu.taskInfo.Id())
+	mismatch, err := utu.checkRemoteFile()
+	if err == nil {
+		fmt.Printf("[%s] 校验通过\n", utu.taskInfo.Id())
+		return
+	}
+
+	result.Succeed = false
+	result.NeedRetry = true
+	result.ResultMessage = "上传校验失败"
+	result.Err = err
+	utu.uploaded = false
+	utu.UploadStatistic.AddTotalSize(-utu.LocalFileChecksum.Length)
+
+	if !mismatch {
+		return
+	}
+	// 删除本次上传的错误文件, 避免重试时按照策略跳过
+	pcsError := utu.PCS.Remove(utu.SavePath)
+	if pcsError != nil {
+		fmt.Printf("[%s] 删除校验失败的网盘文件错误: %s\n", utu.taskInfo.Id(), pcsError)
+	}
+}
+
+// checkRemoteFile 比较网盘文件与本地文件, mismatch 表示确认网盘文件与本地文件不一致,
+// 获取网盘文件出错等无法确认的情况, 只返回错误
+func (utu *UploadTaskUnit) checkRemoteFile() (mismatch bool, err error) {
+	utu.usePrehash()
+	lfc := utu.LocalFileChecksum
+	meta, pcsError := utu.PCS.FilesDirectoriesMeta(utu.SavePath)
+	if pcsError != nil {
+		return false, pcsError
+	}
+	if meta.Isdir || meta.Size != lfc.Length {
+		return true, fmt.Errorf("网盘文件大小 %d 与本地文件 %d 不一致", meta.Size, lfc.Length)
+	}
+
+	// 分块md5, 分块大小与上传时一致
+	if lfc.Length > 0 {
+		if len(lfc.BlocksList) == 0 {
+			err = lfc.CalculateChunkedSum(getBlockSize(lfc.Length))
+			if err != nil {
+				return false, fmt.Errorf("计算文件分块md5出错: %s", err)
+			}
+		}
+		if len(meta.BlockList) != len(lfc.BlocksList) {
+			return true, fmt.Errorf("网盘文件分块数量 %d 与本地文件 %d 不一致", len(meta.BlockList), len(lfc.BlocksList))
+		}
+		for k := range lfc.BlocksList {
+			if !strings.EqualFold(meta.BlockList[k], lfc.BlocksList[k]) {
+				return true, fmt.Errorf("第 %d 个分块的md5不一致", k+1)
+			}
+		}
+	}
+
+	// 随机范围的数据
+	if utu.VerifySampleSize <= 0 || lfc.Length == 0 {
+		return false, nil
+	}
+	var (
+		length = min(utu.VerifySampleSize, lfc.Length)
+		offset = rand.Int64N(lfc.Length - length + 1)
+		remote = &bytes.Buffer{}
+	)
+	err = pcsdownload.StreamFile(utu.PCS, utu.SavePath, remote, &pcsdownload.StreamOptions{
+		Offset:   offset,
+		Length:   length,
+		Parallel: 1,
+		MaxRetry: pcsdownload.DefaultDownloadMaxRetry,
+	})
+	if err != nil {
+		return false, fmt.Errorf("下载网盘文件出错: %s", err)
+	}
+	local, _, err := lfc.GetSliceDataContent(offset, length)
+	if err != nil {
+		return false, fmt.Errorf("读取本地文件出错: %s", err)
+	}
+	if !bytes.Equal(remote.Bytes(), local) {
+		return true, fmt.Errorf("网盘文件 [%d, %d) 范围的数据与本地文件不一致", offset, offset+length)
+	}
+	return false, nil
+}
+
+// removeSource 校验网盘文件的大小和md5与本地文件一致后, 删除本地文件
+func (utu *UploadTaskUnit) removeSource() {
+	localPath := utu.LocalFileChecksum.Path
+	utu.usePrehash()
+	if utu.LocalFileChecksum.MD5 == nil {
+		err := utu.LocalFileChecksum.Sum(checksum.CHECKSUM_MD5)
+		if err != nil {
+			fmt.Printf("[%s] 计算本地文件md5错误: %s, 不删除源文件\n", utu.taskInfo.Id(), err)
+			return
+		}
+	}
+	localMD5 := hex.EncodeToString(utu.LocalFileChecksum.MD5)
+
+	meta, pcsError := utu.PCS.FilesDirectoriesMeta(utu.SavePath)
+	if pcsError != nil {
+		fmt.Printf("[%s] 获取网盘文件信息错误: %s, 不删除源文件\n", utu.taskInfo.Id(), pcsError)
+		return
+	}
+	if meta.Isdir || meta.Size != utu.LocalFileChecksum.Length {
+		fmt.Printf("[%s] 网盘文件大小与本地文件不一致, 不删除源文件\n", utu.taskInfo.Id())
+		return
+	}
+	if !strings.EqualFold(meta.MD5, localMD5) {
+		// 分片上传的文件, 网盘记录的md5可能不正确, 通过下载链接获取实际的md5
+		info, pcsError := utu.PCS.GetRapidUploadInfoByFileInfo(meta)
+		if pcsError != nil || !strings.EqualFold(info.ContentMD5, localMD5) {
+			fmt.Printf("[%s] 网盘文件md5与本地文件不一致, 不删除源文件\n", utu.taskInfo.Id())
+			return
+		}
+	}
+
+	utu.LocalFileChecksum.Close() // 部分系统无法删除打开的文件
+	err := os.Remove(localPath)
+	if err != nil {
+		fmt.Printf("[%s] 删除源文件错误: %s\n", utu.taskInfo.Id(), err)
+		return
+	}
+	fmt.Printf("[%s] 校验一致, 已删除源文件: %s\n", utu.taskInfo.Id(), localPath)
+	utu.UploadStatistic.AddRemoved(localPath)
+}
@@ -0,0 +1,244 @@
+package pcsupload
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/qjfoidnh/BaiduPCS-Go/baidupcs"
+	"github.com/qjfoidnh/BaiduPCS-Go/pcsutil/checksum"
+	"github.com/qjfoidnh/BaiduPCS-Go/pcsutil/taskframework"
+	"github.com/qjfoidnh/BaiduPCS-Go/requester"
+)
+
+// fakeRemote 模拟网盘中上传后的文件 /f, 以及获取下载链接和下载的接口
+type fakeRemote struct {
+	mu      sync.Mutex
+	missing bool     // 文件不存在
+	data    []byte   // 文件内容
+	md5     string   // 元信息记录的md5
+	blocks  []string // 元信息记录的分块md5
+	linkMD5 string   // 下载链接返回的md5
+	removed int      // 收到的删除请求次数
+	copied  []string // 收到的拷贝请求的参数
+}
+
+func (fr *fakeRemote) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	if r.URL.Path != "/rest/2.0/pcs/file" {
+		// 下载链接
+		w.Header().Set("Content-MD5", fr.linkMD5)
+		w.Header().Set("Content-Disposition", `attachment; filename="f"`)
+		w.Header().Set("x-bs-meta-crc32", "1")
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(fr.data))
+		return
+	}
+
+	switch r.URL.Query().Get("method") {
+	case "meta":
+		if fr.missing {
+			io.WriteString(w, `{"error_code":31066,"error_msg":"file does not exist"}`)
+			return
+		}
+		blocks, _ := json.Marshal(fr.blocks)
+		io.WriteString(w, `{"list":[{"fs_id":1,"path":"/f","server_filename":"f","isdir":0,"size":`+
+			strconv.Itoa(len(fr.data))+`,"md5":"`+fr.md5+`","block_list":`+string(blocks)+`}]}`)
+	case "locatedownload":
+		io.WriteString(w, `{"urls":[{"url":"http://d.pcs.example.com/file/f","encrypt":0}]}`)
+	case "mkdir":
+		io.WriteString(w, `{"fs_id":2,"path":"/dir","isdir":1,"request_id":1}`)
+	case "copy":
+		fr.copied = append(fr.copied, r.FormValue("param"))
+		io.WriteString(w, `{"extra":{},"request_id":1}`)
+	case "delete":
+		fr.removed++
+		fr.missing = true
+		io.WriteString(w, `{"extra":{},"request_id":1}`)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// newTestUploadTaskUnit 启动模拟服务器, 返回上传本地文件 data 到 /f 的任务
+func newTestUploadTaskUnit(t *testing.T, data []byte) (*fakeRemote, *UploadTaskUnit) {
+	fr := &fakeRemote{}
+	srv := httptest.NewServer(fr)
+	requester.SetGlobalProxy(srv.Listener.Addr().String())
+	t.Cleanup(func() {
+		requester.SetGlobalProxy("")
+		srv.Close()
+	})
+
+	pcs := baidupcs.NewPCS(0, "")
+	pcs.SetUID(1)
+	pcs.SetPanUserAgent(baidupcs.NetdiskUA)
+	pcs.GetClient()
+	pcs.SetHTTPS(false)
+	pcs.SetStaticPCSAddr(true)
+
+	localPath := filepath.Join(t.TempDir(), "f")
+	if err := os.WriteFile(localPath, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	lfc := checksum.NewLocalFileChecksum(localPath, int(baidupcs.SliceMD5Size))
+	if err := lfc.OpenPath(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		lfc.Close()
+	})
+	return fr, &UploadTaskUnit{
+		LocalFileChecksum: lfc,
+		SavePath:          "/f",
+		PCS:               pcs,
+		UploadStatistic:   &UploadStatistic{},
+		taskInfo:          &taskframework.TaskInfo{},
+	}
+}
+
+func md5Hex(data []byte) string {
+	sum := md5.Sum(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestCheckRemoteFile(t *testing.T) {
+	data := []byte("uploaded file content")
+	other := []byte("uploaded file CONTENT")
+	fr, utu := newTestUploadTaskUnit(t, data)
+
+	for _, c := range []struct {
+		name     string
+		missing  bool
+		data     []byte
+		blocks   []string
+		sample   int64
+		mismatch bool
+		err      bool
+	}{
+		{"same", false, data, []string{md5Hex(data)}, 0, false, false},
+		{"same with sample", false, data, []string{md5Hex(data)}, int64(len(data)), false, false},
+		{"missing", true, data, nil, 0, false, true},
+		{"size", false, data[1:], []string{md5Hex(data)}, 0, true, true},
+		{"block count", false, data, []string{md5Hex(data), md5Hex(data)}, 0, true, true},
+		{"block md5", false, data, []string{md5Hex(other)}, 0, true, true},
+		// 分块md5一致, 但下载的数据不一致
+		{"sample", false, other, []string{md5Hex(data)}, int64(len(data)), true, true},
+	} {
+		fr.missing, fr.data, fr.blocks = c.missing, c.data, c.blocks
+		utu.VerifySampleSize = c.sample
+		mismatch, err := utu.checkRemoteFile()
+		if mismatch != c.mismatch || (err != nil) != c.err {
+			t.Errorf("%s: mismatch %v, err %v", c.name, mismatch, err)
+		}
+	}
+}
+
+func TestVerifyUpload(t *testing.T) {
+	data := []byte("uploaded file content")
+	fr, utu := newTestUploadTaskUnit(t, data)
+
+	// 校验通过, 保持成功
+	fr.data, fr.blocks = data, []string{md5Hex(data)}
+	result := &taskframework.TaskUnitRunResult{Succeed: true}
+	utu.uploaded = true
+	utu.verifyUpload(result)
+	if !result.Succeed || !utu.uploaded || fr.removed != 0 {
+		t.Fatalf("verified: %+v, uploaded %v, removed %d", result, utu.uploaded, fr.removed)
+	}
+
+	// 确认不一致, 删除网盘文件并重试
+	fr.blocks = []string{md5Hex([]byte("other"))}
+	result = &taskframework.TaskUnitRunResult{Succeed: true}
+	utu.verifyUpload(result)
+	if result.Succeed || !result.NeedRetry || utu.uploaded || fr.removed != 1 {
+		t.Errorf("mismatch: %+v, uploaded %v, removed %d", result, utu.uploaded, fr.removed)
+	}
+
+	// 无法确认时只重试, 不删除网盘文件
+	result = &taskframework.TaskUnitRunResult{Succeed: true}
+	utu.verifyUpload(result)
+	if result.Succeed || !result.NeedRetry || fr.removed != 1 {
+		t.Errorf("unknown: %+v, removed %d", result, fr.removed)
+	}
+}
+
+func TestRemoveSource(t *testing.T) {
+	data := []byte("source file content")
+	other := md5Hex([]byte("other"))
+
+	for _, c := range []struct {
+		name    string
+		data    []byte
+		md5     string
+		blocks  []string
+		linkMD5 string
+		removed bool
+	}{
+		{"md5", data, md5Hex(data), []string{md5Hex(data)}, "", true},
+		// 分片上传的文件, 元信息记录的md5不可靠, 以下载链接的md5为准
+		{"link md5", data, other, []string{other, other}, md5Hex(data), true},
+		{"link md5 mismatch", data, other, []string{other, other}, other, false},
+		{"size", data[1:], md5Hex(data), []string{md5Hex(data)}, md5Hex(data), false},
+	} {
+		fr, utu := newTestUploadTaskUnit(t, data)
+		fr.data, fr.md5, fr.blocks, fr.linkMD5 = c.data, c.md5, c.blocks, c.linkMD5
+		utu.removeSource()
+
+		localPath := utu.LocalFileChecksum.Path
+		_, err := os.Stat(localPath)
+		if removed := os.IsNotExist(err); removed != c.removed {
+			t.Errorf("%s: removed %v", c.name, removed)
+		}
+		if got := utu.UploadStatistic.Removed(); c.removed != (len(got) == 1 && got[0] == localPath) {
+			t.Errorf("%s: statistic %v", c.name, got)
+		}
+	}
+}
+
+func TestExistingUpload(t *testing.T) {
+	data := []byte("existing file content")
+	other := md5Hex([]byte("other"))
+
+	for _, c := range []struct {
+		name     string
+		entry    *RemoteIndexEntry
+		copy     bool
+		isCopied bool
+		isSkip   bool
+	}{
+		{"not found", nil, false, false, false},
+		{"same path", &RemoteIndexEntry{Path: "/f"}, true, false, true},
+		{"elsewhere", &RemoteIndexEntry{Path: "/old/f"}, false, false, true},
+		{"copy", &RemoteIndexEntry{Path: "/old/f"}, true, true, true},
+		// 网盘记录的md5可能不正确, 分块md5不一致时继续上传
+		{"block mismatch", &RemoteIndexEntry{Path: "/old/f", BlockList: []string{other, other}}, true, false, false},
+		{"block count mismatch", &RemoteIndexEntry{Path: "/old/f", BlockList: []string{md5Hex(data), other}}, true, false, false},
+	} {
+		fr, utu := newTestUploadTaskUnit(t, data)
+		utu.panDir, utu.CopyExisting = "/", c.copy
+		utu.RemoteIndex = &RemoteIndex{Scope: "/", files: map[string]*RemoteIndexEntry{}}
+		if c.entry != nil {
+			utu.RemoteIndex.add(int64(len(data)), md5Hex(data), c.entry)
+		}
+
+		isContinue, result := utu.existingUpload()
+		if isContinue == c.isSkip || (c.isSkip && !result.Succeed) {
+			t.Errorf("%s: continue %v, result %+v", c.name, isContinue, result)
+		}
+		if isCopied := len(fr.copied) == 1 && strings.Contains(fr.copied[0], `"/old/f"`); isCopied != c.isCopied || utu.uploaded != c.isCopied {
+			t.Errorf("%s: copied %v, uploaded %v", c.name, fr.copied, utu.uploaded)
+		}
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/qjfoidnh/BaiduPCS-Go/baidupcs"
 	"io"
 	"strconv"
+	"strings"
 )
 
 func getBlockSize(fileSize int64) int64 {
@@ -36,3 +37,16 @@ func creaetDataOffset(contentMD5 string, uk, dataTime, fileSize, subSize int64)
 	offset = rawOffset % (fileSize - subSize + 1)
 	return
 }
+
+// remoteFileMatch 网盘文件是否与本地数据的大小和md5一致
+func remoteFileMatch(pcs *baidupcs.BaiduPCS, meta *baidupcs.FileDirectory, size int64, md5Str string) bool {
+	if meta.Isdir || meta.Size != size {
+		return false
+	}
+	if strings.EqualFold(meta.MD5, md5Str) {
+		return true
+	}
+	// 分片上传的文件, 网盘记录的md5可能不正确, 通过下载链接获取实际的md5
+	info, pcsError := pcs.GetRapidUploadInfoByFileInfo(meta)
+	return pcsError == nil && strings.EqualFold(info.ContentMD5, md5Str)
+}
@@ -0,0 +1,105 @@
+package pcsfunctions
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// SplitManifestSuffix 分卷上传的清单文件后缀, 清单文件名为 <原文件名>.pcsparts.json
+	SplitManifestSuffix = ".pcsparts.json"
+	// SplitManifestVersion 清单文件的版本
+	SplitManifestVersion = 1
+)
+
+var (
+	// ErrSplitManifestInvalid 清单文件格式错误
+	ErrSplitManifestInvalid = errors.New("分卷清单格式错误")
+)
+
+type (
+	// SplitPart 分卷信息
+	SplitPart struct {
+		Name string `json:"name"` // 分卷文件名, 与清单文件位于同一目录
+		Size int64  `json:"size"`
+		MD5  string `json:"md5"`
+	}
+
+	// SplitManifest 分卷上传的清单, 记录原文件和各个分卷的信息, 下载时用于合并分卷
+	SplitManifest struct {
+		Version  int          `json:"version"`
+		Name     string       `json:"name"` // 原文件名
+		Size     int64        `json:"size"`
+		MD5      string       `json:"md5"`
+		PartSize int64        `json:"part_size"`
+		Parts    []*SplitPart `json:"parts"`
+	}
+)
+
+// IsSplitManifest 文件名是否为分卷清单
+func IsSplitManifest(name string) bool {
+	return strings.HasSuffix(name, SplitManifestSuffix) && len(name) > len(SplitManifestSuffix)
+}
+
+// SplitManifestName 返回原文件对应的清单文件名
+func SplitManifestName(name string) string {
+	return name + SplitManifestSuffix
+}
+
+// SplitPartName 返回原文件的第 i 个分卷的文件名, i 从0开始
+func SplitPartName(name string, i int) string {
+	return fmt.Sprintf("%s.part%03d", name, i+1)
+}
+
+// ParseSplitManifest 解析并检查分卷清单
+func ParseSplitManifest(data []byte) (*SplitManifest, error) {
+	m := &SplitManifest{}
+	err := json.Unmarshal(data, m)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", ErrSplitManifestInvalid, err)
+	}
+	err = m.Validate()
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Validate 检查清单的版本, 文件名和分卷大小之和是否与原文件一致
+func (m *SplitManifest) Validate() error {
+	if m.Version != SplitManifestVersion {
+		return fmt.Errorf("%s: 不支持的版本 %d", ErrSplitManifestInvalid, m.Version)
+	}
+	if !validSplitName(m.Name) || len(m.Parts) == 0 {
+		return ErrSplitManifestInvalid
+	}
+
+	var (
+		size  int64
+		names = map[string]bool{m.Name: true} // 合并后会删除分卷, 分卷不能与原文件或其他分卷同名
+	)
+	for _, part := range m.Parts {
+		if part == nil || !validSplitName(part.Name) || names[part.Name] || part.Size < 0 {
+			return ErrSplitManifestInvalid
+		}
+		names[part.Name] = true
+		size += part.Size
+	}
+	if size != m.Size {
+		return fmt.Errorf("%s: 分卷大小之和 %d 与原文件大小 %d 不一致", ErrSplitManifestInvalid, size, m.Size)
+	}
+	return nil
+}
+
+// validSplitName 清单中的文件名只能是当前目录中的文件名, 防止合并分卷时读写其他目录的文件
+func validSplitName(name string) bool {
+	return name != "." && name != ".." && !strings.ContainsAny(name, "/\\\x00") && filepath.IsLocal(name)
+}
+
+// Marshal 序列化清单
+func (m *SplitManifest) Marshal() ([]byte, error) {
+	return json.MarshalIndent(m, "", "  ")
+}
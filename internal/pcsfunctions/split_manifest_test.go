@@ -0,0 +1,66 @@
+package pcsfunctions_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/qjfoidnh/BaiduPCS-Go/internal/pcsfunctions"
+)
+
+func TestSplitManifestRoundTrip(t *testing.T) {
+	m := &pcsfunctions.SplitManifest{
+		Version:  pcsfunctions.SplitManifestVersion,
+		Name:     "disk.img",
+		Size:     10,
+		MD5:      "0123456789abcdef0123456789abcdef",
+		PartSize: 6,
+		Parts: []*pcsfunctions.SplitPart{
+			{Name: pcsfunctions.SplitPartName("disk.img", 0), Size: 6, MD5: "a"},
+			{Name: pcsfunctions.SplitPartName("disk.img", 1), Size: 4, MD5: "b"},
+		},
+	}
+	data, err := m.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := pcsfunctions.ParseSplitManifest(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(parsed, m) {
+		t.Errorf("got %+v, expected %+v", parsed, m)
+	}
+	if parsed.Parts[1].Name != "disk.img.part002" {
+		t.Errorf("part name: %s", parsed.Parts[1].Name)
+	}
+}
+
+func TestParseSplitManifestInvalid(t *testing.T) {
+	const valid = `{"version":1,"name":"a.bin","size":3,"parts":[{"name":"a.bin.part001","size":3}]}`
+	if _, err := pcsfunctions.ParseSplitManifest([]byte(valid)); err != nil {
+		t.Fatalf("valid manifest: %s", err)
+	}
+
+	for _, name := range []string{`..`, `.`, `../a.bin`, `sub/a.bin`, `..\\a.bin`, `C:\\a.bin`, `/etc/passwd`, ``, `a.bin`} {
+		data := strings.Replace(valid, `"a.bin.part001"`, `"`+name+`"`, 1)
+		if _, err := pcsfunctions.ParseSplitManifest([]byte(data)); err == nil {
+			t.Errorf("part name %q accepted", name)
+		}
+		data = strings.Replace(valid, `"name":"a.bin"`, `"name":"`+name+`"`, 1)
+		if _, err := pcsfunctions.ParseSplitManifest([]byte(data)); err == nil && name != "a.bin" {
+			t.Errorf("name %q accepted", name)
+		}
+	}
+
+	for _, data := range []string{
+		`{"version":2,"name":"a.bin","size":3,"parts":[{"name":"a.bin.part001","size":3}]}`,
+		`{"version":1,"name":"a.bin","size":4,"parts":[{"name":"a.bin.part001","size":3}]}`,
+		`{"version":1,"name":"a.bin","size":0,"parts":[]}`,
+		`{"version":1,"name":"a.bin","size":6,"parts":[{"name":"p","size":3},{"name":"p","size":3}]}`,
+	} {
+		if _, err := pcsfunctions.ParseSplitManifest([]byte(data)); err == nil {
+			t.Errorf("manifest accepted: %s", data)
+		}
+	}
+}
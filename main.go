@@ -2,6 +2,7 @@ package main
 
 import (
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
@@ -51,6 +52,17 @@ const (
 	GZIP <disable-gzip>:
 		在文件加密之前, 启用GZIP压缩文件; 文件解密之后启用GZIP解压缩文件, 默认启用,
 		如果不启用, 则无法检测文件是否解密成功, 解密文件时会保留源文件, 避免解密失败造成文件数据丢失.`
+
+	filterDescription = `
+	过滤条件:
+		路径均为相对于指定目录的路径, 在创建任务之前过滤.
+		--include 和 --exclude 为 glob, 可多次指定, * 匹配除 / 以外的任意字符, ** 匹配任意层级, 以 / 开头则只从指定目录开始匹配.
+		--include-regex 和 --exclude-regex 为正则表达式, 可多次指定.
+		指定 include 时只处理匹配的文件, exclude 排除的目录整个跳过.
+		--newer 和 --older 可以是相对现在的时长, 如 30m, 12h, 7d, 2w, 也可以是日期, 如 2006-01-02.
+
+	只处理最近一周修改的, 大于 1GB 的 mkv 文件
+	--include "*.mkv" --min-size 1GB --newer 7d`
 )
 
 var (
@@ -74,6 +86,203 @@ var (
 	}
 
 	isCli bool
+
+	// filterFlags 递归处理文件时共用的过滤条件
+	filterFlags = []cli.Flag{
+		cli.StringSliceFlag{
+			Name:  "include",
+			Usage: "只处理匹配 glob 的文件, 可多次指定",
+		},
+		cli.StringSliceFlag{
+			Name:  "exclude",
+			Usage: "排除匹配 glob 的文件或目录, 可多次指定",
+		},
+		cli.StringSliceFlag{
+			Name:  "include-regex",
+			Usage: "只处理匹配正则表达式的文件, 可多次指定",
+		},
+		cli.StringSliceFlag{
+			Name:  "exclude-regex",
+			Usage: "排除匹配正则表达式的文件或目录, 可多次指定",
+		},
+		cli.StringFlag{
+			Name:  "min-size",
+			Usage: "只处理不小于此大小的文件, 如 100MB",
+		},
+		cli.StringFlag{
+			Name:  "max-size",
+			Usage: "只处理不大于此大小的文件, 如 1GB",
+		},
+		cli.StringFlag{
+			Name:  "newer",
+			Usage: "只处理此时间之后修改的文件, 如 7d, 2006-01-02",
+		},
+		cli.StringFlag{
+			Name:  "older",
+			Usage: "只处理此时间之前修改的文件, 如 7d, 2006-01-02",
+		},
+		cli.IntFlag{
+			Name:  "max-depth",
+			Usage: "最大递归深度, 指定目录的子项深度为1, 0代表不限制",
+		},
+	}
+	newFileFilter = func(c *cli.Context) (*pcscommand.FileFilter, error) {
+		return pcscommand.NewFileFilter(&pcscommand.FileFilterOptions{
+			Includes:       c.StringSlice("include"),
+			Excludes:       c.StringSlice("exclude"),
+			IncludeRegexps: c.StringSlice("include-regex"),
+			ExcludeRegexps: c.StringSlice("exclude-regex"),
+			MinSize:        c.String("min-size"),
+			MaxSize:        c.String("max-size"),
+			Newer:          c.String("newer"),
+			Older:          c.String("older"),
+			MaxDepth:       c.Int("max-depth"),
+		})
+	}
+
+	// verifyFlag, sidecarFlag 下载完成后的校验方式和生成的校验文件
+	verifyFlag = cli.StringFlag{
+		Name:  "verify",
+		Usage: "下载完成后的校验方式, 可选值: none, size, md5, full, 默认为 md5, 设置了 nocheck 时为 size",
+	}
+	sidecarFlag = cli.StringFlag{
+		Name:  "sidecar",
+		Usage: "下载完成后生成校验文件, 可选值: md5 (生成 <文件名>.md5), sha256 (记录到所在目录的 SHA256SUMS), 多个用逗号分隔",
+	}
+	parseVerifyFlags = func(c *cli.Context) (verify string, sidecars int, err error) {
+		verify = c.String("verify")
+		if verify != "" {
+			if _, err = pcsdownload.ParseVerifyMode(verify); err != nil {
+				return
+			}
+		}
+		sidecars, err = pcsdownload.ParseSidecars(c.String("sidecar"))
+		return
+	}
+
+	// downloadFlags 下载文件, 以及重试下载队列时共用的选项
+	downloadFlags = append([]cli.Flag{
+		cli.BoolFlag{
+			Name:  "test",
+			Usage: "测试下载, 此操作不会保存文件到本地",
+		},
+		cli.BoolFlag{
+			Name:  "ow",
+			Usage: "overwrite, 覆盖已存在的文件",
+		},
+		cli.BoolFlag{
+			Name:  "status",
+			Usage: "输出所有线程的工作状态",
+		},
+		cli.BoolFlag{
+			Name:  "save",
+			Usage: "将下载的文件直接保存到当前工作目录",
+		},
+		cli.StringFlag{
+			Name:  "saveto",
+			Usage: "将下载的文件直接保存到指定的目录",
+		},
+		cli.BoolFlag{
+			Name:  "x",
+			Usage: "为文件加上执行权限, (windows系统无效)",
+		},
+		cli.StringFlag{
+			Name:  "mode",
+			Usage: "下载模式, 可选值: pcs, stream, locate, 默认为 locate, 相关说明见上面的帮助",
+			Value: "locate",
+		},
+		cli.IntFlag{
+			Name:  "p",
+			Usage: "指定下载线程数",
+		},
+		cli.IntFlag{
+			Name:  "l",
+			Usage: "指定同时进行下载文件的数量",
+		},
+		cli.IntFlag{
+			Name:  "retry",
+			Usage: "下载失败最大重试次数",
+			Value: pcsdownload.DefaultDownloadMaxRetry,
+		},
+		cli.BoolFlag{
+			Name:  "nocheck",
+			Usage: "下载文件完成后不校验文件md5, 只校验大小",
+		},
+		verifyFlag,
+		sidecarFlag,
+		cli.BoolFlag{
+			Name:  "mtime",
+			Usage: "将本地文件的修改时间设置为服务器上的修改时间",
+		},
+		cli.BoolFlag{
+			Name:  "resume-queue",
+			Usage: "继续下载队列中未完成的文件, 忽略输入的路径",
+		},
+		cli.IntFlag{
+			Name:  "dindex",
+			Usage: "使用备选下载链接中的第几个，默认第一个",
+		},
+		cli.BoolFlag{
+			Name:  "fullpath",
+			Usage: "以网盘完整路径保存到本地",
+		},
+	}, filterFlags...)
+	newDownloadOptions = func(c *cli.Context) (*pcscommand.DownloadOptions, error) {
+		// 处理saveTo
+		var (
+			saveTo string
+		)
+		if c.Bool("save") {
+			saveTo = "."
+		} else if c.String("saveto") != "" {
+			saveTo = filepath.Clean(c.String("saveto"))
+		}
+
+		// 处理解析downloadMode
+		var (
+			downloadMode pcsdownload.DownloadMode
+		)
+		switch c.String("mode") {
+		case "pcs":
+			downloadMode = pcsdownload.DownloadModePCS
+		case "stream":
+			downloadMode = pcsdownload.DownloadModeStreaming
+		case "locate":
+			downloadMode = pcsdownload.DownloadModeLocate
+		default:
+			return nil, errors.New("下载方式解析失败")
+		}
+
+		filter, err := newFileFilter(c)
+		if err != nil {
+			return nil, err
+		}
+
+		verify, sidecars, err := parseVerifyFlags(c)
+		if err != nil {
+			return nil, err
+		}
+
+		return &pcscommand.DownloadOptions{
+			IsTest:               c.Bool("test"),
+			IsPrintStatus:        c.Bool("status"),
+			IsExecutedPermission: c.Bool("x"),
+			IsOverwrite:          c.Bool("ow"),
+			DownloadMode:         downloadMode,
+			SaveTo:               saveTo,
+			Parallel:             c.Int("p"),
+			Load:                 c.Int("l"),
+			MaxRetry:             c.Int("retry"),
+			NoCheck:              c.Bool("nocheck"),
+			Verify:               verify,
+			Sidecars:             sidecars,
+			LinkPrefer:           c.Int("dindex"),
+			ModifyMTime:          c.Bool("mtime"),
+			FullPath:             c.Bool("fullpath"),
+			ResumeQueue:          c.Bool("resume-queue"),
+			Filter:               filter,
+		}, nil
+	}
 )
 
 func init() {
@@ -154,7 +363,7 @@ func main() {
 				lineArgs                   = args.Parse(line)
 				numArgs                    = len(lineArgs)
 				acceptCompleteFileCommands = []string{
-					"cd", "cp", "download", "export", "locate", "ls", "meta", "mkdir", "mv", "rm", "setastoken", "share", "transfer", "tree", "upload",
+					"cat", "cd", "cp", "download", "export", "head", "locate", "ls", "meta", "mirror", "mkdir", "mv", "rm", "setastoken", "share", "transfer", "tree", "upload",
 				}
 				closed = strings.LastIndex(line, " ") == len(line)-1
 			)
@@ -930,7 +1139,12 @@ func main() {
 
 	删除 /我的资源 整个目录 !!
 	BaiduPCS-Go rm /我的资源
-`,
+
+	指定过滤条件时, 只删除目录内满足条件的文件, 不删除目录.
+
+	删除 /我的资源 内所有的 tmp 文件
+	BaiduPCS-Go rm --include "*.tmp" /我的资源
+`+filterDescription,
 			Category: "百度网盘",
 			Before:   reloadFn,
 			Action: func(c *cli.Context) error {
@@ -939,9 +1153,20 @@ func main() {
 					return nil
 				}
 
+				filter, err := newFileFilter(c)
+				if err != nil {
+					fmt.Println(err)
+					return nil
+				}
+				if filter != nil {
+					pcscommand.RunRemoveByFilter(filter, c.Args()...)
+					return nil
+				}
+
 				pcscommand.RunRemove(c.Args()...)
 				return nil
 			},
+			Flags: filterFlags,
 		},
 		{
 			Name:      "mkdir",
@@ -1018,6 +1243,86 @@ func main() {
 				return nil
 			},
 		},
+		{
+			Name:      "dedupe",
+			Usage:     "查找网盘目录中的重复文件",
+			UsageText: app.Name + " dedupe <目录>",
+			Description: `
+	遍历目录, 按网盘记录的文件大小和md5分组, 列出重复的文件, 不下载任何文件.
+	空文件不视为重复. 可按规则或逐组选择保留的文件, 删除或移动其余的文件.
+
+	保留规则说明 (--keep):
+		oldest: 保留修改日期最早的文件
+		newest: 保留修改日期最新的文件
+		shortest: 保留路径最短的文件
+
+	示例:
+
+	列出 /我的资源 中的重复文件
+	BaiduPCS-Go dedupe /我的资源
+
+	每组保留修改日期最早的文件, 删除其余的文件, 可在网盘文件回收站找回
+	BaiduPCS-Go dedupe --keep oldest /我的资源
+
+	逐组选择要保留的文件, 将其余的文件移动到 /重复文件, 保留原来的目录结构
+	BaiduPCS-Go dedupe -i --quarantine /重复文件 /我的资源
+
+	只检查大于 1MB 的文件, 并将结果导出到 dedupe.json
+	BaiduPCS-Go dedupe --min-size 1MB --json dedupe.json /我的资源
+`+filterDescription,
+			Category: "百度网盘",
+			Before:   reloadFn,
+			Action: func(c *cli.Context) error {
+				if c.NArg() != 1 {
+					cli.ShowCommandHelp(c, c.Command.Name)
+					return nil
+				}
+
+				filter, err := newFileFilter(c)
+				if err != nil {
+					fmt.Println(err)
+					return nil
+				}
+
+				keep, err := pcscommand.ParseDedupeKeep(c.String("keep"))
+				if err != nil {
+					fmt.Println(err)
+					return nil
+				}
+
+				pcscommand.RunDedupe(c.Args().Get(0), &pcscommand.DedupeOptions{
+					Keep:        keep,
+					Interactive: c.Bool("i"),
+					Quarantine:  c.String("quarantine"),
+					Yes:         c.Bool("y"),
+					JSONPath:    c.String("json"),
+					Filter:      filter,
+				})
+				return nil
+			},
+			Flags: append([]cli.Flag{
+				cli.StringFlag{
+					Name:  "keep",
+					Usage: "保留规则, 可选值: oldest, newest, shortest, 与 -i 同时使用时作为默认选择",
+				},
+				cli.BoolFlag{
+					Name:  "i",
+					Usage: "逐组选择要保留的文件",
+				},
+				cli.StringFlag{
+					Name:  "quarantine",
+					Usage: "将多余的文件移动到该网盘目录, 而不是删除",
+				},
+				cli.BoolFlag{
+					Name:  "y",
+					Usage: "按规则处理时不再确认",
+				},
+				cli.StringFlag{
+					Name:  "json",
+					Usage: "将结果导出为 JSON 到本地文件, - 代表输出到标准输出",
+				},
+			}, filterFlags...),
+		},
 		{
 			Name:      "download",
 			Aliases:   []string{"d"},
@@ -1052,29 +1357,146 @@ func main() {
 	下载网盘内的全部文件!!
 	BaiduPCS-Go d /
 	BaiduPCS-Go d *
-`,
+
+	校验方式说明 (--verify):
+		none: 不校验
+		size: 只校验文件大小, 设置了 --nocheck 或 config 中的 no_check 时的默认方式
+		md5: 校验文件大小和网盘记录的md5, 网盘记录的md5不可靠 (如部分大文件) 时只校验大小
+		full: 校验文件大小和md5, 网盘记录的md5不可靠时, 通过下载链接获取实际的md5和crc32再比较
+
+	下载 /我的资源 并完整校验, 同时生成 .md5 和 SHA256SUMS 校验文件
+	BaiduPCS-Go d --verify full --sidecar md5,sha256 /我的资源
+
+	下载队列:
+		每次下载的文件列表会记录到下载队列, 下载成功后移除, 程序中途退出后可继续下载.
+		要下载名为 queue 的文件或目录, 请使用 ./queue
+
+	继续下载队列中未完成的文件
+	BaiduPCS-Go d --resume-queue
+
+	列出下载队列
+	BaiduPCS-Go d queue list
+
+	清空下载队列
+	BaiduPCS-Go d queue clear
+
+	重试下载队列中下载失败的文件
+	BaiduPCS-Go d queue retry-failed
+
+	分卷文件:
+		下载 upload --split-size 生成的分卷清单 (<文件名>.pcsparts.json) 时, 会同时下载清单中的分卷,
+		下载结束后合并为原文件并校验md5, 合并成功后删除分卷.
+
+	下载分卷上传的 /备份/disk.img, 合并为 disk.img
+	BaiduPCS-Go d /备份/disk.img.pcsparts.json
+`+filterDescription,
 			Category: "百度网盘",
 			Before:   reloadFn,
 			Action: func(c *cli.Context) error {
-				if c.NArg() == 0 {
+				if c.NArg() == 0 && !c.Bool("resume-queue") {
 					cli.ShowCommandHelp(c, c.Command.Name)
 					return nil
 				}
 
-				// 处理saveTo
-				var (
-					saveTo string
-				)
-				if c.Bool("save") {
-					saveTo = "."
-				} else if c.String("saveto") != "" {
-					saveTo = filepath.Clean(c.String("saveto"))
+				do, err := newDownloadOptions(c)
+				if err != nil {
+					fmt.Println(err)
+					cli.ShowCommandHelp(c, c.Command.Name)
+					return nil
 				}
 
-				// 处理解析downloadMode
-				var (
-					downloadMode pcsdownload.DownloadMode
-				)
+				pcscommand.RunDownload(c.Args(), do)
+
+				return nil
+			},
+			Flags: downloadFlags,
+			Subcommands: []cli.Command{
+				{
+					Name:      "queue",
+					Usage:     "管理下载队列",
+					UsageText: app.Name + " download queue [list|clear|retry-failed]",
+					Action: func(c *cli.Context) error {
+						if c.NArg() > 0 {
+							cli.ShowCommandHelp(c, c.Command.Name)
+							return nil
+						}
+						pcscommand.RunDownloadQueue("list", nil)
+						return nil
+					},
+					Subcommands: []cli.Command{
+						{
+							Name:  "list",
+							Usage: "列出下载队列",
+							Action: func(c *cli.Context) error {
+								pcscommand.RunDownloadQueue("list", nil)
+								return nil
+							},
+						},
+						{
+							Name:  "clear",
+							Usage: "清空下载队列",
+							Action: func(c *cli.Context) error {
+								pcscommand.RunDownloadQueue("clear", nil)
+								return nil
+							},
+						},
+						{
+							Name:  "retry-failed",
+							Usage: "重试下载队列中下载失败的文件",
+							Action: func(c *cli.Context) error {
+								do, err := newDownloadOptions(c)
+								if err != nil {
+									fmt.Println(err)
+									return nil
+								}
+								pcscommand.RunDownloadQueue("retry-failed", do)
+								return nil
+							},
+							Flags: downloadFlags,
+						},
+					},
+				},
+			},
+		},
+		{
+			Name:      "mirror",
+			Usage:     "将本地目录同步为网盘目录的镜像",
+			UsageText: app.Name + " mirror <网盘目录> <本地目录>",
+			Description: `
+	比较网盘目录和本地目录, 只下载新增或有变化的文件.
+	依次比较文件大小, 修改时间和md5, 大小或修改时间不同的文件视为有变化.
+	修改时间不同但大小相同时, 网盘记录的md5可用才计算本地文件的md5, 一致则只修正本地文件的修改时间.
+	试运行时不计算md5.
+	下载的文件修改时间会设置为与网盘一致, 有变化的文件直接覆盖.
+
+	被过滤的本地文件不会被删除.
+
+	示例:
+
+	将网盘 /我的资源 镜像到本地 D:/我的资源
+	BaiduPCS-Go mirror /我的资源 D:/我的资源
+
+	同时删除本地多余的文件, 先试运行查看要执行的操作
+	BaiduPCS-Go mirror --delete --dry-run /我的资源 D:/我的资源
+
+	只镜像 mp4 文件, 排除 tmp 目录
+	BaiduPCS-Go mirror --include "*.mp4" --exclude "tmp" /我的资源 D:/我的资源
+`+filterDescription,
+			Category: "百度网盘",
+			Before:   reloadFn,
+			Action: func(c *cli.Context) error {
+				if c.NArg() != 2 {
+					cli.ShowCommandHelp(c, c.Command.Name)
+					return nil
+				}
+
+				filter, err := newFileFilter(c)
+				if err != nil {
+					fmt.Println(err)
+					return nil
+				}
+
+				var downloadMode pcsdownload.DownloadMode
 				switch c.String("mode") {
 				case "pcs":
 					downloadMode = pcsdownload.DownloadModePCS
@@ -1088,54 +1510,51 @@ func main() {
 					return nil
 				}
 
-				do := &pcscommand.DownloadOptions{
-					IsTest:               c.Bool("test"),
-					IsPrintStatus:        c.Bool("status"),
-					IsExecutedPermission: c.Bool("x"),
-					IsOverwrite:          c.Bool("ow"),
-					DownloadMode:         downloadMode,
-					SaveTo:               saveTo,
-					Parallel:             c.Int("p"),
-					Load:                 c.Int("l"),
-					MaxRetry:             c.Int("retry"),
-					NoCheck:              c.Bool("nocheck"),
-					LinkPrefer:           c.Int("dindex"),
-					ModifyMTime:          c.Bool("mtime"),
-					FullPath:             c.Bool("fullpath"),
+				verify, sidecars, err := parseVerifyFlags(c)
+				if err != nil {
+					fmt.Println(err)
+					return nil
 				}
 
-				pcscommand.RunDownload(c.Args(), do)
-
+				pcscommand.RunMirror(c.Args().Get(0), c.Args().Get(1), &pcscommand.MirrorOptions{
+					DryRun:   c.Bool("dry-run"),
+					Delete:   c.Bool("delete"),
+					Filter:   filter,
+					Download: &pcscommand.DownloadOptions{
+						IsPrintStatus:        c.Bool("status"),
+						IsExecutedPermission: c.Bool("x"),
+						DownloadMode:         downloadMode,
+						Parallel:             c.Int("p"),
+						Load:                 c.Int("l"),
+						MaxRetry:             c.Int("retry"),
+						NoCheck:              c.Bool("nocheck"),
+						Verify:               verify,
+						Sidecars:             sidecars,
+						LinkPrefer:           c.Int("dindex"),
+					},
+				})
 				return nil
 			},
-			Flags: []cli.Flag{
+			Flags: append([]cli.Flag{
 				cli.BoolFlag{
-					Name:  "test",
-					Usage: "测试下载, 此操作不会保存文件到本地",
+					Name:  "dry-run",
+					Usage: "试运行, 只输出要执行的操作, 不下载也不删除文件",
 				},
 				cli.BoolFlag{
-					Name:  "ow",
-					Usage: "overwrite, 覆盖已存在的文件",
+					Name:  "delete",
+					Usage: "删除本地存在但网盘中不存在的文件和目录",
 				},
 				cli.BoolFlag{
 					Name:  "status",
 					Usage: "输出所有线程的工作状态",
 				},
-				cli.BoolFlag{
-					Name:  "save",
-					Usage: "将下载的文件直接保存到当前工作目录",
-				},
-				cli.StringFlag{
-					Name:  "saveto",
-					Usage: "将下载的文件直接保存到指定的目录",
-				},
 				cli.BoolFlag{
 					Name:  "x",
 					Usage: "为文件加上执行权限, (windows系统无效)",
 				},
 				cli.StringFlag{
 					Name:  "mode",
-					Usage: "下载模式, 可选值: pcs, stream, locate, 默认为 locate, 相关说明见上面的帮助",
+					Usage: "下载模式, 可选值: pcs, stream, locate, 默认为 locate",
 					Value: "locate",
 				},
 				cli.IntFlag{
@@ -1153,80 +1572,607 @@ func main() {
 				},
 				cli.BoolFlag{
 					Name:  "nocheck",
-					Usage: "下载文件完成后不校验文件",
-				},
-				cli.BoolFlag{
-					Name:  "mtime",
-					Usage: "将本地文件的修改时间设置为服务器上的修改时间",
+					Usage: "下载文件完成后不校验文件md5, 只校验大小",
 				},
+				verifyFlag,
+				sidecarFlag,
 				cli.IntFlag{
 					Name:  "dindex",
 					Usage: "使用备选下载链接中的第几个，默认第一个",
 				},
-				cli.BoolFlag{
-					Name:  "fullpath",
-					Usage: "以网盘完整路径保存到本地",
-				},
-			},
+			}, filterFlags...),
 		},
 		{
-			Name:      "upload",
-			Aliases:   []string{"u"},
-			Usage:     "上传文件/目录",
-			UsageText: app.Name + " upload <本地文件/目录的路径1> <文件/目录2> <文件/目录3> ... <目标目录>",
+			Name:      "verify",
+			Usage:     "校验本地文件与网盘文件是否一致",
+			UsageText: app.Name + " verify <网盘文件/目录> <本地文件/目录>",
 			Description: `
-	上传默认采用分片上传的方式, 上传的文件将会保存到, <目标目录>.
-	当上传的文件名和网盘的目录名称相同时, 不会覆盖目录, 防止丢失数据.
+	按照网盘的目录列表, 逐个校验本地已存在的文件, 输出缺失, 大小不一致, 校验失败的文件, 不下载也不修改任何文件.
 
-	注意: 
+	校验方式说明 (--mode):
+		none: 只检查文件是否存在
+		size: 只校验文件大小
+		md5: 默认, 校验文件大小和网盘记录的md5, 网盘记录的md5不可靠时输出为无法校验
+		full: 校验文件大小和md5, 网盘记录的md5不可靠时, 通过下载链接获取实际的md5和crc32再比较
 
-	分片上传之后, 服务器可能会记录到错误的文件md5, 可使用 fixmd5 命令尝试修复文件的MD5值, 修复md5不一定能成功, 但文件的完整性是没问题的.
-	fixmd5 命令使用方法:
-	BaiduPCS-Go fixmd5 -h
+	示例:
 
-	禁用分片上传可以保证服务器记录到正确的md5.
-	禁用分片上传时只能使用单线程上传, 指定的单个文件上传最大线程数将会无效.
+	校验本地 D:/我的资源 与网盘 /我的资源 是否一致
+	BaiduPCS-Go verify /我的资源 D:/我的资源
 
-	示例:
+	完整校验, 并列出本地多余的文件
+	BaiduPCS-Go verify --mode full --extra /我的资源 D:/我的资源
+`+filterDescription,
+			Category: "百度网盘",
+			Before:   reloadFn,
+			Action: func(c *cli.Context) error {
+				if c.NArg() != 2 {
+					cli.ShowCommandHelp(c, c.Command.Name)
+					return nil
+				}
 
-	1. 将本地的 C:\Users\Administrator\Desktop\1.mp4 上传到网盘 /视频 目录
-	注意区别反斜杠 "\" 和 斜杠 "/" !!!
-	BaiduPCS-Go upload C:/Users/Administrator/Desktop/1.mp4 /视频
+				filter, err := newFileFilter(c)
+				if err != nil {
+					fmt.Println(err)
+					return nil
+				}
 
-	2. 将本地的 C:\Users\Administrator\Desktop\1.mp4 和 C:\Users\Administrator\Desktop\2.mp4 上传到网盘 /视频 目录
-	BaiduPCS-Go upload C:/Users/Administrator/Desktop/1.mp4 C:/Users/Administrator/Desktop/2.mp4 /视频
+				mode, err := pcsdownload.ParseVerifyMode(c.String("mode"))
+				if err != nil {
+					fmt.Println(err)
+					return nil
+				}
 
-	3. 将本地的 C:\Users\Administrator\Desktop 整个目录上传到网盘 /视频 目录
-	BaiduPCS-Go upload C:/Users/Administrator/Desktop /视频
+				pcscommand.RunVerify(c.Args().Get(0), c.Args().Get(1), &pcscommand.VerifyOptions{
+					Mode:   mode,
+					Extra:  c.Bool("extra"),
+					Filter: filter,
+				})
+				return nil
+			},
+			Flags: append([]cli.Flag{
+				cli.StringFlag{
+					Name:  "mode",
+					Usage: "校验方式, 可选值: none, size, md5, full",
+					Value: "md5",
+				},
+				cli.BoolFlag{
+					Name:  "extra",
+					Usage: "列出本地存在但网盘中不存在的文件和目录",
+				},
+			}, filterFlags...),
+		},
+		{
+			Name:      "unpack",
+			Usage:     "从打包上传的分卷中取出文件",
+			UsageText: app.Name + " unpack <打包索引文件> [包内的文件/目录/通配符 ...]",
+			Description: `
+	upload --pack 打包上传的小文件, 可以根据打包索引 (<包名>.pcspack.json), 以范围请求从网盘的分卷中单独取出, 不需要下载整个分卷.
+	未指定包内的文件时, 取出全部文件.
 
-	4. 使用相对路径
-	BaiduPCS-Go upload 1.mp4 /视频
+	示例:
+
+	列出打包的文件
+	BaiduPCS-Go unpack --list /备份/photos-3f2a9c1e7b04.pcspack.json
+
+	取出 photos/2024 目录下的文件, 保存到 D:/恢复
+	BaiduPCS-Go unpack --saveto D:/恢复 /备份/photos-3f2a9c1e7b04.pcspack.json photos/2024
+
+	取出所有 jpg 文件
+	BaiduPCS-Go unpack /备份/photos-3f2a9c1e7b04.pcspack.json "photos/*/*.jpg"
 `,
 			Category: "百度网盘",
 			Before:   reloadFn,
 			Action: func(c *cli.Context) error {
-				if c.NArg() < 2 {
+				if c.NArg() < 1 {
 					cli.ShowCommandHelp(c, c.Command.Name)
 					return nil
 				}
 
-				subArgs := c.Args()
-				pcscommand.RunUpload(subArgs[:c.NArg()-1], subArgs[c.NArg()-1], &pcscommand.UploadOptions{
-					Parallel:      c.Int("p"),
-					MaxRetry:      c.Int("retry"),
-					Load:          c.Int("l"),
-					NoRapidUpload: c.Bool("norapid"),
-					Policy:        c.String("policy"),
+				pcscommand.RunUnpack(c.Args().Get(0), c.Args().Tail(), &pcscommand.UnpackOptions{
+					SaveTo:      c.String("saveto"),
+					List:        c.Bool("list"),
+					IsOverwrite: c.Bool("ow"),
 				})
 				return nil
 			},
 			Flags: []cli.Flag{
-				cli.IntFlag{
-					Name:  "p",
-					Usage: "指定单个文件上传的最大线程数",
+				cli.StringFlag{
+					Name:  "saveto",
+					Usage: "将取出的文件保存到此目录, 默认为当前目录",
 				},
-				cli.IntFlag{
-					Name:  "retry",
+				cli.BoolFlag{
+					Name:  "list",
+					Usage: "只列出打包的文件",
+				},
+				cli.BoolFlag{
+					Name:  "ow",
+					Usage: "覆盖已存在的文件",
+				},
+			},
+		},
+		{
+			Name:      "cat",
+			Usage:     "将文件内容输出到标准输出",
+			UsageText: app.Name + " cat <文件1> <文件2> ...",
+			Description: `
+	以分段请求获取文件内容, 按顺序输出到标准输出, 不保存到本地, 可通过管道交给其他程序处理.
+	指定 -p 时多个分段并发请求, 按顺序重新组装后输出.
+	提示信息和错误信息输出到标准错误.
+
+	示例:
+
+	解压网盘内的 /我的资源/1.tar
+	BaiduPCS-Go cat /我的资源/1.tar | tar x
+
+	解压 zstd 压缩的文件, 使用 4 个分段并发请求
+	BaiduPCS-Go cat -p 4 /我的资源/1.tar.zst | zstdcat | tar x
+
+	查看视频文件信息
+	BaiduPCS-Go cat /我的资源/1.mp4 | ffprobe -
+`,
+			Category: "百度网盘",
+			Before:   reloadFn,
+			Action: func(c *cli.Context) error {
+				if c.NArg() == 0 {
+					cli.ShowCommandHelp(c, c.Command.Name)
+					return nil
+				}
+
+				var downloadMode pcsdownload.DownloadMode
+				switch c.String("mode") {
+				case "pcs":
+					downloadMode = pcsdownload.DownloadModePCS
+				case "stream":
+					downloadMode = pcsdownload.DownloadModeStreaming
+				case "locate":
+					downloadMode = pcsdownload.DownloadModeLocate
+				default:
+					fmt.Fprintln(os.Stderr, "下载方式解析失败")
+					cli.ShowCommandHelp(c, c.Command.Name)
+					return nil
+				}
+
+				pcscommand.RunCat(c.Args(), &pcsdownload.StreamOptions{
+					Parallel:     c.Int("p"),
+					MaxRetry:     c.Int("retry"),
+					DownloadMode: downloadMode,
+					DlinkPrefer:  c.Int("dindex"),
+				})
+				return nil
+			},
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "mode",
+					Usage: "下载模式, 可选值: pcs, stream, locate, 默认为 locate",
+					Value: "locate",
+				},
+				cli.IntFlag{
+					Name:  "p",
+					Usage: "同时进行的分段请求数量, 默认为 max_parallel",
+				},
+				cli.IntFlag{
+					Name:  "retry",
+					Usage: "每个分段请求失败的最大重试次数",
+					Value: pcsdownload.DefaultDownloadMaxRetry,
+				},
+				cli.IntFlag{
+					Name:  "dindex",
+					Usage: "使用备选下载链接中的第几个，默认第一个",
+				},
+			},
+		},
+		{
+			Name:      "head",
+			Usage:     "将文件的开头部分输出到标准输出",
+			UsageText: app.Name + " head -c <字节数> <文件>",
+			Description: `
+	只请求文件开头的 <字节数> 个字节, 输出到标准输出, 字节数支持单位, 如 1KB, 4MB.
+
+	示例:
+
+	查看 /我的资源/1.mp4 的前 1KB
+	BaiduPCS-Go head -c 1KB /我的资源/1.mp4 | xxd
+`,
+			Category: "百度网盘",
+			Before:   reloadFn,
+			Action: func(c *cli.Context) error {
+				if c.NArg() != 1 || c.String("c") == "" {
+					cli.ShowCommandHelp(c, c.Command.Name)
+					return nil
+				}
+
+				n, err := converter.ParseFileSizeStr(c.String("c"))
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "解析字节数错误: %s\n", err)
+					return nil
+				}
+
+				var downloadMode pcsdownload.DownloadMode
+				switch c.String("mode") {
+				case "pcs":
+					downloadMode = pcsdownload.DownloadModePCS
+				case "stream":
+					downloadMode = pcsdownload.DownloadModeStreaming
+				case "locate":
+					downloadMode = pcsdownload.DownloadModeLocate
+				default:
+					fmt.Fprintln(os.Stderr, "下载方式解析失败")
+					cli.ShowCommandHelp(c, c.Command.Name)
+					return nil
+				}
+
+				pcscommand.RunHead(c.Args().Get(0), n, &pcsdownload.StreamOptions{
+					Parallel:     c.Int("p"),
+					MaxRetry:     c.Int("retry"),
+					DownloadMode: downloadMode,
+					DlinkPrefer:  c.Int("dindex"),
+				})
+				return nil
+			},
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "c",
+					Usage: "输出的字节数",
+				},
+				cli.StringFlag{
+					Name:  "mode",
+					Usage: "下载模式, 可选值: pcs, stream, locate, 默认为 locate",
+					Value: "locate",
+				},
+				cli.IntFlag{
+					Name:  "p",
+					Usage: "同时进行的分段请求数量, 默认为 max_parallel",
+				},
+				cli.IntFlag{
+					Name:  "retry",
+					Usage: "每个分段请求失败的最大重试次数",
+					Value: pcsdownload.DefaultDownloadMaxRetry,
+				},
+				cli.IntFlag{
+					Name:  "dindex",
+					Usage: "使用备选下载链接中的第几个，默认第一个",
+				},
+			},
+		},
+		{
+			Name:     "serve",
+			Usage:    "通过本地服务访问网盘文件",
+			Category: "百度网盘",
+			Before:   reloadFn,
+			Action: func(c *cli.Context) error {
+				cli.ShowCommandHelp(c, c.Command.Name)
+				return nil
+			},
+			Subcommands: []cli.Command{
+				{
+					Name:      "http",
+					Usage:     "启动 HTTP 服务",
+					UsageText: app.Name + " serve http [--addr 127.0.0.1:8080] [网盘目录]",
+					Description: `
+	将网盘目录映射为 HTTP 服务, 无需先下载即可播放视频或供其他工具获取文件.
+	文件支持 Range 请求, 返回 Content-Length, ETag (md5) 和 Last-Modified.
+	目录返回 HTML 列表, 请求参数 format=json 或 Accept: application/json 时返回 JSON 列表.
+	网盘目录默认为当前工作目录.
+
+	示例:
+
+	将 /我的资源 映射到 http://127.0.0.1:8080/
+	BaiduPCS-Go serve http /我的资源
+
+	播放视频
+	mpv http://127.0.0.1:8080/1.mp4
+
+	获取 JSON 格式的目录列表
+	curl "http://127.0.0.1:8080/?format=json"
+`,
+					Action: func(c *cli.Context) error {
+						if c.NArg() > 1 {
+							cli.ShowCommandHelp(c, c.Command.Name)
+							return nil
+						}
+
+						var downloadMode pcsdownload.DownloadMode
+						switch c.String("mode") {
+						case "pcs":
+							downloadMode = pcsdownload.DownloadModePCS
+						case "stream":
+							downloadMode = pcsdownload.DownloadModeStreaming
+						case "locate":
+							downloadMode = pcsdownload.DownloadModeLocate
+						default:
+							fmt.Println("下载方式解析失败")
+							cli.ShowCommandHelp(c, c.Command.Name)
+							return nil
+						}
+
+						pcscommand.RunServeHTTP(c.String("addr"), c.Args().Get(0), &pcsdownload.StreamOptions{
+							MaxRetry:     c.Int("retry"),
+							DownloadMode: downloadMode,
+							DlinkPrefer:  c.Int("dindex"),
+						})
+						return nil
+					},
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:  "addr",
+							Usage: "监听的地址",
+							Value: "127.0.0.1:8080",
+						},
+						cli.StringFlag{
+							Name:  "mode",
+							Usage: "下载模式, 可选值: pcs, stream, locate, 默认为 locate",
+							Value: "locate",
+						},
+						cli.IntFlag{
+							Name:  "retry",
+							Usage: "读取文件失败的最大重试次数",
+							Value: pcsdownload.DefaultDownloadMaxRetry,
+						},
+						cli.IntFlag{
+							Name:  "dindex",
+							Usage: "使用备选下载链接中的第几个，默认第一个",
+						},
+					},
+				},
+				{
+					Name:      "webdav",
+					Usage:     "启动 WebDAV 服务",
+					UsageText: app.Name + " serve webdav [--addr 127.0.0.1:8081] [--user <用户名> --password <密码>] [网盘目录]",
+					Description: `
+	将网盘目录映射为 WebDAV 服务, 可在文件管理器或其他支持 WebDAV 的工具中挂载.
+	支持列出目录, 创建目录, 删除, 移动, 重命名, 复制 (在服务器端进行), 读取文件和上传文件.
+	读取文件支持 Range 请求; 上传的文件先保存到本地临时目录, 接收完毕后再上传到网盘, 同名文件会被覆盖.
+	启用 config 的 meta_cache 时, 浏览使用的目录列表和元信息通过持久化缓存获取, 数据最多可能滞后30秒;
+	未启用时, 目录列表缓存在内存中, 1分钟后失效, 元信息每次从服务器获取. 写入前的检查总是从服务器获取.
+	网盘目录默认为当前工作目录, 建议设置用户名和密码.
+
+	示例:
+
+	将 /我的资源 映射到 http://127.0.0.1:8081/, 并启用认证
+	BaiduPCS-Go serve webdav --user admin --password 123456 /我的资源
+
+	使用 rclone 挂载
+	rclone mount :webdav: /mnt/pan --webdav-url http://127.0.0.1:8081/ --webdav-user admin --webdav-pass $(rclone obscure 123456)
+`,
+					Action: func(c *cli.Context) error {
+						if c.NArg() > 1 {
+							cli.ShowCommandHelp(c, c.Command.Name)
+							return nil
+						}
+
+						var downloadMode pcsdownload.DownloadMode
+						switch c.String("mode") {
+						case "pcs":
+							downloadMode = pcsdownload.DownloadModePCS
+						case "stream":
+							downloadMode = pcsdownload.DownloadModeStreaming
+						case "locate":
+							downloadMode = pcsdownload.DownloadModeLocate
+						default:
+							fmt.Println("下载方式解析失败")
+							cli.ShowCommandHelp(c, c.Command.Name)
+							return nil
+						}
+
+						pcscommand.RunServeWebDAV(c.String("addr"), c.Args().Get(0), &pcsdownload.StreamOptions{
+							MaxRetry:     c.Int("retry"),
+							DownloadMode: downloadMode,
+							DlinkPrefer:  c.Int("dindex"),
+						}, &pcscommand.ServeWebDAVOptions{
+							Username:       c.String("user"),
+							Password:       c.String("password"),
+							UploadParallel: c.Int("p"),
+							UploadMaxRetry: c.Int("retry"),
+						})
+						return nil
+					},
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:  "addr",
+							Usage: "监听的地址",
+							Value: "127.0.0.1:8081",
+						},
+						cli.StringFlag{
+							Name:  "user",
+							Usage: "basic auth 认证的用户名",
+						},
+						cli.StringFlag{
+							Name:  "password",
+							Usage: "basic auth 认证的密码",
+						},
+						cli.StringFlag{
+							Name:  "mode",
+							Usage: "下载模式, 可选值: pcs, stream, locate, 默认为 locate",
+							Value: "locate",
+						},
+						cli.IntFlag{
+							Name:  "p",
+							Usage: "上传单个文件的最大并发量",
+						},
+						cli.IntFlag{
+							Name:  "retry",
+							Usage: "读取或上传文件失败的最大重试次数",
+							Value: pcsdownload.DefaultDownloadMaxRetry,
+						},
+						cli.IntFlag{
+							Name:  "dindex",
+							Usage: "使用备选下载链接中的第几个，默认第一个",
+						},
+					},
+				},
+			},
+		},
+		{
+			Name:      "upload",
+			Aliases:   []string{"u"},
+			Usage:     "上传文件/目录",
+			UsageText: app.Name + " upload <本地文件/目录的路径1> <文件/目录2> <文件/目录3> ... <目标目录>",
+			Description: `
+	上传默认采用分片上传的方式, 上传的文件将会保存到, <目标目录>.
+	当上传的文件名和网盘的目录名称相同时, 不会覆盖目录, 防止丢失数据.
+
+	注意: 
+
+	分片上传之后, 服务器可能会记录到错误的文件md5, 可使用 fixmd5 命令尝试修复文件的MD5值, 修复md5不一定能成功, 但文件的完整性是没问题的.
+	fixmd5 命令使用方法:
+	BaiduPCS-Go fixmd5 -h
+
+	禁用分片上传可以保证服务器记录到正确的md5.
+	禁用分片上传时只能使用单线程上传, 指定的单个文件上传最大线程数将会无效.
+
+	示例:
+
+	1. 将本地的 C:\Users\Administrator\Desktop\1.mp4 上传到网盘 /视频 目录
+	注意区别反斜杠 "\" 和 斜杠 "/" !!!
+	BaiduPCS-Go upload C:/Users/Administrator/Desktop/1.mp4 /视频
+
+	2. 将本地的 C:\Users\Administrator\Desktop\1.mp4 和 C:\Users\Administrator\Desktop\2.mp4 上传到网盘 /视频 目录
+	BaiduPCS-Go upload C:/Users/Administrator/Desktop/1.mp4 C:/Users/Administrator/Desktop/2.mp4 /视频
+
+	3. 将本地的 C:\Users\Administrator\Desktop 整个目录上传到网盘 /视频 目录
+	BaiduPCS-Go upload C:/Users/Administrator/Desktop /视频
+
+	4. 使用相对路径
+	BaiduPCS-Go upload 1.mp4 /视频
+
+	5. 从标准输入上传到网盘文件 /备份/db.sql.zst, 不需要在本地保存完整的文件
+	pg_dump mydb | zstd | BaiduPCS-Go upload - /备份/db.sql.zst
+
+	从标准输入上传时, <目标目录> 为网盘中的文件路径, 数据按分片缓存在内存中, 无法秒传和续传.
+	数据流的大小事先未知, 最多上传 2048 个分片, 超出时立即失败, 上传较大的数据流时可使用 --block-size 设置更大的分片 (普通用户最大4MB).
+
+	6. 将超过 4GB 的文件分卷上传, 生成 disk.img.part001, disk.img.part002 ... 和清单 disk.img.pcsparts.json
+	BaiduPCS-Go upload --split-size 4GB disk.img /备份
+
+	每个分卷复制到系统临时目录后按普通文件上传 (支持秒传), 网盘中已存在大小和md5都一致的分卷时跳过.
+	分卷上传的文件, 下载清单文件时会自动合并分卷, 见 download 命令的说明.
+
+	7. 将目录中小于 1MB 的小文件打包为 tar.zst 分卷上传, 其他文件正常上传
+	BaiduPCS-Go upload --pack tar.zst --pack-threshold 1MB D:/photos /备份
+
+	打包上传会生成分卷 <包名>.vol001.tar.zst ... 和索引 <包名>.pcspack.json, 包名为 <本地目录名>-<摘要>, 上传多个本地路径时为 pack-<摘要>,
+	摘要根据打包的文件的路径, 大小, 修改时间计算, 文件改变或打包其他同名目录时包名不同, 不会覆盖已有的包.
+	文件不变时打包的结果相同, 中断后再次上传会跳过网盘中大小和md5一致的分卷; 索引已存在时按照 --policy 处理, skip 和 rsync 跳过整个包.
+	索引记录了每个文件在分卷中的位置, 可使用 unpack 命令单独取出文件.
+
+	8. 上传后删除本地文件 (移动), 只删除上传成功且网盘文件的大小和md5与本地一致的文件, 跳过和失败的文件不删除
+	BaiduPCS-Go upload --remove-source D:/ingest /归档
+
+	--remove-source 不删除分卷上传和打包上传的文件. 上传目录中因此变为空的子目录也会被删除, 上传目录本身保留.
+
+	9. 上传后校验网盘文件的大小和分块md5, 并下载随机的 4MB 数据与本地比较, 不一致则删除网盘文件并重新上传
+	BaiduPCS-Go upload --verify-sample 4MB D:/备份.zip /备份
+
+	10. 上传的同时由 4 个线程预先计算后续文件的md5和分块md5, 同一磁盘上同时只读取 1 个文件, 适用于多块机械硬盘
+	BaiduPCS-Go upload --hash-parallel 4 --hash-io 1 D:/照片 E:/视频 /备份
+
+	11. 上传前在网盘的 /照片 目录中查找相同的文件, 已存在的文件不上传, 而是在服务器端拷贝到 /备份/照片
+	BaiduPCS-Go upload --skip-if-exists-anywhere /照片 --copy-existing D:/照片 /备份
+
+	12. 使用名为 photos 的上传会话上传大量文件, 中断后继续上传, 已完成的文件不再计算md5
+	BaiduPCS-Go upload --session photos D:/照片 /备份
+	BaiduPCS-Go upload --session photos
+
+	列出未完成的上传会话及进度, 会话中的文件全部完成后自动删除会话.
+	会话不记录分卷上传和打包上传的文件.
+	BaiduPCS-Go upload sessions
+
+	上传名为 sessions 的本地文件或目录时, 请使用 ./sessions, 以免与 sessions 子命令混淆.
+`+filterDescription,
+			Category: "百度网盘",
+			Before:   reloadFn,
+			Action: func(c *cli.Context) error {
+				// 只指定会话名称时, 继续上传会话中记录的路径
+				if c.NArg() < 2 && !(c.NArg() == 0 && c.String("session") != "") {
+					cli.ShowCommandHelp(c, c.Command.Name)
+					return nil
+				}
+
+				filter, err := newFileFilter(c)
+				if err != nil {
+					fmt.Println(err)
+					return nil
+				}
+
+				var blockSize, splitSize, packThreshold, packVolumeSize, verifySample int64
+				if c.IsSet("verify-sample") {
+					verifySample, err = converter.ParseFileSizeStr(c.String("verify-sample"))
+					if err != nil || verifySample <= 0 {
+						fmt.Printf("校验范围大小格式错误: %s\n", c.String("verify-sample"))
+						return nil
+					}
+				}
+				if c.IsSet("pack-threshold") {
+					packThreshold, err = converter.ParseFileSizeStr(c.String("pack-threshold"))
+					if err != nil || packThreshold <= 0 {
+						fmt.Printf("打包阈值格式错误: %s\n", c.String("pack-threshold"))
+						return nil
+					}
+				}
+				if c.IsSet("pack-volume") {
+					packVolumeSize, err = converter.ParseFileSizeStr(c.String("pack-volume"))
+					if err != nil || packVolumeSize <= 0 {
+						fmt.Printf("打包分卷大小格式错误: %s\n", c.String("pack-volume"))
+						return nil
+					}
+				}
+				if c.IsSet("split-size") {
+					splitSize, err = converter.ParseFileSizeStr(c.String("split-size"))
+					if err != nil || splitSize <= 0 {
+						fmt.Printf("分卷大小格式错误: %s\n", c.String("split-size"))
+						return nil
+					}
+				}
+				if c.IsSet("block-size") {
+					blockSize, err = converter.ParseFileSizeStr(c.String("block-size"))
+					if err != nil {
+						fmt.Printf("分片大小格式错误: %s\n", err)
+						return nil
+					}
+					if blockSize <= 0 || blockSize > baidupcs.MaxUploadBlockSize {
+						fmt.Printf("分片大小超出范围: %s, 最大 %s\n", c.String("block-size"), converter.ConvertFileSize(baidupcs.MaxUploadBlockSize))
+						return nil
+					}
+				}
+
+				opt := &pcscommand.UploadOptions{
+					Parallel:       c.Int("p"),
+					MaxRetry:       c.Int("retry"),
+					Load:           c.Int("l"),
+					HashParallel:   c.Int("hash-parallel"),
+					HashIOParallel: c.Int("hash-io"),
+					NoRapidUpload:  c.Bool("norapid"),
+					Policy:         c.String("policy"),
+					Filter:         filter,
+					BlockSize:      blockSize,
+					SplitSize:      splitSize,
+					Pack:           c.String("pack"),
+					PackThreshold:  packThreshold,
+					PackVolumeSize: packVolumeSize,
+					RemoveSource:   c.Bool("remove-source"),
+					VerifyUpload:   c.Bool("verify-upload"),
+					VerifySample:   verifySample,
+					ExistsScope:    c.String("skip-if-exists-anywhere"),
+					CopyExisting:   c.Bool("copy-existing"),
+					Session:        c.String("session"),
+				}
+				if c.NArg() == 0 {
+					pcscommand.ResumeUploadSession(opt)
+					return nil
+				}
+
+				subArgs := c.Args()
+				pcscommand.RunUpload(subArgs[:c.NArg()-1], subArgs[c.NArg()-1], opt)
+				return nil
+			},
+			Flags: append([]cli.Flag{
+				cli.IntFlag{
+					Name:  "p",
+					Usage: "指定单个文件上传的最大线程数",
+				},
+				cli.IntFlag{
+					Name:  "retry",
 					Usage: "上传失败最大重试次数",
 					Value: pcscommand.DefaultUploadMaxRetry,
 				},
@@ -1234,6 +2180,14 @@ func main() {
 					Name:  "l",
 					Usage: "指定同时上传的最大文件数",
 				},
+				cli.IntFlag{
+					Name:  "hash-parallel",
+					Usage: "上传前预先计算文件摘要的并发量, 默认使用配置 hash_parallel",
+				},
+				cli.IntFlag{
+					Name:  "hash-io",
+					Usage: "同一磁盘上同时读取计算摘要的文件数量, 默认使用配置 hash_io_parallel, 机械硬盘建议为1",
+				},
 				cli.BoolFlag{
 					Name:  "norapid",
 					Usage: "跳过秒传",
@@ -1242,6 +2196,69 @@ func main() {
 					Name:  "policy",
 					Usage: fmt.Sprintf("对同名文件的处理策略 (default: %s), %s, %s", baidupcs.SkipPolicy, baidupcs.OverWritePolicy, baidupcs.RsyncPolicy),
 				},
+				cli.StringFlag{
+					Name:  "block-size",
+					Usage: "从标准输入上传时的分片大小, 如 16MB",
+				},
+				cli.StringFlag{
+					Name:  "split-size",
+					Usage: "超过该大小的文件分卷上传, 如 4GB, 同时上传分卷清单",
+				},
+				cli.BoolFlag{
+					Name:  "verify-upload",
+					Usage: "上传完成后校验网盘文件的大小和分块md5, 不一致则重新上传",
+				},
+				cli.StringFlag{
+					Name:  "verify-sample",
+					Usage: "校验时再下载一段随机范围的数据与本地比较, 如 1MB, 设置后自动启用 --verify-upload",
+				},
+				cli.StringFlag{
+					Name:  "session",
+					Usage: "上传会话名称, 记录每个文件的状态, 中断后再次运行时跳过已完成的文件",
+				},
+				cli.StringFlag{
+					Name:  "skip-if-exists-anywhere",
+					Usage: "上传前在该网盘目录中查找大小和md5相同的文件, 找到则跳过上传",
+				},
+				cli.BoolFlag{
+					Name:  "copy-existing",
+					Usage: "与 --skip-if-exists-anywhere 同时使用, 找到相同的文件时在服务器端拷贝到保存路径",
+				},
+				cli.BoolFlag{
+					Name:  "remove-source",
+					Usage: "上传成功并校验网盘文件的大小和md5后, 删除本地文件和变为空的子目录, 保留上传目录本身",
+				},
+				cli.StringFlag{
+					Name:  "pack",
+					Usage: "将小文件打包上传, 可选值: tar, tar.zst",
+				},
+				cli.StringFlag{
+					Name:  "pack-threshold",
+					Usage: "打包上传的文件大小阈值, 小于该大小的文件打包上传",
+					Value: "1MB",
+				},
+				cli.StringFlag{
+					Name:  "pack-volume",
+					Usage: "打包的分卷大小",
+					Value: "1GB",
+				},
+			}, filterFlags...),
+			Subcommands: []cli.Command{
+				{
+					Name:      "sessions",
+					Usage:     "列出未完成的上传会话及进度",
+					UsageText: app.Name + " upload sessions",
+					Description: `
+	上传名为 sessions 的本地文件或目录时, 请使用 ./sessions`,
+					Action: func(c *cli.Context) error {
+						if c.NArg() > 0 {
+							cli.ShowCommandHelp(c, c.Command.Name)
+							return nil
+						}
+						pcscommand.RunUploadSessions()
+						return nil
+					},
+				},
 			},
 		},
 		{
@@ -1418,6 +2435,10 @@ func main() {
 
 	获取 C:\Users\Administrator\Desktop\1.mp4 的秒传信息
 	BaiduPCS-Go sumfile C:/Users/Administrator/Desktop/1.mp4
+
+	计算结果会缓存在配置目录中, 文件的大小和修改时间不变时直接读取缓存,
+	upload 和 sync 同样使用该缓存. 使用 --no-cache 重新计算, 不读取也不写入缓存
+	BaiduPCS-Go sumfile --no-cache C:/Users/Administrator/Desktop/1.mp4
 `,
 			Category: "其他",
 			Before:   reloadFn,
@@ -1427,8 +2448,12 @@ func main() {
 					return nil
 				}
 
+				var sumCache *checksum.SumCache
+				if !c.Bool("no-cache") {
+					sumCache = pcsconfig.OpenSumCache()
+				}
 				for k, filePath := range c.Args() {
-					lp, err := checksum.GetFileSum(filePath, checksum.CHECKSUM_MD5|checksum.CHECKSUM_SLICE_MD5|checksum.CHECKSUM_CRC32)
+					lp, err := checksum.GetFileSumWithCache(filePath, checksum.CHECKSUM_MD5|checksum.CHECKSUM_SLICE_MD5|checksum.CHECKSUM_CRC32, sumCache)
 					if err != nil {
 						fmt.Printf("[%d] %s\n", k+1, err)
 						continue
@@ -1456,6 +2481,12 @@ func main() {
 
 				return nil
 			},
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "no-cache",
+					Usage: "不使用摘要缓存, 重新计算",
+				},
+			},
 		},
 		{
 			Name:      "transfer",
@@ -1607,7 +2638,10 @@ func main() {
 
 	导出 /我的资源
 	BaiduPCS-Go export /我的资源
-`,
+
+	递归导出 /我的资源 内大于 100MB 的文件
+	BaiduPCS-Go export -r --min-size 100MB /我的资源
+`+filterDescription,
 			Category: "百度网盘",
 			Before:   reloadFn,
 			Action: func(c *cli.Context) error {
@@ -1616,6 +2650,12 @@ func main() {
 					pcspaths = []string{"."}
 				}
 
+				filter, err := newFileFilter(c)
+				if err != nil {
+					fmt.Println(err)
+					return nil
+				}
+
 				pcscommand.RunExport(pcspaths, &pcscommand.ExportOptions{
 					RootPath:   c.String("root"),
 					SavePath:   c.String("out"),
@@ -1623,10 +2663,11 @@ func main() {
 					Recursive:  c.Bool("r"),
 					LinkFormat: c.Bool("link"),
 					StdOut:     c.Bool("stdout"),
+					Filter:     filter,
 				})
 				return nil
 			},
-			Flags: []cli.Flag{
+			Flags: append([]cli.Flag{
 				cli.StringFlag{
 					Name:  "root",
 					Usage: "设置要导出文件或目录的根路径, 可以是相对路径",
@@ -1652,7 +2693,7 @@ func main() {
 					Name:  "stdout",
 					Usage: "导出信息不存文件, 直接打印至标准输出",
 				},
-			},
+			}, filterFlags...),
 		},
 		{
 			Name:    "offlinedl",
@@ -1904,13 +2945,17 @@ func main() {
 		谨慎修改 appid, user_agent, pcs_ua, pan_ua 的值, 否则访问网盘服务器时, 可能会出现错误
 		cache_size 的值支持可选设置单位了, 单位不区分大小写, b 和 B 均表示字节的意思, 如 64KB, 1MB, 32kb, 65536b, 65536
 		max_download_rate, max_upload_rate 的值支持可选设置单位了, 单位为每秒的传输速率, 后缀'/s' 可省略, 如 2MB/s, 2MB, 2m, 2mb 均为一个意思
+		bandwidth_schedule 按时间段限速, 规则用 ; 分隔, 按顺序使用第一条匹配的规则, 没有匹配时使用 max_download_rate, max_upload_rate,
+		每条规则的格式为 [星期] HH:MM-HH:MM 速度, 星期可选, 如 Mon-Fri, Sat,Sun, 省略表示每天, 时间段可跨越午夜,
+		速度如 2MB 同时限制下载和上传, down=2MB up=512KB 分别限制, 0 或 unlimited 表示不限制, 修改后对进行中的传输实时生效
 
 	例子:
 		BaiduPCS-Go config set -appid=266719
 		BaiduPCS-Go config set -enable_https=false
 		BaiduPCS-Go config set -user_agent="netdisk;2.2.51.6;netdisk;10.0.63;PC;android-android"
 		BaiduPCS-Go config set -cache_size 64KB
-		BaiduPCS-Go config set -cache_size 16384 -max_parallel 200 -savedir D:/download`,
+		BaiduPCS-Go config set -cache_size 16384 -max_parallel 200 -savedir D:/download
+		BaiduPCS-Go config set -bandwidth_schedule "Mon-Fri 09:00-18:00 2MB; 23:00-07:00 unlimited"`,
 					Action: func(c *cli.Context) error {
 						if c.NumFlags() <= 0 || c.NArg() > 0 {
 							cli.ShowCommandHelp(c, c.Command.Name)
@@ -1961,6 +3006,12 @@ func main() {
 						if c.IsSet("max_parallel") {
 							pcsconfig.Config.MaxParallel = c.Int("max_parallel")
 						}
+						if c.IsSet("min_parallel") {
+							pcsconfig.Config.MinParallel = c.Int("min_parallel")
+						}
+						if c.IsSet("adaptive_parallel") {
+							pcsconfig.Config.AdaptiveParallel = c.Bool("adaptive_parallel")
+						}
 						if c.IsSet("max_upload_parallel") {
 							pcsconfig.Config.MaxUploadParallel = c.Int("max_upload_parallel")
 						}
@@ -1970,6 +3021,12 @@ func main() {
 						if c.IsSet("max_upload_load") {
 							pcsconfig.Config.MaxUploadLoad = c.Int("max_upload_load")
 						}
+						if c.IsSet("hash_parallel") {
+							pcsconfig.Config.HashParallel = c.Int("hash_parallel")
+						}
+						if c.IsSet("hash_io_parallel") {
+							pcsconfig.Config.HashIOParallel = c.Int("hash_io_parallel")
+						}
 						if c.IsSet("max_download_rate") {
 							err := pcsconfig.Config.SetMaxDownloadRateByStr(c.String("max_download_rate"))
 							if err != nil {
@@ -1984,6 +3041,37 @@ func main() {
 								return nil
 							}
 						}
+						if c.IsSet("bandwidth_schedule") {
+							err := pcsconfig.Config.SetBandwidthSchedule(c.String("bandwidth_schedule"))
+							if err != nil {
+								fmt.Printf("设置 bandwidth_schedule 错误: %s\n", err)
+								return nil
+							}
+						}
+						if c.IsSet("batch_size") {
+							pcsconfig.Config.SetBatchSize(c.Int("batch_size"))
+						}
+						if c.IsSet("batch_parallel") {
+							pcsconfig.Config.SetBatchParallel(c.Int("batch_parallel"))
+						}
+						if c.IsSet("list_page_size") {
+							pcsconfig.Config.SetListPageSize(c.Int("list_page_size"))
+						}
+						if c.IsSet("recurse_parallel") {
+							pcsconfig.Config.SetRecurseParallel(c.Int("recurse_parallel"))
+						}
+						if c.IsSet("api_rate_pcs") {
+							pcsconfig.Config.SetAPIRate(baidupcs.APIClassPCS, c.Int("api_rate_pcs"))
+						}
+						if c.IsSet("api_rate_pan") {
+							pcsconfig.Config.SetAPIRate(baidupcs.APIClassPan, c.Int("api_rate_pan"))
+						}
+						if c.IsSet("api_rate_xpan") {
+							pcsconfig.Config.SetAPIRate(baidupcs.APIClassXPan, c.Int("api_rate_xpan"))
+						}
+						if c.IsSet("meta_cache") {
+							pcsconfig.Config.SetMetaCache(c.Bool("meta_cache"))
+						}
 						if c.IsSet("savedir") {
 							pcsconfig.Config.SaveDir = c.String("savedir")
 						}
@@ -2021,6 +3109,14 @@ func main() {
 							Name:  "max_parallel",
 							Usage: "下载网络全部连接的最大并发量",
 						},
+						cli.IntFlag{
+							Name:  "min_parallel",
+							Usage: "自适应下载并发量的下限",
+						},
+						cli.BoolFlag{
+							Name:  "adaptive_parallel",
+							Usage: "根据实测的下载速度自动调整并发量",
+						},
 						cli.IntFlag{
 							Name:  "max_upload_parallel",
 							Usage: "上传网络单个连接的最大并发量",
@@ -2033,6 +3129,14 @@ func main() {
 							Name:  "max_upload_load",
 							Usage: "同时进行上传文件的最大数量",
 						},
+						cli.IntFlag{
+							Name:  "hash_parallel",
+							Usage: "上传前预先计算文件摘要的并发量",
+						},
+						cli.IntFlag{
+							Name:  "hash_io_parallel",
+							Usage: "同一磁盘上同时读取计算摘要的文件数量",
+						},
 						cli.StringFlag{
 							Name:  "max_download_rate",
 							Usage: "限制最大下载速度, 0代表不限制",
@@ -2041,6 +3145,42 @@ func main() {
 							Name:  "max_upload_rate",
 							Usage: "限制最大上传速度, 0代表不限制",
 						},
+						cli.StringFlag{
+							Name:  "bandwidth_schedule",
+							Usage: "按时间段限速, 如 \"Mon-Fri 09:00-18:00 2MB; 23:00-07:00 unlimited\", 留空表示不使用",
+						},
+						cli.IntFlag{
+							Name:  "batch_size",
+							Usage: "批量操作单次请求的最大路径数量",
+						},
+						cli.IntFlag{
+							Name:  "batch_parallel",
+							Usage: "批量操作分片的最大并发量",
+						},
+						cli.IntFlag{
+							Name:  "list_page_size",
+							Usage: "分页获取目录列表时每页的条目数量",
+						},
+						cli.IntFlag{
+							Name:  "recurse_parallel",
+							Usage: "递归获取目录列表时同时进行的请求数量",
+						},
+						cli.IntFlag{
+							Name:  "api_rate_pcs",
+							Usage: "PCS 接口每秒最大请求数, 0代表不限制",
+						},
+						cli.IntFlag{
+							Name:  "api_rate_pan",
+							Usage: "网盘首页接口每秒最大请求数, 0代表不限制",
+						},
+						cli.IntFlag{
+							Name:  "api_rate_xpan",
+							Usage: "开放平台接口每秒最大请求数, 0代表不限制",
+						},
+						cli.BoolFlag{
+							Name:  "meta_cache",
+							Usage: "启用持久化的目录列表和元信息缓存",
+						},
 						cli.StringFlag{
 							Name:  "savedir",
 							Usage: "下载文件的储存目录",
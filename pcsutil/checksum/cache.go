@@ -0,0 +1,266 @@
+package checksum
+
+import (
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/qjfoidnh/BaiduPCS-Go/pcsutil/jsonhelper"
+)
+
+const (
+	// SumCacheMaxEntries 摘要缓存最多保留的记录数, 超出时丢弃最久未使用的记录
+	SumCacheMaxEntries = 100000
+
+	// sumCacheSaveDelay 缓存变更后延迟写入文件的时间, 合并多次写入
+	sumCacheSaveDelay = 2 * time.Second
+)
+
+type (
+	// SumCache 持久化的本地文件摘要缓存, 以 (设备号, inode, 大小, 修改时间) 为键,
+	// 文件内容改变后修改时间随之改变, 缓存自然失效
+	SumCache struct {
+		Entries map[string]*SumCacheEntry `json:"entries"`
+
+		mu        sync.Mutex
+		filePath  string
+		saveTimer *time.Timer
+		dirty     bool // 有未写入文件的记录
+	}
+
+	// SumCacheEntry 缓存的文件摘要
+	SumCacheEntry struct {
+		Path     string `json:"path"` // 计算摘要时的路径, 仅供参考
+		Flag     int    `json:"flag"` // 已缓存的摘要, CHECKSUM_MD5 等的组合
+		MD5      string `json:"md5,omitempty"`
+		SliceMD5 string `json:"slicemd5,omitempty"`
+		CRC32    uint32 `json:"crc32,omitempty"`
+		Time     int64  `json:"time"` // 最近使用的时间, 读取时只更新内存中的记录, 随下次写入保存
+
+		BlockSize int64    `json:"blocksize,omitempty"` // 分块md5的分块大小
+		BlockList []string `json:"blocklist,omitempty"` // 分块md5
+	}
+)
+
+var (
+	sumCaches sync.Map // 已打开的缓存, 同一个文件只打开一次
+)
+
+// OpenSumCache 打开摘要缓存文件, 文件不存在或已损坏时使用空缓存
+func OpenSumCache(filePath string) (sc *SumCache, err error) {
+	filePath, err = filepath.Abs(filePath)
+	if err != nil {
+		return nil, err
+	}
+	if scItf, ok := sumCaches.Load(filePath); ok {
+		return scItf.(*SumCache), nil
+	}
+
+	sc = &SumCache{
+		filePath: filePath,
+	}
+	file, err := os.Open(filePath)
+	if err == nil {
+		err = jsonhelper.UnmarshalData(file, sc)
+		file.Close()
+		if err != nil {
+			sc.Entries = nil
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	if sc.Entries == nil {
+		sc.Entries = map[string]*SumCacheEntry{}
+	}
+
+	scItf, _ := sumCaches.LoadOrStore(filePath, sc)
+	return scItf.(*SumCache), nil
+}
+
+// sumCacheKey 返回文件在缓存中的键, 无法获取 inode 的系统以绝对路径代替
+func sumCacheKey(localPath string, info os.FileInfo) string {
+	builder := &strings.Builder{}
+	if dev, ino, ok := fileID(info); ok {
+		builder.WriteString(strconv.FormatUint(dev, 10))
+		builder.WriteByte(':')
+		builder.WriteString(strconv.FormatUint(ino, 10))
+	} else {
+		absPath, err := filepath.Abs(localPath)
+		if err != nil {
+			absPath = localPath
+		}
+		builder.WriteString(absPath)
+	}
+	builder.WriteByte(':')
+	builder.WriteString(strconv.FormatInt(info.Size(), 10))
+	builder.WriteByte(':')
+	builder.WriteString(strconv.FormatInt(info.ModTime().UnixNano(), 10))
+	return builder.String()
+}
+
+// DeviceKey 返回文件所在设备的标识, 无法获取设备号的系统以卷名代替
+func DeviceKey(localPath string, info os.FileInfo) string {
+	if dev, _, ok := fileID(info); ok {
+		return strconv.FormatUint(dev, 10)
+	}
+	absPath, err := filepath.Abs(localPath)
+	if err != nil {
+		absPath = localPath
+	}
+	return filepath.VolumeName(absPath)
+}
+
+// Load 查找文件的摘要, 缓存中包含 flag 指定的全部摘要才返回
+func (sc *SumCache) Load(localPath string, info os.FileInfo, flag int) (entry SumCacheEntry, ok bool) {
+	if sc == nil || flag == 0 {
+		return
+	}
+	key := sumCacheKey(localPath, info)
+
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	e := sc.Entries[key]
+	if e == nil || e.Flag&flag != flag {
+		return
+	}
+	// 只读取时不写入文件
+	e.Time = time.Now().Unix()
+	return *e, true
+}
+
+// LoadBlockList 查找文件按 blockSize 分块的md5
+func (sc *SumCache) LoadBlockList(localPath string, info os.FileInfo, blockSize int64) (blockList []string, ok bool) {
+	if sc == nil || blockSize <= 0 {
+		return
+	}
+	key := sumCacheKey(localPath, info)
+
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	e := sc.Entries[key]
+	if e == nil || e.BlockSize != blockSize || len(e.BlockList) == 0 {
+		return
+	}
+	e.Time = time.Now().Unix()
+	return append([]string(nil), e.BlockList...), true
+}
+
+// Store 保存文件的摘要, 与已缓存的摘要合并
+func (sc *SumCache) Store(localPath string, info os.FileInfo, flag int, md5, sliceMD5 []byte, crc32 uint32) {
+	if sc == nil || flag == 0 {
+		return
+	}
+	key := sumCacheKey(localPath, info)
+
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	e := sc.Entries[key]
+	if e == nil {
+		e = &SumCacheEntry{}
+		sc.Entries[key] = e
+	}
+	e.Path = localPath
+	e.Time = time.Now().Unix()
+	e.Flag |= flag
+	if flag&CHECKSUM_MD5 != 0 {
+		e.MD5 = hex.EncodeToString(md5)
+	}
+	if flag&CHECKSUM_SLICE_MD5 != 0 {
+		e.SliceMD5 = hex.EncodeToString(sliceMD5)
+	}
+	if flag&CHECKSUM_CRC32 != 0 {
+		e.CRC32 = crc32
+	}
+	sc.scheduleSave()
+}
+
+// StoreBlockList 保存文件按 blockSize 分块的md5, 替换已缓存的其他分块大小的记录
+func (sc *SumCache) StoreBlockList(localPath string, info os.FileInfo, blockSize int64, blockList []string) {
+	if sc == nil || blockSize <= 0 || len(blockList) == 0 {
+		return
+	}
+	key := sumCacheKey(localPath, info)
+
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	e := sc.Entries[key]
+	if e == nil {
+		e = &SumCacheEntry{}
+		sc.Entries[key] = e
+	}
+	e.Path = localPath
+	e.Time = time.Now().Unix()
+	e.BlockSize = blockSize
+	e.BlockList = append([]string(nil), blockList...)
+	sc.scheduleSave()
+}
+
+// Save 立即将缓存写入文件, 没有新的记录时不写入
+func (sc *SumCache) Save() error {
+	if sc == nil {
+		return nil
+	}
+	sc.mu.Lock()
+	if sc.saveTimer != nil {
+		sc.saveTimer.Stop()
+		sc.saveTimer = nil
+	}
+	if !sc.dirty {
+		sc.mu.Unlock()
+		return nil
+	}
+	sc.prune()
+	builder := &strings.Builder{}
+	err := jsonhelper.MarshalData(builder, sc)
+	sc.dirty = err != nil
+	sc.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	// 先写入临时文件, 避免写入中途退出损坏缓存
+	tmpPath := sc.filePath + ".tmp"
+	err = os.WriteFile(tmpPath, []byte(builder.String()), 0600)
+	if err == nil {
+		err = os.Rename(tmpPath, sc.filePath)
+	}
+	if err != nil {
+		sc.mu.Lock()
+		sc.dirty = true
+		sc.mu.Unlock()
+	}
+	return err
+}
+
+// scheduleSave 标记缓存有新的记录, 延迟写入文件, 调用时需持有锁
+func (sc *SumCache) scheduleSave() {
+	sc.dirty = true
+	if sc.saveTimer != nil {
+		return
+	}
+	sc.saveTimer = time.AfterFunc(sumCacheSaveDelay, func() {
+		sc.Save()
+	})
+}
+
+// prune 丢弃最久未使用的记录, 调用时需持有锁
+func (sc *SumCache) prune() {
+	if len(sc.Entries) <= SumCacheMaxEntries {
+		return
+	}
+	keys := make([]string, 0, len(sc.Entries))
+	for key := range sc.Entries {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return sc.Entries[keys[i]].Time < sc.Entries[keys[j]].Time
+	})
+	for _, key := range keys[:len(keys)-SumCacheMaxEntries] {
+		delete(sc.Entries, key)
+	}
+}
@@ -0,0 +1,150 @@
+package checksum_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/qjfoidnh/BaiduPCS-Go/pcsutil/checksum"
+)
+
+func TestSumCache(t *testing.T) {
+	dir := t.TempDir()
+	filePath := filepath.Join(dir, "a.txt")
+	err := os.WriteFile(filePath, []byte("hello"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cachePath := filepath.Join(dir, "cache.json")
+	sc, err := checksum.OpenSumCache(cachePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	flag := checksum.CHECKSUM_MD5 | checksum.CHECKSUM_SLICE_MD5 | checksum.CHECKSUM_CRC32
+	want, err := checksum.GetFileSum(filePath, flag)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = checksum.GetFileSumWithCache(filePath, flag, sc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = sc.Save()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry, ok := sc.Load(filePath, info, flag)
+	if !ok {
+		t.Fatal("cache miss")
+	}
+	if entry.CRC32 != want.CRC32 {
+		t.Fatalf("crc32 %d, want %d", entry.CRC32, want.CRC32)
+	}
+	got, err := checksum.GetFileSumWithCache(filePath, flag, sc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.MD5, want.MD5) || !bytes.Equal(got.SliceMD5, want.SliceMD5) || got.CRC32 != want.CRC32 {
+		t.Fatalf("cached sum %x %x %d, want %x %x %d", got.MD5, got.SliceMD5, got.CRC32, want.MD5, want.SliceMD5, want.CRC32)
+	}
+
+	// 修改文件后缓存失效
+	err = os.WriteFile(filePath, []byte("world"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mtime := info.ModTime().Add(time.Second)
+	os.Chtimes(filePath, mtime, mtime)
+	info, err = os.Stat(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok = sc.Load(filePath, info, flag); ok {
+		t.Fatal("stale cache hit")
+	}
+	got, err = checksum.GetFileSumWithCache(filePath, flag, sc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(got.MD5, want.MD5) {
+		t.Fatal("md5 not recalculated")
+	}
+}
+
+func TestSumCacheReadOnly(t *testing.T) {
+	dir := t.TempDir()
+	filePath := filepath.Join(dir, "a.txt")
+	err := os.WriteFile(filePath, bytes.Repeat([]byte("0123456789"), 100), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cachePath := filepath.Join(dir, "cache.json")
+	sc, err := checksum.OpenSumCache(cachePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lfc := checksum.NewLocalFileChecksum(filePath, 256)
+	lfc.SetSumCache(sc)
+	err = lfc.OpenPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lfc.Close()
+	err = lfc.Sum(checksum.CHECKSUM_MD5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = lfc.CalculateChunkedSum(300)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := lfc.BlocksList
+	if len(want) != 4 {
+		t.Fatalf("block list %v", want)
+	}
+	err = sc.Save()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 分块md5从缓存中读取, 分块大小不同时不使用缓存
+	info, err := os.Stat(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blockList, ok := sc.LoadBlockList(filePath, info, 300)
+	if !ok || strings.Join(blockList, ",") != strings.Join(want, ",") {
+		t.Fatalf("cached block list %v, want %v", blockList, want)
+	}
+	if _, ok = sc.LoadBlockList(filePath, info, 400); ok {
+		t.Fatal("block list with another block size hit")
+	}
+
+	// 只读取缓存时, 不重写缓存文件
+	os.Remove(cachePath)
+	lfc.BlocksList = nil
+	err = lfc.CalculateChunkedSum(300)
+	if err != nil || strings.Join(lfc.BlocksList, ",") != strings.Join(want, ",") {
+		t.Fatalf("block list %v, err %v", lfc.BlocksList, err)
+	}
+	if _, ok = sc.Load(filePath, info, checksum.CHECKSUM_MD5); !ok {
+		t.Fatal("cache miss")
+	}
+	err = sc.Save()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat(cachePath); !os.IsNotExist(err) {
+		t.Fatal("cache file rewritten after read-only access")
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"crypto/md5"
 	"encoding/hex"
 	"fmt"
+	"github.com/qjfoidnh/BaiduPCS-Go/baidupcs"
 	"github.com/qjfoidnh/BaiduPCS-Go/pcsutil/cachepool"
 	"github.com/qjfoidnh/BaiduPCS-Go/pcsutil/converter"
 	"hash/crc32"
@@ -44,7 +45,8 @@ type (
 		bufSize   int
 		sliceSize int
 		buf       []byte
-		file      *os.File // 文件
+		file      *os.File  // 文件
+		sumCache  *SumCache // 摘要缓存, 为空则不使用缓存
 	}
 )
 
@@ -191,8 +193,56 @@ func (lfc *LocalFileChecksum) createChecksumWriteUnit(cw ChecksumWriter, isAll,
 	}
 }
 
-// Sum 计算文件摘要值
+// SetSumCache 设置摘要缓存, Sum 和 CalculateChunkedSum 优先从缓存中读取
+func (lfc *LocalFileChecksum) SetSumCache(sc *SumCache) {
+	lfc.sumCache = sc
+}
+
+// Sum 计算文件摘要值, 设置了摘要缓存时优先从缓存中读取, 计算完成后写入缓存
 func (lfc *LocalFileChecksum) Sum(checkSumFlag int) (err error) {
+	if lfc.sumCache == nil || lfc.file == nil {
+		return lfc.sum(checkSumFlag)
+	}
+
+	// 前 sliceSize 切片的 md5 仅缓存默认的切片大小
+	cacheFlag := checkSumFlag
+	if lfc.sliceSize != int(baidupcs.SliceMD5Size) {
+		cacheFlag &^= CHECKSUM_SLICE_MD5
+	}
+	info, err := lfc.file.Stat()
+	if err != nil {
+		return err
+	}
+	if cacheFlag == checkSumFlag {
+		if entry, ok := lfc.sumCache.Load(lfc.Path, info, cacheFlag); ok {
+			if checkSumFlag&CHECKSUM_MD5 != 0 {
+				lfc.MD5, _ = hex.DecodeString(entry.MD5)
+			}
+			if checkSumFlag&CHECKSUM_SLICE_MD5 != 0 {
+				lfc.SliceMD5, _ = hex.DecodeString(entry.SliceMD5)
+			}
+			if checkSumFlag&CHECKSUM_CRC32 != 0 {
+				lfc.CRC32 = entry.CRC32
+			}
+			return nil
+		}
+	}
+
+	err = lfc.sum(checkSumFlag)
+	if err != nil {
+		return err
+	}
+
+	// 计算过程中文件被修改, 不写入缓存
+	after, err := lfc.file.Stat()
+	if err != nil || after.Size() != info.Size() || !after.ModTime().Equal(info.ModTime()) {
+		return nil
+	}
+	lfc.sumCache.Store(lfc.Path, info, cacheFlag, lfc.MD5, lfc.SliceMD5, lfc.CRC32)
+	return nil
+}
+
+func (lfc *LocalFileChecksum) sum(checkSumFlag int) (err error) {
 	lfc.fix()
 	wus := make([]*ChecksumWriteUnit, 0, 2)
 	if (checkSumFlag & (CHECKSUM_MD5 | CHECKSUM_SLICE_MD5)) != 0 {
@@ -235,9 +285,36 @@ func (lfc *LocalFileChecksum) Sum(checkSumFlag int) (err error) {
 	return
 }
 
-// CalculateChunkedMD5 按指定大小分块计算MD5
+// CalculateChunkedSum 按指定大小分块计算MD5, 设置了摘要缓存时优先从缓存中读取, 计算完成后写入缓存
 func (lfc *LocalFileChecksum) CalculateChunkedSum(chunkSize int64) (err error) {
+	if lfc.sumCache == nil || lfc.file == nil || chunkSize <= 0 {
+		return lfc.calculateChunkedSum(chunkSize)
+	}
+
+	info, err := lfc.file.Stat()
+	if err != nil {
+		return err
+	}
+	if blockList, ok := lfc.sumCache.LoadBlockList(lfc.Path, info, chunkSize); ok {
+		lfc.BlocksList = blockList
+		return nil
+	}
 
+	err = lfc.calculateChunkedSum(chunkSize)
+	if err != nil {
+		return err
+	}
+
+	// 计算过程中文件被修改, 不写入缓存
+	after, err := lfc.file.Stat()
+	if err != nil || after.Size() != info.Size() || !after.ModTime().Equal(info.ModTime()) {
+		return nil
+	}
+	lfc.sumCache.StoreBlockList(lfc.Path, info, chunkSize, lfc.BlocksList)
+	return nil
+}
+
+func (lfc *LocalFileChecksum) calculateChunkedSum(chunkSize int64) (err error) {
 	// 确保分块大小有效
 	if chunkSize <= 0 {
 		return fmt.Errorf("invalid block size: %d", chunkSize)
@@ -283,6 +360,10 @@ func (lfc *LocalFileChecksum) CalculateChunkedSum(chunkSize int64) (err error) {
 			if err != nil && err != io.EOF {
 				return err
 			}
+			if n == 0 {
+				// 文件在计算过程中变小
+				return io.ErrUnexpectedEOF
+			}
 
 			chunkMD5.Write(buffer[:n])
 			bytesRead += int64(n)
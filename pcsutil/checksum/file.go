@@ -33,7 +33,13 @@ func (lfm *LocalFileMeta) CompleteAbsPath() {
 
 // GetFileSum 获取文件的大小, md5, 前256KB切片的 md5, crc32
 func GetFileSum(localPath string, flag int) (lfc *LocalFileChecksum, err error) {
+	return GetFileSumWithCache(localPath, flag, nil)
+}
+
+// GetFileSumWithCache 同 GetFileSum, 优先从摘要缓存 sc 中读取, sc 为空则不使用缓存
+func GetFileSumWithCache(localPath string, flag int, sc *SumCache) (lfc *LocalFileChecksum, err error) {
 	lfc = NewLocalFileChecksum(localPath, int(baidupcs.SliceMD5Size))
+	lfc.SetSumCache(sc)
 	defer lfc.Close()
 
 	err = lfc.OpenPath()
@@ -0,0 +1,261 @@
+package downloader
+
+import (
+	"github.com/qjfoidnh/BaiduPCS-Go/pcsverbose"
+	"github.com/qjfoidnh/BaiduPCS-Go/requester/transfer"
+	"sort"
+	"time"
+)
+
+const (
+	// AdaptiveInterval 自适应并发量的评估间隔
+	AdaptiveInterval = 5 * time.Second
+
+	// adaptiveGainRatio 增加连接后速度至少提升的比例, 否则视为已达瓶颈
+	adaptiveGainRatio = 0.1
+	// adaptiveHoldRounds 达到瓶颈或被服务器限制后, 暂停增加连接的评估轮数
+	adaptiveHoldRounds = 6
+	// adaptiveMaxForbidden 已是最小并发量时连续被 403 的最大轮数, 超出则视为下载链接失效
+	adaptiveMaxForbidden = 3
+)
+
+type (
+	// adaptiveParallel 根据实测的总速度调整目标并发量:
+	// 每轮增加一个连接, 速度提升不足 adaptiveGainRatio 则撤回并暂停增加,
+	// 遇到 403/429 时减半
+	adaptiveParallel struct {
+		min, max int
+		target   int
+
+		baseSpeeds int64 // 上次增加连接前的速度
+		probing    bool  // 上一轮增加了连接, 等待本轮验证
+		hold       int   // 剩余暂停增加连接的轮数
+		throttled  bool  // 本轮已因服务器限制减半, 避免多个连接同时被限制时重复减半
+		forbidden  int   // 已是最小并发量时连续被 403 的轮数
+	}
+)
+
+func newAdaptiveParallel(min, max, start int) *adaptiveParallel {
+	if max < 1 {
+		max = 1
+	}
+	if min < 1 {
+		min = 1
+	}
+	if min > max {
+		min = max
+	}
+	if start < min {
+		start = min
+	}
+	if start > max {
+		start = max
+	}
+	return &adaptiveParallel{
+		min:    min,
+		max:    max,
+		target: start,
+	}
+}
+
+// evaluate 根据本轮的平均速度调整目标并发量
+func (ap *adaptiveParallel) evaluate(speeds int64) {
+	if ap.throttled {
+		ap.throttled = false
+		return
+	}
+	if speeds > 0 {
+		ap.forbidden = 0
+	}
+
+	if ap.probing {
+		ap.probing = false
+		if float64(speeds) < float64(ap.baseSpeeds)*(1+adaptiveGainRatio) {
+			// 增加连接没有带来足够的提升, 撤回
+			if ap.target > ap.min {
+				ap.target--
+			}
+			ap.hold = adaptiveHoldRounds
+			return
+		}
+	}
+
+	if ap.hold > 0 {
+		ap.hold--
+		return
+	}
+
+	if ap.target < ap.max {
+		ap.baseSpeeds = speeds
+		ap.target++
+		ap.probing = true
+	}
+}
+
+// throttle 被服务器限制, 目标并发量减半, 本轮内只减半一次.
+// forbidden 为 true 表示返回了 403, 已是最小并发量时连续多轮被 403 则返回 fatal
+func (ap *adaptiveParallel) throttle(forbidden bool) (fatal bool) {
+	if ap.throttled {
+		return false
+	}
+	if forbidden && ap.target <= ap.min {
+		ap.forbidden++
+		if ap.forbidden >= adaptiveMaxForbidden {
+			return true
+		}
+	}
+	ap.throttled = true
+	ap.probing = false
+	ap.hold = adaptiveHoldRounds
+	ap.target /= 2
+	if ap.target < ap.min {
+		ap.target = ap.min
+	}
+	return false
+}
+
+// running worker 是否占用连接, 包括正在建立连接和已暂停的
+func (wer *Worker) running() bool {
+	return !wer.parked && !wer.Completed() && !wer.Failed()
+}
+
+// numRunningWorkers 占用连接的 worker 数量
+func (mt *Monitor) numRunningWorkers() (num int) {
+	for _, worker := range mt.workers {
+		if worker.running() {
+			num++
+		}
+	}
+	return
+}
+
+// belowTarget 是否可以建立新连接, 未启用自适应并发量时不限制
+func (mt *Monitor) belowTarget() bool {
+	if mt.adaptive == nil {
+		return true
+	}
+	return mt.numRunningWorkers() < mt.adaptive.target
+}
+
+// handleThrottledWorkers 暂停被服务器限制的 worker 并降低目标并发量.
+// 已是最小并发量时仍连续多轮返回 403, 视为下载链接失效, 停止下载
+func (mt *Monitor) handleThrottledWorkers() {
+	for _, worker := range mt.workers {
+		if worker.Parked() || worker.status.statusCode != StatusCodeTooManyConnections {
+			continue
+		}
+		if mt.adaptive.throttle(worker.err == ErrWorkerForbidden) {
+			worker.status.SetStatusCode(StatusCodeInternalError)
+			return
+		}
+
+		worker.parked = true
+		pcsverbose.Verbosef("MONITOR: worker[%d] throttled: %s, parallel: %d\n", worker.ID(), worker.Err(), mt.adaptive.target)
+	}
+}
+
+// parkExcessWorkers 占用连接的 worker 超过目标并发量时, 暂停速度最慢的
+func (mt *Monitor) parkExcessWorkers() {
+	for excess := mt.numRunningWorkers() - mt.adaptive.target; excess > 0; excess-- {
+		var slowest *Worker
+		for _, worker := range mt.workers {
+			if !worker.running() {
+				continue
+			}
+			if slowest == nil || worker.GetSpeedsPerSecond() < slowest.GetSpeedsPerSecond() {
+				slowest = worker
+			}
+		}
+		if slowest == nil {
+			return
+		}
+		pcsverbose.Verbosef("MONITOR: worker[%d] parked, parallel: %d\n", slowest.ID(), mt.adaptive.target)
+		slowest.Park()
+	}
+}
+
+// fillAdaptiveWorkers 占用连接的 worker 少于目标并发量时, 优先恢复已暂停的 worker,
+// 其次为空闲的 worker 分配新的 range, 没有空闲的 worker 时创建.
+// 本轮被服务器限制后不再建立连接
+func (mt *Monitor) fillAdaptiveWorkers() {
+	// 先处理刚被限制的 worker, 避免不断建立新连接
+	mt.handleThrottledWorkers()
+	if mt.adaptive.throttled {
+		return
+	}
+
+	for mt.belowTarget() && mt.resetController.CanReset() {
+		if worker := mt.getParkedWorker(); worker != nil {
+			worker.parked = false
+			worker.ClearStatus()
+			mt.resetController.AddResetNum()
+			pcsverbose.Verbosef("MONITOR: worker[%d] resumed, parallel: %d\n", worker.ID(), mt.adaptive.target)
+			go worker.Execute()
+			continue
+		}
+
+		availableWorker := mt.GetAvailableWorker()
+		if availableWorker == nil {
+			availableWorker = mt.newWorker()
+			if availableWorker == nil {
+				return
+			}
+		}
+		if !mt.assignRange(availableWorker) {
+			return
+		}
+	}
+}
+
+func (mt *Monitor) getParkedWorker() *Worker {
+	for _, worker := range mt.workers {
+		if worker.Parked() {
+			return worker
+		}
+	}
+	return nil
+}
+
+// newWorker 创建空闲的 worker, 数量不超过最大并发量
+func (mt *Monitor) newWorker() *Worker {
+	if mt.newWorkerFunc == nil || len(mt.workers) >= mt.adaptive.max {
+		return nil
+	}
+	worker := mt.newWorkerFunc(len(mt.workers))
+	if worker == nil {
+		return nil
+	}
+	worker.SetRange(&transfer.Range{})
+	worker.SetDownloadStatus(mt.status)
+	worker.SetForbiddenAsThrottle(true)
+	worker.status.SetStatusCode(StatusCodeSucceeded) // 空闲
+	mt.Append(worker)
+	return worker
+}
+
+// assignRange 为空闲的 worker 分配未下载的 range, 没有则拆分剩余下载量最多的 worker
+func (mt *Monitor) assignRange(availableWorker *Worker) bool {
+	gen := mt.status.RangeListGen()
+	if gen != nil && !gen.IsDone() {
+		if _, r := gen.GenRange(); r != nil {
+			availableWorker.SetRange(r)
+			availableWorker.ClearStatus()
+			mt.resetController.AddResetNum()
+			pcsverbose.Verbosef("MONITOR: worker[%d] add new range: %s\n", availableWorker.ID(), r.ShowDetails())
+			go availableWorker.Execute()
+			return true
+		}
+	}
+
+	workers := mt.workers.Duplicate()
+	sort.Sort(ByLeftDesc{workers})
+	for _, worker := range workers {
+		if worker == availableWorker || !worker.running() {
+			continue
+		}
+		if mt.splitWorker(worker, availableWorker) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,125 @@
+package downloader
+
+import (
+	"testing"
+)
+
+func TestNewAdaptiveParallel(t *testing.T) {
+	for _, c := range []struct {
+		min, max, start  int
+		wantMin, wantMax int
+		wantTarget       int
+	}{
+		{1, 8, 4, 1, 8, 4},
+		{0, 0, 0, 1, 1, 1},
+		{4, 2, 1, 2, 2, 2},
+		{2, 8, 16, 2, 8, 8},
+	} {
+		ap := newAdaptiveParallel(c.min, c.max, c.start)
+		if ap.min != c.wantMin || ap.max != c.wantMax || ap.target != c.wantTarget {
+			t.Errorf("newAdaptiveParallel(%d, %d, %d) = %d, %d, %d", c.min, c.max, c.start, ap.min, ap.max, ap.target)
+		}
+	}
+}
+
+func TestAdaptiveParallelEvaluate(t *testing.T) {
+	ap := newAdaptiveParallel(1, 4, 2)
+
+	// 每轮增加一个连接
+	ap.evaluate(100)
+	if ap.target != 3 || !ap.probing {
+		t.Fatalf("target %d, probing %v", ap.target, ap.probing)
+	}
+
+	// 速度提升不足, 撤回并暂停增加
+	ap.evaluate(105)
+	if ap.target != 2 || ap.hold != adaptiveHoldRounds {
+		t.Fatalf("target %d, hold %d after no gain", ap.target, ap.hold)
+	}
+	for i := 0; i < adaptiveHoldRounds; i++ {
+		ap.evaluate(105)
+		if ap.target != 2 {
+			t.Fatalf("target %d during hold", ap.target)
+		}
+	}
+	ap.evaluate(105)
+	if ap.target != 3 {
+		t.Fatalf("target %d after hold", ap.target)
+	}
+
+	// 速度提升足够, 保留并继续增加, 不超过最大并发量
+	ap.evaluate(200)
+	if ap.target != 4 || ap.baseSpeeds != 200 {
+		t.Fatalf("target %d, base %d after gain", ap.target, ap.baseSpeeds)
+	}
+	ap.evaluate(400)
+	ap.evaluate(400)
+	if ap.target != 4 || ap.probing {
+		t.Errorf("target %d, probing %v at max", ap.target, ap.probing)
+	}
+}
+
+func TestAdaptiveParallelThrottle(t *testing.T) {
+	ap := newAdaptiveParallel(1, 8, 8)
+
+	// 被限制时减半, 同一轮内只减半一次
+	if ap.throttle(false) || ap.target != 4 {
+		t.Fatalf("target %d after throttle", ap.target)
+	}
+	ap.throttle(false)
+	if ap.target != 4 {
+		t.Errorf("target %d after throttled twice in a round", ap.target)
+	}
+
+	// 被限制的一轮不评估, 之后暂停增加连接
+	ap.evaluate(1000)
+	if ap.target != 4 || ap.throttled || ap.hold != adaptiveHoldRounds {
+		t.Errorf("target %d, throttled %v, hold %d", ap.target, ap.throttled, ap.hold)
+	}
+
+	// 不低于最小并发量
+	for i := 0; i < 5; i++ {
+		ap.throttle(false)
+		ap.evaluate(0)
+	}
+	if ap.target != 1 {
+		t.Errorf("target %d below min", ap.target)
+	}
+}
+
+func TestAdaptiveParallelForbidden(t *testing.T) {
+	ap := newAdaptiveParallel(1, 4, 1)
+
+	// 已是最小并发量时连续多轮被 403, 视为下载链接失效
+	for i := 1; i < adaptiveMaxForbidden; i++ {
+		if ap.throttle(true) {
+			t.Fatalf("fatal after %d rounds", i)
+		}
+		ap.evaluate(0)
+	}
+	if !ap.throttle(true) {
+		t.Error("not fatal after max forbidden rounds")
+	}
+
+	// 期间有数据下载则重新计数
+	ap = newAdaptiveParallel(1, 1, 1)
+	for i := 0; i < adaptiveMaxForbidden*2; i++ {
+		if ap.throttle(true) {
+			t.Fatalf("fatal at round %d with progress", i)
+		}
+		ap.evaluate(0)
+		ap.evaluate(100)
+	}
+
+	// 并发量大于最小值时, 403 只减半
+	ap = newAdaptiveParallel(1, 4, 4)
+	for i := 0; i < adaptiveMaxForbidden; i++ {
+		if ap.throttle(true) {
+			t.Fatalf("fatal at target %d", ap.target)
+		}
+		ap.evaluate(0)
+	}
+	if ap.forbidden != 1 {
+		t.Errorf("forbidden rounds %d", ap.forbidden)
+	}
+}
@@ -19,9 +19,12 @@ var (
 type Config struct {
 	Mode                       transfer.RangeGenMode      // 下载Range分配模式
 	MaxParallel                int                        // 最大下载并发量
+	Adaptive                   bool                       // 根据实测的速度自动调整并发量, 以 MaxParallel 为上限
+	MinParallel                int                        // 自适应并发量的下限, 也是初始的并发量
 	CacheSize                  int                        // 下载缓冲
 	BlockSize                  int64                      // 每个Range区块的大小, RangeGenMode 为 RangeGenMode2 时才有效
 	MaxRate                    int64                      // 限制最大下载速度
+	MaxRateFunc                func() int64               // 按时间段限速, 非空时代替 MaxRate, 下载过程中每秒更新
 	InstanceStateStorageFormat InstanceStateStorageFormat // 断点续传储存类型
 	InstanceStatePath          string                     // 断点续传信息路径
 	IsTest                     bool                       // 是否测试下载
@@ -45,6 +45,7 @@ type (
 		executeTime             time.Time
 		durl                    string
 		loadBalansers           []string
+		loadBalancers           *LoadBalancerResponseList // 检测通过的下载服务器
 		writer                  io.WriterAt
 		client                  *requester.HTTPClient
 		config                  *Config
@@ -205,6 +206,7 @@ func DefaultDURLCheckFunc(client *requester.HTTPClient, durl string) (contentLen
 func (der *Downloader) checkLoadBalancers() *LoadBalancerResponseList {
 	var (
 		loadBalancerResponses = make([]*LoadBalancerResponse, 0, len(der.loadBalansers)+1)
+		loadBalancerMu        sync.Mutex
 		handleLoadBalancer    = func(req *http.Request) {
 			if req == nil {
 				return
@@ -219,7 +221,9 @@ func (der *Downloader) checkLoadBalancers() *LoadBalancerResponseList {
 				Referer: req.Referer(),
 			}
 
+			loadBalancerMu.Lock()
 			loadBalancerResponses = append(loadBalancerResponses, loadBalancer)
+			loadBalancerMu.Unlock()
 			pcsverbose.Verbosef("DEBUG: load balance task: URL: %s, Referer: %s\n", loadBalancer.URL, loadBalancer.Referer)
 		}
 	)
@@ -229,11 +233,11 @@ func (der *Downloader) checkLoadBalancers() *LoadBalancerResponseList {
 		URL: der.durl,
 	})
 
-	// 多下载服务器的负载均衡, 在本项目中无意义，因为百度只用单下载服务器
+	// 多下载服务器的负载均衡, 检测其他的下载链接是否可用且与主服务器一致
 	wg := waitgroup.NewWaitGroup(4)
 	privTimeout := der.client.Client.Timeout
 	der.client.SetTimeout(5 * time.Second)
-	for _, loadBalanser := range der.loadBalansers { // 这里服务器列表数量为0，所以逻辑实际未生效
+	for _, loadBalanser := range der.loadBalansers {
 		wg.AddDelta()
 		go func(loadBalanser string) {
 			defer wg.Done()
@@ -365,7 +369,11 @@ func (der *Downloader) Execute() error {
 	}
 
 	// 设置限速
-	if der.config.MaxRate > 0 {
+	if der.config.MaxRateFunc != nil {
+		rl := speeds.NewDynamicRateLimit(der.config.MaxRateFunc)
+		status.SetRateLimit(rl)
+		defer rl.Stop()
+	} else if der.config.MaxRate > 0 {
 		rl := speeds.NewRateLimit(der.config.MaxRate)
 		status.SetRateLimit(rl)
 		defer rl.Stop()
@@ -373,7 +381,31 @@ func (der *Downloader) Execute() error {
 
 	// 数据处理
 	parallel := der.SelectParallel(single, der.config.MaxParallel, status.TotalSize(), bii.Ranges) // 实际的下载并行量
-	blockSize, err := der.SelectBlockSizeAndInitRangeGen(single, status, parallel)                 // 实际的BlockSize
+
+	// 自适应并发量, 以 MinParallel 开始, 不超过 MaxParallel 和断点信息中的 range 数量中较大的一个
+	var (
+		adaptive         = der.config.Adaptive && !single
+		adaptiveMax      = parallel
+		adaptiveParallel = parallel
+	)
+	if adaptive {
+		if maxParallel := der.SelectParallel(single, der.config.MaxParallel, status.TotalSize(), nil); maxParallel > adaptiveMax {
+			adaptiveMax = maxParallel
+		}
+		adaptiveParallel = der.config.MinParallel
+		if adaptiveParallel < 1 {
+			adaptiveParallel = 1
+		}
+		if adaptiveParallel > adaptiveMax {
+			adaptiveParallel = adaptiveMax
+		}
+		adaptive = adaptiveMax > 1
+		if adaptive && len(bii.Ranges) == 0 {
+			// 新的下载, 只分配初始并发量的 range
+			parallel = adaptiveParallel
+		}
+	}
+	blockSize, err := der.SelectBlockSizeAndInitRangeGen(single, status, parallel) // 实际的BlockSize
 	if err != nil {
 		return err
 	}
@@ -418,35 +450,49 @@ func (der *Downloader) Execute() error {
 	}
 
 	var (
-		writeMu = &sync.Mutex{}
+		writeMu   = &sync.Mutex{}
+		newWorker = func(id int) *Worker {
+			loadBalancer := loadBalancerResponseList.SequentialGet()
+			if loadBalancer == nil {
+				return nil
+			}
+
+			worker := NewWorker(id, loadBalancer.URL, writer)
+			worker.SetClient(der.client)
+			worker.SetWriteMutex(writeMu)
+			worker.SetLoadBalancer(loadBalancer, loadBalancerResponseList)
+			worker.SetTotalSize(der.firstInfo.ContentLength)
+			worker.SetAcceptRange(der.firstInfo.AcceptRanges)
+			return worker
+		}
 	)
 	for k, r := range bii.Ranges {
-		loadBalancer := loadBalancerResponseList.SequentialGet()
-		if loadBalancer == nil {
+		worker := newWorker(k)
+		if worker == nil {
 			continue
 		}
 
-		worker := NewWorker(k, loadBalancer.URL, writer)
-		worker.SetClient(der.client)
-		worker.SetWriteMutex(writeMu)
-		worker.SetReferer(loadBalancer.Referer)
-		worker.SetTotalSize(der.firstInfo.ContentLength)
-
 		// 使用第一个连接
 		// 断点续传时不使用
 		if k == 0 && !isInstance {
 			worker.firstResp = resp
 		}
 
-		worker.SetAcceptRange(der.firstInfo.AcceptRanges)
 		worker.SetRange(r) // 分配Range
 		der.monitor.Append(worker)
 	}
 
 	der.monitor.SetStatus(status)
+	der.monitor.SetLoadBalancers(loadBalancerResponseList)
+	der.loadBalancers = loadBalancerResponseList
+	status.SetParallel(parallel)
+	if adaptive {
+		der.monitor.SetAdaptive(adaptiveParallel, adaptiveMax, adaptiveParallel, newWorker)
+		pcsverbose.Verbosef("DEBUG: download task adaptive parallel: %d ~ %d\n", adaptiveParallel, adaptiveMax)
+	}
 
 	// 服务器不支持断点续传, 或者单线程下载, 都不重载worker
-	der.monitor.SetReloadWorker(parallel > 1)
+	der.monitor.SetReloadWorker(parallel > 1 || adaptive)
 
 	moniterCtx, moniterCancelFunc := context.WithCancel(context.Background())
 	der.monitorCancelFunc = moniterCancelFunc
@@ -1,8 +1,22 @@
 package downloader
 
 import (
+	"github.com/qjfoidnh/BaiduPCS-Go/requester/rio/speeds"
 	"net/http"
+	"net/url"
+	"sort"
+	"sync"
 	"sync/atomic"
+	"time"
+)
+
+const (
+	// hostDemoteRatio 得分低于最佳服务器的该比例时降级, 其上的连接转移到最佳服务器
+	hostDemoteRatio = 0.5
+	// hostEWMAWeight 延迟和速度的指数移动平均中, 新样本的权重
+	hostEWMAWeight = 0.3
+	// hostMinTransferSize 连接结束时, 读取的数据量超过该值才记录速度
+	hostMinTransferSize = 256 * 1024
 )
 
 type (
@@ -10,6 +24,31 @@ type (
 	LoadBalancerResponse struct {
 		URL     string
 		Referer string
+
+		health hostHealth
+	}
+
+	// hostHealth 下载过程中统计的服务器状态
+	hostHealth struct {
+		mu          sync.Mutex
+		requests    int64         // 请求数
+		failures    int64         // 失败数, 包括请求失败和读取数据失败
+		latency     time.Duration // 响应延迟的移动平均
+		speeds      int64         // 单个连接速度的移动平均
+		connections int           // 正在下载的连接数
+		speedsStat  speeds.Speeds
+	}
+
+	// HostStat 下载服务器的统计信息
+	HostStat struct {
+		Host        string
+		Requests    int64
+		Failures    int64
+		Latency     time.Duration
+		Speeds      int64 // 单个连接的平均速度
+		Connections int
+		Score       float64
+		Demoted     bool // 已降级
 	}
 
 	// LoadBalancerResponseList 负载均衡列表
@@ -43,16 +82,204 @@ func (lbrl *LoadBalancerResponseList) SequentialGet() *LoadBalancerResponse {
 	return lbr
 }
 
+// Len 返回服务器数量
+func (lbrl *LoadBalancerResponseList) Len() int {
+	if lbrl == nil {
+		return 0
+	}
+	return len(lbrl.lbr)
+}
+
+// best 返回得分最高的服务器, 只考虑已有足够统计数据的
+func (lbrl *LoadBalancerResponseList) best() (best *LoadBalancerResponse, bestScore float64) {
+	for _, lbr := range lbrl.lbr {
+		if !lbr.sampled() {
+			continue
+		}
+		if score := lbr.score(); best == nil || score > bestScore {
+			best, bestScore = lbr, score
+		}
+	}
+	return
+}
+
+// IsDemoted 服务器是否已降级, 即得分远低于最佳服务器
+func (lbrl *LoadBalancerResponseList) IsDemoted(lbr *LoadBalancerResponse) bool {
+	if lbrl.Len() < 2 || lbr == nil || !lbr.sampled() {
+		return false
+	}
+	best, bestScore := lbrl.best()
+	if best == nil || best == lbr {
+		return false
+	}
+	return lbr.score() < bestScore*hostDemoteRatio
+}
+
+// HealthyGet 当前服务器已降级时返回最佳服务器, 否则返回当前服务器
+func (lbrl *LoadBalancerResponseList) HealthyGet(current *LoadBalancerResponse) *LoadBalancerResponse {
+	if current != nil && !lbrl.IsDemoted(current) {
+		return current
+	}
+	if best, _ := lbrl.best(); best != nil {
+		return best
+	}
+	if current != nil {
+		return current
+	}
+	return lbrl.SequentialGet()
+}
+
+// UpdateSpeeds 更新各个服务器的单个连接速度, 由监控器定时调用
+func (lbrl *LoadBalancerResponseList) UpdateSpeeds() {
+	for _, lbr := range lbrl.lbr {
+		lbr.updateSpeeds()
+	}
+}
+
+// Stats 返回各个服务器的统计信息, 按得分降序
+func (lbrl *LoadBalancerResponseList) Stats() []HostStat {
+	if lbrl == nil {
+		return nil
+	}
+	stats := make([]HostStat, 0, len(lbrl.lbr))
+	for _, lbr := range lbrl.lbr {
+		stat := lbr.Stat()
+		stat.Demoted = lbrl.IsDemoted(lbr)
+		stats = append(stats, stat)
+	}
+	sort.SliceStable(stats, func(i, j int) bool {
+		return stats[i].Score > stats[j].Score
+	})
+	return stats
+}
+
 // RandomGet 随机获取
 func (lbrl *LoadBalancerResponseList) RandomGet() *LoadBalancerResponse {
 	return lbrl.lbr[RandomNumber(0, len(lbrl.lbr))]
 }
 
+// Host 返回服务器的主机名
+func (lbr *LoadBalancerResponse) Host() string {
+	u, err := url.Parse(lbr.URL)
+	if err != nil {
+		return lbr.URL
+	}
+	return u.Host
+}
+
+// recordRequest 记录一次请求的结果和响应延迟
+func (lbr *LoadBalancerResponse) recordRequest(latency time.Duration, ok bool) {
+	h := &lbr.health
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.requests++
+	if !ok {
+		h.failures++
+		return
+	}
+	if h.latency == 0 {
+		h.latency = latency
+		return
+	}
+	h.latency = time.Duration(float64(h.latency)*(1-hostEWMAWeight) + float64(latency)*hostEWMAWeight)
+}
+
+// recordFailure 记录读取数据失败
+func (lbr *LoadBalancerResponse) recordFailure() {
+	lbr.health.mu.Lock()
+	lbr.health.failures++
+	lbr.health.mu.Unlock()
+}
+
+// addConnections 增减正在下载的连接数
+func (lbr *LoadBalancerResponse) addConnections(delta int) {
+	lbr.health.mu.Lock()
+	lbr.health.connections += delta
+	lbr.health.mu.Unlock()
+}
+
+// addDownloaded 统计下载的数据量
+func (lbr *LoadBalancerResponse) addDownloaded(n int64) {
+	lbr.health.speedsStat.Add(n)
+}
+
+// recordTransfer 连接结束时, 记录该连接的平均速度, 数据量太少时忽略
+func (lbr *LoadBalancerResponse) recordTransfer(n int64, elapsed time.Duration) {
+	if n < hostMinTransferSize || elapsed <= 0 {
+		return
+	}
+	lbr.health.mu.Lock()
+	defer lbr.health.mu.Unlock()
+	lbr.health.addSpeeds(int64(float64(n) / elapsed.Seconds()))
+}
+
+// addSpeeds 加入单个连接速度的样本
+func (h *hostHealth) addSpeeds(sps int64) {
+	if h.speeds == 0 {
+		h.speeds = sps
+		return
+	}
+	h.speeds = int64(float64(h.speeds)*(1-hostEWMAWeight) + float64(sps)*hostEWMAWeight)
+}
+
+// updateSpeeds 计算单个连接的速度, 没有连接时保留上次的结果
+func (lbr *LoadBalancerResponse) updateSpeeds() {
+	h := &lbr.health
+	sps := h.speedsStat.GetSpeeds()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.connections <= 0 {
+		return
+	}
+	h.addSpeeds(sps / int64(h.connections))
+}
+
+// sampled 是否已有足够的统计数据用于比较, 即已测得速度或者有失败记录
+func (lbr *LoadBalancerResponse) sampled() bool {
+	lbr.health.mu.Lock()
+	defer lbr.health.mu.Unlock()
+	return lbr.health.requests > 0 && (lbr.health.speeds > 0 || lbr.health.failures > 0)
+}
+
+// score 服务器得分, 成功率越高, 速度越快, 延迟越低, 得分越高
+func (lbr *LoadBalancerResponse) score() float64 {
+	h := &lbr.health
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	successRate := float64(h.requests-h.failures+1) / float64(h.requests+2)
+	if successRate < 0 {
+		successRate = 0
+	}
+	return successRate * float64(h.speeds+1) / (1 + h.latency.Seconds())
+}
+
+// Stat 返回服务器的统计信息
+func (lbr *LoadBalancerResponse) Stat() HostStat {
+	score := lbr.score()
+	h := &lbr.health
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return HostStat{
+		Host:        lbr.Host(),
+		Requests:    h.requests,
+		Failures:    h.failures,
+		Latency:     h.latency,
+		Speeds:      h.speeds,
+		Connections: h.connections,
+		Score:       score,
+	}
+}
+
 // AddLoadBalanceServer 增加负载均衡服务器
 func (der *Downloader) AddLoadBalanceServer(urls ...string) {
 	der.loadBalansers = append(der.loadBalansers, urls...)
 }
 
+// HostStats 返回各个下载服务器的统计信息, 下载开始前返回 nil
+func (der *Downloader) HostStats() []HostStat {
+	return der.loadBalancers.Stats()
+}
+
 // DefaultLoadBalancerCompareFunc 检测负载均衡的服务器是否一致
 func DefaultLoadBalancerCompareFunc(info map[string]string, subResp *http.Response) bool {
 	if info == nil || subResp == nil {
@@ -0,0 +1,109 @@
+package downloader
+
+import (
+	"testing"
+	"time"
+)
+
+// newTestHost 返回已测得速度的服务器
+func newTestHost(url string, sps int64, latency time.Duration) *LoadBalancerResponse {
+	lbr := &LoadBalancerResponse{URL: url}
+	lbr.recordRequest(latency, true)
+	lbr.health.addSpeeds(sps)
+	return lbr
+}
+
+func TestLoadBalancerDemote(t *testing.T) {
+	var (
+		fast  = newTestHost("https://fast.example.com/f", 1000, 100*time.Millisecond)
+		slow  = newTestHost("https://slow.example.com/f", 300, 100*time.Millisecond)
+		fresh = &LoadBalancerResponse{URL: "https://fresh.example.com/f"}
+		hosts = NewLoadBalancerResponseList([]*LoadBalancerResponse{slow, fast, fresh})
+	)
+
+	// 得分低于最佳服务器的一半时降级, 没有统计数据的不降级
+	if !hosts.IsDemoted(slow) || hosts.IsDemoted(fast) || hosts.IsDemoted(fresh) {
+		t.Errorf("demoted: slow %v, fast %v, fresh %v", hosts.IsDemoted(slow), hosts.IsDemoted(fast), hosts.IsDemoted(fresh))
+	}
+	if got := hosts.HealthyGet(slow); got != fast {
+		t.Errorf("healthy for slow: %s", got.Host())
+	}
+	if got := hosts.HealthyGet(fresh); got != fresh {
+		t.Errorf("healthy for fresh: %s", got.Host())
+	}
+	if got := hosts.HealthyGet(nil); got != fast {
+		t.Errorf("healthy for nil: %s", got.Host())
+	}
+
+	// 差距不足一半时不降级
+	slow.health.speeds = 600
+	if hosts.IsDemoted(slow) {
+		t.Error("slow demoted within ratio")
+	}
+
+	// 只有一个服务器时不降级
+	single := NewLoadBalancerResponseList([]*LoadBalancerResponse{slow})
+	slow.health.speeds = 1
+	if single.IsDemoted(slow) || single.HealthyGet(slow) != slow {
+		t.Error("single host demoted")
+	}
+
+	// 按得分降序
+	stats := hosts.Stats()
+	if len(stats) != 3 || stats[0].Host != "fast.example.com" || stats[0].Demoted ||
+		stats[1].Host != "slow.example.com" || !stats[1].Demoted || stats[2].Host != "fresh.example.com" || stats[2].Demoted {
+		t.Errorf("stats %+v", stats)
+	}
+}
+
+func TestLoadBalancerScore(t *testing.T) {
+	var (
+		a = newTestHost("https://a.example.com/f", 1000, 100*time.Millisecond)
+		b = newTestHost("https://b.example.com/f", 1000, 100*time.Millisecond)
+		c = newTestHost("https://c.example.com/f", 1000, 2*time.Second)
+	)
+
+	// 失败越多, 延迟越高, 得分越低
+	for i := 0; i < 3; i++ {
+		b.recordRequest(0, false)
+	}
+	if a.score() <= b.score() || a.score() <= c.score() {
+		t.Errorf("scores: a %v, b %v, c %v", a.score(), b.score(), c.score())
+	}
+
+	// 只有失败记录的服务器也参与比较
+	failed := &LoadBalancerResponse{URL: "https://failed.example.com/f"}
+	failed.recordRequest(0, false)
+	hosts := NewLoadBalancerResponseList([]*LoadBalancerResponse{a, failed})
+	if !hosts.IsDemoted(failed) {
+		t.Error("failed host not demoted")
+	}
+}
+
+func TestLoadBalancerHealth(t *testing.T) {
+	lbr := &LoadBalancerResponse{URL: "https://a.example.com/f"}
+
+	// 延迟取移动平均
+	lbr.recordRequest(100*time.Millisecond, true)
+	lbr.recordRequest(200*time.Millisecond, true)
+	if stat := lbr.Stat(); stat.Latency != 130*time.Millisecond || stat.Requests != 2 {
+		t.Errorf("latency %s, requests %d", stat.Latency, stat.Requests)
+	}
+
+	// 数据量太少的连接不记录速度
+	lbr.recordTransfer(hostMinTransferSize-1, time.Second)
+	if lbr.sampled() {
+		t.Error("sampled by small transfer")
+	}
+	lbr.recordTransfer(hostMinTransferSize*2, 2*time.Second)
+	if stat := lbr.Stat(); stat.Speeds != hostMinTransferSize || !lbr.sampled() {
+		t.Errorf("speeds %d", stat.Speeds)
+	}
+
+	// 读取数据失败计入失败数, 没有连接时不更新速度
+	lbr.recordFailure()
+	lbr.updateSpeeds()
+	if stat := lbr.Stat(); stat.Failures != 1 || stat.Speeds != hostMinTransferSize {
+		t.Errorf("failures %d, speeds %d", stat.Failures, stat.Speeds)
+	}
+}
@@ -3,9 +3,14 @@ package downloader
 import (
 	"context"
 	"errors"
+	"fmt"
+	"github.com/qjfoidnh/BaiduPCS-Go/pcstable"
 	"github.com/qjfoidnh/BaiduPCS-Go/pcsverbose"
 	"github.com/qjfoidnh/BaiduPCS-Go/requester/transfer"
 	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
@@ -24,11 +29,22 @@ type (
 		err             error
 		resetController *ResetController
 		isReloadWorker  bool //是否重载worker, 单线程模式不重载
+		workersMu       sync.RWMutex
+
+		// 下载服务器, 多于一个时根据统计的状态转移连接
+		hosts *LoadBalancerResponseList
+
+		// 自适应并发量, 为空则不启用
+		adaptive      *adaptiveParallel
+		newWorkerFunc NewWorkerFunc
 
 		// 临时变量
 		lastAvaliableIndex int
 	}
 
+	// NewWorkerFunc 创建新的worker, 用于自适应并发量增加连接
+	NewWorkerFunc func(id int) *Worker
+
 	// RangeWorkerFunc 遍历workers的函数
 	RangeWorkerFunc func(key int, worker *Worker) bool
 )
@@ -61,7 +77,36 @@ func (mt *Monitor) Append(worker *Worker) {
 	if worker == nil {
 		return
 	}
+	mt.workersMu.Lock()
 	mt.workers = append(mt.workers, worker)
+	mt.workersMu.Unlock()
+}
+
+// SetAdaptive 启用自适应并发量, 以 start 个连接开始, 在 [min, max] 之间调整,
+// newWorkerFunc 用于连接数超过已有的 worker 数量时创建新的 worker
+func (mt *Monitor) SetAdaptive(min, max, start int, newWorkerFunc NewWorkerFunc) {
+	mt.adaptive = newAdaptiveParallel(min, max, start)
+	mt.newWorkerFunc = newWorkerFunc
+}
+
+// SetLoadBalancers 设置下载服务器列表
+func (mt *Monitor) SetLoadBalancers(hosts *LoadBalancerResponseList) {
+	mt.hosts = hosts
+}
+
+// Parallel 返回当前的目标并发量, 未启用自适应并发量时返回 worker 数量
+func (mt *Monitor) Parallel() int {
+	if mt.adaptive != nil {
+		return mt.adaptive.target
+	}
+	return len(mt.workerList())
+}
+
+// workerList 返回 workers 的快照, 用于在监控协程以外遍历
+func (mt *Monitor) workerList() WorkerList {
+	mt.workersMu.RLock()
+	defer mt.workersMu.RUnlock()
+	return mt.workers
 }
 
 //SetWorkers 设置workers, 此操作会覆盖原有的workers
@@ -152,7 +197,7 @@ func (mt *Monitor) IsLeftWorkersAllFailed() bool {
 func (mt *Monitor) registerAllCompleted() {
 	mt.completed = make(chan struct{}, 0)
 	var (
-		workerNum   = len(mt.workers)
+		workerNum   int
 		completeNum = 0
 	)
 
@@ -160,8 +205,11 @@ func (mt *Monitor) registerAllCompleted() {
 		for {
 			time.Sleep(1 * time.Second)
 
+			// 自适应并发量会增加 worker
+			workers := mt.workerList()
+			workerNum = len(workers)
 			completeNum = 0
-			for _, worker := range mt.workers {
+			for _, worker := range workers {
 				switch worker.GetStatus().StatusCode() {
 				case StatusCodeInternalError:
 					// 检测到内部错误
@@ -190,6 +238,9 @@ func (mt *Monitor) ResetFailedAndNetErrorWorkers() {
 		if !mt.resetController.CanReset() {
 			continue
 		}
+		if mt.workers[k].Parked() || !mt.belowTarget() {
+			continue
+		}
 
 		switch mt.workers[k].GetStatus().StatusCode() {
 		case StatusCodeNetError:
@@ -210,24 +261,43 @@ func (mt *Monitor) ResetFailedAndNetErrorWorkers() {
 
 //RangeWorker 遍历worker
 func (mt *Monitor) RangeWorker(f RangeWorkerFunc) {
-	for k := range mt.workers {
-		if !f(k, mt.workers[k]) {
+	workers := mt.workerList()
+	for k := range workers {
+		if !f(k, workers[k]) {
 			break
 		}
 	}
 }
 
+// ShowWorkers 返回所有worker的状态, 用于调试
+func (mt *Monitor) ShowWorkers() string {
+	var (
+		builder = &strings.Builder{}
+		tb      = pcstable.NewTable(builder)
+	)
+	tb.SetHeader([]string{"#", "status", "range", "left", "speeds", "error"})
+	mt.RangeWorker(func(key int, worker *Worker) bool {
+		wrange := worker.GetRange()
+		tb.Append([]string{fmt.Sprint(worker.ID()), worker.GetStatus().StatusText(), wrange.ShowDetails(), strconv.FormatInt(wrange.Len(), 10), strconv.FormatInt(worker.GetSpeedsPerSecond(), 10), fmt.Sprint(worker.Err())})
+		return true
+	})
+	tb.Render()
+	return "\n" + builder.String()
+}
+
 //Pause 暂停所有的下载
 func (mt *Monitor) Pause() {
-	for k := range mt.workers {
-		mt.workers[k].Pause()
+	workers := mt.workerList()
+	for k := range workers {
+		workers[k].Pause()
 	}
 }
 
 //Resume 恢复所有的下载
 func (mt *Monitor) Resume() {
-	for k := range mt.workers {
-		mt.workers[k].Resume()
+	workers := mt.workerList()
+	for k := range workers {
+		workers[k].Resume()
 	}
 }
 
@@ -241,7 +311,7 @@ func (mt *Monitor) TryAddNewWork() {
 		return
 	}
 
-	if !mt.resetController.CanReset() { //能否建立新连接
+	if !mt.resetController.CanReset() || !mt.belowTarget() { //能否建立新连接
 		return
 	}
 
@@ -267,14 +337,7 @@ func (mt *Monitor) TryAddNewWork() {
 
 // DynamicSplitWorker 动态分配线程
 func (mt *Monitor) DynamicSplitWorker(worker *Worker) {
-	if !mt.resetController.CanReset() {
-		return
-	}
-
-	switch worker.status.statusCode {
-	case StatusCodeDownloading, StatusCodeFailed, StatusCodeNetError:
-	//pass
-	default:
+	if !mt.resetController.CanReset() || !mt.belowTarget() {
 		return
 	}
 
@@ -283,6 +346,17 @@ func (mt *Monitor) DynamicSplitWorker(worker *Worker) {
 	if availableWorker == nil || worker == availableWorker { // 没有空的
 		return
 	}
+	mt.splitWorker(worker, availableWorker)
+}
+
+// splitWorker 将 worker 剩余的范围折半, 后一半分配给空闲的 availableWorker
+func (mt *Monitor) splitWorker(worker, availableWorker *Worker) bool {
+	switch worker.status.statusCode {
+	case StatusCodeDownloading, StatusCodeFailed, StatusCodeNetError:
+	//pass
+	default:
+		return false
+	}
 
 	workerRange := worker.GetRange()
 
@@ -290,7 +364,7 @@ func (mt *Monitor) DynamicSplitWorker(worker *Worker) {
 	middle := (workerRange.LoadBegin() + end) / 2
 
 	if end-middle < MinParallelSize/5 { // 如果线程剩余的下载量太少, 不分配空闲线程
-		return
+		return false
 	}
 
 	// 折半
@@ -304,6 +378,30 @@ func (mt *Monitor) DynamicSplitWorker(worker *Worker) {
 	mt.resetController.AddResetNum()
 	pcsverbose.Verbosef("MONITOR: worker duplicated: %d <- %d\n", availableWorker.ID(), worker.ID())
 	go availableWorker.Execute()
+	return true
+}
+
+// RebalanceHosts 更新下载服务器的状态, 重设已降级服务器上正在下载的 worker,
+// worker 重新建立连接时切换到最佳服务器
+func (mt *Monitor) RebalanceHosts() {
+	if mt.hosts.Len() < 2 {
+		return
+	}
+	mt.hosts.UpdateSpeeds()
+	for _, worker := range mt.workers {
+		if worker.host == nil || worker.status.statusCode != StatusCodeDownloading {
+			continue
+		}
+		if !mt.hosts.IsDemoted(worker.host) {
+			continue
+		}
+		if !mt.resetController.CanReset() {
+			return
+		}
+		mt.resetController.AddResetNum()
+		pcsverbose.Verbosef("MONITOR: worker[%d] host demoted: %s\n", worker.ID(), worker.host.Host())
+		worker.Reset()
+	}
 }
 
 // ResetWorker 重设长时间无响应, 和下载速度为 0 的 Worker
@@ -312,7 +410,7 @@ func (mt *Monitor) ResetWorker(worker *Worker) {
 		return
 	}
 
-	if worker.Completed() {
+	if worker.Completed() || worker.Parked() {
 		return
 	}
 
@@ -349,10 +447,25 @@ func (mt *Monitor) Execute(cancelCtx context.Context) {
 	}
 
 	mt.lazyInit()
-	for _, worker := range mt.workers {
+	for k, worker := range mt.workers {
 		worker.SetDownloadStatus(mt.status)
+		if mt.adaptive != nil {
+			worker.SetForbiddenAsThrottle(true)
+			// 超出初始并发量的 worker (来自断点信息), 等待恢复
+			if k >= mt.adaptive.target {
+				worker.Park()
+				continue
+			}
+		}
 		go worker.Execute()
 	}
+	if mt.adaptive != nil {
+		mt.status.SetParallel(mt.adaptive.target)
+	}
+	var (
+		lastDownloaded = mt.status.Downloaded()
+		lastEvaluate   = time.Now()
+	)
 
 	mt.registerAllCompleted() // 注册completed
 	ticker := time.NewTicker(990 * time.Millisecond)
@@ -387,12 +500,25 @@ func (mt *Monitor) Execute(cancelCtx context.Context) {
 				})
 			}
 
+			if mt.adaptive != nil {
+				mt.handleThrottledWorkers()
+				if elapsed := time.Since(lastEvaluate); elapsed >= AdaptiveInterval {
+					downloaded := mt.status.Downloaded()
+					mt.adaptive.evaluate(int64(float64(downloaded-lastDownloaded) / elapsed.Seconds()))
+					lastDownloaded, lastEvaluate = downloaded, time.Now()
+				}
+				mt.parkExcessWorkers()
+				mt.status.SetParallel(mt.adaptive.target)
+			}
 
 			// 不重载worker
 			if !mt.isReloadWorker {
 				continue
 			}
 
+			// 转移降级服务器上的连接
+			mt.RebalanceHosts()
+
 			// 更新maxSpeeds
 			mt.status.SetMaxSpeeds(mt.status.SpeedsPerSecond())
 
@@ -420,6 +546,11 @@ func (mt *Monitor) Execute(cancelCtx context.Context) {
 				}
 			} // end if 2
 		case <-ticker2.C:
+			if mt.adaptive != nil {
+				// 补足目标并发量
+				mt.fillAdaptiveWorkers()
+				continue
+			}
 			// 加入新range
 			mt.TryAddNewWork()
 		} //end select
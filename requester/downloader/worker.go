@@ -12,6 +12,12 @@ import (
 	"io"
 	"net/http"
 	"sync"
+	"time"
+)
+
+var (
+	// ErrWorkerForbidden 服务器返回 403
+	ErrWorkerForbidden = errors.New("403 Forbidden")
 )
 
 type (
@@ -30,6 +36,12 @@ type (
 		writeMu      *sync.Mutex
 		execMu       sync.Mutex
 
+		host  *LoadBalancerResponse     // 当前使用的下载服务器
+		hosts *LoadBalancerResponseList // 可选的下载服务器, 当前服务器降级时切换
+
+		forbiddenAsThrottle bool // 403 视为连接数太多, 由自适应并发量处理
+		parked              bool // 已被监控器暂停, 保留剩余的范围, 等待恢复
+
 		pauseChan              chan struct{}
 		workerCancelFunc       context.CancelFunc
 		resetFunc              context.CancelFunc
@@ -114,6 +126,25 @@ func (wer *Worker) SetReferer(referer string) {
 	wer.referer = referer
 }
 
+// SetLoadBalancer 设置下载服务器, 并统计其状态. 每次建立连接前,
+// 若当前服务器已在 hosts 中降级, 则切换到最佳服务器
+func (wer *Worker) SetLoadBalancer(host *LoadBalancerResponse, hosts *LoadBalancerResponseList) {
+	wer.host = host
+	wer.hosts = hosts
+	wer.url = host.URL
+	wer.referer = host.Referer
+}
+
+// Host 返回当前使用的下载服务器, 未设置时返回 nil
+func (wer *Worker) Host() *LoadBalancerResponse {
+	return wer.host
+}
+
+// SetForbiddenAsThrottle 设置是否将 403 视为连接数太多, 而不是内部错误
+func (wer *Worker) SetForbiddenAsThrottle(b bool) {
+	wer.forbiddenAsThrottle = b
+}
+
 // SetWriteMutex 设置数据写锁
 func (wer *Worker) SetWriteMutex(mu *sync.Mutex) {
 	wer.writeMu = mu
@@ -189,6 +220,25 @@ func (wer *Worker) Reset() {
 	go wer.Execute()
 }
 
+// Park 断开连接并停止下载, 保留剩余的范围, 之后由监控器恢复
+func (wer *Worker) Park() {
+	wer.parked = true
+	if wer.resetFunc == nil {
+		// 还未开始执行
+		wer.status.statusCode = StatusCodeReseted
+		return
+	}
+	wer.resetFunc()
+	if wer.readRespBodyCancelFunc != nil {
+		wer.readRespBodyCancelFunc()
+	}
+}
+
+// Parked 是否已被暂停, 等待恢复
+func (wer *Worker) Parked() bool {
+	return wer.parked
+}
+
 // Canceled 是否已经取消
 func (wer *Worker) Canceled() bool {
 	return wer.status.statusCode == StatusCodeCanceled
@@ -255,6 +305,14 @@ func (wer *Worker) Execute() {
 	resetCtx, resetFunc := context.WithCancel(context.Background())
 	wer.resetFunc = resetFunc
 
+	// 当前服务器已降级, 切换到最佳服务器, 第一个连接已建立, 不切换
+	if wer.hosts != nil && wer.firstResp == nil {
+		if host := wer.hosts.HealthyGet(wer.host); host != wer.host {
+			pcsverbose.Verbosef("DEBUG: worker[%d] switch host: %s -> %s\n", wer.id, wer.host.Host(), host.Host())
+			wer.SetLoadBalancer(host, wer.hosts)
+		}
+	}
+
 	header := map[string]string{}
 	if wer.referer != "" {
 		header["Referer"] = wer.referer
@@ -266,11 +324,18 @@ func (wer *Worker) Execute() {
 
 	wer.status.statusCode = StatusCodePending
 
-	var resp *http.Response
+	var (
+		resp     *http.Response
+		host     = wer.host
+		reqStart = time.Now()
+	)
 	if wer.firstResp != nil {
 		resp = wer.firstResp // 使用第一个连接
 	} else {
 		resp, wer.err = wer.client.Req(http.MethodGet, wer.url, nil, header)
+		if host != nil {
+			host.recordRequest(time.Since(reqStart), wer.err == nil && (resp.StatusCode == 200 || resp.StatusCode == 206))
+		}
 	}
 	if resp != nil {
 		defer func() {
@@ -290,9 +355,14 @@ func (wer *Worker) Execute() {
 	switch resp.StatusCode {
 	case 200, 206:
 		// do nothing, continue
-	case 416: //Requested Range Not Satisfiable
-		fallthrough
 	case 403: // Forbidden
+		if wer.forbiddenAsThrottle {
+			wer.status.SetStatusCode(StatusCodeTooManyConnections)
+			wer.err = ErrWorkerForbidden
+			return
+		}
+		fallthrough
+	case 416: //Requested Range Not Satisfiable
 		fallthrough
 	case 404: // file block not exists
 		wer.status.statusCode = StatusCodeInternalError
@@ -337,6 +407,16 @@ func (wer *Worker) Execute() {
 		}
 	}
 
+	var connRead int64 // 本次连接读取的数据量
+	if host != nil {
+		host.addConnections(1)
+		connStart := time.Now()
+		defer func() {
+			host.addConnections(-1)
+			host.recordTransfer(connRead, time.Since(connStart))
+		}()
+	}
+
 	var (
 		buf       = cachepool.SyncPool.Get().([]byte)
 		n, nn     int
@@ -371,6 +451,10 @@ func (wer *Worker) Execute() {
 					wer.downloadStatus.AddSpeedsDownloaded(nn64) // 限速在这里阻塞
 				}
 				wer.speedsStat.Add(nn64)
+				if host != nil {
+					host.addDownloaded(nn64)
+					connRead += nn64
+				}
 				n += nn
 			}
 
@@ -446,7 +530,10 @@ func (wer *Worker) Execute() {
 					}
 					return
 				default:
-					// 其他错误, 返回
+					// 其他错误, 返回, 主动断开的连接不计入服务器的失败数
+					if host != nil && resetCtx.Err() == nil && workerCancelCtx.Err() == nil {
+						host.recordFailure()
+					}
 					wer.status.statusCode = StatusCodeFailed
 					wer.err = readErr
 					return
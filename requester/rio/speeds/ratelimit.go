@@ -8,7 +8,9 @@ import (
 
 type (
 	RateLimit struct {
-		MaxRate int64
+		MaxRate int64 // 小于等于0代表不限制
+
+		maxRateFunc func() int64 // 每个周期更新 MaxRate, 用于按时间段限速
 
 		count           int64
 		interval        time.Duration
@@ -27,6 +29,24 @@ func NewRateLimit(maxRate int64) *RateLimit {
 	}
 }
 
+// NewDynamicRateLimit 每个周期调用 maxRateFunc 更新最大速度, 返回值小于等于0代表不限制
+func NewDynamicRateLimit(maxRateFunc func() int64) *RateLimit {
+	return &RateLimit{
+		MaxRate:     maxRateFunc(),
+		maxRateFunc: maxRateFunc,
+	}
+}
+
+// SetMaxRate 修改最大速度, 下一个周期生效
+func (rl *RateLimit) SetMaxRate(maxRate int64) {
+	atomic.StoreInt64(&rl.MaxRate, maxRate)
+}
+
+// GetMaxRate 返回当前的最大速度
+func (rl *RateLimit) GetMaxRate() int64 {
+	return atomic.LoadInt64(&rl.MaxRate)
+}
+
 func (rl *RateLimit) SetInterval(i time.Duration) {
 	if i <= 0 {
 		i = 1 * time.Second
@@ -66,6 +86,9 @@ func (rl *RateLimit) backService() {
 		for {
 			select {
 			case <-rl.ticker.C:
+				if rl.maxRateFunc != nil {
+					rl.SetMaxRate(rl.maxRateFunc())
+				}
 				rl.resetChan()
 				atomic.StoreInt64(&rl.count, 0)
 			case <-rl.closeChan:
@@ -78,7 +101,8 @@ func (rl *RateLimit) backService() {
 func (rl *RateLimit) Add(count int64) {
 	rl.backServiceOnce.Do(rl.backService)
 	for {
-		if atomic.LoadInt64(&rl.count) >= rl.MaxRate { // 超出最大限额
+		maxRate := rl.GetMaxRate()
+		if maxRate > 0 && atomic.LoadInt64(&rl.count) >= maxRate { // 超出最大限额
 			// 阻塞
 			<-rl.muChan
 			continue
@@ -3,6 +3,7 @@ package speeds_test
 import (
 	"fmt"
 	"github.com/qjfoidnh/BaiduPCS-Go/requester/rio/speeds"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -31,3 +32,26 @@ func TestRateLimit(t *testing.T) {
 	r.Stop()
 	time.Sleep(10e9)
 }
+
+func TestDynamicRateLimit(t *testing.T) {
+	var maxRate int64 // 0 不限制
+	r := speeds.NewDynamicRateLimit(func() int64 {
+		return atomic.LoadInt64(&maxRate)
+	})
+	r.SetInterval(100 * time.Millisecond)
+	defer r.Stop()
+
+	start := time.Now()
+	for i := 0; i < 10; i++ {
+		r.Add(100)
+	}
+	if since := time.Since(start); since > 50*time.Millisecond {
+		t.Fatalf("unlimited add blocked: %s", since)
+	}
+
+	atomic.StoreInt64(&maxRate, 100)
+	time.Sleep(150 * time.Millisecond) // 等待下一个周期更新
+	if r.GetMaxRate() != 100 {
+		t.Fatalf("max rate not updated: %d", r.GetMaxRate())
+	}
+}
@@ -15,6 +15,7 @@ type (
 		SpeedsPerSecond() int64
 		TimeElapsed() time.Duration // 已开始时间
 		TimeLeft() time.Duration    // 预计剩余时间, 负数代表未知
+		Parallel() int              // 当前的下载并发量, 0代表未知
 	}
 
 	//DownloadStatus 下载状态及统计信息
@@ -25,6 +26,7 @@ type (
 		maxSpeeds        int64         // 最大下载速度
 		tmpSpeeds        int64         // 缓存的速度
 		speedsStat       speeds.Speeds // 速度统计 (注意对齐)
+		parallel         int32         // 当前的下载并发量
 
 		startTime time.Time // 开始下载的时间
 
@@ -123,6 +125,16 @@ func (ds *DownloadStatus) TimeLeft() (left time.Duration) {
 	return
 }
 
+// SetParallel 设置当前的下载并发量, 原子操作
+func (ds *DownloadStatus) SetParallel(parallel int) {
+	atomic.StoreInt32(&ds.parallel, int32(parallel))
+}
+
+// Parallel 返回当前的下载并发量, 原子操作
+func (ds *DownloadStatus) Parallel() int {
+	return int(atomic.LoadInt32(&ds.parallel))
+}
+
 // RangeListGen 返回RangeListGen
 func (ds *DownloadStatus) RangeListGen() *RangeListGen {
 	return ds.gen
@@ -52,6 +52,8 @@ type (
 		BlockSize int64  // 上传分块
 		MaxRate   int64  // 限制最大上传速度
 		Policy    string // 文件重名策略
+
+		MaxRateFunc func() int64 // 按时间段限速, 非空时代替 MaxRate, 上传过程中每秒更新
 	}
 )
 
@@ -108,7 +110,10 @@ func (muer *MultiUploader) Execute() {
 	muer.check()
 	muer.lazyInit()
 	// 初始化限速
-	if muer.config.MaxRate > 0 {
+	if muer.config.MaxRateFunc != nil {
+		muer.rateLimit = speeds.NewDynamicRateLimit(muer.config.MaxRateFunc)
+		defer muer.rateLimit.Stop()
+	} else if muer.config.MaxRate > 0 {
 		muer.rateLimit = speeds.NewRateLimit(muer.config.MaxRate)
 		defer muer.rateLimit.Stop()
 	}
@@ -0,0 +1,337 @@
+package uploader
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/qjfoidnh/BaiduPCS-Go/pcsutil/converter"
+	"github.com/qjfoidnh/BaiduPCS-Go/requester/rio"
+	"github.com/qjfoidnh/BaiduPCS-Go/requester/rio/speeds"
+	"github.com/qjfoidnh/BaiduPCS-Go/requester/transfer"
+	"hash"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// DefaultStreamMaxRetry 流式上传单个分片的默认最大重试次数
+	DefaultStreamMaxRetry = 10
+)
+
+var (
+	// ErrStreamBlockChecksumMismatch 服务器返回的分片md5与本地计算的不一致
+	ErrStreamBlockChecksumMismatch = errors.New("服务器返回的分片md5与本地计算的不一致")
+	// ErrStreamTooManyBlocks 数据流超出分片数量上限
+	ErrStreamTooManyBlocks = errors.New("数据流超出分片数量上限, 需要设置更大的分片")
+)
+
+type (
+	// StreamUploaderConfig 流式上传配置
+	StreamUploaderConfig struct {
+		Parallel  int    // 上传并发量
+		BlockSize int64  // 上传分块, 数据流的总大小未知, 需要足够大, 避免分片数量超出限制
+		SpoolSize int    // 内存中最多缓存的分片数量, 包括正在上传的分片, 默认为 Parallel+1
+		MaxBlocks int    // 分片数量上限, 超出时立即失败, 0代表不限制
+		MaxRetry  int    // 单个分片上传失败的最大重试次数
+		MaxRate   int64  // 限制最大上传速度
+		Policy    string // 文件重名策略
+
+		MaxRateFunc func() int64 // 按时间段限速, 非空时代替 MaxRate, 上传过程中每秒更新
+	}
+
+	// StreamUploader 从数据流上传, 如标准输入.
+	// 数据流不能回退, 读取的分片缓存在内存中, 上传成功后才释放,
+	// 分片md5在读取时计算, 数据流结束后合并分片
+	StreamUploader struct {
+		onUploadStatusEvent UploadStatusFunc // 上传状态事件
+
+		multiUpload MultiUpload
+		reader      io.Reader
+		uploadid    string
+		config      *StreamUploaderConfig
+		targetPath  string
+		speedsStat  *speeds.Speeds
+		rateLimit   *speeds.RateLimit
+
+		contentMD5  hash.Hash
+		size        int64 // 已从数据流读取的数据量
+		uploaded    int64 // 已上传成功的分片的数据量
+		active      map[int]SplitUnit
+		activeMu    sync.Mutex
+		executeTime time.Time
+		finished    chan struct{}
+	}
+
+	// streamBlock 从数据流读取的分片
+	streamBlock struct {
+		id       int
+		offset   int64
+		buf      []byte
+		checksum string
+	}
+)
+
+// NewStreamUploader 初始化流式上传, uploadid 为 precreate 获取的上传id
+func NewStreamUploader(multiUpload MultiUpload, reader io.Reader, uploadid string, config *StreamUploaderConfig, targetPath string) *StreamUploader {
+	return &StreamUploader{
+		multiUpload: multiUpload,
+		reader:      reader,
+		uploadid:    uploadid,
+		config:      config,
+		targetPath:  targetPath,
+	}
+}
+
+func (su *StreamUploader) lazyInit() {
+	if su.config == nil {
+		su.config = &StreamUploaderConfig{}
+	}
+	if su.config.Parallel <= 0 {
+		su.config.Parallel = 4
+	}
+	if su.config.BlockSize <= 0 {
+		su.config.BlockSize = 16 * converter.MB
+	}
+	if su.config.SpoolSize < su.config.Parallel {
+		su.config.SpoolSize = su.config.Parallel + 1
+	}
+	if su.config.MaxRetry <= 0 {
+		su.config.MaxRetry = DefaultStreamMaxRetry
+	}
+	if su.speedsStat == nil {
+		su.speedsStat = &speeds.Speeds{}
+	}
+	su.contentMD5 = md5.New()
+	su.active = make(map[int]SplitUnit)
+	su.finished = make(chan struct{})
+}
+
+// OnUploadStatusEvent 设置上传状态事件, 状态中的总大小为已从数据流读取的数据量
+func (su *StreamUploader) OnUploadStatusEvent(f UploadStatusFunc) {
+	su.onUploadStatusEvent = f
+}
+
+// Size 返回已从数据流读取的数据量, 上传成功后即为文件大小
+func (su *StreamUploader) Size() int64 {
+	return atomic.LoadInt64(&su.size)
+}
+
+// MD5 返回数据流的md5, 上传成功后调用
+func (su *StreamUploader) MD5() string {
+	return hex.EncodeToString(su.contentMD5.Sum(nil))
+}
+
+// Execute 执行上传, 读取数据流直到结束, 再合并分片
+func (su *StreamUploader) Execute() (err error) {
+	su.lazyInit()
+	if su.config.MaxRateFunc != nil {
+		su.rateLimit = speeds.NewDynamicRateLimit(su.config.MaxRateFunc)
+		defer su.rateLimit.Stop()
+	} else if su.config.MaxRate > 0 {
+		su.rateLimit = speeds.NewRateLimit(su.config.MaxRate)
+		defer su.rateLimit.Stop()
+	}
+
+	originPCSHost, pcsError := su.multiUpload.Precreate()
+	if pcsError != nil {
+		return pcsError
+	}
+
+	su.executeTime = time.Now()
+	su.uploadStatusEvent()
+	defer close(su.finished)
+
+	var (
+		ctx, cancel = context.WithCancel(context.Background())
+		// spool 空闲的缓冲区, 限制内存中缓存的分片数量
+		spool       = make(chan []byte, su.config.SpoolSize)
+		blocks      = make(chan *streamBlock)
+		checksumMap = make(map[int]string)
+		mu          sync.Mutex
+		wg          sync.WaitGroup
+		uperr       error
+		errOnce     sync.Once
+	)
+	defer cancel()
+	for i := 0; i < su.config.SpoolSize; i++ {
+		spool <- nil // 按需分配
+	}
+	setErr := func(e error) {
+		errOnce.Do(func() {
+			uperr = e
+			cancel()
+		})
+	}
+
+	for i := 0; i < su.config.Parallel; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for block := range blocks {
+				if ctx.Err() == nil {
+					err := su.uploadBlock(ctx, block)
+					if err != nil {
+						setErr(err)
+					} else {
+						mu.Lock()
+						checksumMap[block.id] = block.checksum
+						mu.Unlock()
+					}
+				}
+				spool <- block.buf
+			}
+		}()
+	}
+
+	readErr := su.readBlocks(ctx, spool, blocks)
+	if readErr != nil {
+		// 不再上传剩余的分片
+		cancel()
+	}
+	close(blocks)
+	wg.Wait()
+	if readErr != nil {
+		return readErr
+	}
+	if uperr != nil {
+		return uperr
+	}
+
+	return su.multiUpload.CreateSuperFile(originPCSHost, su.config.Policy, su.uploadid, su.Size(), checksumMap)
+}
+
+// readBlocks 按分片读取数据流, 计算分片md5后交给上传的协程
+func (su *StreamUploader) readBlocks(ctx context.Context, spool chan []byte, blocks chan<- *streamBlock) error {
+	for id := 0; ; id++ {
+		var buf []byte
+		select {
+		case <-ctx.Done():
+			return nil
+		case buf = <-spool:
+		}
+		if buf == nil {
+			buf = make([]byte, su.config.BlockSize)
+		}
+
+		n, err := io.ReadFull(su.reader, buf)
+		switch err {
+		case nil, io.ErrUnexpectedEOF:
+		case io.EOF:
+			// 空的数据流也需要上传一个分片
+			if id > 0 {
+				return nil
+			}
+		default:
+			return fmt.Errorf("读取数据流错误: %s", err)
+		}
+		if su.config.MaxBlocks > 0 && id >= su.config.MaxBlocks && n > 0 {
+			return fmt.Errorf("%w: %d x %s", ErrStreamTooManyBlocks, su.config.MaxBlocks, converter.ConvertFileSize(su.config.BlockSize))
+		}
+
+		blockMD5 := md5.Sum(buf[:n])
+		su.contentMD5.Write(buf[:n])
+		block := &streamBlock{
+			id:       id,
+			offset:   atomic.AddInt64(&su.size, int64(n)) - int64(n),
+			buf:      buf[:n],
+			checksum: hex.EncodeToString(blockMD5[:]),
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case blocks <- block:
+		}
+		if err != nil {
+			// 数据流结束
+			return nil
+		}
+	}
+}
+
+// uploadBlock 上传单个分片, 失败则重试, 分片数据在内存中, 可以重复读取
+func (su *StreamUploader) uploadBlock(ctx context.Context, block *streamBlock) error {
+	var (
+		unit = NewBufioSplitUnit(rio.NewBuffer(block.buf), transfer.Range{Begin: 0, End: int64(len(block.buf))}, su.speedsStat, su.rateLimit)
+		terr error
+	)
+	su.activeMu.Lock()
+	su.active[block.id] = unit
+	su.activeMu.Unlock()
+	defer func() {
+		su.activeMu.Lock()
+		delete(su.active, block.id)
+		su.activeMu.Unlock()
+	}()
+
+	for retry := 0; retry <= su.config.MaxRetry; retry++ {
+		if retry > 0 {
+			uploaderVerbose.Warnf("upload err: %s, id: %d, retry %d/%d\n", terr, block.id, retry, su.config.MaxRetry)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(time.Duration(retry) * time.Second):
+			}
+			unit.Seek(0, os.SEEK_SET)
+		}
+
+		var checksum string
+		checksum, terr = su.multiUpload.TmpFile(ctx, su.uploadid, su.targetPath, block.id, block.offset, unit)
+		if terr != nil {
+			var me *MultiError
+			if errors.As(terr, &me) && me.Terminated {
+				return me.Err
+			}
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			continue
+		}
+		if checksum != block.checksum {
+			terr = ErrStreamBlockChecksumMismatch
+			continue
+		}
+		atomic.AddInt64(&su.uploaded, int64(len(block.buf)))
+		return nil
+	}
+	return terr
+}
+
+// uploadedSize 返回已上传的数据量, 包括正在上传的分片
+func (su *StreamUploader) uploadedSize() int64 {
+	su.activeMu.Lock()
+	defer su.activeMu.Unlock()
+	uploaded := atomic.LoadInt64(&su.uploaded)
+	for _, unit := range su.active {
+		uploaded += unit.Readed()
+	}
+	return uploaded
+}
+
+func (su *StreamUploader) uploadStatusEvent() {
+	if su.onUploadStatusEvent == nil {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(3 * time.Second) // 每3秒统计
+		defer ticker.Stop()
+		for {
+			select {
+			case <-su.finished:
+				return
+			case <-ticker.C:
+				su.onUploadStatusEvent(&UploadStatus{
+					totalSize:       su.Size(),
+					uploaded:        su.uploadedSize(),
+					speedsPerSecond: su.speedsStat.GetSpeeds(),
+					timeElapsed:     time.Since(su.executeTime) / 1e8 * 1e8,
+				}, nil)
+			}
+		}
+	}()
+}
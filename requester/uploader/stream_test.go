@@ -0,0 +1,117 @@
+package uploader_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"github.com/qjfoidnh/BaiduPCS-Go/baidupcs/pcserror"
+	"github.com/qjfoidnh/BaiduPCS-Go/requester/rio"
+	"github.com/qjfoidnh/BaiduPCS-Go/requester/uploader"
+	"io"
+	"math/rand"
+	"sort"
+	"sync"
+	"testing"
+)
+
+type memUpload struct {
+	mu       sync.Mutex
+	parts    map[int][]byte
+	failures int // 前几次上传失败
+	size     int64
+	blocks   []string
+}
+
+func (mu *memUpload) Precreate() (string, pcserror.Error) {
+	return "", nil
+}
+
+func (mu *memUpload) TmpFile(ctx context.Context, uploadid, targetPath string, partseq int, partOffset int64, r rio.ReaderLen64) (string, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	mu.mu.Lock()
+	defer mu.mu.Unlock()
+	if mu.failures > 0 {
+		mu.failures--
+		return "", errors.New("mock upload error")
+	}
+	mu.parts[partseq] = data
+	sum := md5.Sum(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func (mu *memUpload) CreateSuperFile(pcsHost, policy, uploadId string, fileSize int64, checksumMap map[int]string) error {
+	mu.size = fileSize
+	ids := make([]int, 0, len(checksumMap))
+	for id := range checksumMap {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	for _, id := range ids {
+		mu.blocks = append(mu.blocks, checksumMap[id])
+	}
+	return nil
+}
+
+func TestStreamUploader(t *testing.T) {
+	for _, size := range []int{0, 1000, 4096, 10000} {
+		data := make([]byte, size)
+		rand.Read(data)
+
+		mu := &memUpload{parts: map[int][]byte{}, failures: 1}
+		su := uploader.NewStreamUploader(mu, bytes.NewReader(data), "uploadid", &uploader.StreamUploaderConfig{
+			Parallel:  3,
+			BlockSize: 1024,
+		}, "/test")
+		err := su.Execute()
+		if err != nil {
+			t.Fatalf("size %d: %s", size, err)
+		}
+
+		var merged []byte
+		for i := 0; i < len(mu.parts); i++ {
+			merged = append(merged, mu.parts[i]...)
+		}
+		sum := md5.Sum(data)
+		switch {
+		case !bytes.Equal(merged, data):
+			t.Errorf("size %d: merged data mismatch", size)
+		case mu.size != int64(size) || su.Size() != int64(size):
+			t.Errorf("size %d: got size %d, %d", size, mu.size, su.Size())
+		case su.MD5() != hex.EncodeToString(sum[:]):
+			t.Errorf("size %d: md5 mismatch", size)
+		case len(mu.blocks) != len(mu.parts) || len(mu.blocks) != (size+1023)/1024 && size > 0:
+			t.Errorf("size %d: got %d blocks", size, len(mu.blocks))
+		}
+	}
+}
+
+func TestStreamUploaderMaxBlocks(t *testing.T) {
+	for _, c := range []struct {
+		size int
+		fail bool
+	}{
+		{3072, false}, // 正好3个分片
+		{3073, true},
+	} {
+		mu := &memUpload{parts: map[int][]byte{}}
+		su := uploader.NewStreamUploader(mu, bytes.NewReader(make([]byte, c.size)), "uploadid", &uploader.StreamUploaderConfig{
+			Parallel:  2,
+			BlockSize: 1024,
+			MaxBlocks: 3,
+		}, "/test")
+		err := su.Execute()
+		switch {
+		case !c.fail && err != nil:
+			t.Errorf("size %d: %s", c.size, err)
+		case c.fail && !errors.Is(err, uploader.ErrStreamTooManyBlocks):
+			t.Errorf("size %d: expected ErrStreamTooManyBlocks, got %v", c.size, err)
+		case c.fail && mu.blocks != nil:
+			t.Errorf("size %d: should not create superfile", c.size)
+		}
+	}
+}